@@ -1,11 +1,14 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -56,6 +59,68 @@ type Config struct {
 	// Default quality preference (e.g., "1080p", "720p", "best")
 	Quality string `yaml:"quality,omitempty"`
 
+	// Quality fallback ladder, highest first (e.g., ["1080p", "720p", "480p"]).
+	// When the requested quality is missing, lower rungs are tried in order
+	// before falling back to the best available format.
+	QualityLadder []string `yaml:"quality_ladder,omitempty"`
+
+	// Default headers for media requests, keyed by extractor name (e.g.,
+	// "browser" for the generic fallback). Headers set by the extractor for
+	// a format take precedence; these take precedence over server.default_referer.
+	// Example YAML:
+	//   extractor_headers:
+	//     browser:
+	//       Referer: "https://example.com/"
+	ExtractorHeaders map[string]map[string]string `yaml:"extractor_headers,omitempty"`
+
+	// Minimum acceptable video height in pixels (0 = no floor). Formats known
+	// to be shorter are never picked; when nothing meets the floor, the
+	// download fails instead of saving a low-quality copy.
+	MinHeight int `yaml:"min_height,omitempty"`
+
+	// Save downloads under a YYYY/MM/DD directory of the output dir, named
+	// after the date the job started
+	DatePartition bool `yaml:"date_partition,omitempty"`
+
+	// Embed chapter markers into downloaded videos with ffmpeg. Without it,
+	// or when ffmpeg is unavailable, chapters go to a .chapters sidecar file.
+	EmbedChapters bool `yaml:"embed_chapters,omitempty"`
+
+	// Save the media's thumbnail, when the source exposes one, next to the
+	// downloaded file (same base name, image extension)
+	DownloadThumbnail bool `yaml:"download_thumbnail,omitempty"`
+
+	// Largest thumbnail height to save when the source offers several sizes;
+	// the largest size no taller than this is picked (0 = the largest)
+	ThumbnailMaxHeight int `yaml:"thumbnail_max_height,omitempty"`
+
+	// Container for HLS (m3u8) downloads: "mp4" remuxes the stream with
+	// ffmpeg after download (default), "ts" keeps the raw MPEG-TS file
+	HLSFormat string `yaml:"hls_format,omitempty"`
+
+	// Container ("mp4", "mkv" or "webm") downloaded videos are converted to
+	// with ffmpeg, copying the streams when the container accepts them and
+	// transcoding otherwise. Empty keeps the source container.
+	ConvertTo string `yaml:"convert_to,omitempty"`
+
+	// Normalize the loudness of downloaded audio with ffmpeg's loudnorm
+	// filter, to LoudnessTarget
+	NormalizeAudio bool `yaml:"normalize_audio,omitempty"`
+
+	// Integrated loudness normalize_audio aims for, in LUFS (0 = -16, the
+	// usual podcast target)
+	LoudnessTarget float64 `yaml:"loudness_target,omitempty"`
+
+	// Move the moov atom of downloaded MP4s to the front with ffmpeg
+	// (-movflags +faststart), so browsers can play them while loading
+	Faststart bool `yaml:"faststart,omitempty"`
+
+	// Rules for sanitizing output filenames (defaults match the built-in behavior)
+	FilenameRules FilenameRules `yaml:"filename_rules,omitempty"`
+
+	// Backoff shared by retry loops (defaults match the built-in behavior)
+	Retry RetryConfig `yaml:"retry,omitempty"`
+
 	// WebDAV servers configuration
 	WebDAVServers map[string]WebDAVServer `yaml:"webdavServers,omitempty"`
 
@@ -65,6 +130,15 @@ type Config struct {
 	// Server configuration for `vget serve`
 	Server ServerConfig `yaml:"server,omitempty"`
 
+	// Storage backend for files downloaded by `vget serve`
+	Storage StorageConfig `yaml:"storage,omitempty"`
+
+	// Remote target that `vget serve` uploads finished downloads to
+	Destination DestinationConfig `yaml:"destination,omitempty"`
+
+	// Notifications sent when jobs finish
+	Notifiers NotifiersConfig `yaml:"notifiers,omitempty"`
+
 	// Express tracking providers configuration
 	// Each provider has its own config structure stored as map[string]string
 	// Example YAML:
@@ -213,6 +287,25 @@ func (c *Config) SetExpressConfig(provider, key, value string) {
 	c.Express[provider][key] = value
 }
 
+// SetExtractorHeader sets a default header for an extractor's media
+// requests, removing it when value is empty
+func (c *Config) SetExtractorHeader(extractor, header, value string) {
+	if value == "" {
+		delete(c.ExtractorHeaders[extractor], header)
+		if len(c.ExtractorHeaders[extractor]) == 0 {
+			delete(c.ExtractorHeaders, extractor)
+		}
+		return
+	}
+	if c.ExtractorHeaders == nil {
+		c.ExtractorHeaders = make(map[string]map[string]string)
+	}
+	if c.ExtractorHeaders[extractor] == nil {
+		c.ExtractorHeaders[extractor] = make(map[string]string)
+	}
+	c.ExtractorHeaders[extractor][header] = value
+}
+
 // DeleteExpressConfig removes a config value for an express provider
 func (c *Config) DeleteExpressConfig(provider, key string) {
 	if c.Express == nil || c.Express[provider] == nil {
@@ -225,6 +318,120 @@ func (c *Config) DeleteExpressConfig(provider, key string) {
 	}
 }
 
+// FilenameRules customizes how titles are turned into output filenames
+type FilenameRules struct {
+	// Replacement substitutes path separators, colons, etc. (default "-")
+	Replacement string `yaml:"replacement,omitempty"`
+
+	// KeepWhitespace disables collapsing runs of whitespace into one space
+	KeepWhitespace bool `yaml:"keep_whitespace,omitempty"`
+
+	// MaxLength is the maximum filename length in characters, excluding the extension (default 60)
+	MaxLength int `yaml:"max_length,omitempty"`
+
+	// Lowercase converts filenames to lower case
+	Lowercase bool `yaml:"lowercase,omitempty"`
+}
+
+// DefaultLoudnessTarget is the normalize_audio target when loudness_target is unset
+const DefaultLoudnessTarget = -16.0
+
+// LoudnessTargetLUFS returns the configured loudness target, or the default
+func (c *Config) LoudnessTargetLUFS() float64 {
+	if c.LoudnessTarget == 0 {
+		return DefaultLoudnessTarget
+	}
+	return c.LoudnessTarget
+}
+
+// DefaultQualityLadder is used when quality_ladder is not configured
+var DefaultQualityLadder = []string{"2160p", "1440p", "1080p", "720p", "480p", "360p", "240p"}
+
+// QualityCandidates returns the qualities to try, in order, for a requested
+// quality: the request itself, then every lower rung of the ladder, skipping
+// anything below min_height.
+// It returns nil for "best" (or empty), meaning "pick the best format".
+func (c *Config) QualityCandidates(requested string) []string {
+	requested = NormalizeQuality(requested)
+	if requested == "" || requested == "best" {
+		return nil
+	}
+
+	ladder := c.QualityLadder
+	if len(ladder) == 0 {
+		ladder = DefaultQualityLadder
+	}
+
+	var candidates []string
+	if c.meetsMinHeight(requested) {
+		candidates = append(candidates, requested)
+	}
+	found := false
+	for _, rung := range ladder {
+		rung = NormalizeQuality(rung)
+		if rung == requested {
+			found = true
+			continue
+		}
+		if found && rung != "" && rung != "best" && c.meetsMinHeight(rung) {
+			candidates = append(candidates, rung)
+		}
+	}
+	return candidates
+}
+
+// meetsMinHeight reports whether a normalized quality label is at or above
+// min_height. Labels without a height (e.g., "hd") always pass.
+func (c *Config) meetsMinHeight(quality string) bool {
+	height := QualityHeight(quality)
+	return height == 0 || height >= c.MinHeight
+}
+
+// QualityHeight returns the height of a quality label such as "720p" or
+// "1080", or 0 when the label doesn't name a height
+func QualityHeight(quality string) int {
+	height, err := strconv.Atoi(strings.TrimSuffix(NormalizeQuality(quality), "p"))
+	if err != nil || height < 0 {
+		return 0
+	}
+	return height
+}
+
+// NormalizeQuality lowercases a quality label and adds the "p" suffix to bare
+// heights, so "1080", "1080P" and "1080p" compare equal
+func NormalizeQuality(quality string) string {
+	quality = strings.ToLower(strings.TrimSpace(quality))
+	if quality == "" {
+		return ""
+	}
+	if _, err := strconv.Atoi(quality); err == nil {
+		return quality + "p"
+	}
+	return quality
+}
+
+// RetryConfig tunes the exponential backoff between retries. Durations are
+// Go durations (e.g., "500ms"); unset fields use the built-in defaults.
+type RetryConfig struct {
+	// BaseDelay is the wait before the first retry (default "500ms")
+	BaseDelay string `yaml:"base_delay,omitempty"`
+
+	// MaxDelay caps any single wait (default "8s")
+	MaxDelay string `yaml:"max_delay,omitempty"`
+
+	// Multiplier grows the wait after every retry (default 2)
+	Multiplier float64 `yaml:"multiplier,omitempty"`
+
+	// Jitter randomly spreads each wait by this fraction, 0 to 1 (default 0.2)
+	Jitter *float64 `yaml:"jitter,omitempty"`
+
+	// MaxAttempts is the number of attempts, including the first (default 10)
+	MaxAttempts int `yaml:"max_attempts,omitempty"`
+
+	// MaxElapsed gives up once this much time has passed (default no limit)
+	MaxElapsed string `yaml:"max_elapsed,omitempty"`
+}
+
 // TwitterConfig holds Twitter/X authentication settings
 type TwitterConfig struct {
 	// AuthToken is the auth_token cookie value from browser (for NSFW content)
@@ -239,8 +446,643 @@ type ServerConfig struct {
 	// MaxConcurrent is the max number of concurrent downloads (default: 10)
 	MaxConcurrent int `yaml:"max_concurrent,omitempty"`
 
+	// MaxConcurrentFFmpeg is the maximum number of concurrent ffmpeg merges
+	// and remuxes, independent of MaxConcurrent (default: 1)
+	MaxConcurrentFFmpeg int `yaml:"max_concurrent_ffmpeg,omitempty"`
+
+	// MaxConnections caps simultaneously accepted HTTP connections; further
+	// clients wait until one closes (0 = unlimited). Applied at startup.
+	MaxConnections int `yaml:"max_connections,omitempty"`
+
+	// Scheduler picks which queued job starts next: "fifo" (default) in
+	// submission order, or "fair" to take turns between bulk batches and
+	// individual downloads so one large batch can't occupy every worker
+	Scheduler string `yaml:"scheduler,omitempty"`
+
 	// APIKey for authentication (optional, used to sign JWTs for API access)
 	APIKey string `yaml:"api_key,omitempty"`
+
+	// JWTIssuer is the "iss" claim set in and required of JWTs (default: "vget")
+	JWTIssuer string `yaml:"jwt_issuer,omitempty"`
+
+	// JWTAudience is the "aud" claim set in and required of JWTs (empty disables the check)
+	JWTAudience string `yaml:"jwt_audience,omitempty"`
+
+	// BasePath prefixes all routes when served behind a reverse proxy subpath (e.g., "/vget")
+	BasePath string `yaml:"base_path,omitempty"`
+
+	// DisableJobsETag turns off ETag/If-None-Match handling on GET /api/jobs
+	DisableJobsETag bool `yaml:"disable_jobs_etag,omitempty"`
+
+	// HideHealthLoad omits queue load counters from the unauthenticated
+	// /api/health response (they remain available on /api/stats)
+	HideHealthLoad bool `yaml:"hide_health_load,omitempty"`
+
+	// CleanupPartialOnFailure deletes the partially written output of jobs
+	// that fail (unset means true)
+	CleanupPartialOnFailure *bool `yaml:"cleanup_partial_on_failure,omitempty"`
+
+	// SkipIfCompleted returns the earlier job when a URL that already
+	// completed is submitted again, instead of downloading it again
+	SkipIfCompleted bool `yaml:"skip_if_completed,omitempty"`
+
+	// SkipMatch selects how SkipIfCompleted compares URLs: "exact" (default)
+	// or "loose" to ignore scheme, "www.", fragments, trailing slashes,
+	// query order and tracking parameters
+	SkipMatch string `yaml:"skip_match,omitempty"`
+
+	// SkipCheckFile makes SkipIfCompleted download again when the earlier
+	// job's files no longer exist (unset means true)
+	SkipCheckFile *bool `yaml:"skip_check_file,omitempty"`
+
+	// EnableBenchmark turns on POST /api/benchmark, which downloads a URL
+	// with several concurrency and buffer settings to measure throughput.
+	// Off by default since every run uses real bandwidth.
+	EnableBenchmark bool `yaml:"enable_benchmark,omitempty"`
+
+	// MaxItems caps how many entries are downloaded from a playlist or a
+	// paginated listing such as a channel (0 = no limit)
+	MaxItems int `yaml:"max_items,omitempty"`
+
+	// ResolveShorteners follows links from known URL shorteners (t.co,
+	// bit.ly, ...) to their destination before choosing an extractor
+	ResolveShorteners bool `yaml:"resolve_shorteners,omitempty"`
+
+	// DefaultReferer sends a Referer of the source page's origin on media
+	// requests when the extractor didn't provide one
+	DefaultReferer bool `yaml:"default_referer,omitempty"`
+
+	// AllowedDomains restricts downloads to matching hosts (empty allows all).
+	// Entries are exact hosts ("example.com") or wildcards ("*.example.com"),
+	// where a wildcard matches the domain itself and any of its subdomains.
+	AllowedDomains []string `yaml:"allowed_domains,omitempty"`
+
+	// BlockedDomains rejects downloads from matching hosts (same syntax as AllowedDomains).
+	// Blocked entries take precedence over allowed ones.
+	BlockedDomains []string `yaml:"blocked_domains,omitempty"`
+
+	// SignedLinkReferrers restricts which sites may use signed file links,
+	// by the host of the request's Origin or Referer (same syntax as
+	// AllowedDomains, plus "none" for requests sending neither). Empty
+	// allows any.
+	SignedLinkReferrers []string `yaml:"signed_link_referrers,omitempty"`
+
+	// JobTimeout is the wall-clock limit for a single download job as a Go
+	// duration (e.g., "30m", "2h"); jobs exceeding it are cancelled and marked
+	// failed. Empty or "0" means no limit.
+	JobTimeout string `yaml:"job_timeout,omitempty"`
+
+	// ExtractCacheTTL is how long extracted media info for a URL is reused
+	// by later downloads as a Go duration (e.g., "5m"). Empty or "0" only
+	// shares extractions that run at the same time.
+	ExtractCacheTTL string `yaml:"extract_cache_ttl,omitempty"`
+
+	// ExtractFreshness is the oldest cached extraction a job may start its
+	// download from, as a Go duration (e.g., "2m"), for sources whose signed
+	// URLs expire sooner than extract_cache_ttl. Older entries are extracted
+	// again when the job is dispatched. Empty or "0" uses any cached entry.
+	ExtractFreshness string `yaml:"extract_freshness,omitempty"`
+
+	// PrefetchExtraction extracts the next queued job's media while all
+	// workers are busy, so it can start downloading as soon as one frees up
+	PrefetchExtraction bool `yaml:"prefetch_extraction,omitempty"`
+
+	// StreamTimeout caps how long a synchronous stream (return_file) may
+	// run as a Go duration (e.g., "30m"); a stream still going when it
+	// expires is cut off. Empty or "0" means no limit.
+	StreamTimeout string `yaml:"stream_timeout,omitempty"`
+
+	// MaxLiveDuration caps how long a playlist entry without a known
+	// duration, typically an internet radio stream that never ends, is
+	// recorded, as a Go duration (default DefaultMaxLiveDuration). What was
+	// recorded by then is kept.
+	MaxLiveDuration string `yaml:"max_live_duration,omitempty"`
+
+	// WriteTimeout is the HTTP server write timeout as a Go duration (default
+	// none). Synchronous file streams extend their deadline after every chunk,
+	// so only stalled writes are cut off.
+	WriteTimeout string `yaml:"write_timeout,omitempty"`
+
+	// RateLimit caps total download bandwidth per second across all jobs
+	// (e.g., "10MB", "512K"); it is shared between running jobs by weight.
+	// Empty or "0" means unlimited.
+	RateLimit string `yaml:"rate_limit,omitempty"`
+
+	// MaxRate caps each job's download bandwidth per second (e.g., "2MB"),
+	// on top of its share of RateLimit; requests can lower it for their job
+	// with max_rate_bps. Empty or "0" means unlimited.
+	MaxRate string `yaml:"max_rate,omitempty"`
+
+	// RateSchedule overrides RateLimit during daily time windows, e.g.
+	// ["mon-fri 09:00-18:00 1MB", "23:00-07:00 unlimited"] (see
+	// ParseRateWindow). The first window containing the current time wins;
+	// outside all of them RateLimit applies.
+	RateSchedule []string `yaml:"rate_schedule,omitempty"`
+
+	// RateScheduleTimezone is the IANA time zone RateSchedule is read in
+	// (e.g., "Asia/Shanghai"); empty uses the server's local time zone
+	RateScheduleTimezone string `yaml:"rate_schedule_timezone,omitempty"`
+
+	// ProgressLog is a file the server appends a JSON line to on every job
+	// state transition (empty disables it)
+	ProgressLog string `yaml:"progress_log,omitempty"`
+
+	// ProgressLogMaxSize is the size at which the progress log is rotated to
+	// <file>.1 (e.g., "10MB"; empty uses the default of 10MB)
+	ProgressLogMaxSize string `yaml:"progress_log_max_size,omitempty"`
+
+	// RootPage selects what GET / serves: "json" (default), "page" for a
+	// minimal HTML status page, "redirect" to the health endpoint, or "off"
+	RootPage string `yaml:"root_page,omitempty"`
+
+	// BatchWebhook receives a POST with a summary when every job of a bulk
+	// download batch has finished (empty disables it unless the request sets one)
+	BatchWebhook string `yaml:"batch_webhook,omitempty"`
+
+	// InsecureSkipVerify disables TLS certificate verification for media
+	// requests (direct files, playlists, HLS). Only for trusted internal
+	// sources with self-signed certificates; requests can override it.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify,omitempty"`
+
+	// MinTLSVersion is the lowest TLS version media transfers negotiate:
+	// "1.2" (default) or "1.3"
+	MinTLSVersion string `yaml:"min_tls_version,omitempty"`
+
+	// LogRedactParams lists extra query parameters whose values are masked
+	// in request logs (token, key, signature, path and similar are always masked)
+	LogRedactParams []string `yaml:"log_redact_params,omitempty"`
+
+	// MinVideoSize fails video downloads smaller than this (e.g., "100KB")
+	// as "login required", since they are usually a login page's assets
+	// (empty = no size check)
+	MinVideoSize string `yaml:"min_video_size,omitempty"`
+
+	// MinOutputSize fails video and audio downloads that saved less than
+	// this (e.g., "10KB"), deleting the file, since such outputs are
+	// usually an error page (empty = no size check)
+	MinOutputSize string `yaml:"min_output_size,omitempty"`
+
+	// MaxInlineSize is the largest file an "inline" download returns
+	// base64-encoded in its JSON response (e.g., "512KB"; empty uses the
+	// default of 1MB); larger files are streamed instead
+	MaxInlineSize string `yaml:"max_inline_size,omitempty"`
+
+	// LoginMarkers are extra URL path fragments (besides login, signin,
+	// paywall, ...) that mark a redirect target as a login page
+	LoginMarkers []string `yaml:"login_markers,omitempty"`
+
+	// DisableMediaTypeCheck accepts media responses whatever their
+	// Content-Type, for sources that serve files as text/html. Redirects to
+	// login pages and min_video_size are still checked.
+	DisableMediaTypeCheck bool `yaml:"disable_media_type_check,omitempty"`
+
+	// ForceHTTP1 keeps media transfers (direct downloads, streamed files and
+	// HLS segments) on HTTP/1.1 instead of negotiating HTTP/2, for CDNs that
+	// throttle HTTP/2 clients. By default Go's usual negotiation applies.
+	ForceHTTP1 bool `yaml:"force_http1,omitempty"`
+
+	// RawContentEncoding saves media bodies as sent even when the server
+	// compressed them (Content-Encoding: gzip or deflate). By default they
+	// are decoded before they're written.
+	RawContentEncoding bool `yaml:"raw_content_encoding,omitempty"`
+
+	// MaxRedirects is the redirect at which a media transfer fails with
+	// "too many redirects", as in Go's default client: max-1 redirects are
+	// followed (0 = DefaultMaxRedirects)
+	MaxRedirects int `yaml:"max_redirects,omitempty"`
+
+	// LogRedirects logs every redirect hop of media transfers
+	LogRedirects bool `yaml:"log_redirects,omitempty"`
+
+	// FormatFallbacks is how many other formats of a video, next-best
+	// first, are tried when the selected one fails to download (0 = none).
+	// Only transfer and media check failures fall back, not login walls or
+	// the domain policy. Downloads with a quality in the request never do.
+	FormatFallbacks int `yaml:"format_fallbacks,omitempty"`
+
+	// MaxPathLength caps the full path of local output files; longer titles
+	// are shortened to fit (0 = the platform limit, 259 characters on
+	// Windows without long path support)
+	MaxPathLength int `yaml:"max_path_length,omitempty"`
+
+	// GallerySplit splits image galleries with more images than this into
+	// jobs of at most this many images each (1 = one job per image), queued
+	// under the gallery job's group. 0 keeps each gallery in one job.
+	GallerySplit int `yaml:"gallery_split,omitempty"`
+
+	// JobsFile is a JSON file the job queue is saved to, so queued jobs and
+	// job history survive a restart; relative paths are under output_dir
+	// (e.g., "jobs.json"). Empty keeps jobs in memory only.
+	JobsFile string `yaml:"jobs_file,omitempty"`
+}
+
+// RateLimitBytes returns the parsed rate limit in bytes per second (0 if unset or invalid)
+func (c *ServerConfig) RateLimitBytes() int64 {
+	n, err := ParseByteSize(c.RateLimit)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// MaxRateBytes returns the parsed per-job rate cap in bytes per second (0 if unset or invalid)
+func (c *ServerConfig) MaxRateBytes() int64 {
+	n, err := ParseByteSize(c.MaxRate)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ProgressLogMaxBytes returns the parsed progress log size cap (0 if unset or invalid)
+func (c *ServerConfig) ProgressLogMaxBytes() int64 {
+	n, err := ParseByteSize(c.ProgressLogMaxSize)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// MinOutputSizeBytes returns the parsed minimum output size (0 if unset or invalid)
+func (c *ServerConfig) MinOutputSizeBytes() int64 {
+	n, err := ParseByteSize(c.MinOutputSize)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// MaxInlineSizeBytes returns the parsed inline download size cap (0 if unset or invalid)
+func (c *ServerConfig) MaxInlineSizeBytes() int64 {
+	n, err := ParseByteSize(c.MaxInlineSize)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// MinVideoSizeBytes returns the parsed minimum video size (0 if unset or invalid)
+func (c *ServerConfig) MinVideoSizeBytes() int64 {
+	n, err := ParseByteSize(c.MinVideoSize)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// ParseByteSize parses sizes like "512", "64K", "10MB" or "1.5G" (1024-based).
+// A trailing "/s" is accepted so rates can be written as "10MB/s".
+func ParseByteSize(value string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	s = strings.TrimSuffix(s, "/S")
+	if s == "" {
+		return 0, nil
+	}
+
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{
+		{"GB", 1 << 30}, {"G", 1 << 30},
+		{"MB", 1 << 20}, {"M", 1 << 20},
+		{"KB", 1 << 10}, {"K", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatByteSize formats a byte count for people, e.g. "512 B" or "1.5 MB"
+// (1024-based, like ParseByteSize)
+func FormatByteSize(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// DefaultMaxConcurrentFFmpeg is the ffmpeg concurrency when unset
+const DefaultMaxConcurrentFFmpeg = 1
+
+// FFmpegConcurrency returns the configured ffmpeg concurrency, or the default
+func (c *ServerConfig) FFmpegConcurrency() int {
+	if c.MaxConcurrentFFmpeg <= 0 {
+		return DefaultMaxConcurrentFFmpeg
+	}
+	return c.MaxConcurrentFFmpeg
+}
+
+// JobTimeoutDuration returns the parsed job timeout (0 if unset or invalid)
+func (c *ServerConfig) JobTimeoutDuration() time.Duration {
+	if c.JobTimeout == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.JobTimeout)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// DefaultMaxRedirects is the redirect limit when max_redirects is unset, as
+// in Go's default client
+const DefaultMaxRedirects = 10
+
+// RedirectLimit returns the configured max_redirects or DefaultMaxRedirects
+func (c *ServerConfig) RedirectLimit() int {
+	if c.MaxRedirects <= 0 {
+		return DefaultMaxRedirects
+	}
+	return c.MaxRedirects
+}
+
+// StreamTimeoutDuration returns the parsed stream timeout (0 if unset or invalid)
+func (c *ServerConfig) StreamTimeoutDuration() time.Duration {
+	if c.StreamTimeout == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.StreamTimeout)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// DefaultMaxLiveDuration is the recording limit for live playlist entries
+// when max_live_duration is unset
+const DefaultMaxLiveDuration = time.Hour
+
+// MaxLiveDurationLimit returns the parsed max_live_duration, or
+// DefaultMaxLiveDuration when unset or invalid
+func (c *ServerConfig) MaxLiveDurationLimit() time.Duration {
+	d, err := time.ParseDuration(c.MaxLiveDuration)
+	if err != nil || d <= 0 {
+		return DefaultMaxLiveDuration
+	}
+	return d
+}
+
+// ExtractFreshnessDuration returns the parsed extraction freshness window (0 if unset or invalid)
+func (c *ServerConfig) ExtractFreshnessDuration() time.Duration {
+	if c.ExtractFreshness == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.ExtractFreshness)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// ExtractCacheTTLDuration returns the parsed extraction cache TTL (0 if unset or invalid)
+func (c *ServerConfig) ExtractCacheTTLDuration() time.Duration {
+	if c.ExtractCacheTTL == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(c.ExtractCacheTTL)
+	if err != nil || d < 0 {
+		return 0
+	}
+	return d
+}
+
+// TLSVersions maps the accepted min_tls_version values to their versions
+var TLSVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSMinVersion returns the minimum TLS version for media transfers (TLS
+// 1.2 if unset or invalid)
+func (c *ServerConfig) TLSMinVersion() uint16 {
+	if v, ok := TLSVersions[c.MinTLSVersion]; ok {
+		return v
+	}
+	return tls.VersionTLS12
+}
+
+// CleanupPartialEnabled reports whether failed jobs' partial files are removed
+func (c *ServerConfig) CleanupPartialEnabled() bool {
+	return c.CleanupPartialOnFailure == nil || *c.CleanupPartialOnFailure
+}
+
+// SkipCheckFileEnabled reports whether skip_if_completed requires the
+// earlier job's files to still exist
+func (c *ServerConfig) SkipCheckFileEnabled() bool {
+	return c.SkipCheckFile == nil || *c.SkipCheckFile
+}
+
+// IsDomainAllowed reports whether downloads from host are permitted
+// by the allowed/blocked domain lists
+func (c *ServerConfig) IsDomainAllowed(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range c.BlockedDomains {
+		if matchDomain(host, pattern) {
+			return false
+		}
+	}
+	if len(c.AllowedDomains) == 0 {
+		return true
+	}
+	for _, pattern := range c.AllowedDomains {
+		if matchDomain(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsReferrerAllowed reports whether a signed file link may be used from a
+// page on host ("" when the request names no page)
+func (c *ServerConfig) IsReferrerAllowed(host string) bool {
+	if len(c.SignedLinkReferrers) == 0 {
+		return true
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range c.SignedLinkReferrers {
+		if host == "" && strings.EqualFold(strings.TrimSpace(pattern), "none") {
+			return true
+		}
+		if host != "" && matchDomain(host, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchDomain checks host against a domain pattern.
+// "*.example.com" matches "example.com" and any subdomain of it.
+func matchDomain(host, pattern string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	if pattern == "" {
+		return false
+	}
+	if base, ok := strings.CutPrefix(pattern, "*."); ok {
+		return host == base || strings.HasSuffix(host, "."+base)
+	}
+	return host == pattern
+}
+
+// Values for StorageConfig.Type
+const (
+	StorageLocal = "local" // Files under output_dir on the local disk (default)
+	StorageS3    = "s3"    // Objects in an S3-compatible bucket
+)
+
+// StorageConfig selects where the server writes downloaded files
+type StorageConfig struct {
+	// Type is "local" (default) or "s3"
+	Type string `yaml:"type,omitempty"`
+
+	// Endpoint is the S3 API URL (default: https://s3.<region>.amazonaws.com).
+	// Set it for S3-compatible services such as MinIO or R2.
+	Endpoint string `yaml:"endpoint,omitempty"`
+
+	// Region is the S3 signing region (default: us-east-1)
+	Region string `yaml:"region,omitempty"`
+
+	// Bucket receives the downloaded objects
+	Bucket string `yaml:"bucket,omitempty"`
+
+	// Prefix is prepended to every object key, e.g. "vget/"
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// AccessKey and SecretKey are the S3 credentials; when empty they are
+	// read from AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	AccessKey string `yaml:"access_key,omitempty"`
+	SecretKey string `yaml:"secret_key,omitempty"`
+
+	// PathStyle addresses the bucket as <endpoint>/<bucket> instead of
+	// <bucket>.<endpoint host>; most self-hosted services need this
+	PathStyle bool `yaml:"path_style,omitempty"`
+}
+
+// DestinationWebDAV is the DestinationConfig.Type of WebDAV targets; S3
+// targets use StorageS3
+const DestinationWebDAV = "webdav"
+
+// DestinationConfig selects a remote target that finished downloads are
+// uploaded to after the job's transfer completes
+type DestinationConfig struct {
+	// Type is "s3" or "webdav"; empty disables uploads
+	Type string `yaml:"type,omitempty"`
+
+	// URL is the WebDAV collection files are uploaded into, and Username
+	// and Password its credentials
+	URL      string `yaml:"url,omitempty"`
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+
+	// S3 settings, as for StorageConfig
+	Endpoint  string `yaml:"endpoint,omitempty"`
+	Region    string `yaml:"region,omitempty"`
+	Bucket    string `yaml:"bucket,omitempty"`
+	AccessKey string `yaml:"access_key,omitempty"`
+	SecretKey string `yaml:"secret_key,omitempty"`
+	PathStyle bool   `yaml:"path_style,omitempty"`
+
+	// Prefix is prepended to every uploaded name, e.g. "vget/"
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// DeleteLocal removes the downloaded files once they are uploaded
+	DeleteLocal bool `yaml:"delete_local,omitempty"`
+
+	// FailOnError fails the job when the upload fails (default true);
+	// otherwise the failure is only recorded on the job
+	FailOnError *bool `yaml:"fail_on_error,omitempty"`
+}
+
+// NotifiersConfig selects the notifications sent when a job finishes.
+// Each notifier is enabled by its settings; failures are only logged.
+type NotifiersConfig struct {
+	// Events are the final job statuses that notify (default completed,
+	// partial and failed)
+	Events []string `yaml:"events,omitempty"`
+
+	// Desktop shows a notification on the server's desktop
+	Desktop bool `yaml:"desktop,omitempty"`
+
+	// Telegram sends a message from a bot to a chat
+	Telegram TelegramNotifierConfig `yaml:"telegram,omitempty"`
+
+	// Email sends a message through an SMTP server
+	Email EmailNotifierConfig `yaml:"email,omitempty"`
+}
+
+// DefaultNotifyEvents are the job statuses that notify when events is unset
+var DefaultNotifyEvents = []string{"completed", "partial", "failed"}
+
+// NotifyEvents returns the configured events, or the default
+func (c *NotifiersConfig) NotifyEvents() []string {
+	if len(c.Events) == 0 {
+		return DefaultNotifyEvents
+	}
+	return c.Events
+}
+
+// TelegramNotifierConfig is enabled when both fields are set
+type TelegramNotifierConfig struct {
+	BotToken string `yaml:"bot_token,omitempty"`
+	ChatID   string `yaml:"chat_id,omitempty"`
+}
+
+// EmailNotifierConfig is enabled when Host and To are set
+type EmailNotifierConfig struct {
+	Host     string   `yaml:"host,omitempty"`
+	Port     int      `yaml:"port,omitempty"` // Default 587
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"`
+	From     string   `yaml:"from,omitempty"` // Default Username
+	To       []string `yaml:"to,omitempty"`
+}
+
+// FailOnErrorEnabled reports whether a failed upload fails the job
+func (c *DestinationConfig) FailOnErrorEnabled() bool {
+	return c.FailOnError == nil || *c.FailOnError
+}
+
+// Validate reports a Type no destination exists for. SFTP is named
+// explicitly since it's the one users most often expect.
+func (c *DestinationConfig) Validate() error {
+	switch {
+	case c.Type == "" || c.Type == StorageS3 || c.Type == DestinationWebDAV:
+		return nil
+	case strings.EqualFold(c.Type, "sftp"):
+		return fmt.Errorf("destination.type %q is not supported: SFTP uploads are not implemented (use s3 or webdav)", c.Type)
+	default:
+		return fmt.Errorf("unknown destination.type %q (want s3 or webdav)", c.Type)
+	}
+}
+
+// S3Config returns the S3 settings of an "s3" destination
+func (c *DestinationConfig) S3Config() StorageConfig {
+	return StorageConfig{
+		Type:      StorageS3,
+		Endpoint:  c.Endpoint,
+		Region:    c.Region,
+		Bucket:    c.Bucket,
+		Prefix:    c.Prefix,
+		AccessKey: c.AccessKey,
+		SecretKey: c.SecretKey,
+		PathStyle: c.PathStyle,
+	}
 }
 
 // WebDAVServer represents a WebDAV server configuration
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestExpandPath(t *testing.T) {
@@ -68,3 +71,242 @@ func TestExpandPath(t *testing.T) {
 		})
 	}
 }
+
+func TestIsDomainAllowed(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      ServerConfig
+		host     string
+		expected bool
+	}{
+		{
+			name:     "No lists configured",
+			cfg:      ServerConfig{},
+			host:     "example.com",
+			expected: true,
+		},
+		{
+			name:     "Exact allow match",
+			cfg:      ServerConfig{AllowedDomains: []string{"example.com"}},
+			host:     "example.com",
+			expected: true,
+		},
+		{
+			name:     "Exact allow does not cover subdomain",
+			cfg:      ServerConfig{AllowedDomains: []string{"example.com"}},
+			host:     "cdn.example.com",
+			expected: false,
+		},
+		{
+			name:     "Wildcard allow matches subdomain",
+			cfg:      ServerConfig{AllowedDomains: []string{"*.example.com"}},
+			host:     "a.b.example.com",
+			expected: true,
+		},
+		{
+			name:     "Wildcard allow matches apex",
+			cfg:      ServerConfig{AllowedDomains: []string{"*.example.com"}},
+			host:     "example.com",
+			expected: true,
+		},
+		{
+			name:     "Wildcard does not match lookalike",
+			cfg:      ServerConfig{AllowedDomains: []string{"*.example.com"}},
+			host:     "badexample.com",
+			expected: false,
+		},
+		{
+			name:     "Case insensitive",
+			cfg:      ServerConfig{AllowedDomains: []string{"Example.COM"}},
+			host:     "EXAMPLE.com",
+			expected: true,
+		},
+		{
+			name:     "Blocked domain",
+			cfg:      ServerConfig{BlockedDomains: []string{"*.evil.com"}},
+			host:     "cdn.evil.com",
+			expected: false,
+		},
+		{
+			name: "Block takes precedence over allow",
+			cfg: ServerConfig{
+				AllowedDomains: []string{"*.example.com"},
+				BlockedDomains: []string{"private.example.com"},
+			},
+			host:     "private.example.com",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cfg.IsDomainAllowed(tt.host)
+			if got != tt.expected {
+				t.Errorf("IsDomainAllowed(%q) = %v; want %v", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestQualityCandidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		ladder    []string
+		minHeight int
+		requested string
+		expected  []string
+	}{
+		{
+			name:      "Best means no ladder",
+			requested: "best",
+			expected:  nil,
+		},
+		{
+			name:      "Default ladder from 1080p",
+			requested: "1080",
+			expected:  []string{"1080p", "720p", "480p", "360p", "240p"},
+		},
+		{
+			name:      "Custom ladder",
+			ladder:    []string{"1080p", "720P", "480"},
+			requested: "720p",
+			expected:  []string{"720p", "480p"},
+		},
+		{
+			name:      "Quality not on ladder",
+			ladder:    []string{"1080p", "720p"},
+			requested: "540p",
+			expected:  []string{"540p"},
+		},
+		{
+			name:      "Min height stops the ladder",
+			minHeight: 480,
+			requested: "1080p",
+			expected:  []string{"1080p", "720p", "480p"},
+		},
+		{
+			name:      "Request below min height",
+			minHeight: 720,
+			requested: "480p",
+			expected:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{QualityLadder: tt.ladder, MinHeight: tt.minHeight}
+			got := cfg.QualityCandidates(tt.requested)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("QualityCandidates(%q) = %v; want %v", tt.requested, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{input: "", expected: 0},
+		{input: "512", expected: 512},
+		{input: "64K", expected: 64 << 10},
+		{input: "10MB", expected: 10 << 20},
+		{input: "1.5g", expected: 3 << 29},
+		{input: "2MB/s", expected: 2 << 20},
+		{input: "fast", wantErr: true},
+		{input: "-1M", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseByteSize(%q) error = %v; wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParseByteSize(%q) = %d; want %d", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestSiteValidate(t *testing.T) {
+	tests := []struct {
+		site    Site
+		wantErr bool
+	}{
+		{site: Site{Match: "a.com"}},
+		{site: Site{Match: "a.com", Quality: "720", Format: "WebM"}},
+		{site: Site{Match: "a.com", Quality: "best", Format: "best"}},
+		{site: Site{Match: "a.com", Quality: "high"}, wantErr: true},
+		{site: Site{Match: "a.com", Format: "avi"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.site.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%+v) error = %v; wantErr %v", tt.site, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDestinationValidate(t *testing.T) {
+	tests := []struct {
+		typ     string
+		wantErr bool
+	}{
+		{typ: ""},
+		{typ: StorageS3},
+		{typ: DestinationWebDAV},
+		{typ: "sftp", wantErr: true},
+		{typ: "SFTP", wantErr: true},
+		{typ: "ftp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		dest := DestinationConfig{Type: tt.typ}
+		if err := dest.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) error = %v; wantErr %v", tt.typ, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRateWindow(t *testing.T) {
+	// 2024-05-03 is a Friday
+	at := func(day int, clock string) time.Time {
+		ts, _ := time.Parse("2006-01-02 15:04", fmt.Sprintf("2024-05-%02d %s", day, clock))
+		return ts
+	}
+	tests := []struct {
+		entry string
+		at    time.Time
+		want  bool
+		rate  int64
+	}{
+		{entry: "mon-fri 09:00-18:00 1MB", at: at(3, "09:00"), want: true, rate: 1 << 20},
+		{entry: "mon-fri 09:00-18:00 1MB", at: at(3, "18:00"), want: false, rate: 1 << 20},
+		{entry: "mon-fri 09:00-18:00 1MB", at: at(4, "12:00"), want: false, rate: 1 << 20},
+		{entry: "23:00-07:00 unlimited", at: at(3, "23:30"), want: true},
+		{entry: "23:00-07:00 unlimited", at: at(4, "06:59"), want: true},
+		{entry: "fri 23:00-07:00 512K", at: at(4, "03:00"), want: true, rate: 512 << 10},
+		{entry: "fri 23:00-07:00 512K", at: at(3, "03:00"), want: false, rate: 512 << 10},
+		{entry: "sat-sun 00:00-24:00 0", at: at(5, "23:59"), want: true},
+	}
+
+	for _, tt := range tests {
+		w, err := ParseRateWindow(tt.entry)
+		if err != nil {
+			t.Errorf("ParseRateWindow(%q) error: %v", tt.entry, err)
+			continue
+		}
+		if got := w.Contains(tt.at); got != tt.want || w.Rate != tt.rate {
+			t.Errorf("%q at %s: contains = %v, rate = %d; want %v, %d", tt.entry, tt.at.Format("Mon 15:04"), got, w.Rate, tt.want, tt.rate)
+		}
+	}
+
+	for _, entry := range []string{"09:00-18:00", "mon-fry 09:00-18:00 1MB", "9-18 1MB", "09:00-09:00 1MB", "09:00-25:00 1MB", "09:00-18:00 fast"} {
+		if _, err := ParseRateWindow(entry); err == nil {
+			t.Errorf("ParseRateWindow(%q) succeeded; want error", entry)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RateWindow is a parsed server.rate_schedule entry: a daily time range,
+// optionally limited to some weekdays, with its download rate
+type RateWindow struct {
+	Days  [7]bool // Indexed by time.Weekday
+	Start int     // Minutes since midnight
+	End   int     // Minutes since midnight; End <= Start wraps past midnight
+	Rate  int64   // Bytes per second (0 = unlimited)
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseRateWindow parses a schedule entry of the form
+// "[days] HH:MM-HH:MM rate", e.g. "mon-fri 09:00-18:00 1MB" or
+// "23:00-07:00 0". Days are a weekday or a range of them ("sat-sun");
+// without days the window applies every day. A window ending at or before
+// its start runs past midnight, and belongs to the day it starts on. The
+// rate uses the rate_limit syntax; "0" or "unlimited" lifts the limit.
+func ParseRateWindow(entry string) (RateWindow, error) {
+	var w RateWindow
+	fields := strings.Fields(strings.ToLower(entry))
+	switch len(fields) {
+	case 2:
+		for d := range w.Days {
+			w.Days[d] = true
+		}
+	case 3:
+		if err := parseWeekdays(fields[0], &w.Days); err != nil {
+			return w, fmt.Errorf("invalid rate_schedule entry %q: %w", entry, err)
+		}
+		fields = fields[1:]
+	default:
+		return w, fmt.Errorf("invalid rate_schedule entry %q: want \"[days] HH:MM-HH:MM rate\"", entry)
+	}
+
+	var err error
+	start, end, ok := strings.Cut(fields[0], "-")
+	if !ok {
+		return w, fmt.Errorf("invalid rate_schedule entry %q: time range must be HH:MM-HH:MM", entry)
+	}
+	if w.Start, err = parseClock(start); err != nil {
+		return w, fmt.Errorf("invalid rate_schedule entry %q: %w", entry, err)
+	}
+	if w.End, err = parseClock(end); err != nil {
+		return w, fmt.Errorf("invalid rate_schedule entry %q: %w", entry, err)
+	}
+	if w.Start == w.End {
+		return w, fmt.Errorf("invalid rate_schedule entry %q: empty time range", entry)
+	}
+
+	if fields[1] != "unlimited" {
+		if w.Rate, err = ParseByteSize(fields[1]); err != nil {
+			return w, fmt.Errorf("invalid rate_schedule entry %q: %w", entry, err)
+		}
+	}
+	return w, nil
+}
+
+// parseWeekdays marks the days named by spec ("mon" or "mon-fri") in days
+func parseWeekdays(spec string, days *[7]bool) error {
+	first, last, isRange := strings.Cut(spec, "-")
+	from, ok := weekdays[first]
+	if !ok {
+		return fmt.Errorf("unknown weekday %q", first)
+	}
+	to := from
+	if isRange {
+		if to, ok = weekdays[last]; !ok {
+			return fmt.Errorf("unknown weekday %q", last)
+		}
+	}
+	// Ranges may wrap around the week, e.g. "fri-mon"
+	for d := from; ; d = (d + 1) % 7 {
+		days[d] = true
+		if d == to {
+			return nil
+		}
+	}
+}
+
+// parseClock parses "HH:MM" (00:00 to 24:00) into minutes since midnight
+func parseClock(value string) (int, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(value, "%d:%d", &h, &m); err != nil || h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return h*60 + m, nil
+}
+
+// Contains reports whether t (in the schedule's time zone) falls inside the window
+func (w RateWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.Days[day] && minute >= w.Start && minute < w.End
+	}
+	// Past midnight: the late part of the start day or the early part of the next
+	yesterday := (day + 6) % 7
+	return (w.Days[day] && minute >= w.Start) || (w.Days[yesterday] && minute < w.End)
+}
+
+// RateWindows returns the parsed rate_schedule entries, skipping invalid ones
+func (c *ServerConfig) RateWindows() []RateWindow {
+	var windows []RateWindow
+	for _, entry := range c.RateSchedule {
+		if w, err := ParseRateWindow(entry); err == nil {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
+
+// ScheduleLocation returns the time zone rate_schedule is read in: the
+// configured IANA zone, or the server's local time zone (TZ) if unset or
+// unknown
+func (c *ServerConfig) ScheduleLocation() *time.Location {
+	if c.RateScheduleTimezone != "" {
+		if loc, err := time.LoadLocation(c.RateScheduleTimezone); err == nil {
+			return loc
+		}
+	}
+	return time.Local
+}
@@ -17,6 +17,31 @@ type Site struct {
 
 	// Type is the media type to extract (e.g., "m3u8", "mp4")
 	Type string `yaml:"type"`
+
+	// Quality is the default quality for downloads from this site (e.g.,
+	// "720p", "best"), used instead of the global quality
+	Quality string `yaml:"quality,omitempty"`
+
+	// Format is the preferred container for downloads from this site (e.g.,
+	// "webm", "mp4"); "best" or empty considers every format
+	Format string `yaml:"format,omitempty"`
+}
+
+// siteFormats are the containers a site's format preference may name
+var siteFormats = map[string]bool{
+	"best": true, "mp4": true, "webm": true, "mkv": true, "mov": true,
+	"flv": true, "m4v": true, "ts": true, "m3u8": true,
+}
+
+// Validate checks the site's quality and format preferences
+func (s *Site) Validate() error {
+	if q := NormalizeQuality(s.Quality); q != "" && q != "best" && QualityHeight(q) == 0 {
+		return fmt.Errorf("site %q: invalid quality %q (use e.g. \"720p\" or \"best\")", s.Match, s.Quality)
+	}
+	if f := strings.ToLower(s.Format); f != "" && !siteFormats[f] {
+		return fmt.Errorf("site %q: invalid format %q (use e.g. \"mp4\", \"webm\" or \"best\")", s.Match, s.Format)
+	}
+	return nil
 }
 
 // SitesConfig holds the sites configuration
@@ -38,6 +63,11 @@ func LoadSites() (*SitesConfig, error) {
 	if err := yaml.Unmarshal(data, cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse %s: %w", SitesFileName, err)
 	}
+	for i := range cfg.Sites {
+		if err := cfg.Sites[i].Validate(); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", SitesFileName, err)
+		}
+	}
 
 	return cfg, nil
 }
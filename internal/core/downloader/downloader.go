@@ -4,11 +4,23 @@ import (
 	"fmt"
 	"io"
 	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
 )
 
 // DefaultUserAgent is the default User-Agent header used for downloads
 const DefaultUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
 
+// StatusError is an unexpected HTTP status answering a media request
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
 // Downloader handles file downloads with progress reporting
 type Downloader struct {
 	lang string
@@ -38,16 +50,7 @@ func (d *Downloader) DownloadFromReader(reader io.ReadCloser, size int64, output
 }
 
 func formatBytes(b int64) string {
-	const unit = 1024
-	if b < unit {
-		return fmt.Sprintf("%d B", b)
-	}
-	div, exp := int64(unit), 0
-	for n := b / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(b)/float64(div), "KMGTPE"[exp])
+	return config.FormatByteSize(b)
 }
 
 func formatDuration(d time.Duration) string {
@@ -0,0 +1,87 @@
+package downloader
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// FaststartExts are the extensions of the MP4-family files Faststart
+// applies to
+var FaststartExts = []string{"mp4", "m4v", "m4a", "mov"}
+
+// NeedsFaststart reports whether the MP4 file at path has its moov atom
+// (the index players need before they can start) after its media data, so
+// playback can't begin until the whole file is fetched. It reads only the
+// top-level atom headers.
+func NeedsFaststart(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	var offset int64
+	header := make([]byte, 16)
+	for {
+		if _, err := f.ReadAt(header[:8], offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return false, fmt.Errorf("no moov atom found")
+			}
+			return false, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		switch string(header[4:8]) {
+		case "moov":
+			return false, nil
+		case "mdat":
+			return true, nil
+		}
+
+		switch size {
+		case 0: // Runs to the end of the file
+			return false, fmt.Errorf("no moov atom found")
+		case 1: // 64-bit size follows the type
+			if _, err := f.ReadAt(header[8:16], offset+8); err != nil {
+				return false, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+		}
+		if size < 8 {
+			return false, fmt.Errorf("invalid atom size %d at offset %d", size, offset)
+		}
+		offset += size
+	}
+}
+
+// Faststart copies the streams of the MP4 file at inputPath to outputPath
+// with the moov atom moved to the front (-movflags +faststart), using the
+// system ffmpeg (no re-encoding). ffmpeg is killed if ctx ends first.
+func Faststart(ctx context.Context, inputPath, outputPath string) error {
+	if !FFmpegAvailable() {
+		return fmt.Errorf("ffmpeg not found in PATH")
+	}
+
+	args := []string{
+		"-i", inputPath,
+		"-map", "0",
+		"-c", "copy",
+		"-movflags", "+faststart",
+		"-y",
+		outputPath,
+	}
+	log.Printf("[ffmpeg] command: ffmpeg %s", strings.Join(args, " "))
+
+	output, err := exec.CommandContext(ctx, "ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg faststart failed: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
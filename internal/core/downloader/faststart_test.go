@@ -0,0 +1,52 @@
+package downloader
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// atom builds an MP4 atom of the given type around body
+func atom(kind string, body []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, kind...), body...)
+}
+
+func TestNeedsFaststart(t *testing.T) {
+	ftyp := atom("ftyp", []byte("isom0000"))
+	moov := atom("moov", make([]byte, 32))
+	mdat := atom("mdat", make([]byte, 64))
+	// A 64-bit sized free atom, as written for large mdat boxes
+	large := append(binary.BigEndian.AppendUint32(nil, 1), "free"...)
+	large = append(binary.BigEndian.AppendUint64(large, 24), make([]byte, 8)...)
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected bool
+		wantErr  bool
+	}{
+		{"moov first", concat(ftyp, moov, mdat), false, false},
+		{"moov last", concat(ftyp, mdat, moov), true, false},
+		{"64-bit atom", concat(ftyp, large, mdat, moov), true, false},
+		{"no moov", concat(ftyp, atom("free", nil)), false, true},
+		{"bad size", concat(ftyp, []byte{0, 0, 0, 4, 'f', 'r', 'e', 'e'}), false, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "video.mp4")
+		os.WriteFile(path, tt.data, 0o644)
+		got, err := NeedsFaststart(path)
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("%s: NeedsFaststart = %v, %v; want %v (error: %v)", tt.name, got, err, tt.expected, tt.wantErr)
+		}
+	}
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
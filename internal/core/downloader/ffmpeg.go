@@ -7,6 +7,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // FFmpegAvailable checks if ffmpeg is installed and available in PATH
@@ -124,3 +125,175 @@ func MergeVideoAudioKeepOriginals(videoPath, audioPath string) (string, error) {
 
 	return mergedPath, nil
 }
+
+// RemuxToMP4 copies the streams of inputPath into an MP4 container at outputPath
+// using the system ffmpeg (no re-encoding)
+func RemuxToMP4(inputPath, outputPath string) error {
+	if !FFmpegAvailable() {
+		return fmt.Errorf("ffmpeg not found in PATH")
+	}
+
+	args := []string{
+		"-err_detect", "ignore_err",
+		"-i", inputPath,
+		"-c", "copy",
+		"-f", "mp4",
+		"-y",
+		outputPath,
+	}
+	log.Printf("[ffmpeg] command: ffmpeg %s", strings.Join(args, " "))
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg remux failed: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// EmbedChapters copies the streams of inputPath into outputPath with the
+// chapters of metadataPath, an FFMETADATA file, using the system ffmpeg
+// (no re-encoding)
+func EmbedChapters(inputPath, metadataPath, outputPath string) error {
+	if !FFmpegAvailable() {
+		return fmt.Errorf("ffmpeg not found in PATH")
+	}
+
+	args := []string{
+		"-i", inputPath,
+		"-i", metadataPath,
+		"-map", "0",
+		"-map_chapters", "1",
+		"-c", "copy",
+		"-y",
+		outputPath,
+	}
+	log.Printf("[ffmpeg] command: ffmpeg %s", strings.Join(args, " "))
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg chapter embedding failed: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// TrimMedia copies the streams of inputPath between start and end into
+// outputPath using the system ffmpeg (no re-encoding, so cuts snap to the
+// nearest keyframes). A zero start or end leaves that side of the range open.
+func TrimMedia(inputPath, outputPath string, start, end time.Duration) error {
+	if !FFmpegAvailable() {
+		return fmt.Errorf("ffmpeg not found in PATH")
+	}
+
+	args := []string{"-i", inputPath}
+	if start > 0 {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", start.Seconds()))
+	}
+	if end > 0 {
+		args = append(args, "-to", fmt.Sprintf("%.3f", end.Seconds()))
+	}
+	args = append(args,
+		"-c", "copy",
+		"-avoid_negative_ts", "make_zero",
+		"-y",
+		outputPath,
+	)
+	log.Printf("[ffmpeg] command: ffmpeg %s", strings.Join(args, " "))
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg trim failed: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// containerCodecs are the ffmpeg muxer and the codecs ConvertContainer
+// transcodes to for each container it can convert to
+var containerCodecs = map[string]struct{ muxer, video, audio string }{
+	"mp4":  {"mp4", "libx264", "aac"},
+	"mkv":  {"matroska", "libx264", "aac"},
+	"webm": {"webm", "libvpx-vp9", "libopus"},
+}
+
+// ConvertContainers lists the containers ConvertContainer supports
+var ConvertContainers = []string{"mp4", "mkv", "webm"}
+
+// ConvertContainer rewrites inputPath as container ("mp4", "mkv" or "webm")
+// at outputPath using the system ffmpeg. The streams are copied when the
+// container accepts their codecs, and transcoded only when it doesn't; the
+// result reports which happened.
+func ConvertContainer(inputPath, outputPath, container string) (transcoded bool, err error) {
+	if !FFmpegAvailable() {
+		return false, fmt.Errorf("ffmpeg not found in PATH")
+	}
+	codecs, ok := containerCodecs[container]
+	if !ok {
+		return false, fmt.Errorf("unsupported container: %s", container)
+	}
+
+	copyArgs := []string{"-c", "copy"}
+	transcodeArgs := []string{"-c:v", codecs.video, "-c:a", codecs.audio}
+	for _, codecArgs := range [][]string{copyArgs, transcodeArgs} {
+		args := append([]string{"-i", inputPath, "-map", "0:v?", "-map", "0:a?"}, codecArgs...)
+		args = append(args, "-f", codecs.muxer, "-y", outputPath)
+		log.Printf("[ffmpeg] command: ffmpeg %s", strings.Join(args, " "))
+
+		var output []byte
+		output, err = exec.Command("ffmpeg", args...).CombinedOutput()
+		if err == nil {
+			return transcoded, nil
+		}
+		os.Remove(outputPath)
+		err = fmt.Errorf("ffmpeg conversion to %s failed: %w\nOutput: %s", container, err, string(output))
+		transcoded = true
+	}
+	return false, err
+}
+
+// loudnormEncoders are the audio encoders NormalizeLoudness re-encodes each
+// extension with, since filtering rules out stream copy
+var loudnormEncoders = map[string]string{
+	"mp3":  "libmp3lame",
+	"m4a":  "aac",
+	"aac":  "aac",
+	"ogg":  "libvorbis",
+	"opus": "libopus",
+	"webm": "libopus",
+	"wav":  "pcm_s16le",
+	"flac": "flac",
+}
+
+// NormalizeLoudness writes the audio of inputPath to outputPath (with the
+// same extension) through ffmpeg's loudnorm filter, targeting an integrated
+// loudness of targetLUFS. Metadata is kept; cover art and other non-audio
+// streams are dropped.
+func NormalizeLoudness(inputPath, outputPath string, targetLUFS float64) error {
+	if !FFmpegAvailable() {
+		return fmt.Errorf("ffmpeg not found in PATH")
+	}
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(outputPath)), ".")
+	encoder, ok := loudnormEncoders[ext]
+	if !ok {
+		return fmt.Errorf("unsupported audio format: %s", ext)
+	}
+
+	args := []string{
+		"-i", inputPath,
+		"-map", "0:a",
+		"-map_metadata", "0",
+		"-af", fmt.Sprintf("loudnorm=I=%g:TP=-1.5:LRA=11", targetLUFS),
+		"-c:a", encoder,
+		"-y",
+		outputPath,
+	}
+	log.Printf("[ffmpeg] command: ffmpeg %s", strings.Join(args, " "))
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg loudness normalization failed: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
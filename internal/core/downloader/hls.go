@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/tls"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -24,8 +25,48 @@ import (
 
 // HLSConfig holds configuration for HLS downloads
 type HLSConfig struct {
-	Workers    int // Number of parallel segment downloads
-	BufferSize int // Buffer size for reading segments
+	Workers    int  // Number of parallel segment downloads
+	BufferSize int  // Buffer size for reading segments
+	Remux      bool // Remux the downloaded .ts into .mp4 (stream copy) when possible
+
+	// InsecureSkipVerify disables TLS certificate verification for the
+	// playlist, key, and segment requests (self-signed sources only)
+	InsecureSkipVerify bool
+
+	// MinTLSVersion is the lowest TLS version negotiated, e.g.,
+	// tls.VersionTLS13 (0 = Go's default, TLS 1.2)
+	MinTLSVersion uint16
+
+	// ForceHTTP1 downloads segments over HTTP/1.1 connections only, for
+	// CDNs that throttle HTTP/2 (see ForceHTTP1)
+	ForceHTTP1 bool
+
+	// AcquireFFmpeg, if set, is called before remuxing and returns a func
+	// to call once ffmpeg is done; servers use it to limit concurrent
+	// ffmpeg runs. An error aborts the download.
+	AcquireFFmpeg func(ctx context.Context) (release func(), err error)
+
+	// Throttle, if set, is called with the size of every chunk read from a
+	// segment before it's used and may block; servers use it to hold the
+	// download within a bandwidth limit. An error aborts the download.
+	Throttle func(ctx context.Context, n int) error
+}
+
+// throttledReader passes each chunk read from r through throttle
+type throttledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	throttle func(ctx context.Context, n int) error
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if throttleErr := t.throttle(t.ctx, n); throttleErr != nil {
+			return n, throttleErr
+		}
+	}
+	return n, err
 }
 
 // DefaultHLSConfig returns default HLS configuration
@@ -33,7 +74,26 @@ func DefaultHLSConfig() HLSConfig {
 	return HLSConfig{
 		Workers:    8,
 		BufferSize: 512 * 1024, // 512KB
+		Remux:      true,
+	}
+}
+
+// tlsConfig returns the TLS config for the download's requests, or nil for
+// Go's defaults
+func (c HLSConfig) tlsConfig() *tls.Config {
+	if !c.InsecureSkipVerify && c.MinTLSVersion == 0 {
+		return nil
 	}
+	return &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify, MinVersion: c.MinTLSVersion}
+}
+
+// ForceHTTP1 stops t from negotiating HTTP/2, so parallel requests use
+// separate HTTP/1.1 connections instead of one multiplexed connection.
+// Some CDNs rate limit HTTP/2 clients more aggressively. It returns t.
+func ForceHTTP1(t *http.Transport) *http.Transport {
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	return t
 }
 
 // hlsState tracks HLS download progress
@@ -136,7 +196,7 @@ func downloadHLSWithHeaders(ctx context.Context, m3u8URL, output string, state *
 	var decryptKey []byte
 	var decryptIV []byte
 	if playlist.IsEncrypted && playlist.KeyURL != "" {
-		decryptKey, err = fetchKeyWithHeaders(playlist.KeyURL, headers)
+		decryptKey, err = fetchKeyWithHeaders(playlist.KeyURL, headers, nil)
 		if err != nil {
 			return fmt.Errorf("failed to fetch encryption key: %w", err)
 		}
@@ -213,13 +273,18 @@ func downloadSegmentsOrdered(ctx context.Context, segments []Segment, file *os.F
 	close(segmentChan)
 
 	// Create HTTP client
+	transport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConnsPerHost: config.Workers * 2,
+		DisableCompression:  true,
+		TLSClientConfig:     config.tlsConfig(),
+	}
+	if config.ForceHTTP1 {
+		ForceHTTP1(transport)
+	}
 	client := &http.Client{
-		Timeout: 60 * time.Second,
-		Transport: &http.Transport{
-			Proxy:               http.ProxyFromEnvironment,
-			MaxIdleConnsPerHost: config.Workers * 2,
-			DisableCompression:  true,
-		},
+		Timeout:   60 * time.Second,
+		Transport: transport,
 	}
 
 	// Start workers
@@ -235,7 +300,7 @@ func downloadSegmentsOrdered(ctx context.Context, segments []Segment, file *os.F
 				default:
 				}
 
-				data, err := downloadSegment(client, seg.URL, decryptKey, decryptIV, seg.Index, headers)
+				data, err := downloadSegment(ctx, client, seg.URL, decryptKey, decryptIV, seg.Index, headers, config.Throttle)
 				resultsChan <- segmentResult{
 					index: seg.Index,
 					data:  data,
@@ -251,8 +316,10 @@ func downloadSegmentsOrdered(ctx context.Context, segments []Segment, file *os.F
 		close(resultsChan)
 	}()
 
-	// Collect results and write in order
+	// Collect results and write in order, marking each segment as it's
+	// written so drops and duplicates are caught
 	nextIndex := 0
+	written := make([]bool, len(segments))
 	var writeErr error
 
 	for result := range resultsChan {
@@ -262,6 +329,11 @@ func downloadSegmentsOrdered(ctx context.Context, segments []Segment, file *os.F
 		}
 
 		resultsLock.Lock()
+		if _, buffered := results[result.index]; buffered || result.index < nextIndex {
+			writeErr = fmt.Errorf("segment %d was downloaded twice", result.index)
+			resultsLock.Unlock()
+			continue
+		}
 		results[result.index] = result.data
 		hlsState.incDownloaded()
 
@@ -276,6 +348,9 @@ func downloadSegmentsOrdered(ctx context.Context, segments []Segment, file *os.F
 				}
 				hlsState.addBytes(int64(len(data)))
 				delete(results, nextIndex)
+				if nextIndex < len(written) {
+					written[nextIndex] = true
+				}
 				nextIndex++
 			} else {
 				break
@@ -284,16 +359,63 @@ func downloadSegmentsOrdered(ctx context.Context, segments []Segment, file *os.F
 		resultsLock.Unlock()
 	}
 
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if writeErr != nil {
 		return fmt.Errorf("failed to write segment: %w", writeErr)
 	}
 
-	return nil
+	var missing []int
+	for i, ok := range written {
+		if !ok {
+			missing = append(missing, segments[i].Sequence)
+		}
+	}
+	return missingSegmentsError(missing)
 }
 
-// downloadSegment downloads a single segment
-func downloadSegment(client *http.Client, url string, decryptKey, decryptIV []byte, index int, headers map[string]string) ([]byte, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// missingSegmentsError reports segments (by media sequence number) absent
+// from the output, or returns nil if there are none
+func missingSegmentsError(missing []int) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	const maxListed = 20
+	if len(missing) > maxListed {
+		return fmt.Errorf("missing segments: %v and %d more", missing[:maxListed], len(missing)-maxListed)
+	}
+	return fmt.Errorf("missing segments: %v", missing)
+}
+
+// reconcileLivePlaylist reloads a live playlist after its segments were
+// downloaded and checks that every segment it now lists within the
+// downloaded sequence range is one that was written. A stream that moved
+// on past the range is fine; a segment replaced inside it is not.
+func reconcileLivePlaylist(mediaURL string, downloaded []Segment, headers map[string]string, tlsCfg *tls.Config) error {
+	final, err := parseM3U8(mediaURL, headers, tlsCfg)
+	if err != nil {
+		return fmt.Errorf("failed to reload live playlist: %w", err)
+	}
+
+	first, last := downloaded[0].Sequence, downloaded[len(downloaded)-1].Sequence
+	urls := make(map[int]string, len(downloaded))
+	for _, seg := range downloaded {
+		urls[seg.Sequence] = seg.URL
+	}
+	var missing []int
+	for _, seg := range final.Segments {
+		if seg.Sequence >= first && seg.Sequence <= last && urls[seg.Sequence] != seg.URL {
+			missing = append(missing, seg.Sequence)
+		}
+	}
+	return missingSegmentsError(missing)
+}
+
+// downloadSegment downloads a single segment, reading it through throttle
+// if set
+func downloadSegment(ctx context.Context, client *http.Client, url string, decryptKey, decryptIV []byte, index int, headers map[string]string, throttle func(ctx context.Context, n int) error) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -311,10 +433,14 @@ func downloadSegment(client *http.Client, url string, decryptKey, decryptIV []by
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("segment %d returned status %d", index, resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("segment %d returned status %d", index, resp.StatusCode)}
 	}
 
-	data, err := io.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if throttle != nil {
+		body = &throttledReader{ctx: ctx, r: resp.Body, throttle: throttle}
+	}
+	data, err := io.ReadAll(body)
 	if err != nil {
 		return nil, err
 	}
@@ -331,11 +457,12 @@ func downloadSegment(client *http.Client, url string, decryptKey, decryptIV []by
 }
 
 // fetchKeyWithHeaders fetches the encryption key from the URL with custom headers
-func fetchKeyWithHeaders(url string, headers map[string]string) ([]byte, error) {
+func fetchKeyWithHeaders(url string, headers map[string]string, tlsCfg *tls.Config) ([]byte, error) {
 	client := &http.Client{
 		Timeout: 30 * time.Second,
 		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsCfg,
 		},
 	}
 
@@ -357,7 +484,7 @@ func fetchKeyWithHeaders(url string, headers map[string]string) ([]byte, error)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("key server returned status %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("key server returned status %d", resp.StatusCode)}
 	}
 
 	return io.ReadAll(resp.Body)
@@ -399,23 +526,32 @@ func decryptAES128(data, key, iv []byte, segmentIndex int) ([]byte, error) {
 }
 
 // DownloadHLSWithProgress downloads an HLS stream with a progress callback (for server use)
-// Returns the final output path (may be .mp4 if converted in Docker) and error
+// Returns the final output path (may be .mp4 if remuxed) and error
 func DownloadHLSWithProgress(ctx context.Context, m3u8URL, output string, headers map[string]string, progressFn func(downloaded, total int64)) (string, error) {
-	hlsConfig := DefaultHLSConfig()
+	return DownloadHLSWithConfig(ctx, m3u8URL, output, headers, DefaultHLSConfig(), progressFn)
+}
+
+// DownloadHLSWithConfig is DownloadHLSWithProgress with explicit HLS settings.
+// The returned path is where the output actually ended up: the .mp4 when
+// hlsConfig.Remux is set and remuxing succeeded, otherwise output itself.
+func DownloadHLSWithConfig(ctx context.Context, m3u8URL, output string, headers map[string]string, hlsConfig HLSConfig, progressFn func(downloaded, total int64)) (string, error) {
+	tlsCfg := hlsConfig.tlsConfig()
 
 	// Parse the m3u8 playlist
-	playlist, err := ParseM3U8WithHeaders(m3u8URL, headers)
+	playlist, err := parseM3U8(m3u8URL, headers, tlsCfg)
 	if err != nil {
 		return "", fmt.Errorf("failed to parse m3u8: %w", err)
 	}
 
 	// If master playlist, get the best variant and parse it
+	mediaURL := m3u8URL
 	if playlist.IsMaster {
 		variant := playlist.SelectBestVariant()
 		if variant == nil {
 			return "", fmt.Errorf("no variants found in master playlist")
 		}
-		playlist, err = ParseM3U8WithHeaders(variant.URL, headers)
+		mediaURL = variant.URL
+		playlist, err = parseM3U8(variant.URL, headers, tlsCfg)
 		if err != nil {
 			return "", fmt.Errorf("failed to parse variant playlist: %w", err)
 		}
@@ -429,7 +565,7 @@ func DownloadHLSWithProgress(ctx context.Context, m3u8URL, output string, header
 	var decryptKey []byte
 	var decryptIV []byte
 	if playlist.IsEncrypted && playlist.KeyURL != "" {
-		decryptKey, err = fetchKeyWithHeaders(playlist.KeyURL, headers)
+		decryptKey, err = fetchKeyWithHeaders(playlist.KeyURL, headers, tlsCfg)
 		if err != nil {
 			return "", fmt.Errorf("failed to fetch encryption key: %w", err)
 		}
@@ -487,13 +623,30 @@ func DownloadHLSWithProgress(ctx context.Context, m3u8URL, output string, header
 	// Close file before conversion (ffmpeg needs exclusive access)
 	file.Close()
 
+	if !playlist.EndList {
+		if err := reconcileLivePlaylist(mediaURL, playlist.Segments, headers, tlsCfg); err != nil {
+			return "", err
+		}
+	}
+
 	// Final progress update - download complete
 	if progressFn != nil {
 		finalBytes := hlsState.getBytes()
 		progressFn(finalBytes, finalBytes)
 	}
 
-	// Convert .ts to .mp4 in Docker environment
+	if !hlsConfig.Remux {
+		return output, nil
+	}
+
+	// Remux .ts to .mp4 so the file opens in common players
+	if hlsConfig.AcquireFFmpeg != nil {
+		release, err := hlsConfig.AcquireFFmpeg(ctx)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+	}
 	finalPath, convErr := convertTsToMp4(output)
 	if convErr != nil {
 		// Log warning but don't fail - the .ts file is still usable
@@ -504,7 +657,8 @@ func DownloadHLSWithProgress(ctx context.Context, m3u8URL, output string, header
 	return finalPath, nil
 }
 
-// convertTsToMp4 converts a .ts file to .mp4 using embedded ffmpeg (copy, no re-encoding)
+// convertTsToMp4 converts a .ts file to .mp4 (copy, no re-encoding) using the
+// system ffmpeg if available, otherwise the embedded one
 // Returns the new .mp4 path if conversion succeeded, otherwise returns original path
 func convertTsToMp4(tsPath string) (string, error) {
 	// Only convert .ts files
@@ -524,6 +678,17 @@ func convertTsToMp4(tsPath string) (string, error) {
 		mp4Path = strings.TrimSuffix(absPath, ".TS") + ".mp4"
 	}
 
+	// Prefer the system ffmpeg when installed (native, faster on large files)
+	if FFmpegAvailable() {
+		if err := RemuxToMP4(absPath, mp4Path); err != nil {
+			return tsPath, err
+		}
+		if err := os.Remove(tsPath); err != nil {
+			fmt.Printf("Warning: could not remove original .ts file: %v\n", err)
+		}
+		return mp4Path, nil
+	}
+
 	// Mount directory for WASM filesystem access
 	dir := filepath.Dir(absPath)
 
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"bufio"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
@@ -21,6 +22,8 @@ type M3U8Playlist struct {
 	IsEncrypted   bool      // True if segments are encrypted
 	KeyURL        string    // URL of encryption key
 	KeyIV         string    // Initialization vector for encryption
+	MediaSequence int       // Sequence number of the first segment (EXT-X-MEDIA-SEQUENCE)
+	EndList       bool      // False for live playlists that may still grow (no EXT-X-ENDLIST)
 }
 
 // Variant represents a stream variant in a master playlist
@@ -37,6 +40,7 @@ type Segment struct {
 	URL      string
 	Duration float64
 	Index    int
+	Sequence int // Media sequence number, stable across reloads of a live playlist
 	Title    string
 }
 
@@ -58,12 +62,18 @@ func ParseM3U8(m3u8URL string) (*M3U8Playlist, error) {
 
 // ParseM3U8WithHeaders parses an m3u8 playlist from a URL with custom headers
 func ParseM3U8WithHeaders(m3u8URL string, headers map[string]string) (*M3U8Playlist, error) {
+	return parseM3U8(m3u8URL, headers, nil)
+}
+
+// parseM3U8 is ParseM3U8WithHeaders with a TLS config (nil = defaults)
+func parseM3U8(m3u8URL string, headers map[string]string, tlsCfg *tls.Config) (*M3U8Playlist, error) {
 	client := &http.Client{
 		Timeout: 60 * time.Second,
 		Transport: &http.Transport{
 			Proxy:                  http.ProxyFromEnvironment,
 			ResponseHeaderTimeout:  30 * time.Second,
 			IdleConnTimeout:        90 * time.Second,
+			TLSClientConfig:        tlsCfg,
 		},
 	}
 
@@ -85,7 +95,7 @@ func ParseM3U8WithHeaders(m3u8URL string, headers map[string]string) (*M3U8Playl
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("server returned status %d", resp.StatusCode)}
 	}
 
 	return parseM3U8Content(resp.Body, m3u8URL)
@@ -156,6 +166,15 @@ func parseM3U8Content(reader io.Reader, baseURL string) (*M3U8Playlist, error) {
 			continue
 		}
 
+		if value, ok := strings.CutPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"); ok {
+			playlist.MediaSequence, _ = strconv.Atoi(strings.TrimSpace(value))
+			continue
+		}
+		if line == "#EXT-X-ENDLIST" {
+			playlist.EndList = true
+			continue
+		}
+
 		// Skip other directives
 		if strings.HasPrefix(line, "#") {
 			continue
@@ -167,6 +186,7 @@ func parseM3U8Content(reader io.Reader, baseURL string) (*M3U8Playlist, error) {
 				URL:      resolveURL(base, line),
 				Duration: currentSegmentDuration,
 				Index:    segmentIndex,
+				Sequence: playlist.MediaSequence + segmentIndex,
 				Title:    currentSegmentTitle,
 			}
 			playlist.Segments = append(playlist.Segments, segment)
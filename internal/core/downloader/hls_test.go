@@ -0,0 +1,114 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newHLSServer serves a three-segment media playlist. Unless endList is set
+// the playlist is live, and every reload after the first replaces segment 11.
+func newHLSServer(t *testing.T, endList bool) *httptest.Server {
+	var loads atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/live.m3u8" {
+			fmt.Fprintf(w, "data%s;", r.URL.Path)
+			return
+		}
+		second := "b.ts"
+		if loads.Add(1) > 1 {
+			second = "b2.ts"
+		}
+		playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:10\n" +
+			"#EXTINF:2,\na.ts\n#EXTINF:2,\n" + second + "\n#EXTINF:2,\nc.ts\n"
+		if endList {
+			playlist += "#EXT-X-ENDLIST\n"
+		}
+		fmt.Fprint(w, playlist)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestHLSSegmentContinuity(t *testing.T) {
+	t.Run("complete playlist", func(t *testing.T) {
+		ts := newHLSServer(t, true)
+		output := filepath.Join(t.TempDir(), "out.ts")
+		if _, err := DownloadHLSWithConfig(context.Background(), ts.URL+"/live.m3u8", output, nil, HLSConfig{Workers: 3}, nil); err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if data, _ := os.ReadFile(output); string(data) != "data/a.ts;data/b.ts;data/c.ts;" {
+			t.Errorf("output = %q; want the segments in playlist order", data)
+		}
+	})
+
+	t.Run("live playlist replaced a segment", func(t *testing.T) {
+		ts := newHLSServer(t, false)
+		output := filepath.Join(t.TempDir(), "out.ts")
+		_, err := DownloadHLSWithConfig(context.Background(), ts.URL+"/live.m3u8", output, nil, HLSConfig{Workers: 3}, nil)
+		if err == nil || !strings.Contains(err.Error(), "missing segments: [11]") {
+			t.Errorf("err = %v; want missing segments: [11]", err)
+		}
+	})
+
+	t.Run("throttled download", func(t *testing.T) {
+		ts := newHLSServer(t, true)
+		var throttled atomic.Int64
+		config := HLSConfig{Workers: 3, Throttle: func(ctx context.Context, n int) error {
+			throttled.Add(int64(n))
+			return nil
+		}}
+		output := filepath.Join(t.TempDir(), "out.ts")
+		if _, err := DownloadHLSWithConfig(context.Background(), ts.URL+"/live.m3u8", output, nil, config, nil); err != nil {
+			t.Fatalf("download: %v", err)
+		}
+		if info, _ := os.Stat(output); throttled.Load() != info.Size() {
+			t.Errorf("throttled %d bytes; want all %d segment bytes", throttled.Load(), info.Size())
+		}
+	})
+
+	t.Run("cancelled download", func(t *testing.T) {
+		ts := newHLSServer(t, true)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		output := filepath.Join(t.TempDir(), "out.ts")
+		if _, err := DownloadHLSWithConfig(ctx, ts.URL+"/live.m3u8", output, nil, HLSConfig{Workers: 3}, nil); err == nil {
+			t.Error("cancelled download succeeded")
+		}
+	})
+
+	if err := missingSegmentsError(make([]int, 25)); !strings.HasSuffix(err.Error(), "and 5 more") {
+		t.Errorf("long list = %v; want it truncated", err)
+	}
+}
+
+func TestForceHTTP1(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	proto := func(transport *http.Transport) string {
+		transport.TLSClientConfig = HLSConfig{InsecureSkipVerify: true}.tlsConfig()
+		resp, err := (&http.Client{Transport: transport}).Get(ts.URL)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		defer resp.Body.Close()
+		return resp.Proto
+	}
+	if got := proto(&http.Transport{ForceAttemptHTTP2: true}); got != "HTTP/2.0" {
+		t.Fatalf("default proto = %s; want HTTP/2.0", got)
+	}
+	if got := proto(ForceHTTP1(&http.Transport{ForceAttemptHTTP2: true})); got != "HTTP/1.1" {
+		t.Errorf("forced proto = %s; want HTTP/1.1", got)
+	}
+}
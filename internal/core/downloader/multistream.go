@@ -15,19 +15,21 @@ import (
 
 // MultiStreamConfig configures multi-stream downloads
 type MultiStreamConfig struct {
-	Streams    int   // Number of parallel streams (default 12)
-	ChunkSize  int64 // Size of each chunk in bytes (default 16MB)
-	BufferSize int   // Buffer size per stream (default 1MB)
-	UseHTTP2   bool  // Enable HTTP/2 (default true, better for HTTPS)
+	Streams    int         // Number of parallel streams (default 12)
+	ChunkSize  int64       // Size of each chunk in bytes (default 16MB)
+	BufferSize int         // Buffer size per stream (default 1MB)
+	UseHTTP2   bool        // Enable HTTP/2 (default true, better for HTTPS)
+	Retry      RetryPolicy // Backoff for failed chunks; attempts reset while a chunk makes progress
 }
 
 // DefaultMultiStreamConfig returns sensible defaults similar to rclone
 func DefaultMultiStreamConfig() MultiStreamConfig {
 	return MultiStreamConfig{
-		Streams:    12,               // 12 parallel streams - balanced for stability
-		ChunkSize:  8 * 1024 * 1024,  // 8MB chunks - smaller for faster recovery on failure
-		BufferSize: 1024 * 1024,      // 1MB buffer per stream
-		UseHTTP2:   true,             // Enable HTTP/2 by default for better multiplexing
+		Streams:    12,              // 12 parallel streams - balanced for stability
+		ChunkSize:  8 * 1024 * 1024, // 8MB chunks - smaller for faster recovery on failure
+		BufferSize: 1024 * 1024,     // 1MB buffer per stream
+		UseHTTP2:   true,            // Enable HTTP/2 by default for better multiplexing
+		Retry:      DefaultRetryPolicy(),
 	}
 }
 
@@ -227,7 +229,7 @@ func MultiStreamDownload(ctx context.Context, url, output string, config MultiSt
 		go func() {
 			defer wg.Done()
 			for c := range chunkChan {
-				if err := downloadChunk(ctx, client, url, file, c, config.BufferSize, msState); err != nil {
+				if err := downloadChunk(ctx, client, url, file, c, config.BufferSize, config.Retry, msState); err != nil {
 					msState.addError(fmt.Errorf("chunk %d failed: %w", c.index, err))
 				}
 			}
@@ -288,25 +290,11 @@ func calculateChunks(totalSize int64, chunkSize int64) []chunk {
 
 // downloadChunk downloads a single chunk using HTTP Range request with resumable retry logic
 // Instead of restarting from byte 0 on failure, it resumes from the last successfully written byte
-func downloadChunk(ctx context.Context, client *http.Client, url string, file *os.File, c chunk, bufferSize int, state *multiStreamState) error {
-	const maxRetries = 10 // More retries since we resume, not restart
-	var lastErr error
+func downloadChunk(ctx context.Context, client *http.Client, url string, file *os.File, c chunk, bufferSize int, retry RetryPolicy, state *multiStreamState) error {
 	currentStart := c.start // Track where we are in the chunk
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Shorter backoff since we're resuming: 500ms, 1s, 2s, 4s... capped at 8s
-			backoff := time.Duration(1<<uint(attempt-1)) * 500 * time.Millisecond
-			if backoff > 8*time.Second {
-				backoff = 8 * time.Second
-			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
-
+	backoff := retry.Begin()
+	for {
 		// Create a sub-chunk from current position to end
 		subChunk := chunk{
 			index: c.index,
@@ -319,7 +307,6 @@ func downloadChunk(ctx context.Context, client *http.Client, url string, file *o
 			return nil // Success!
 		}
 
-		lastErr = err
 		// Update currentStart to resume from where we left off
 		// bytesWritten already added to state, so we keep that progress
 		if bytesWritten > 0 {
@@ -334,11 +321,12 @@ func downloadChunk(ctx context.Context, client *http.Client, url string, file *o
 		// If we've made no progress at all in this attempt, count it as a real failure
 		// Otherwise, reset attempt counter since we made progress
 		if bytesWritten > 0 {
-			attempt = 0 // Reset retries when we make progress
+			backoff.Reset()
+		}
+		if werr := backoff.Wait(ctx); werr != nil {
+			return fmt.Errorf("%w: %w", werr, err)
 		}
 	}
-
-	return fmt.Errorf("after %d retries: %w", maxRetries, lastErr)
 }
 
 // downloadChunkOnce performs a single attempt to download a chunk
@@ -515,7 +503,7 @@ func MultiStreamDownloadWithAuth(ctx context.Context, url, authHeader, output st
 		go func() {
 			defer wg.Done()
 			for c := range chunkChan {
-				if err := downloadChunkWithAuth(ctx, client, url, authHeader, file, c, config.BufferSize, msState); err != nil {
+				if err := downloadChunkWithAuth(ctx, client, url, authHeader, file, c, config.BufferSize, config.Retry, msState); err != nil {
 					msState.addError(fmt.Errorf("chunk %d failed: %w", c.index, err))
 				}
 			}
@@ -543,25 +531,11 @@ func MultiStreamDownloadWithAuth(ctx context.Context, url, authHeader, output st
 
 // downloadChunkWithAuth downloads a single chunk using HTTP Range request with auth
 // It includes resumable retry logic - on failure, it resumes from the last written byte
-func downloadChunkWithAuth(ctx context.Context, client *http.Client, url, authHeader string, file *os.File, c chunk, bufferSize int, state *multiStreamState) error {
-	const maxRetries = 10 // More retries since we resume, not restart
-	var lastErr error
+func downloadChunkWithAuth(ctx context.Context, client *http.Client, url, authHeader string, file *os.File, c chunk, bufferSize int, retry RetryPolicy, state *multiStreamState) error {
 	currentStart := c.start // Track where we are in the chunk
 
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		if attempt > 0 {
-			// Shorter backoff since we're resuming: 500ms, 1s, 2s, 4s... capped at 8s
-			backoff := time.Duration(1<<uint(attempt-1)) * 500 * time.Millisecond
-			if backoff > 8*time.Second {
-				backoff = 8 * time.Second
-			}
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(backoff):
-			}
-		}
-
+	backoff := retry.Begin()
+	for {
 		// Create a sub-chunk from current position to end
 		subChunk := chunk{
 			index: c.index,
@@ -574,7 +548,6 @@ func downloadChunkWithAuth(ctx context.Context, client *http.Client, url, authHe
 			return nil // Success!
 		}
 
-		lastErr = err
 		// Update currentStart to resume from where we left off
 		if bytesWritten > 0 {
 			currentStart = newOffset
@@ -587,11 +560,12 @@ func downloadChunkWithAuth(ctx context.Context, client *http.Client, url, authHe
 
 		// Reset attempt counter when we make progress
 		if bytesWritten > 0 {
-			attempt = 0
+			backoff.Reset()
+		}
+		if werr := backoff.Wait(ctx); werr != nil {
+			return fmt.Errorf("%w: %w", werr, err)
 		}
 	}
-
-	return fmt.Errorf("after %d retries: %w", maxRetries, lastErr)
 }
 
 // downloadChunkWithAuthOnce performs a single attempt to download a chunk
@@ -337,7 +337,7 @@ func downloadWithProgress(client *http.Client, url, output string, state *downlo
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+		return &StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("download failed with status %d", resp.StatusCode)}
 	}
 
 	total := resp.ContentLength
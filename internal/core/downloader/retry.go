@@ -0,0 +1,193 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy controls the exponential backoff between attempts of a
+// retried operation. Zero durations, multiplier, and attempts fall back to
+// DefaultRetryPolicy; a zero Jitter means no jitter.
+type RetryPolicy struct {
+	BaseDelay   time.Duration // Delay before the first retry
+	MaxDelay    time.Duration // Cap on any single delay, jitter included
+	Multiplier  float64       // Growth of the delay per retry
+	Jitter      float64       // Random spread of each delay as a fraction (0.2 = ±20%)
+	MaxAttempts int           // Attempts including the first
+	MaxElapsed  time.Duration // Give up once this much time has passed (0 = no limit)
+}
+
+// DefaultRetryPolicy returns the built-in backoff: 500ms, 1s, 2s, 4s...
+// capped at 8s, ±20% jitter, 10 attempts
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    8 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+		MaxAttempts: 10,
+	}
+}
+
+// withDefaults fills unset fields from DefaultRetryPolicy
+func (p RetryPolicy) withDefaults() RetryPolicy {
+	def := DefaultRetryPolicy()
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = def.BaseDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = def.MaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = def.Multiplier
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = def.MaxAttempts
+	}
+	p.Jitter = min(max(p.Jitter, 0), 1)
+	return p
+}
+
+// Delay returns the wait before retry n (1 for the first retry), with
+// jitter applied and capped at MaxDelay
+func (p RetryPolicy) Delay(n int) time.Duration {
+	p = p.withDefaults()
+	d := float64(p.BaseDelay)
+	for i := 1; i < n && d < float64(p.MaxDelay); i++ {
+		d *= p.Multiplier
+	}
+	if p.Jitter > 0 {
+		// Spread retries of concurrent failures so they don't land together
+		d *= 1 + p.Jitter*(2*rand.Float64()-1)
+	}
+	return time.Duration(min(d, float64(p.MaxDelay)))
+}
+
+// ErrRetriesExhausted is returned by Backoff.Wait once the policy allows no
+// further attempts
+var ErrRetriesExhausted = errors.New("retries exhausted")
+
+// Backoff paces one retry loop:
+//
+//	b := policy.Begin()
+//	for {
+//		if err = attempt(); err == nil {
+//			break
+//		}
+//		if werr := b.Wait(ctx); werr != nil {
+//			return werr
+//		}
+//	}
+type Backoff struct {
+	policy   RetryPolicy
+	start    time.Time
+	attempts int // Attempts made since the last Reset
+}
+
+// Begin starts a retry loop, counting the attempt about to be made
+func (p RetryPolicy) Begin() *Backoff {
+	return &Backoff{policy: p.withDefaults(), start: time.Now(), attempts: 1}
+}
+
+// Wait sleeps until the next attempt is due. It returns ErrRetriesExhausted
+// when the attempts or the elapsed time are used up, and ctx's error if ctx
+// ends during the wait.
+func (b *Backoff) Wait(ctx context.Context) error {
+	if b.attempts >= b.policy.MaxAttempts {
+		return ErrRetriesExhausted
+	}
+	delay := b.policy.Delay(b.attempts)
+	if b.policy.MaxElapsed > 0 && time.Since(b.start)+delay > b.policy.MaxElapsed {
+		return ErrRetriesExhausted
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	b.attempts++
+	return nil
+}
+
+// Reset restarts attempt counting, for loops that resume where a failed
+// attempt left off and shouldn't give up while they make progress. The
+// elapsed time limit still counts from Begin.
+func (b *Backoff) Reset() {
+	b.attempts = 1
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err so Retry returns it without further attempts
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry calls fn until it succeeds, returns a Permanent error, the policy
+// gives up, or ctx ends, and returns fn's last error
+func Retry(ctx context.Context, p RetryPolicy, fn func() error) error {
+	b := p.Begin()
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+
+		if werr := b.Wait(ctx); werr != nil {
+			if errors.Is(werr, ErrRetriesExhausted) {
+				return fmt.Errorf("after %d attempts: %w", b.attempts, err)
+			}
+			return werr
+		}
+	}
+}
+
+type retryPolicyKey struct{}
+
+// WithRetryPolicy returns ctx carrying p for the retry loops of the
+// transfers and extractions made with it
+func WithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, p)
+}
+
+// RetryPolicyFrom returns the policy attached by WithRetryPolicy, or a
+// single attempt if there is none
+func RetryPolicyFrom(ctx context.Context) RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); ok {
+		return p
+	}
+	return RetryPolicy{MaxAttempts: 1}
+}
+
+// RetryContext is Retry with the policy attached to ctx. Without one, fn
+// runs once and its error is returned as is.
+func RetryContext(ctx context.Context, fn func() error) error {
+	if _, ok := ctx.Value(retryPolicyKey{}).(RetryPolicy); !ok {
+		err := fn()
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		return err
+	}
+	return Retry(ctx, RetryPolicyFrom(ctx), fn)
+}
@@ -0,0 +1,118 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 3}
+
+	tests := []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{retry: 1, expected: 100 * time.Millisecond},
+		{retry: 2, expected: 300 * time.Millisecond},
+		{retry: 3, expected: 900 * time.Millisecond},
+		{retry: 4, expected: time.Second}, // Capped
+		{retry: 50, expected: time.Second},
+	}
+	for _, tt := range tests {
+		if got := policy.Delay(tt.retry); got != tt.expected {
+			t.Errorf("Delay(%d) = %v; want %v", tt.retry, got, tt.expected)
+		}
+	}
+
+	// Jitter spreads delays within ±Jitter and never past the cap
+	policy.Jitter = 0.5
+	for range 100 {
+		if got := policy.Delay(2); got < 150*time.Millisecond || got > 450*time.Millisecond {
+			t.Fatalf("Delay(2) with 50%% jitter = %v; want 150ms-450ms", got)
+		}
+		if got := policy.Delay(4); got > time.Second {
+			t.Fatalf("Delay(4) with jitter = %v; want at most the 1s cap", got)
+		}
+	}
+}
+
+func TestRetry(t *testing.T) {
+	fast := RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxAttempts: 3}
+	errFlaky := errors.New("flaky")
+
+	tests := []struct {
+		name     string
+		policy   RetryPolicy
+		failures int   // Calls failing before one succeeds
+		err      error // Error of the failing calls
+		calls    int
+		wantErr  error
+	}{
+		{name: "Succeeds after retries", policy: fast, failures: 2, err: errFlaky, calls: 3},
+		{name: "Gives up after max attempts", policy: fast, failures: 5, err: errFlaky, calls: 3, wantErr: errFlaky},
+		{name: "Permanent error stops", policy: fast, failures: 5, err: Permanent(errFlaky), calls: 1, wantErr: errFlaky},
+		{
+			name:     "Gives up after max elapsed",
+			policy:   RetryPolicy{BaseDelay: 20 * time.Millisecond, Multiplier: 1, MaxAttempts: 100, MaxElapsed: 50 * time.Millisecond},
+			failures: 100,
+			err:      errFlaky,
+			calls:    3,
+			wantErr:  errFlaky,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := Retry(context.Background(), tt.policy, func() error {
+				calls++
+				if calls <= tt.failures {
+					return tt.err
+				}
+				return nil
+			})
+			if calls != tt.calls {
+				t.Errorf("calls = %d; want %d", calls, tt.calls)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil) != (err == nil) {
+				t.Errorf("Retry() error = %v; want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryCancelledDuringBackoff(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	calls := 0
+	err := Retry(ctx, RetryPolicy{BaseDelay: time.Hour, MaxDelay: time.Hour}, func() error {
+		calls++
+		return errors.New("down")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Retry() error = %v; want context.Canceled", err)
+	}
+	if calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("calls = %d after %v; want 1, returning as soon as ctx is cancelled", calls, time.Since(start))
+	}
+}
+
+func TestBackoffReset(t *testing.T) {
+	b := RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxAttempts: 2}.Begin()
+	ctx := context.Background()
+
+	if err := b.Wait(ctx); err != nil {
+		t.Fatalf("first Wait() = %v; want nil", err)
+	}
+	if err := b.Wait(ctx); !errors.Is(err, ErrRetriesExhausted) {
+		t.Fatalf("Wait() past max attempts = %v; want ErrRetriesExhausted", err)
+	}
+	b.Reset()
+	if err := b.Wait(ctx); err != nil {
+		t.Errorf("Wait() after Reset = %v; want nil", err)
+	}
+}
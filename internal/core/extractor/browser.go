@@ -21,6 +21,7 @@ import (
 type BrowserExtractor struct {
 	site    *config.Site
 	visible bool
+	markers []string // Extra login URL markers (see WithLoginMarkers)
 }
 
 // NewBrowserExtractor creates a new browser extractor for the given site
@@ -98,7 +99,17 @@ func (e *BrowserExtractor) Extract(rawURL string) (Media, error) {
 		}
 	}
 
+	// A redirect to a sign-in page (or a password form and no media) means
+	// anything found is the login page's own assets, not the real media
+	if info, err := page.Info(); err == nil {
+		if finalURL, err := url.Parse(info.URL); err == nil && redirectedToLogin(rawURL, finalURL, e.markers) {
+			return nil, fmt.Errorf("%w: redirected to %s", ErrLoginRequired, finalURL.Host+finalURL.Path)
+		}
+	}
 	if mediaURL == "" {
+		if hasPasswordField(page) {
+			return nil, fmt.Errorf("%w: page shows a login form", ErrLoginRequired)
+		}
 		return nil, fmt.Errorf("website not supported (no %s stream found)", e.site.Type)
 	}
 
@@ -274,6 +285,15 @@ func (e *BrowserExtractor) findInVideoPlayer(page *rod.Page, targetExt string) s
 	return result.Value.String()
 }
 
+// hasPasswordField reports whether the page shows a password input
+func hasPasswordField(page *rod.Page) bool {
+	result, err := page.Eval(`() => document.querySelector('input[type="password"]') !== null`)
+	if err != nil {
+		return false
+	}
+	return result.Value.Bool()
+}
+
 // findInPageSource searches for media URLs in page HTML/JavaScript source
 func (e *BrowserExtractor) findInPageSource(page *rod.Page, targetExt string) string {
 	html, err := page.HTML()
@@ -1,18 +1,44 @@
 package extractor
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"net/url"
 	"path"
 	"strings"
 	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
 )
 
 // DirectExtractor handles direct file URLs (mp4, mp3, jpg, etc.)
 // This is a fallback extractor that matches any URL not handled by others
 type DirectExtractor struct {
-	client *http.Client
+	client  *http.Client
+	markers []string // Extra login URL markers (see WithLoginMarkers)
+}
+
+// WithInsecureTLS returns a copy of ext that skips TLS certificate
+// verification, for extractors that fetch the media URL themselves (direct
+// files and m3u/pls playlists). Other extractors are returned unchanged.
+func WithInsecureTLS(ext Extractor) Extractor {
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	switch e := ext.(type) {
+	case *DirectExtractor:
+		return &DirectExtractor{client: client, markers: e.markers}
+	case *PlaylistExtractor:
+		return &PlaylistExtractor{client: client}
+	}
+	return ext
 }
 
 // Name returns the extractor name
@@ -64,6 +90,16 @@ func (d *DirectExtractor) Extract(urlStr string) (Media, error) {
 	contentType := resp.Header.Get("Content-Type")
 	finalURL := resp.Request.URL.String() // URL after redirects
 
+	// Bounced to a sign-in page instead of the file
+	if redirectedToLogin(urlStr, resp.Request.URL, d.markers) && strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+		return nil, fmt.Errorf("%w: redirected to %s", ErrLoginRequired, resp.Request.URL.Host+resp.Request.URL.Path)
+	}
+
+	// m3u/pls playlist served without a recognizable extension
+	if IsPlaylistContentType(contentType) {
+		return (&PlaylistExtractor{client: d.client}).Extract(finalURL)
+	}
+
 	// Determine media type and extension
 	mediaType, ext := detectMediaType(contentType, finalURL)
 
@@ -131,6 +167,63 @@ func (d *DirectExtractor) Extract(urlStr string) (Media, error) {
 	}
 }
 
+// downloadContentTypes are non-media Content-Types that still mean a file
+// rather than a web page
+var downloadContentTypes = map[string]bool{
+	"application/octet-stream":     true,
+	"application/pdf":              true,
+	"application/zip":              true,
+	"application/x-7z-compressed":  true,
+	"application/x-rar-compressed": true,
+	"application/gzip":             true,
+	"application/x-tar":            true,
+	"application/epub+zip":         true,
+}
+
+// probeTimeout bounds ProbeDirect, which runs before the job's extraction
+const probeTimeout = 10 * time.Second
+
+// ProbeDirect sends a HEAD request with client to a URL no extractor matched
+// and returns the extractor for it if the Content-Type shows a media file
+// rather than a page: m3u8 for HLS, playlist for m3u/pls, and direct for
+// other audio, video, image and download types. It returns nil for pages
+// and when the probe fails or ctx is done, leaving the URL to the browser
+// extractor. The client carries the caller's transport and redirect policy.
+func ProbeDirect(ctx context.Context, client *http.Client, urlStr string) Extractor {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "HEAD", urlStr, nil)
+	if err != nil {
+		return nil
+	}
+	req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if IsPlaylistContentType(contentType) {
+		return playlistExtractor
+	}
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch {
+	case contentType == "application/vnd.apple.mpegurl", contentType == "application/x-mpegurl":
+		return m3u8Extractor
+	case strings.HasPrefix(contentType, "video/"),
+		strings.HasPrefix(contentType, "audio/"),
+		strings.HasPrefix(contentType, "image/"),
+		downloadContentTypes[contentType]:
+		return fallbackExtractor
+	}
+	return nil
+}
+
 // detectMediaType determines the media type from Content-Type header or URL extension
 func detectMediaType(contentType, urlStr string) (MediaType, string) {
 	// First try Content-Type header
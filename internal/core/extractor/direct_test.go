@@ -0,0 +1,78 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+func TestProbeDirect(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != downloader.DefaultUserAgent {
+			t.Errorf("User-Agent = %q; want downloader.DefaultUserAgent", ua)
+		}
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+	}))
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		contentType string
+		expected    string // Extractor name ("" = none)
+	}{
+		{"video/mp4", "direct"},
+		{"audio/mpeg", "direct"},
+		{"image/jpeg", "direct"},
+		{"application/octet-stream", "direct"},
+		{"application/vnd.apple.mpegurl", "m3u8"},
+		{"audio/x-scpls", "playlist"},
+		{"text/html; charset=utf-8", ""},
+		{"application/json", ""},
+	}
+	for _, tt := range tests {
+		var got string
+		if ext := ProbeDirect(context.Background(), ts.Client(), ts.URL+"/file?type="+url.QueryEscape(tt.contentType)); ext != nil {
+			got = ext.Name()
+		}
+		if got != tt.expected {
+			t.Errorf("ProbeDirect(%s) = %q; want %q", tt.contentType, got, tt.expected)
+		}
+	}
+
+	if ext := ProbeDirect(context.Background(), ts.Client(), ts.URL+"/missing"); ext != nil {
+		t.Errorf("ProbeDirect(404) = %s; want nil", ext.Name())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if ext := ProbeDirect(ctx, ts.Client(), ts.URL+"/file?type=video%2Fmp4"); ext != nil {
+		t.Errorf("ProbeDirect(cancelled) = %s; want nil", ext.Name())
+	}
+}
+
+func TestDirectLoginMarkers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file.mp4" {
+			http.Redirect(w, r, "/members-only/welcome", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+	}))
+	t.Cleanup(ts.Close)
+
+	if _, err := (&DirectExtractor{client: ts.Client()}).Extract(ts.URL + "/file.mp4"); errors.Is(err, ErrLoginRequired) {
+		t.Errorf("Extract without markers = %v; want no login error", err)
+	}
+	ext := WithLoginMarkers(&DirectExtractor{client: ts.Client()}, []string{"members-only"})
+	if _, err := ext.Extract(ts.URL + "/file.mp4"); !errors.Is(err, ErrLoginRequired) {
+		t.Errorf("Extract with markers = %v; want ErrLoginRequired", err)
+	}
+}
@@ -0,0 +1,55 @@
+package extractor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatIDs returns an identifier for each of formats that stays the same
+// across extractions of the same media, unlike signed format URLs: the
+// quality label and extension (e.g., "720p-mp4"), with the bitrate and
+// then a counter added where formats would otherwise share one. Clients
+// pick a format from an extraction and pass its ID with the download.
+func FormatIDs(formats []VideoFormat) []string {
+	ids := make([]string, len(formats))
+	for i := range formats {
+		f := &formats[i]
+		label := strings.ToLower(strings.ReplaceAll(f.QualityLabel(), " ", ""))
+		if label == "unknown" {
+			label = "format"
+		}
+		ids[i] = label
+		if ext := strings.ToLower(f.Ext); ext != "" {
+			ids[i] += "-" + ext
+		}
+	}
+
+	// Tell formats sharing an ID apart by bitrate, then by position
+	for i, n := range countIDs(ids) {
+		if n > 1 && formats[i].Bitrate > 0 {
+			ids[i] += fmt.Sprintf("-%dk", formats[i].Bitrate/1000)
+		}
+	}
+	counts := countIDs(ids)
+	seen := make(map[string]int)
+	for i, id := range ids {
+		if counts[i] > 1 {
+			seen[id]++
+			ids[i] = fmt.Sprintf("%s-%d", id, seen[id])
+		}
+	}
+	return ids
+}
+
+// countIDs returns, for each of ids, how many times it occurs
+func countIDs(ids []string) []int {
+	total := make(map[string]int)
+	for _, id := range ids {
+		total[id]++
+	}
+	counts := make([]int, len(ids))
+	for i, id := range ids {
+		counts[i] = total[id]
+	}
+	return counts
+}
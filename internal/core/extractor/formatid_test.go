@@ -0,0 +1,30 @@
+package extractor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatIDs(t *testing.T) {
+	formats := []VideoFormat{
+		{URL: "a?sig=1", Height: 1080, Ext: "mp4", Bitrate: 4_000_000},
+		{URL: "b?sig=1", Height: 720, Ext: "mp4", Bitrate: 2_500_000},
+		{URL: "c?sig=1", Height: 720, Ext: "mp4", Bitrate: 1_500_000},
+		{URL: "d?sig=1", Quality: "HD", Ext: "webm"},
+		{URL: "e?sig=1", Ext: "m3u8"},
+		{URL: "f?sig=1", Ext: "m3u8"},
+	}
+	got := strings.Join(FormatIDs(formats), ",")
+	expected := "1080p-mp4,720p-mp4-2500k,720p-mp4-1500k,hd-webm,format-m3u8-1,format-m3u8-2"
+	if got != expected {
+		t.Errorf("FormatIDs = %s; want %s", got, expected)
+	}
+
+	// New signed URLs from a later extraction don't change the IDs
+	for i := range formats {
+		formats[i].URL = strings.Replace(formats[i].URL, "sig=1", "sig=2", 1)
+	}
+	if again := strings.Join(FormatIDs(formats), ","); again != expected {
+		t.Errorf("FormatIDs after re-extraction = %s; want %s", again, expected)
+	}
+}
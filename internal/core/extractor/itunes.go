@@ -1,13 +1,25 @@
 package extractor
 
 import (
+	"context"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
+// iTunesLookupURL is the iTunes lookup API endpoint
+var iTunesLookupURL = "https://itunes.apple.com/lookup"
+
+// episodesPerPage is how many feed items make up one page of a podcast's episodes
+const episodesPerPage = 50
+
 // iTunesExtractor handles Apple Podcasts downloads via iTunes API
 type iTunesExtractor struct{}
 
@@ -27,31 +39,176 @@ func (e *iTunesExtractor) Match(u *url.URL) bool {
 }
 
 func (e *iTunesExtractor) Extract(rawURL string) (Media, error) {
+	podcastID, episodeID, err := parsePodcastURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// If episode ID provided, fetch that specific episode
+	if episodeID != "" {
+		return e.extractEpisode(podcastID, episodeID)
+	}
+
+	// Otherwise list the podcast's first page of episodes
+	var first *PlaylistMedia
+	err = e.ExtractPages(context.Background(), rawURL, func(page *PlaylistMedia) error {
+		first = page
+		return ErrStopPaging
+	})
+	if err != nil && !errors.Is(err, ErrStopPaging) {
+		return nil, err
+	}
+	return first, nil
+}
+
+// Paginated reports whether rawURL is a podcast page rather than an episode
+func (e *iTunesExtractor) Paginated(rawURL string) bool {
+	_, episodeID, err := parsePodcastURL(rawURL)
+	return err == nil && episodeID == ""
+}
+
+// ExtractPages lists a podcast's episodes from its RSS feed in pages of
+// episodesPerPage, newest first as the feed orders them. The feed is read
+// as pages are handed out, so long-running shows are never loaded whole.
+func (e *iTunesExtractor) ExtractPages(ctx context.Context, rawURL string, page PageFunc) error {
+	podcastID, _, err := parsePodcastURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	podcast, err := e.lookupPodcast(ctx, podcastID)
+	if err != nil {
+		return err
+	}
+	if podcast.FeedURL == "" {
+		return fmt.Errorf("podcast %s has no public feed", podcastID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", podcast.FeedURL, nil)
+	if err != nil {
+		return fmt.Errorf("invalid feed URL: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch feed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	newPage := func(entries []PlaylistEntry) *PlaylistMedia {
+		return &PlaylistMedia{
+			ID:       podcastID,
+			Title:    SanitizeFilename(podcast.CollectionName),
+			Uploader: podcast.ArtistName,
+			Entries:  entries,
+		}
+	}
+
+	var entries []PlaylistEntry
+	listed := 0
+	decoder := xml.NewDecoder(resp.Body)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read feed: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "item" {
+			continue
+		}
+
+		var item podcastFeedItem
+		if err := decoder.DecodeElement(&item, &start); err != nil {
+			return fmt.Errorf("failed to read feed: %w", err)
+		}
+		if item.Enclosure.URL == "" {
+			continue
+		}
+		entries = append(entries, PlaylistEntry{
+			URL:      item.Enclosure.URL,
+			Title:    strings.TrimSpace(item.Title),
+			Duration: parseFeedDuration(item.Duration),
+		})
+		listed++
+
+		if len(entries) == episodesPerPage {
+			if err := page(newPage(entries)); err != nil {
+				return err
+			}
+			entries = nil
+		}
+	}
+
+	if listed == 0 {
+		return fmt.Errorf("podcast feed lists no episodes")
+	}
+	if len(entries) > 0 {
+		return page(newPage(entries))
+	}
+	return nil
+}
+
+// lookupPodcast fetches a podcast's details, including its feed URL
+func (e *iTunesExtractor) lookupPodcast(ctx context.Context, podcastID string) (*iTunesLookupResult, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", iTunesLookupURL+"?id="+url.QueryEscape(podcastID), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result iTunesLookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	for _, item := range result.Results {
+		if item.Kind == "podcast" {
+			return &item, nil
+		}
+	}
+	return nil, fmt.Errorf("podcast not found")
+}
+
+// parsePodcastURL returns the podcast ID of an Apple Podcasts URL and the
+// episode ID if it links to a single episode
+func parsePodcastURL(rawURL string) (podcastID, episodeID string, err error) {
 	u, err := url.Parse(rawURL)
 	if err != nil {
-		return nil, fmt.Errorf("invalid URL: %w", err)
+		return "", "", fmt.Errorf("invalid URL: %w", err)
 	}
 
 	matches := applePodcastRegex.FindStringSubmatch(u.Path)
 	if len(matches) < 2 {
-		return nil, fmt.Errorf("could not extract podcast ID from URL")
+		return "", "", fmt.Errorf("could not extract podcast ID from URL")
 	}
+	return matches[1], u.Query().Get("i"), nil
+}
 
-	podcastID := matches[1]
-	episodeID := u.Query().Get("i")
-
-	// If episode ID provided, fetch that specific episode
-	if episodeID != "" {
-		return e.extractEpisode(podcastID, episodeID)
+// parseFeedDuration parses an itunes:duration value, given in seconds or as
+// [HH:]MM:SS, returning 0 if it's missing or malformed
+func parseFeedDuration(value string) int {
+	seconds := 0
+	for part := range strings.SplitSeq(strings.TrimSpace(value), ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
 	}
-
-	// Otherwise list episodes from the podcast
-	return e.listEpisodes()
+	return seconds
 }
 
 func (e *iTunesExtractor) extractEpisode(podcastID, episodeID string) (*AudioMedia, error) {
 	// Lookup episode by ID
-	url := fmt.Sprintf("https://itunes.apple.com/lookup?id=%s&entity=podcastEpisode", podcastID)
+	url := fmt.Sprintf("%s?id=%s&entity=podcastEpisode", iTunesLookupURL, podcastID)
 
 	resp, err := http.Get(url)
 	if err != nil {
@@ -76,12 +233,18 @@ func (e *iTunesExtractor) extractEpisode(podcastID, episodeID string) (*AudioMed
 			filename := SanitizeFilename(fmt.Sprintf("%s - %s", item.CollectionName, item.TrackName))
 
 			return &AudioMedia{
-				ID:       episodeID,
-				Title:    filename,
-				Uploader: item.ArtistName,
-				Duration: item.TrackTimeMillis / 1000,
-				URL:      item.EpisodeURL,
-				Ext:      ext,
+				ID:        episodeID,
+				Title:     filename,
+				Uploader:  item.ArtistName,
+				Duration:  item.TrackTimeMillis / 1000,
+				URL:       item.EpisodeURL,
+				Ext:       ext,
+				Thumbnail: item.ArtworkURL600,
+				Thumbnails: []Thumbnail{
+					{URL: item.ArtworkURL60, Width: 60, Height: 60},
+					{URL: item.ArtworkURL160, Width: 160, Height: 160},
+					{URL: item.ArtworkURL600, Width: 600, Height: 600},
+				},
 			}, nil
 		}
 	}
@@ -89,12 +252,6 @@ func (e *iTunesExtractor) extractEpisode(podcastID, episodeID string) (*AudioMed
 	return nil, fmt.Errorf("episode not found")
 }
 
-func (e *iTunesExtractor) listEpisodes() (*AudioMedia, error) {
-	// Return error suggesting to use specific episode URL
-	// We could list episodes here but for now keep it simple
-	return nil, fmt.Errorf("please provide a specific episode URL. Use 'vget search --podcast <name>' to find episodes, or visit the podcast page and select an episode")
-}
-
 // iTunes API response structures
 type iTunesLookupResponse struct {
 	ResultCount int                  `json:"resultCount"`
@@ -104,6 +261,7 @@ type iTunesLookupResponse struct {
 type iTunesLookupResult struct {
 	WrapperType          string `json:"wrapperType"`
 	Kind                 string `json:"kind"`
+	FeedURL              string `json:"feedUrl"`
 	TrackID              int    `json:"trackId"`
 	ArtistName           string `json:"artistName"`
 	CollectionName       string `json:"collectionName"`
@@ -112,6 +270,18 @@ type iTunesLookupResult struct {
 	EpisodeURL           string `json:"episodeUrl"`
 	EpisodeFileExtension string `json:"episodeFileExtension"`
 	ReleaseDate          string `json:"releaseDate"`
+	ArtworkURL60         string `json:"artworkUrl60"`
+	ArtworkURL160        string `json:"artworkUrl160"`
+	ArtworkURL600        string `json:"artworkUrl600"`
+}
+
+// podcastFeedItem is an episode in a podcast's RSS feed
+type podcastFeedItem struct {
+	Title     string `xml:"title"`
+	Duration  string `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Enclosure struct {
+		URL string `xml:"url,attr"`
+	} `xml:"enclosure"`
 }
 
 func init() {
@@ -0,0 +1,81 @@
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestITunesExtractPages(t *testing.T) {
+	var feed strings.Builder
+	feed.WriteString(`<rss xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"><channel><title>Show</title>`)
+	for i := range 120 {
+		fmt.Fprintf(&feed, `<item><title>Episode %d</title><itunes:duration>1:02:03</itunes:duration><enclosure url="https://cdn.example.com/%d.mp3" type="audio/mpeg"/></item>`, i, i)
+	}
+	feed.WriteString(`<item><title>Trailer without audio</title></item></channel></rss>`)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/lookup":
+			if r.URL.Query().Get("id") != "173001861" {
+				t.Errorf("looked up id %q", r.URL.Query().Get("id"))
+			}
+			fmt.Fprintf(w, `{"resultCount":1,"results":[{"wrapperType":"track","kind":"podcast","collectionName":"Hardcore History","artistName":"Dan Carlin","feedUrl":"%s/feed"}]}`, ts.URL)
+		case "/feed":
+			w.Write([]byte(feed.String()))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	defer func(old string) { iTunesLookupURL = old }(iTunesLookupURL)
+	iTunesLookupURL = ts.URL + "/lookup"
+
+	e := &iTunesExtractor{}
+	podcastURL := "https://podcasts.apple.com/us/podcast/dan-carlins-hardcore-history/id173001861"
+	if !e.Paginated(podcastURL) || e.Paginated(podcastURL+"?i=1000682587885") {
+		t.Error("Paginated should hold for podcast pages only, not episodes")
+	}
+
+	var sizes []int
+	err := e.ExtractPages(context.Background(), podcastURL, func(page *PlaylistMedia) error {
+		sizes = append(sizes, len(page.Entries))
+		if page.ID != "173001861" || page.Title != "Hardcore History" || page.Uploader != "Dan Carlin" {
+			t.Errorf("page = %+v", page)
+		}
+		if first := page.Entries[0]; len(sizes) == 1 && (first.URL != "https://cdn.example.com/0.mp3" || first.Title != "Episode 0" || first.Duration != 3723) {
+			t.Errorf("first entry = %+v", first)
+		}
+		return nil
+	})
+	if err != nil || fmt.Sprint(sizes) != "[50 50 20]" {
+		t.Errorf("ExtractPages = %v with page sizes %v; want [50 50 20]", err, sizes)
+	}
+
+	media, err := e.Extract(podcastURL)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if m, ok := media.(*PlaylistMedia); !ok || len(m.Entries) != episodesPerPage {
+		t.Errorf("Extract = %#v; want the first page", media)
+	}
+}
+
+func TestParseFeedDuration(t *testing.T) {
+	tests := map[string]int{
+		"3723":     3723,
+		"62:03":    3723,
+		"1:02:03":  3723,
+		"":         0,
+		"about 1h": 0,
+	}
+	for value, want := range tests {
+		if got := parseFeedDuration(value); got != want {
+			t.Errorf("parseFeedDuration(%q) = %d; want %d", value, got, want)
+		}
+	}
+}
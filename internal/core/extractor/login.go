@@ -0,0 +1,58 @@
+package extractor
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// ErrLoginRequired reports that a login or paywall page was served in
+// place of the media, so the download needs credentials (e.g., cookies)
+var ErrLoginRequired = errors.New("login required")
+
+// DefaultLoginMarkers are URL path fragments identifying login and paywall pages
+var DefaultLoginMarkers = []string{
+	"login", "log-in", "signin", "sign-in", "sign_in",
+	"auth/", "paywall", "subscribe",
+}
+
+// IsLoginURL reports whether u's path contains one of DefaultLoginMarkers
+// or the extra markers (case-insensitive)
+func IsLoginURL(u *url.URL, extra []string) bool {
+	if u == nil {
+		return false
+	}
+	p := strings.ToLower(u.Path)
+	for _, markers := range [][]string{DefaultLoginMarkers, extra} {
+		for _, marker := range markers {
+			if marker != "" && strings.Contains(p, strings.ToLower(marker)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// redirectedToLogin reports whether a request for rawURL ended up on a
+// different URL that looks like a login page, by the default markers or
+// the extra ones
+func redirectedToLogin(rawURL string, final *url.URL, extra []string) bool {
+	return final != nil && final.String() != rawURL && IsLoginURL(final, extra)
+}
+
+// WithLoginMarkers returns a copy of ext that also treats redirects to URLs
+// containing markers as login pages, for extractors that check redirects
+// themselves (direct files and the browser). Other extractors are returned
+// unchanged.
+func WithLoginMarkers(ext Extractor, markers []string) Extractor {
+	if len(markers) == 0 {
+		return ext
+	}
+	switch e := ext.(type) {
+	case *DirectExtractor:
+		return &DirectExtractor{client: e.client, markers: markers}
+	case *BrowserExtractor:
+		return &BrowserExtractor{site: e.site, visible: e.visible, markers: markers}
+	}
+	return ext
+}
@@ -0,0 +1,293 @@
+package extractor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPlaylistSize caps how much of a playlist file is read (playlists are small text files)
+const maxPlaylistSize = 1 << 20
+
+// PlaylistEntry is a single item listed in an m3u/pls playlist file
+type PlaylistEntry struct {
+	URL      string
+	Title    string
+	Duration int // seconds (-1 or 0 if unknown)
+}
+
+// Ext returns the file extension of the entry URL, defaulting to "mp3"
+// since most playlist files point at audio streams
+func (e PlaylistEntry) Ext() string {
+	if u, err := url.Parse(e.URL); err == nil {
+		if ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), ".")); ext != "" {
+			return ext
+		}
+	}
+	return "mp3"
+}
+
+// PlaylistMedia represents a playlist file (m3u/pls) listing direct media URLs
+type PlaylistMedia struct {
+	ID       string
+	Title    string
+	Uploader string
+	Entries  []PlaylistEntry
+}
+
+func (p *PlaylistMedia) GetID() string       { return p.ID }
+func (p *PlaylistMedia) GetTitle() string    { return p.Title }
+func (p *PlaylistMedia) GetUploader() string { return p.Uploader }
+func (p *PlaylistMedia) Type() MediaType     { return MediaTypeAudio }
+
+// ErrStopPaging is returned by a PageFunc to end ExtractPages early without error
+var ErrStopPaging = errors.New("stop paging")
+
+// PageFunc receives one page of a paginated listing, with the playlist's
+// ID and title and that page's entries. Returning an error stops paging.
+type PageFunc func(page *PlaylistMedia) error
+
+// PagedExtractor is implemented by extractors for listings that span several
+// pages, such as channels and profiles, so entries can be downloaded as
+// pages arrive instead of after the whole listing is fetched. Extract
+// returns only the first page.
+type PagedExtractor interface {
+	Extractor
+
+	// Paginated reports whether url is a listing to be read with
+	// ExtractPages; other URLs (e.g., single items) go through Extract
+	Paginated(url string) bool
+
+	// ExtractPages calls page for each page of the listing at url, in
+	// order, until the listing ends, page returns an error, or ctx is done.
+	// It returns page's error as is.
+	ExtractPages(ctx context.Context, url string, page PageFunc) error
+}
+
+// PlaylistExtractor handles m3u and pls playlist files
+type PlaylistExtractor struct {
+	client *http.Client
+}
+
+// Name returns the extractor name
+func (p *PlaylistExtractor) Name() string {
+	return "playlist"
+}
+
+// Match checks if the URL looks like an m3u/pls playlist file
+func (p *PlaylistExtractor) Match(u *url.URL) bool {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	ext := strings.ToLower(path.Ext(u.Path))
+	return ext == ".m3u" || ext == ".pls"
+}
+
+// Extract downloads the playlist file and parses its entries.
+// HLS playlists served under a .m3u name are handed back as an m3u8 video.
+func (p *PlaylistExtractor) Extract(urlStr string) (Media, error) {
+	if p.client == nil {
+		p.client = &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				Proxy: http.ProxyFromEnvironment,
+			},
+		}
+	}
+
+	req, err := http.NewRequest("GET", urlStr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch playlist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPlaylistSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read playlist: %w", err)
+	}
+
+	finalURL := resp.Request.URL.String()
+	id := generateID(finalURL)
+
+	if IsHLSPlaylist(data) {
+		return &VideoMedia{
+			ID:      id,
+			Title:   id,
+			Formats: []VideoFormat{{URL: finalURL, Ext: "m3u8"}},
+		}, nil
+	}
+
+	entries, err := ParsePlaylist(data, finalURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PlaylistMedia{
+		ID:      id,
+		Title:   id,
+		Entries: entries,
+	}, nil
+}
+
+// IsPlaylistContentType reports whether a Content-Type denotes an m3u/pls
+// playlist file (as opposed to an HLS stream)
+func IsPlaylistContentType(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	switch contentType {
+	case "audio/x-mpegurl", "audio/mpegurl", "audio/x-scpls", "audio/scpls", "application/pls+xml":
+		return true
+	}
+	return false
+}
+
+// IsHLSPlaylist reports whether an extended m3u file is an HLS media or master playlist
+func IsHLSPlaylist(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("#EXTM3U")) &&
+		bytes.Contains(data, []byte("#EXT-X-"))
+}
+
+// ParsePlaylist parses an m3u (plain or extended) or pls playlist.
+// Relative entry URLs are resolved against baseURL.
+func ParsePlaylist(data []byte, baseURL string) ([]PlaylistEntry, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // UTF-8 BOM
+
+	var entries []PlaylistEntry
+	if bytes.HasPrefix(bytes.ToLower(bytes.TrimSpace(data)), []byte("[playlist]")) {
+		entries = parsePLS(data)
+	} else {
+		entries = parseM3U(data)
+	}
+
+	base, _ := url.Parse(baseURL)
+	var result []PlaylistEntry
+	for _, entry := range entries {
+		u, err := url.Parse(entry.URL)
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			continue
+		}
+		entry.URL = u.String()
+		result = append(result, entry)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("playlist contains no media URLs")
+	}
+	return result, nil
+}
+
+// parseM3U parses plain and extended (#EXTINF) m3u playlists
+func parseM3U(data []byte) []PlaylistEntry {
+	var entries []PlaylistEntry
+	var pending PlaylistEntry
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			// #EXTINF:<duration>[ attributes],<title>
+			if info, ok := strings.CutPrefix(line, "#EXTINF:"); ok {
+				durationPart, title, _ := strings.Cut(info, ",")
+				if fields := strings.Fields(durationPart); len(fields) > 0 {
+					if d, err := strconv.ParseFloat(fields[0], 64); err == nil {
+						pending.Duration = int(d)
+					}
+				}
+				pending.Title = strings.TrimSpace(title)
+			}
+			continue
+		}
+
+		pending.URL = line
+		entries = append(entries, pending)
+		pending = PlaylistEntry{}
+	}
+
+	return entries
+}
+
+// parsePLS parses pls playlists (File1=..., Title1=..., Length1=...)
+func parsePLS(data []byte) []PlaylistEntry {
+	byIndex := map[int]*PlaylistEntry{}
+	var order []int
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		var field string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+				break
+			}
+		}
+		if field == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(key[len(field):])
+		if err != nil {
+			continue
+		}
+		entry, ok := byIndex[n]
+		if !ok {
+			entry = &PlaylistEntry{}
+			byIndex[n] = entry
+			order = append(order, n)
+		}
+
+		switch field {
+		case "file":
+			entry.URL = value
+		case "title":
+			entry.Title = value
+		case "length":
+			entry.Duration, _ = strconv.Atoi(value)
+		}
+	}
+
+	slices.Sort(order)
+	var entries []PlaylistEntry
+	for _, n := range order {
+		if byIndex[n].URL != "" {
+			entries = append(entries, *byIndex[n])
+		}
+	}
+	return entries
+}
@@ -0,0 +1,71 @@
+package extractor
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlaylist(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []PlaylistEntry
+	}{
+		{
+			name:  "Plain m3u",
+			input: "http://radio.example.com/a.mp3\n\n# comment\nhttp://radio.example.com/b.aac\n",
+			expected: []PlaylistEntry{
+				{URL: "http://radio.example.com/a.mp3"},
+				{URL: "http://radio.example.com/b.aac"},
+			},
+		},
+		{
+			name:  "Extended m3u with relative entry",
+			input: "#EXTM3U\r\n#EXTINF:123 tvg-id=\"x\",Artist - Song\r\nsong.mp3\r\n#EXTINF:-1,Live\r\nhttps://live.example.com/stream\r\n",
+			expected: []PlaylistEntry{
+				{URL: "https://cdn.example.com/lists/song.mp3", Title: "Artist - Song", Duration: 123},
+				{URL: "https://live.example.com/stream", Title: "Live", Duration: -1},
+			},
+		},
+		{
+			name:  "pls out of order",
+			input: "[playlist]\nNumberOfEntries=2\nFile2=http://b.example.com/2.ogg\nTitle2=Two\nFile1=http://a.example.com/1.mp3\nTitle1=One\nLength1=-1\nVersion=2\n",
+			expected: []PlaylistEntry{
+				{URL: "http://a.example.com/1.mp3", Title: "One", Duration: -1},
+				{URL: "http://b.example.com/2.ogg", Title: "Two"},
+			},
+		},
+		{
+			name:  "Non-http entries skipped",
+			input: "file:///etc/passwd\nhttp://ok.example.com/a.mp3\n",
+			expected: []PlaylistEntry{
+				{URL: "http://ok.example.com/a.mp3"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePlaylist([]byte(tt.input), "https://cdn.example.com/lists/radio.m3u")
+			if err != nil {
+				t.Fatalf("ParsePlaylist() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParsePlaylist() = %+v; want %+v", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ParsePlaylist([]byte("#EXTM3U\n"), ""); err == nil {
+		t.Errorf("ParsePlaylist() on empty playlist returned no error")
+	}
+}
+
+func TestIsHLSPlaylist(t *testing.T) {
+	if !IsHLSPlaylist([]byte("#EXTM3U\n#EXT-X-VERSION:3\n#EXTINF:10,\nseg0.ts\n")) {
+		t.Errorf("HLS media playlist not detected")
+	}
+	if IsHLSPlaylist([]byte("#EXTM3U\n#EXTINF:-1,Radio\nhttp://radio.example.com/live\n")) {
+		t.Errorf("extended m3u detected as HLS")
+	}
+}
@@ -0,0 +1,168 @@
+package extractor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+// RateLimitState is the last rate limit a host reported in its
+// X-RateLimit-* (or RateLimit-*) response headers
+type RateLimitState struct {
+	Limit     int       `json:"limit"`     // Requests per window (0 = not reported)
+	Remaining int       `json:"remaining"` // Requests left, less the ones sent since
+	Reset     time.Time `json:"reset"`     // When the window resets
+}
+
+// MaxRateLimitWait caps how long a request waits for a host's rate limit
+// window to reset; requests that would wait longer fail instead
+const MaxRateLimitWait = 15 * time.Minute
+
+// lowRateLimit is how few requests left count as running low when the host
+// doesn't report its limit
+const lowRateLimit = 5
+
+var rateLimits = struct {
+	mu    sync.Mutex
+	hosts map[string]RateLimitState
+}{hosts: make(map[string]RateLimitState)}
+
+// ObserveRateLimit records the rate limit headers of resp for its host
+func ObserveRateLimit(resp *http.Response) {
+	if resp == nil || resp.Request == nil {
+		return
+	}
+	state, ok := parseRateLimit(resp.Header, time.Now())
+	if !ok {
+		return
+	}
+	rateLimits.mu.Lock()
+	defer rateLimits.mu.Unlock()
+	rateLimits.hosts[resp.Request.URL.Host] = state
+}
+
+// parseRateLimit reads X-RateLimit-Remaining and X-RateLimit-Reset (or the
+// unprefixed RateLimit-* draft headers). Reset may be a Unix time, as on
+// Twitter and GitHub, or a number of seconds from now.
+func parseRateLimit(h http.Header, now time.Time) (RateLimitState, bool) {
+	for _, prefix := range []string{"X-RateLimit-", "RateLimit-"} {
+		remaining, err := strconv.Atoi(strings.TrimSpace(h.Get(prefix + "Remaining")))
+		if err != nil {
+			continue
+		}
+		reset, err := strconv.ParseInt(strings.TrimSpace(h.Get(prefix+"Reset")), 10, 64)
+		if err != nil || reset < 0 {
+			continue
+		}
+		state := RateLimitState{Remaining: remaining}
+		state.Limit, _ = strconv.Atoi(strings.TrimSpace(h.Get(prefix + "Limit")))
+		if reset > 1_000_000_000 {
+			state.Reset = time.Unix(reset, 0)
+		} else {
+			state.Reset = now.Add(time.Duration(reset) * time.Second)
+		}
+		return state, true
+	}
+	return RateLimitState{}, false
+}
+
+// WaitRateLimit delays a request to host according to its last reported
+// rate limit: until the reset when no requests are left, or spread evenly
+// over the rest of the window when few are. It fails if that would take
+// longer than MaxRateLimitWait or ctx ends first.
+func WaitRateLimit(ctx context.Context, host string) error {
+	rateLimits.mu.Lock()
+	state, ok := rateLimits.hosts[host]
+	now := time.Now()
+	if !ok || !now.Before(state.Reset) {
+		delete(rateLimits.hosts, host)
+		rateLimits.mu.Unlock()
+		return nil
+	}
+	delay := rateLimitDelay(state, now)
+	if delay > MaxRateLimitWait {
+		rateLimits.mu.Unlock()
+		return fmt.Errorf("%s rate limit exhausted until %s", host, state.Reset.Format(time.RFC3339))
+	}
+	// Count this request so concurrent ones don't all see the same budget
+	if state.Remaining > 0 {
+		state.Remaining--
+		rateLimits.hosts[host] = state
+	}
+	rateLimits.mu.Unlock()
+
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// rateLimitDelay returns how long the next request should wait
+func rateLimitDelay(state RateLimitState, now time.Time) time.Duration {
+	untilReset := state.Reset.Sub(now)
+	if state.Remaining <= 0 {
+		return untilReset
+	}
+	low := lowRateLimit
+	if state.Limit > 0 {
+		low = max(state.Limit/10, 1)
+	}
+	if state.Remaining > low {
+		return 0
+	}
+	return untilReset / time.Duration(state.Remaining+1)
+}
+
+// RateLimits returns the hosts whose last reported rate limit window is
+// still open
+func RateLimits() map[string]RateLimitState {
+	rateLimits.mu.Lock()
+	defer rateLimits.mu.Unlock()
+	now := time.Now()
+	maps.DeleteFunc(rateLimits.hosts, func(_ string, state RateLimitState) bool {
+		return !now.Before(state.Reset)
+	})
+	return maps.Clone(rateLimits.hosts)
+}
+
+// doRateLimited sends req with client after waiting for its host's rate
+// limit, and records the limit the response reports. 429 responses are
+// retried with the retry policy of req's context; once it gives up, the
+// last 429 response is returned. req must have no body.
+func doRateLimited(client *http.Client, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	backoff := downloader.RetryPolicyFrom(ctx).Begin()
+	for {
+		if err := WaitRateLimit(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		ObserveRateLimit(resp)
+		if err != nil || resp.StatusCode != http.StatusTooManyRequests {
+			return resp, err
+		}
+		if err := backoff.Wait(ctx); err != nil {
+			if errors.Is(err, downloader.ErrRetriesExhausted) {
+				return resp, nil
+			}
+			resp.Body.Close()
+			return nil, err
+		}
+		resp.Body.Close()
+	}
+}
@@ -0,0 +1,131 @@
+package extractor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	h := http.Header{}
+	h.Set("X-RateLimit-Limit", "150")
+	h.Set("X-RateLimit-Remaining", "3")
+	h.Set("X-RateLimit-Reset", "1700000900")
+	state, ok := parseRateLimit(h, now)
+	if !ok || state.Limit != 150 || state.Remaining != 3 || !state.Reset.Equal(now.Add(15*time.Minute)) {
+		t.Errorf("epoch reset = %+v, %v; want 150/3 resetting in 15m", state, ok)
+	}
+
+	h = http.Header{}
+	h.Set("RateLimit-Remaining", "0")
+	h.Set("RateLimit-Reset", "30")
+	if state, ok := parseRateLimit(h, now); !ok || !state.Reset.Equal(now.Add(30*time.Second)) {
+		t.Errorf("delta reset = %+v, %v; want resetting in 30s", state, ok)
+	}
+
+	if _, ok := parseRateLimit(http.Header{}, now); ok {
+		t.Error("parsed a rate limit from no headers")
+	}
+}
+
+func TestRateLimitDelay(t *testing.T) {
+	now := time.Now()
+	reset := now.Add(time.Minute)
+	tests := []struct {
+		state    RateLimitState
+		expected time.Duration
+	}{
+		{RateLimitState{Limit: 100, Remaining: 50, Reset: reset}, 0},
+		{RateLimitState{Limit: 100, Remaining: 5, Reset: reset}, 10 * time.Second},
+		{RateLimitState{Remaining: 10, Reset: reset}, 0},
+		{RateLimitState{Remaining: 0, Reset: reset}, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := rateLimitDelay(tt.state, now); got != tt.expected {
+			t.Errorf("rateLimitDelay(%+v) = %v; want %v", tt.state, got, tt.expected)
+		}
+	}
+}
+
+func TestDoRateLimited(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	}))
+	t.Cleanup(ts.Close)
+	u, _ := url.Parse(ts.URL)
+	host := u.Host
+
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	resp, err := doRateLimited(ts.Client(), req)
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	resp.Body.Close()
+	if state, ok := RateLimits()[host]; !ok || state.Remaining != 0 {
+		t.Fatalf("RateLimits()[%s] = %+v, %v; want the exhausted limit", host, state, ok)
+	}
+
+	// The window resets beyond MaxRateLimitWait, so the next request fails fast
+	if err := WaitRateLimit(context.Background(), host); err == nil {
+		t.Error("WaitRateLimit with the limit exhausted for an hour succeeded")
+	}
+}
+
+func TestTwitterRateLimitWaitCancelled(t *testing.T) {
+	host := "cdn.syndication.twimg.com"
+	rateLimits.mu.Lock()
+	rateLimits.hosts[host] = RateLimitState{Remaining: 0, Reset: time.Now().Add(10 * time.Minute)}
+	rateLimits.mu.Unlock()
+	t.Cleanup(func() {
+		rateLimits.mu.Lock()
+		delete(rateLimits.hosts, host)
+		rateLimits.mu.Unlock()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := (&TwitterExtractor{}).ExtractContext(ctx, "https://x.com/user/status/123"); err == nil {
+		t.Error("ExtractContext with the context done succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("ExtractContext returned after %v; want it to stop with the context", elapsed)
+	}
+}
+
+func TestDoRateLimitedRetries429(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) < 3 {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	// Without a policy the 429 is returned at once
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	resp, err := doRateLimited(ts.Client(), req)
+	if err != nil || resp.StatusCode != http.StatusTooManyRequests || requests.Load() != 1 {
+		t.Fatalf("without a policy: %v, %v after %d requests; want one 429", resp, err, requests.Load())
+	}
+	resp.Body.Close()
+
+	requests.Store(0)
+	ctx := downloader.WithRetryPolicy(context.Background(), downloader.RetryPolicy{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, MaxAttempts: 5})
+	req, _ = http.NewRequestWithContext(ctx, "GET", ts.URL, nil)
+	resp, err = doRateLimited(ts.Client(), req)
+	if err != nil || resp.StatusCode != http.StatusOK || requests.Load() != 3 {
+		t.Fatalf("with a policy: %v, %v after %d requests; want 200 after 3", resp, err, requests.Load())
+	}
+	resp.Body.Close()
+}
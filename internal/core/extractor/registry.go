@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/url"
 	"path"
+	"slices"
 	"strings"
 )
 
@@ -16,6 +17,9 @@ var fallbackExtractor Extractor
 // m3u8Extractor handles m3u8 URLs specifically (no HEAD request validation)
 var m3u8Extractor = &M3U8Extractor{}
 
+// playlistExtractor handles m3u/pls playlist files listing direct media URLs
+var playlistExtractor = &PlaylistExtractor{}
+
 // directDownloadExtensions are file extensions that bypass host-based extractors
 var directDownloadExtensions = map[string]bool{
 	// Video
@@ -24,6 +28,8 @@ var directDownloadExtensions = map[string]bool{
 	// Audio
 	".mp3": true, ".m4a": true, ".aac": true, ".ogg": true, ".wav": true,
 	".flac": true, ".wma": true,
+	// Playlists
+	".m3u": true, ".pls": true,
 	// Image
 	".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".webp": true,
 	".bmp": true, ".svg": true, ".ico": true, ".tiff": true,
@@ -98,9 +104,12 @@ func Match(rawURL string) Extractor {
 	ext := strings.ToLower(path.Ext(u.Path))
 	if directDownloadExtensions[ext] {
 		// Use specialized m3u8 extractor for HLS streams (no HEAD validation needed)
-		if ext == ".m3u8" || ext == ".m3u" {
+		if ext == ".m3u8" {
 			return m3u8Extractor
 		}
+		if ext == ".m3u" || ext == ".pls" {
+			return playlistExtractor
+		}
 		return fallbackExtractor
 	}
 
@@ -128,6 +137,46 @@ func Match(rawURL string) Extractor {
 	return nil
 }
 
+// ByName returns the extractor with the given name, or nil if there is none.
+// Besides host-registered extractors this covers "direct", "m3u8", "playlist"
+// and the generic "browser" extractor.
+func ByName(name string) Extractor {
+	switch name {
+	case "":
+		return nil
+	case "m3u8":
+		return m3u8Extractor
+	case "playlist":
+		return playlistExtractor
+	case "browser":
+		return NewGenericBrowserExtractor(false)
+	}
+	if fallbackExtractor != nil && fallbackExtractor.Name() == name {
+		return fallbackExtractor
+	}
+	for _, e := range extractorsByHost {
+		if e.Name() == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Names returns the sorted names accepted by ByName
+func Names() []string {
+	names := []string{"browser", "m3u8", "playlist"}
+	if fallbackExtractor != nil {
+		names = append(names, fallbackExtractor.Name())
+	}
+	for _, e := range List() {
+		if !slices.Contains(names, e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
 // List returns all unique registered extractors
 func List() []Extractor {
 	seen := make(map[string]bool)
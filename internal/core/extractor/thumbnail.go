@@ -0,0 +1,46 @@
+package extractor
+
+// Thumbnail is one size of a media's thumbnail or cover art
+type Thumbnail struct {
+	URL    string
+	Width  int // 0 if unknown
+	Height int // 0 if unknown
+}
+
+// BestThumbnail picks the thumbnail to save from the sizes a source offers:
+// the largest, or with maxHeight set the largest no taller than that (the
+// smallest when all are taller). Sizes of unknown dimensions are only
+// picked when no size is known, and without any sizes thumbnail is used.
+func BestThumbnail(thumbnail string, thumbnails []Thumbnail, maxHeight int) string {
+	var best *Thumbnail
+	for i := range thumbnails {
+		t := &thumbnails[i]
+		if t.URL != "" && (best == nil || betterThumbnail(*t, *best, maxHeight)) {
+			best = t
+		}
+	}
+	if best == nil {
+		return thumbnail
+	}
+	return best.URL
+}
+
+// betterThumbnail reports whether a suits maxHeight (0 = none) better than b
+func betterThumbnail(a, b Thumbnail, maxHeight int) bool {
+	if (a.Height > 0) != (b.Height > 0) {
+		return a.Height > 0
+	}
+	if maxHeight > 0 {
+		aFits, bFits := a.Height <= maxHeight, b.Height <= maxHeight
+		if aFits != bFits {
+			return aFits
+		}
+		if !aFits {
+			return a.Height < b.Height
+		}
+	}
+	if a.Height != b.Height {
+		return a.Height > b.Height
+	}
+	return a.Width > b.Width
+}
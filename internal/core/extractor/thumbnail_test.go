@@ -0,0 +1,38 @@
+package extractor
+
+import "testing"
+
+func TestBestThumbnail(t *testing.T) {
+	sizes := []Thumbnail{
+		{URL: "small", Width: 160, Height: 90},
+		{URL: "unknown"},
+		{URL: "large", Width: 1920, Height: 1080},
+		{URL: "medium", Width: 640, Height: 360},
+	}
+	tests := []struct {
+		name       string
+		thumbnails []Thumbnail
+		maxHeight  int
+		expected   string
+	}{
+		{"largest", sizes, 0, "large"},
+		{"largest within limit", sizes, 720, "medium"},
+		{"smallest when all are taller", sizes, 50, "small"},
+		{"unknown sizes only", []Thumbnail{{URL: "unknown"}}, 0, "unknown"},
+		{"no sizes", nil, 0, "fallback"},
+	}
+	for _, tt := range tests {
+		if got := BestThumbnail("fallback", tt.thumbnails, tt.maxHeight); got != tt.expected {
+			t.Errorf("%s: BestThumbnail = %q; want %q", tt.name, got, tt.expected)
+		}
+	}
+
+	// Twitter posters come in named sizes scaled from the original
+	poster := twitterThumbnails("https://pbs.twimg.com/media/abc.jpg", 3000, 1500)
+	if got := BestThumbnail("", poster, 0); got != "https://pbs.twimg.com/media/abc.jpg?format=jpg&name=orig" {
+		t.Errorf("largest Twitter poster = %q; want the original", got)
+	}
+	if got := BestThumbnail("", poster, 600); got != "https://pbs.twimg.com/media/abc.jpg?format=jpg&name=medium" {
+		t.Errorf("Twitter poster within 600px = %q; want medium (1200x600)", got)
+	}
+}
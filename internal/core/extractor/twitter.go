@@ -1,6 +1,7 @@
 package extractor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -75,6 +76,12 @@ func (t *TwitterExtractor) IsAuthenticated() bool {
 
 // Extract retrieves media from a Twitter/X URL
 func (t *TwitterExtractor) Extract(urlStr string) (Media, error) {
+	return t.ExtractContext(context.Background(), urlStr)
+}
+
+// ExtractContext is Extract with the API requests, and any wait for the
+// rate limit before them, bound to ctx
+func (t *TwitterExtractor) ExtractContext(ctx context.Context, urlStr string) (Media, error) {
 	// Initialize HTTP client
 	if t.client == nil {
 		t.client = &http.Client{
@@ -94,7 +101,7 @@ func (t *TwitterExtractor) Extract(urlStr string) (Media, error) {
 
 	// If authenticated, use GraphQL API directly (supports NSFW content)
 	if t.IsAuthenticated() {
-		media, err := t.fetchFromGraphQLAuth(tweetID)
+		media, err := t.fetchFromGraphQLAuth(ctx, tweetID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch tweet: %w", err)
 		}
@@ -102,17 +109,17 @@ func (t *TwitterExtractor) Extract(urlStr string) (Media, error) {
 	}
 
 	// Try syndication API first (simpler, no auth needed for public tweets)
-	media, err := t.fetchFromSyndication(tweetID)
+	media, err := t.fetchFromSyndication(ctx, tweetID)
 	if err == nil {
 		return media, nil
 	}
 
 	// Fallback to GraphQL API with guest token
-	if err := t.fetchGuestToken(); err != nil {
+	if err := t.fetchGuestToken(ctx); err != nil {
 		return nil, fmt.Errorf("failed to get guest token: %w", err)
 	}
 
-	media, err = t.fetchFromGraphQL(tweetID)
+	media, err = t.fetchFromGraphQL(ctx, tweetID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch tweet: %w", err)
 	}
@@ -121,14 +128,14 @@ func (t *TwitterExtractor) Extract(urlStr string) (Media, error) {
 }
 
 // fetchFromSyndication tries the syndication endpoint (works for public tweets)
-func (t *TwitterExtractor) fetchFromSyndication(tweetID string) (Media, error) {
+func (t *TwitterExtractor) fetchFromSyndication(ctx context.Context, tweetID string) (Media, error) {
 	params := url.Values{}
 	params.Set("id", tweetID)
 	params.Set("token", "x") // Required but value doesn't matter
 
 	reqURL := twitterSyndicationURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +143,7 @@ func (t *TwitterExtractor) fetchFromSyndication(tweetID string) (Media, error) {
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
 	req.Header.Set("Accept", "application/json")
 
-	resp, err := t.client.Do(req)
+	resp, err := doRateLimited(t.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -156,15 +163,15 @@ func (t *TwitterExtractor) fetchFromSyndication(tweetID string) (Media, error) {
 }
 
 // fetchGuestToken obtains a guest token for API access
-func (t *TwitterExtractor) fetchGuestToken() error {
-	req, err := http.NewRequest("POST", twitterGuestTokenURL, nil)
+func (t *TwitterExtractor) fetchGuestToken(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", twitterGuestTokenURL, nil)
 	if err != nil {
 		return err
 	}
 
 	req.Header.Set("Authorization", "Bearer "+twitterBearerToken)
 
-	resp, err := t.client.Do(req)
+	resp, err := doRateLimited(t.client, req)
 	if err != nil {
 		return err
 	}
@@ -187,7 +194,7 @@ func (t *TwitterExtractor) fetchGuestToken() error {
 }
 
 // fetchFromGraphQL uses the GraphQL API
-func (t *TwitterExtractor) fetchFromGraphQL(tweetID string) (Media, error) {
+func (t *TwitterExtractor) fetchFromGraphQL(ctx context.Context, tweetID string) (Media, error) {
 	variables := map[string]interface{}{
 		"tweetId":                tweetID,
 		"withCommunity":          false,
@@ -227,7 +234,7 @@ func (t *TwitterExtractor) fetchFromGraphQL(tweetID string) (Media, error) {
 
 	reqURL := twitterGraphQLURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -237,7 +244,7 @@ func (t *TwitterExtractor) fetchFromGraphQL(tweetID string) (Media, error) {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
 
-	resp, err := t.client.Do(req)
+	resp, err := doRateLimited(t.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -257,8 +264,8 @@ func (t *TwitterExtractor) fetchFromGraphQL(tweetID string) (Media, error) {
 }
 
 // fetchCsrfToken fetches the ct0 CSRF token by making a request to Twitter
-func (t *TwitterExtractor) fetchCsrfToken() error {
-	req, err := http.NewRequest("GET", "https://x.com", nil)
+func (t *TwitterExtractor) fetchCsrfToken(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://x.com", nil)
 	if err != nil {
 		return err
 	}
@@ -266,7 +273,7 @@ func (t *TwitterExtractor) fetchCsrfToken() error {
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
 	req.AddCookie(&http.Cookie{Name: "auth_token", Value: t.authToken})
 
-	resp, err := t.client.Do(req)
+	resp, err := doRateLimited(t.client, req)
 	if err != nil {
 		return err
 	}
@@ -283,10 +290,10 @@ func (t *TwitterExtractor) fetchCsrfToken() error {
 }
 
 // fetchFromGraphQLAuth uses the GraphQL API with authentication (for NSFW content)
-func (t *TwitterExtractor) fetchFromGraphQLAuth(tweetID string) (Media, error) {
+func (t *TwitterExtractor) fetchFromGraphQLAuth(ctx context.Context, tweetID string) (Media, error) {
 	// Fetch CSRF token if not already set
 	if t.csrfToken == "" {
-		if err := t.fetchCsrfToken(); err != nil {
+		if err := t.fetchCsrfToken(ctx); err != nil {
 			return nil, fmt.Errorf("failed to get CSRF token: %w", err)
 		}
 	}
@@ -330,7 +337,7 @@ func (t *TwitterExtractor) fetchFromGraphQLAuth(tweetID string) (Media, error) {
 
 	reqURL := twitterGraphQLURL + "?" + params.Encode()
 
-	req, err := http.NewRequest("GET", reqURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -348,7 +355,7 @@ func (t *TwitterExtractor) fetchFromGraphQLAuth(tweetID string) (Media, error) {
 	req.AddCookie(&http.Cookie{Name: "auth_token", Value: t.authToken})
 	req.AddCookie(&http.Cookie{Name: "ct0", Value: t.csrfToken})
 
-	resp, err := t.client.Do(req)
+	resp, err := doRateLimited(t.client, req)
 	if err != nil {
 		return nil, err
 	}
@@ -418,10 +425,12 @@ func (t *TwitterExtractor) parseSyndicationResponse(data *syndicationResponse, t
 
 				videoIndex++
 				videos = append(videos, &VideoMedia{
-					ID:       fmt.Sprintf("%s_%d", tweetID, videoIndex),
-					Title:    title,
-					Uploader: uploader,
-					Formats:  formats,
+					ID:         fmt.Sprintf("%s_%d", tweetID, videoIndex),
+					Title:      title,
+					Uploader:   uploader,
+					Thumbnail:  media.MediaURLHTTPS, // Poster frame
+					Thumbnails: twitterThumbnails(media.MediaURLHTTPS, media.OriginalWidth, media.OriginalHeight),
+					Formats:    formats,
 				})
 			}
 
@@ -600,11 +609,13 @@ func (t *TwitterExtractor) parseGraphQLResponse(body []byte, tweetID string) (Me
 
 				videoIndex++
 				videos = append(videos, &VideoMedia{
-					ID:       fmt.Sprintf("%s_%d", tweetID, videoIndex),
-					Title:    title,
-					Uploader: uploader,
-					Duration: duration,
-					Formats:  formats,
+					ID:         fmt.Sprintf("%s_%d", tweetID, videoIndex),
+					Title:      title,
+					Uploader:   uploader,
+					Duration:   duration,
+					Thumbnail:  media.MediaURLHTTPS, // Poster frame
+					Thumbnails: twitterThumbnails(media.MediaURLHTTPS, media.OriginalInfo.Width, media.OriginalInfo.Height),
+					Formats:    formats,
 				})
 			}
 
@@ -787,6 +798,35 @@ func getHighQualityImageURL(imageURL string) string {
 	return baseURL + "?format=" + format + "&name=orig"
 }
 
+// twitterImageSizes are the named sizes Twitter scales images down to fit
+// (a square of this many pixels), besides "orig"
+var twitterImageSizes = []struct {
+	name string
+	max  int
+}{{"large", 2048}, {"medium", 1200}, {"small", 680}}
+
+// twitterThumbnails lists the sizes Twitter serves an image of the given
+// original dimensions in, or nil when they're unknown
+func twitterThumbnails(imageURL string, width, height int) []Thumbnail {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	orig := getHighQualityImageURL(imageURL)
+	thumbnails := []Thumbnail{{URL: orig, Width: width, Height: height}}
+	for _, size := range twitterImageSizes {
+		longest := max(width, height)
+		if longest <= size.max {
+			continue // Served at the original size
+		}
+		thumbnails = append(thumbnails, Thumbnail{
+			URL:    strings.TrimSuffix(orig, "name=orig") + "name=" + size.name,
+			Width:  width * size.max / longest,
+			Height: height * size.max / longest,
+		})
+	}
+	return thumbnails
+}
+
 // getImageExtension extracts the image extension from URL
 func getImageExtension(imageURL string) string {
 	baseURL := strings.Split(imageURL, "?")[0]
@@ -1,6 +1,7 @@
 package extractor
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"regexp"
@@ -38,14 +39,32 @@ type Extractor interface {
 	Extract(url string) (Media, error)
 }
 
+// ContextExtractor is an Extractor whose requests can be cancelled
+type ContextExtractor interface {
+	Extractor
+
+	// ExtractContext is Extract bound to ctx
+	ExtractContext(ctx context.Context, url string) (Media, error)
+}
+
+// ExtractContext runs ext on url, bound to ctx if ext is a ContextExtractor
+func ExtractContext(ctx context.Context, ext Extractor, url string) (Media, error) {
+	if ce, ok := ext.(ContextExtractor); ok {
+		return ce.ExtractContext(ctx, url)
+	}
+	return ext.Extract(url)
+}
+
 // VideoMedia represents video content with multiple format options
 type VideoMedia struct {
-	ID        string
-	Title     string
-	Uploader  string
-	Duration  int // seconds
-	Thumbnail string
-	Formats   []VideoFormat
+	ID         string
+	Title      string
+	Uploader   string
+	Duration   int // seconds
+	Thumbnail  string
+	Thumbnails []Thumbnail // Sizes of the thumbnail, if the source offers several
+	Formats    []VideoFormat
+	Chapters   []Chapter // Chapter markers, if the source has any
 }
 
 func (v *VideoMedia) GetID() string       { return v.ID }
@@ -53,16 +72,33 @@ func (v *VideoMedia) GetTitle() string    { return v.Title }
 func (v *VideoMedia) GetUploader() string { return v.Uploader }
 func (v *VideoMedia) Type() MediaType     { return MediaTypeVideo }
 
+// Chapter is a named section of a video
+type Chapter struct {
+	Title string
+	Start float64 // seconds
+	End   float64 // seconds
+}
+
 // VideoFormat represents a single video quality option
 type VideoFormat struct {
-	URL     string
-	Quality string // "1080p", "720p", etc.
-	Ext     string // "mp4", "m3u8", "ts"
-	Width   int
-	Height  int
-	Bitrate int
-	Headers map[string]string // Custom headers for download (e.g., Referer)
-	AudioURL string // Separate audio stream URL (for adaptive formats that need merging)
+	URL          string
+	Quality      string // "1080p", "720p", etc.
+	Ext          string // "mp4", "m3u8", "ts"
+	Width        int
+	Height       int
+	Bitrate      int
+	Headers      map[string]string // Custom headers for download (e.g., Referer)
+	AudioURL     string            // Separate audio stream URL (for adaptive formats that need merging)
+	AudioHeaders map[string]string // Headers for AudioURL when they differ from Headers (e.g., another host)
+}
+
+// AudioRequestHeaders returns the headers for downloading AudioURL:
+// AudioHeaders if the extractor set them, otherwise Headers
+func (f *VideoFormat) AudioRequestHeaders() map[string]string {
+	if f.AudioHeaders != nil {
+		return f.AudioHeaders
+	}
+	return f.Headers
 }
 
 // QualityLabel returns a human-readable quality label
@@ -78,12 +114,14 @@ func (f *VideoFormat) QualityLabel() string {
 
 // AudioMedia represents audio content (podcasts, music)
 type AudioMedia struct {
-	ID       string
-	Title    string
-	Uploader string
-	Duration int // seconds
-	URL      string
-	Ext      string // "mp3", "m4a", etc.
+	ID         string
+	Title      string
+	Uploader   string
+	Duration   int // seconds
+	URL        string
+	Ext        string      // "mp3", "m4a", etc.
+	Thumbnail  string      // Cover art URL, if any
+	Thumbnails []Thumbnail // Sizes of the cover art, if the source offers several
 }
 
 func (a *AudioMedia) GetID() string       { return a.ID }
@@ -125,8 +163,39 @@ type Image struct {
 	Height int
 }
 
+// SanitizeOptions tunes SanitizeFilenameWith. The zero value matches SanitizeFilename.
+type SanitizeOptions struct {
+	// Replacement substitutes path separators, colons and similar characters (default "-")
+	Replacement string
+
+	// KeepWhitespace disables collapsing runs of whitespace into one space
+	KeepWhitespace bool
+
+	// MaxLength limits the result to this many runes (default 60)
+	MaxLength int
+
+	// Lowercase converts the result to lower case
+	Lowercase bool
+}
+
 // SanitizeFilename removes or replaces characters that are invalid in filenames
 func SanitizeFilename(name string) string {
+	return SanitizeFilenameWith(name, SanitizeOptions{})
+}
+
+// SanitizeFilenameWith is SanitizeFilename with custom rules
+func SanitizeFilenameWith(name string, opts SanitizeOptions) string {
+	sep := opts.Replacement
+	if sep == "" {
+		sep = "-"
+	}
+	maxRunes := opts.MaxLength
+	if maxRunes <= 0 {
+		// Most filesystems limit filenames to 255 bytes. For UTF-8 with CJK characters
+		// (3-4 bytes each), 60 runes is safe (~180-240 bytes), leaving room for extension.
+		maxRunes = 60
+	}
+
 	// Remove URLs first (before character replacement mangles them)
 	urlRegex := regexp.MustCompile(`https?://[^\s]+`)
 	result := urlRegex.ReplaceAllString(name, "")
@@ -135,9 +204,9 @@ func SanitizeFilename(name string) string {
 	// Includes both ASCII and full-width (CJK) versions of reserved characters
 	replacer := strings.NewReplacer(
 		// ASCII versions
-		"/", "-",
-		"\\", "-",
-		":", "-",
+		"/", sep,
+		"\\", sep,
+		":", sep,
 		"*", "",
 		"?", "",
 		"\"", "",
@@ -148,10 +217,10 @@ func SanitizeFilename(name string) string {
 		"\r", "",
 		"\t", " ",
 		// Full-width versions (common in Chinese/Japanese text)
-		"：", "-", // U+FF1A Full-width colon
-		"／", "-", // U+FF0F Full-width solidus
-		"＼", "-", // U+FF3C Full-width reverse solidus
-		"。", "-", // U+3002 CJK full stop
+		"：", sep, // U+FF1A Full-width colon
+		"／", sep, // U+FF0F Full-width solidus
+		"＼", sep, // U+FF3C Full-width reverse solidus
+		"。", sep, // U+3002 CJK full stop
 		"＊", "",  // U+FF0A Full-width asterisk
 		"？", "",  // U+FF1F Full-width question mark
 		"＂", "",  // U+FF02 Full-width quotation mark
@@ -179,13 +248,16 @@ func SanitizeFilename(name string) string {
 	result = strings.Trim(result, ".")
 
 	// Collapse multiple spaces
-	spaceRegex := regexp.MustCompile(`\s+`)
-	result = spaceRegex.ReplaceAllString(result, " ")
+	if !opts.KeepWhitespace {
+		spaceRegex := regexp.MustCompile(`\s+`)
+		result = spaceRegex.ReplaceAllString(result, " ")
+	}
+
+	if opts.Lowercase {
+		result = strings.ToLower(result)
+	}
 
 	// Limit length to avoid "file name too long" errors
-	// Most filesystems limit filenames to 255 bytes. For UTF-8 with CJK characters
-	// (3-4 bytes each), 60 runes is safe (~180-240 bytes), leaving room for extension.
-	const maxRunes = 60
 	runes := []rune(result)
 	if len(runes) > maxRunes {
 		result = string(runes[:maxRunes])
@@ -99,3 +99,66 @@ func TestSanitizeFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestSanitizeFilenameWith(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		opts     SanitizeOptions
+		expected string
+	}{
+		{
+			name:     "Zero options match defaults",
+			input:    "a/b:c   d",
+			opts:     SanitizeOptions{},
+			expected: "a-b-c d",
+		},
+		{
+			name:     "Custom replacement",
+			input:    "a/b：c",
+			opts:     SanitizeOptions{Replacement: "_"},
+			expected: "a_b_c",
+		},
+		{
+			name:     "Keep whitespace",
+			input:    "a   b",
+			opts:     SanitizeOptions{KeepWhitespace: true},
+			expected: "a   b",
+		},
+		{
+			name:     "Max length",
+			input:    "abcdefghij",
+			opts:     SanitizeOptions{MaxLength: 4},
+			expected: "abcd",
+		},
+		{
+			name:     "Lowercase",
+			input:    "My Video",
+			opts:     SanitizeOptions{Lowercase: true},
+			expected: "my video",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := SanitizeFilenameWith(tt.input, tt.opts)
+			if result != tt.expected {
+				t.Errorf("SanitizeFilenameWith(%q, %+v)\n  got:  %q\n  want: %q", tt.input, tt.opts, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAudioRequestHeaders(t *testing.T) {
+	video := map[string]string{"Referer": "https://video.example.com/"}
+	audio := map[string]string{"Referer": "https://audio.example.com/"}
+
+	f := VideoFormat{Headers: video}
+	if got := f.AudioRequestHeaders(); got["Referer"] != video["Referer"] {
+		t.Errorf("without AudioHeaders = %v; want the video headers", got)
+	}
+	f.AudioHeaders = audio
+	if got := f.AudioRequestHeaders(); got["Referer"] != audio["Referer"] {
+		t.Errorf("with AudioHeaders = %v; want the audio headers", got)
+	}
+}
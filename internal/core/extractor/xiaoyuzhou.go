@@ -82,8 +82,14 @@ func (e *XiaoyuzhouExtractor) extractEpisode(url string) (*AudioMedia, error) {
 					Enclosure struct {
 						URL string `json:"url"`
 					} `json:"enclosure"`
+					Image struct {
+						PicURL string `json:"picUrl"`
+					} `json:"image"`
 					Podcast struct {
 						Title string `json:"title"`
+						Image struct {
+							PicURL string `json:"picUrl"`
+						} `json:"image"`
 					} `json:"podcast"`
 				} `json:"episode"`
 			} `json:"pageProps"`
@@ -108,13 +114,20 @@ func (e *XiaoyuzhouExtractor) extractEpisode(url string) (*AudioMedia, error) {
 	// Create filename: {podcast} - {title}
 	filename := SanitizeFilename(fmt.Sprintf("%s - %s", episode.Podcast.Title, episode.Title))
 
+	// Episodes without their own cover use the podcast's
+	thumbnail := episode.Image.PicURL
+	if thumbnail == "" {
+		thumbnail = episode.Podcast.Image.PicURL
+	}
+
 	return &AudioMedia{
-		ID:       episodeID,
-		Title:    filename,
-		Uploader: episode.Podcast.Title,
-		Duration: episode.Duration,
-		URL:      episode.Enclosure.URL,
-		Ext:      ext,
+		ID:        episodeID,
+		Title:     filename,
+		Uploader:  episode.Podcast.Title,
+		Duration:  episode.Duration,
+		URL:       episode.Enclosure.URL,
+		Ext:       ext,
+		Thumbnail: thumbnail,
 	}, nil
 }
 
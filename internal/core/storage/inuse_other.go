@@ -0,0 +1,9 @@
+//go:build !windows
+
+package storage
+
+import "syscall"
+
+// Elsewhere open files can be replaced and removed; only busy mount points
+// and running executables refuse
+var fileInUseErrnos = []syscall.Errno{syscall.EBUSY, syscall.ETXTBSY}
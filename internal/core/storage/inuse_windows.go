@@ -0,0 +1,12 @@
+package storage
+
+import "syscall"
+
+// Windows reports a file held open by another program (without shared
+// access) or with a locked region as a sharing or lock violation
+const (
+	errorSharingViolation syscall.Errno = 32
+	errorLockViolation    syscall.Errno = 33
+)
+
+var fileInUseErrnos = []syscall.Errno{errorSharingViolation, errorLockViolation}
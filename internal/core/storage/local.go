@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// PartSuffix marks a local file that is still being written. Writers
+// rename it to its final name only once the transfer succeeded, so tools
+// watching the directory never pick up a truncated file.
+const PartSuffix = ".part"
+
+// ValidatorSuffix marks the file next to a partial file that holds the
+// validator of the response it came from (see Resumer)
+const ValidatorSuffix = PartSuffix + ".validator"
+
+// ErrFileInUse reports a local file another program holds open, e.g., a
+// media player playing an earlier download on Windows
+var ErrFileInUse = errors.New("file is in use by another program")
+
+// How long local operations wait for a file in use to be released
+var (
+	inUseRetries    = 3
+	inUseRetryDelay = 500 * time.Millisecond
+)
+
+// LocalStorage keeps files on the local disk under a directory
+type LocalStorage struct {
+	dir string
+}
+
+// NewLocal returns local storage rooted at dir
+func NewLocal(dir string) *LocalStorage {
+	return &LocalStorage{dir: dir}
+}
+
+func (l *LocalStorage) Create(name string) (Writer, error) {
+	var file *os.File
+	err := retryInUse(name+PartSuffix, func() (err error) {
+		file, err = os.Create(name + PartSuffix)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	// A validator left by an earlier partial file doesn't describe this one
+	os.Remove(name + ValidatorSuffix)
+	return localWriter{File: file, name: name}, nil
+}
+
+func (l *LocalStorage) PartialSize(name string) int64 {
+	info, err := os.Stat(name + PartSuffix)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
+
+func (l *LocalStorage) Append(name string) (Writer, error) {
+	var file *os.File
+	err := retryInUse(name+PartSuffix, func() (err error) {
+		file, err = os.OpenFile(name+PartSuffix, os.O_WRONLY|os.O_APPEND, 0)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return localWriter{File: file, name: name}, nil
+}
+
+func (l *LocalStorage) PartialValidator(name string) string {
+	data, err := os.ReadFile(name + ValidatorSuffix)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func (l *LocalStorage) SetPartialValidator(name, validator string) error {
+	return os.WriteFile(name+ValidatorSuffix, []byte(validator), 0644)
+}
+
+func (l *LocalStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (l *LocalStorage) Stat(name string) (FileInfo, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	return FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+func (l *LocalStorage) Remove(name string) error {
+	return retryInUse(name, func() error { return os.Remove(name) })
+}
+
+func (l *LocalStorage) Join(elem ...string) string {
+	return filepath.Join(append([]string{l.dir}, elem...)...)
+}
+
+func (l *LocalStorage) IsLocal() bool {
+	return true
+}
+
+// localWriter writes to name+PartSuffix and moves it to name on Close,
+// dropping the partial file's validator. Abort leaves the partial file and
+// validator in place; removing them is up to the caller's cleanup policy.
+type localWriter struct {
+	*os.File
+	name string
+}
+
+func (w localWriter) Close() error {
+	// Flush to disk first so a crash can't leave a complete-looking but
+	// empty file behind the rename
+	if err := w.File.Sync(); err != nil {
+		w.File.Close()
+		return err
+	}
+	if err := w.File.Close(); err != nil {
+		return err
+	}
+	if err := retryInUse(w.name, func() error { return moveFile(w.File.Name(), w.name) }); err != nil {
+		return err
+	}
+	os.Remove(w.name + ValidatorSuffix)
+	return nil
+}
+
+func (w localWriter) Abort() error {
+	return w.File.Close()
+}
+
+// moveFile renames src to dst, copying and deleting src when they are on
+// different filesystems
+func moveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
+
+// retryInUse runs op on name, retrying briefly while another program holds
+// the file open. A file still in use after the retries is reported as
+// ErrFileInUse.
+func retryInUse(name string, op func() error) error {
+	err := op()
+	for attempt := 0; err != nil && isFileInUse(err); attempt++ {
+		if attempt == inUseRetries {
+			return fmt.Errorf("%w: %s (close it and try again)", ErrFileInUse, name)
+		}
+		time.Sleep(inUseRetryDelay)
+		err = op()
+	}
+	return err
+}
+
+// isFileInUse reports whether err is the platform's error for a file that
+// is open or locked elsewhere
+func isFileInUse(err error) bool {
+	for _, errno := range fileInUseErrnos {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
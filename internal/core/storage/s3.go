@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+)
+
+// emptyPayloadHash is the SHA-256 of an empty request body
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// S3Storage keeps files as objects in an S3-compatible bucket, signing
+// requests with AWS Signature Version 4. Uploads are staged in a temporary
+// file and sent with a single PUT, so objects are limited to 5 GB.
+type S3Storage struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+// NewS3 returns S3 storage for cfg, falling back to the standard AWS
+// environment variables for credentials
+func NewS3(cfg config.StorageConfig) (*S3Storage, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("storage.bucket is required for s3 storage")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid storage.endpoint: %s", endpoint)
+	}
+
+	accessKey, secretKey := cfg.AccessKey, cfg.SecretKey
+	if accessKey == "" && secretKey == "" {
+		accessKey = os.Getenv("AWS_ACCESS_KEY_ID")
+		secretKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("storage.access_key and storage.secret_key are required for s3 storage")
+	}
+
+	return &S3Storage{
+		endpoint:  u,
+		region:    region,
+		bucket:    cfg.Bucket,
+		prefix:    strings.Trim(cfg.Prefix, "/"),
+		accessKey: accessKey,
+		secretKey: secretKey,
+		pathStyle: cfg.PathStyle,
+		client:    &http.Client{},
+	}, nil
+}
+
+func (s *S3Storage) Create(name string) (Writer, error) {
+	tmp, err := os.CreateTemp("", "vget-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	return &s3Writer{s: s, name: name, tmp: tmp, hash: sha256.New()}, nil
+}
+
+func (s *S3Storage) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", name, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *S3Storage) Stat(name string) (FileInfo, error) {
+	resp, err := s.do("HEAD", name, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+func (s *S3Storage) Remove(name string) error {
+	resp, err := s.do("DELETE", name, nil, 0, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Join(elem ...string) string {
+	return path.Join(append([]string{s.prefix}, elem...)...)
+}
+
+func (s *S3Storage) IsLocal() bool {
+	return false
+}
+
+// do sends a signed request for the object name and returns the response
+// if it succeeded. 404s map to fs.ErrNotExist.
+func (s *S3Storage) do(method, name string, body io.Reader, size int64, payloadHash string) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.objectURL(name), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	s.sign(req, payloadHash, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: name, Err: fs.ErrNotExist}
+	}
+	return nil, fmt.Errorf("s3 %s %s failed: %s%s", method, name, resp.Status, s3ErrorCode(resp.Body))
+}
+
+// objectURL returns the URL of the object stored under name
+func (s *S3Storage) objectURL(name string) string {
+	key := s3Escape(strings.TrimPrefix(name, "/"))
+	if s.pathStyle {
+		return fmt.Sprintf("%s://%s/%s/%s", s.endpoint.Scheme, s.endpoint.Host, s3Escape(s.bucket), key)
+	}
+	return fmt.Sprintf("%s://%s.%s/%s", s.endpoint.Scheme, s.bucket, s.endpoint.Host, key)
+}
+
+// sign adds AWS Signature Version 4 headers to req. Besides host, the
+// x-amz-* headers and the few standard headers S3 expects to be covered
+// are signed.
+func (s *S3Storage) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	values := map[string]string{"host": req.URL.Host}
+	for key, vals := range req.Header {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" || lower == "content-type" || lower == "content-md5" {
+			values[lower] = strings.TrimSpace(strings.Join(vals, ","))
+		}
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, signedHeaders, signature))
+}
+
+// s3Writer stages an upload in a temporary file, hashing it as it goes,
+// and PUTs it on Close
+type s3Writer struct {
+	s    *S3Storage
+	name string
+	tmp  *os.File
+	hash hash.Hash
+	size int64
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	n, err := w.tmp.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *s3Writer) Close() error {
+	defer w.Abort()
+
+	if _, err := w.tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read staged upload: %w", err)
+	}
+	resp, err := w.s.do("PUT", w.name, io.NopCloser(w.tmp), w.size, hex.EncodeToString(w.hash.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (w *s3Writer) Abort() error {
+	w.tmp.Close()
+	return os.Remove(w.tmp.Name())
+}
+
+// s3Escape percent-encodes an object key the way SigV4 expects: everything
+// except unreserved characters and the "/" separators
+func s3Escape(key string) string {
+	var b strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// s3ErrorCode extracts the <Code> of an S3 XML error body for messages
+func s3ErrorCode(body io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(body, 4096))
+	_, rest, ok := strings.Cut(string(data), "<Code>")
+	if !ok {
+		return ""
+	}
+	code, _, _ := strings.Cut(rest, "</Code>")
+	return " (" + code + ")"
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,124 @@
+// Package storage abstracts where downloaded files are written, so the
+// server can save to the local disk or to S3-compatible object storage.
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+)
+
+// Storage is a destination for downloaded files. Names are backend paths
+// built with Join; for local storage they are ordinary filesystem paths.
+type Storage interface {
+	// Create opens name for writing, replacing any existing file
+	Create(name string) (Writer, error)
+
+	// Open opens name for reading
+	Open(name string) (io.ReadCloser, error)
+
+	// Stat describes name. A missing file yields an error matching fs.ErrNotExist.
+	Stat(name string) (FileInfo, error)
+
+	// Remove deletes name. A missing file yields an error matching fs.ErrNotExist.
+	Remove(name string) error
+
+	// Join joins path elements onto the storage root
+	Join(elem ...string) string
+
+	// IsLocal reports whether names are local filesystem paths that tools
+	// like ffmpeg can work on directly
+	IsLocal() bool
+}
+
+// Resumer is implemented by storage that keeps the partial file of an
+// aborted Create, so an interrupted transfer can continue where it stopped
+type Resumer interface {
+	// PartialSize returns the size of the partial file of name (0 if none)
+	PartialSize(name string) int64
+
+	// Append opens the partial file of name for writing at its end
+	Append(name string) (Writer, error)
+
+	// PartialValidator returns the validator (the ETag or Last-Modified of
+	// the response being saved) stored with the partial file of name, ""
+	// if none
+	PartialValidator(name string) string
+
+	// SetPartialValidator stores validator with the partial file of name,
+	// so a later resume can check it continues the same remote file
+	SetPartialValidator(name, validator string) error
+}
+
+// Writer is a file being written to storage. Close stores it for good;
+// Abort gives up on it after a failed transfer. Only one of them is called.
+type Writer interface {
+	io.Writer
+	Close() error
+	Abort() error
+}
+
+// FileInfo describes a stored file
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// New returns the backend selected by cfg. Local storage is rooted at
+// outputDir; S3 storage ignores it in favor of the configured prefix.
+func New(cfg config.StorageConfig, outputDir string) (Storage, error) {
+	switch cfg.Type {
+	case "", config.StorageLocal:
+		return NewLocal(outputDir), nil
+	case config.StorageS3:
+		return NewS3(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage type: %s", cfg.Type)
+	}
+}
+
+// NewDestination returns the upload target selected by cfg, or nil when
+// no destination is configured
+func NewDestination(cfg config.DestinationConfig) (Storage, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case config.StorageS3:
+		st, err := NewS3(cfg.S3Config())
+		if err != nil {
+			return nil, fmt.Errorf("destination: %w", err)
+		}
+		return st, nil
+	case config.DestinationWebDAV:
+		st, err := NewWebDAV(cfg.URL, cfg.Username, cfg.Password, cfg.Prefix)
+		if err != nil {
+			return nil, fmt.Errorf("destination: %w", err)
+		}
+		return st, nil
+	default:
+		return nil, fmt.Errorf("unknown destination type: %s", cfg.Type)
+	}
+}
+
+// Upload copies the local file at localPath into st as name
+func Upload(st Storage, localPath, name string) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := st.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Abort()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// WebDAVStorage keeps files on a WebDAV server under a base collection.
+// Uploads are staged in a temporary file so they can be sent with a known
+// length, and missing parent collections are created before the PUT.
+type WebDAVStorage struct {
+	base     *url.URL
+	prefix   string
+	username string
+	password string
+	client   *http.Client
+}
+
+// NewWebDAV returns WebDAV storage rooted at rawURL, with names joined
+// under prefix
+func NewWebDAV(rawURL, username, password, prefix string) (*WebDAVStorage, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webdav url: %s", rawURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+
+	return &WebDAVStorage{
+		base:     u,
+		prefix:   strings.Trim(prefix, "/"),
+		username: username,
+		password: password,
+		client:   &http.Client{},
+	}, nil
+}
+
+func (s *WebDAVStorage) Create(name string) (Writer, error) {
+	tmp, err := os.CreateTemp("", "vget-upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to stage upload: %w", err)
+	}
+	return &webdavWriter{s: s, name: name, tmp: tmp}, nil
+}
+
+func (s *WebDAVStorage) Open(name string) (io.ReadCloser, error) {
+	resp, err := s.do("GET", name, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s *WebDAVStorage) Stat(name string) (FileInfo, error) {
+	resp, err := s.do("HEAD", name, nil, 0)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	resp.Body.Close()
+
+	modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+	return FileInfo{Name: name, Size: resp.ContentLength, ModTime: modTime}, nil
+}
+
+func (s *WebDAVStorage) Remove(name string) error {
+	resp, err := s.do("DELETE", name, nil, 0)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *WebDAVStorage) Join(elem ...string) string {
+	return path.Join(append([]string{s.prefix}, elem...)...)
+}
+
+func (s *WebDAVStorage) IsLocal() bool {
+	return false
+}
+
+// do sends an authenticated request for name and returns the response if
+// it succeeded. 404s map to fs.ErrNotExist.
+func (s *WebDAVStorage) do(method, name string, body io.Reader, size int64) (*http.Response, error) {
+	resp, err := s.send(method, name, body, size)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: name, Err: fs.ErrNotExist}
+	}
+	return nil, fmt.Errorf("webdav %s %s failed: %s", method, name, resp.Status)
+}
+
+// send sends an authenticated request for name, whatever its outcome
+func (s *WebDAVStorage) send(method, name string, body io.Reader, size int64) (*http.Response, error) {
+	req, err := http.NewRequest(method, s.fileURL(name), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if s.username != "" || s.password != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+	return s.client.Do(req)
+}
+
+// fileURL returns the URL of name under the base collection
+func (s *WebDAVStorage) fileURL(name string) string {
+	u := *s.base
+	u.Path = s.base.Path + "/" + strings.TrimPrefix(name, "/")
+	return u.String()
+}
+
+// mkdirAll creates the collections leading up to name. Servers answer 405
+// for collections that already exist, which is fine.
+func (s *WebDAVStorage) mkdirAll(name string) error {
+	dir := path.Dir(strings.TrimPrefix(name, "/"))
+	if dir == "." {
+		return nil
+	}
+
+	var current string
+	for _, part := range strings.Split(dir, "/") {
+		current = path.Join(current, part)
+		resp, err := s.send("MKCOL", current+"/", nil, 0)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusMethodNotAllowed {
+			return fmt.Errorf("webdav MKCOL %s failed: %s", current, resp.Status)
+		}
+	}
+	return nil
+}
+
+// webdavWriter stages an upload in a temporary file and PUTs it on Close
+type webdavWriter struct {
+	s    *WebDAVStorage
+	name string
+	tmp  *os.File
+	size int64
+}
+
+func (w *webdavWriter) Write(p []byte) (int, error) {
+	n, err := w.tmp.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *webdavWriter) Close() error {
+	defer w.Abort()
+
+	if err := w.s.mkdirAll(w.name); err != nil {
+		return fmt.Errorf("failed to create parent collections: %w", err)
+	}
+	if _, err := w.tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to read staged upload: %w", err)
+	}
+	resp, err := w.s.do("PUT", w.name, io.NopCloser(w.tmp), w.size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (w *webdavWriter) Abort() error {
+	w.tmp.Close()
+	return os.Remove(w.tmp.Name())
+}
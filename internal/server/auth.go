@@ -1,7 +1,13 @@
 package server
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,8 +22,17 @@ const (
 	SessionDuration = 24 * time.Hour
 	// APITokenDuration is the duration for API tokens (1 year)
 	APITokenDuration = 365 * 24 * time.Hour
+	// DefaultJWTIssuer is the issuer used when server.jwt_issuer is not configured
+	DefaultJWTIssuer = "vget"
 )
 
+// claimsContextKey is the gin context key holding the validated *JWTClaims
+const claimsContextKey = "vget_claims"
+
+// signedLinkContextKey is the gin context key set when a signed file link
+// authenticated the request
+const signedLinkContextKey = "vget_signed_link"
+
 // JWTClaims represents the claims in a JWT token
 type JWTClaims struct {
 	TokenType string         `json:"type"` // "session" or "api"
@@ -25,8 +40,14 @@ type JWTClaims struct {
 	jwt.RegisteredClaims
 }
 
+// APIKeyHeader carries the api_key on POST /api/auth/token requests that
+// set a custom payload
+const APIKeyHeader = "X-API-Key"
+
 // GenerateTokenRequest is the request body for POST /api/auth/token
 type GenerateTokenRequest struct {
+	// Payload is stored on the token and on jobs it submits (see
+	// customClaims). Setting one requires the api_key in APIKeyHeader.
 	Payload map[string]any `json:"payload,omitempty"`
 }
 
@@ -40,9 +61,12 @@ func (s *Server) generateJWT(tokenType string, duration time.Duration, customPay
 			ExpiresAt: jwt.NewNumericDate(now.Add(duration)),
 			IssuedAt:  jwt.NewNumericDate(now),
 			NotBefore: jwt.NewNumericDate(now),
-			Issuer:    "vget",
+			Issuer:    s.jwtIssuer(),
 		},
 	}
+	if aud := s.config().Server.JWTAudience; aud != "" {
+		claims.Audience = jwt.ClaimStrings{aud}
+	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.apiKey))
@@ -50,9 +74,14 @@ func (s *Server) generateJWT(tokenType string, duration time.Duration, customPay
 
 // validateJWT validates a JWT token and returns the claims
 func (s *Server) validateJWT(tokenString string) (*JWTClaims, error) {
+	opts := []jwt.ParserOption{jwt.WithIssuer(s.jwtIssuer())}
+	if aud := s.config().Server.JWTAudience; aud != "" {
+		opts = append(opts, jwt.WithAudience(aud))
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (any, error) {
 		return []byte(s.apiKey), nil
-	})
+	}, opts...)
 
 	if err != nil {
 		return nil, err
@@ -65,25 +94,34 @@ func (s *Server) validateJWT(tokenString string) (*JWTClaims, error) {
 	return nil, jwt.ErrSignatureInvalid
 }
 
+// jwtIssuer returns the configured JWT issuer, falling back to DefaultJWTIssuer
+func (s *Server) jwtIssuer() string {
+	if iss := s.config().Server.JWTIssuer; iss != "" {
+		return iss
+	}
+	return DefaultJWTIssuer
+}
+
 // jwtAuthMiddleware handles authentication via session cookie or Bearer token
 func (s *Server) jwtAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		path := c.Request.URL.Path
+		prefix := s.apiPrefix()
 
 		// Only API routes require auth
-		if !strings.HasPrefix(path, "/api/") {
+		if !strings.HasPrefix(path, prefix+"/") {
 			c.Next()
 			return
 		}
 
 		// Health endpoint doesn't require auth
-		if path == "/api/health" {
+		if path == prefix+"/health" {
 			c.Next()
 			return
 		}
 
 		// Auth endpoints don't require auth
-		if strings.HasPrefix(path, "/api/auth/") {
+		if strings.HasPrefix(path, prefix+"/auth/") {
 			c.Next()
 			return
 		}
@@ -96,7 +134,8 @@ func (s *Server) jwtAuthMiddleware() gin.HandlerFunc {
 
 		// Check for session cookie first
 		if cookie, err := c.Cookie(SessionCookieName); err == nil {
-			if _, err := s.validateJWT(cookie); err == nil {
+			if claims, err := s.validateJWT(cookie); err == nil {
+				c.Set(claimsContextKey, claims)
 				c.Next()
 				return
 			}
@@ -105,12 +144,20 @@ func (s *Server) jwtAuthMiddleware() gin.HandlerFunc {
 		// Check for Bearer token in Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if token, found := strings.CutPrefix(authHeader, "Bearer "); found {
-			if _, err := s.validateJWT(token); err == nil {
+			if claims, err := s.validateJWT(token); err == nil {
+				c.Set(claimsContextKey, claims)
 				c.Next()
 				return
 			}
 		}
 
+		// Signed file links (e.g., from playlists) authenticate themselves
+		if path == prefix+"/download" && c.Request.Method == http.MethodGet && s.validFileSignature(c) {
+			c.Set(signedLinkContextKey, true)
+			c.Next()
+			return
+		}
+
 		// No valid authentication
 		c.JSON(http.StatusUnauthorized, Response{
 			Code:    401,
@@ -121,6 +168,72 @@ func (s *Server) jwtAuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// customClaims returns the custom payload of the token that authenticated
+// the request, or nil when auth is off or the token has none
+func customClaims(c *gin.Context) map[string]any {
+	if claims, ok := c.Get(claimsContextKey); ok {
+		return claims.(*JWTClaims).Custom
+	}
+	return nil
+}
+
+// signedFileQuery returns the query of a GET /api/download link for
+// filePath that works without other credentials until expires. Without an
+// api_key, links need no signature.
+func (s *Server) signedFileQuery(filePath string, expires time.Time) string {
+	query := url.Values{"path": {filePath}}
+	if s.apiKey != "" {
+		exp := strconv.FormatInt(expires.Unix(), 10)
+		query.Set("expires", exp)
+		query.Set("sig", s.fileSignature(filePath, exp))
+	}
+	return query.Encode()
+}
+
+// fileSignature is the HMAC of a file link, keyed with the api_key
+func (s *Server) fileSignature(filePath, expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.apiKey))
+	fmt.Fprintf(mac, "download\n%s\n%s", filePath, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validFileSignature reports whether the request carries an unexpired
+// signature for its path parameter
+func (s *Server) validFileSignature(c *gin.Context) bool {
+	sig, exp := c.Query("sig"), c.Query("expires")
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if sig == "" || err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.fileSignature(c.Query("path"), exp)))
+}
+
+// signedLinkReferrerAllowed reports whether a request authenticated by a
+// signed file link comes from a site server.signed_link_referrers allows.
+// Other requests are always allowed.
+func (s *Server) signedLinkReferrerAllowed(c *gin.Context) bool {
+	if !c.GetBool(signedLinkContextKey) {
+		return true
+	}
+	return s.config().Server.IsReferrerAllowed(referrerHost(c.Request))
+}
+
+// referrerHost returns the host of the page a request came from, by its
+// Origin header or else its Referer ("" if it names neither)
+func referrerHost(r *http.Request) string {
+	for _, header := range []string{"Origin", "Referer"} {
+		value := r.Header.Get(header)
+		if value == "" || value == "null" {
+			continue
+		}
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			return u.Hostname()
+		}
+		return value // Unparseable, but still not "none"
+	}
+	return ""
+}
+
 // setSessionCookie sets a session cookie for browser clients
 func (s *Server) setSessionCookie(c *gin.Context) {
 	// Only set cookie if api_key is configured
@@ -146,7 +259,7 @@ func (s *Server) setSessionCookie(c *gin.Context) {
 		SessionCookieName,
 		token,
 		int(SessionDuration.Seconds()),
-		"/",
+		s.basePath+"/",
 		"",    // domain - empty means current domain
 		false, // secure - false to allow HTTP
 		true,  // httpOnly - prevent JS access
@@ -181,6 +294,17 @@ func (s *Server) handleGenerateToken(c *gin.Context) {
 	// Ignore binding errors - payload is optional
 	_ = c.ShouldBindJSON(&req)
 
+	// Anyone may get a plain token, so only the api_key holder may vouch
+	// for a payload such as {"user": "alice"}
+	if len(req.Payload) > 0 && !hmac.Equal([]byte(c.GetHeader(APIKeyHeader)), []byte(s.apiKey)) {
+		c.JSON(http.StatusOK, Response{
+			Code:    401,
+			Data:    nil,
+			Message: "a custom payload requires the API key in the " + APIKeyHeader + " header",
+		})
+		return
+	}
+
 	token, err := s.generateJWT("api", APITokenDuration, req.Payload)
 	if err != nil {
 		c.JSON(http.StatusOK, Response{
@@ -0,0 +1,225 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// signTestToken signs claims with key, bypassing generateJWT so tests can
+// produce expired or foreign tokens
+func signTestToken(t *testing.T, key string, claims JWTClaims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(key))
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTAuthMiddleware(t *testing.T) {
+	const apiKey = "test-secret"
+	s := newTestServer(t, apiKey)
+
+	valid, err := s.generateJWT("api", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("generateJWT: %v", err)
+	}
+	expired := signTestToken(t, apiKey, JWTClaims{
+		TokenType: "api",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			Issuer:    DefaultJWTIssuer,
+		},
+	})
+	wrongKey := signTestToken(t, "other-secret", JWTClaims{
+		TokenType: "api",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			Issuer:    DefaultJWTIssuer,
+		},
+	})
+	wrongIssuer := signTestToken(t, apiKey, JWTClaims{
+		TokenType: "api",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			Issuer:    "someone-else",
+		},
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		headers  map[string]string
+		expected int
+	}{
+		{
+			name:     "Health is public",
+			path:     "/api/health",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "Auth status is public",
+			path:     "/api/auth/status",
+			expected: http.StatusOK,
+		},
+		{
+			name:     "Missing token",
+			path:     "/api/jobs",
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "Valid bearer token",
+			path:     "/api/jobs",
+			headers:  map[string]string{"Authorization": "Bearer " + valid},
+			expected: http.StatusOK,
+		},
+		{
+			name:     "Valid session cookie",
+			path:     "/api/jobs",
+			headers:  map[string]string{"Cookie": SessionCookieName + "=" + valid},
+			expected: http.StatusOK,
+		},
+		{
+			name:     "Malformed token",
+			path:     "/api/jobs",
+			headers:  map[string]string{"Authorization": "Bearer not-a-jwt"},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "Expired token",
+			path:     "/api/jobs",
+			headers:  map[string]string{"Authorization": "Bearer " + expired},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "Token signed with another key",
+			path:     "/api/jobs",
+			headers:  map[string]string{"Authorization": "Bearer " + wrongKey},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:     "Token from another issuer",
+			path:     "/api/jobs",
+			headers:  map[string]string{"Authorization": "Bearer " + wrongIssuer},
+			expected: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doRequest(s, "GET", tt.path, nil, tt.headers)
+			if w.Code != tt.expected {
+				t.Errorf("GET %s = %d; want %d", tt.path, w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestJWTAudience(t *testing.T) {
+	s := newTestServer(t, "test-secret")
+	s.cfg.Server.JWTAudience = "gateway"
+
+	token, err := s.generateJWT("api", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("generateJWT: %v", err)
+	}
+	if _, err := s.validateJWT(token); err != nil {
+		t.Errorf("token with configured audience rejected: %v", err)
+	}
+
+	s.cfg.Server.JWTAudience = "other"
+	if _, err := s.validateJWT(token); err == nil {
+		t.Errorf("token with mismatched audience accepted")
+	}
+}
+
+func TestJobClaims(t *testing.T) {
+	const apiKey = "test-secret"
+	s := newTestServer(t, apiKey)
+	media := newMediaServer(t, "bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	submit := func(user string) string {
+		t.Helper()
+		token, err := s.generateJWT("api", time.Hour, map[string]any{"user": user})
+		if err != nil {
+			t.Fatalf("generateJWT: %v", err)
+		}
+		w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL, "filename": user}, map[string]string{"Authorization": "Bearer " + token})
+		id, _ := decodeData(t, w)["id"].(string)
+		waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+		return id
+	}
+	alice := submit("alice")
+	submit("bob")
+
+	token, _ := s.generateJWT("api", time.Hour, nil)
+	auth := map[string]string{"Authorization": "Bearer " + token}
+
+	data := decodeData(t, doRequest(s, "GET", "/api/status/"+alice, nil, auth))
+	if claims, _ := data["claims"].(map[string]any); claims["user"] != "alice" {
+		t.Errorf("claims = %v; want user alice", data["claims"])
+	}
+
+	w := doRequest(s, "GET", "/api/jobs?user=alice", nil, auth)
+	jobs, _ := decodeData(t, w)["jobs"].([]any)
+	if len(jobs) != 1 || jobs[0].(map[string]any)["id"] != alice {
+		t.Errorf("jobs for alice = %v; want only %s", jobs, alice)
+	}
+	etag := w.Header().Get("ETag")
+	if all := doRequest(s, "GET", "/api/jobs", nil, auth).Header().Get("ETag"); all == etag {
+		t.Errorf("filtered and unfiltered listings share ETag %s", etag)
+	}
+}
+
+func TestGenerateTokenPayload(t *testing.T) {
+	const apiKey = "test-secret"
+	s := newTestServer(t, apiKey)
+	payload := jsonBody{"payload": map[string]any{"user": "alice"}}
+
+	tests := []struct {
+		name    string
+		body    jsonBody
+		headers map[string]string
+		code    float64
+	}{
+		{"plain token", jsonBody{}, nil, 201},
+		{"payload without api_key", payload, nil, 401},
+		{"payload with wrong api_key", payload, map[string]string{APIKeyHeader: "guess"}, 401},
+		{"payload with api_key", payload, map[string]string{APIKeyHeader: apiKey}, 201},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				Code float64        `json:"code"`
+				Data map[string]any `json:"data"`
+			}
+			w := doRequest(s, "POST", "/api/auth/token", tt.body, tt.headers)
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if resp.Code != tt.code {
+				t.Fatalf("code = %v (%s); want %v", resp.Code, w.Body.String(), tt.code)
+			}
+			if tt.code != 201 {
+				return
+			}
+			claims, err := s.validateJWT(resp.Data["jwt"].(string))
+			if err != nil {
+				t.Fatalf("minted token is invalid: %v", err)
+			}
+			if want := tt.body["payload"]; want != nil && claims.Custom["user"] != "alice" {
+				t.Errorf("custom claims = %v; want %v", claims.Custom, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+)
+
+// DefaultJobWeight is the bandwidth weight of jobs that don't set one
+const DefaultJobWeight = 1
+
+// MaxJobWeight caps per-job weights so one job can't starve the rest entirely
+const MaxJobWeight = 100
+
+// bandwidthLimiter enforces a global download rate shared between active
+// jobs in proportion to their weights, and each job's own cap. Each job
+// gets a token bucket whose rate is recomputed whenever a job starts or
+// finishes. All transfers of a job, such as the video and audio streams
+// downloaded in parallel, draw from the same bucket.
+type bandwidthLimiter struct {
+	mu          sync.Mutex
+	rate        int64 // bytes per second across all jobs (0 = unlimited)
+	totalWeight int
+	shares      map[*bandwidthShare]struct{}
+
+	// schedule overrides rate while the time, read in loc, is in one of its windows
+	schedule []config.RateWindow
+	loc      *time.Location
+}
+
+// bandwidthShare is one job's slice of the global bandwidth
+type bandwidthShare struct {
+	limiter *bandwidthLimiter
+	weight  int
+	maxRate int64 // Bytes per second this job never exceeds (0 = no cap)
+
+	// Token bucket state, guarded by limiter.mu
+	tokens float64
+	last   time.Time
+}
+
+type bandwidthShareKey struct{}
+
+func newBandwidthLimiter() *bandwidthLimiter {
+	return &bandwidthLimiter{shares: make(map[*bandwidthShare]struct{})}
+}
+
+// SetRate changes the global limit; active jobs pick it up on their next read
+func (l *bandwidthLimiter) SetRate(bytesPerSec int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rate = bytesPerSec
+}
+
+// SetSchedule changes the time windows that override the global limit;
+// active jobs switch limits as the time crosses a window boundary
+func (l *bandwidthLimiter) SetSchedule(windows []config.RateWindow, loc *time.Location) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.schedule = windows
+	l.loc = loc
+}
+
+// currentRate returns the limit in effect at now: that of the first
+// schedule window containing it, else the global rate. l.mu must be held.
+func (l *bandwidthLimiter) currentRate(now time.Time) int64 {
+	if len(l.schedule) > 0 {
+		local := now.In(l.loc)
+		for _, w := range l.schedule {
+			if w.Contains(local) {
+				return w.Rate
+			}
+		}
+	}
+	return l.rate
+}
+
+// attach registers a job with the given weight and rate cap (0 = none) and
+// returns a context that carries its share, plus a release func to call
+// when the job ends
+func (l *bandwidthLimiter) attach(ctx context.Context, weight int, maxRate int64) (context.Context, func()) {
+	if weight <= 0 {
+		weight = DefaultJobWeight
+	}
+	share := &bandwidthShare{limiter: l, weight: weight, maxRate: maxRate, last: time.Now()}
+
+	l.mu.Lock()
+	l.shares[share] = struct{}{}
+	l.totalWeight += weight
+	l.mu.Unlock()
+
+	release := func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.shares[share]; ok {
+			delete(l.shares, share)
+			l.totalWeight -= weight
+		}
+	}
+	return context.WithValue(ctx, bandwidthShareKey{}, share), release
+}
+
+// jobRateCap returns the rate cap of a job that asked for requested bytes
+// per second under a server-wide cap of limit (0 = none for either): the
+// lower of the two that are set
+func jobRateCap(requested, limit int64) int64 {
+	if requested > 0 && (limit <= 0 || requested < limit) {
+		return requested
+	}
+	return limit
+}
+
+// bandwidthShareFrom returns the share attached to ctx, or nil
+func bandwidthShareFrom(ctx context.Context) *bandwidthShare {
+	share, _ := ctx.Value(bandwidthShareKey{}).(*bandwidthShare)
+	return share
+}
+
+// throttle blocks until n bytes read under ctx fit within the bandwidth
+// share attached to it; transfers without a share aren't limited
+func throttle(ctx context.Context, n int) error {
+	if share := bandwidthShareFrom(ctx); share != nil {
+		return share.wait(ctx, n)
+	}
+	return nil
+}
+
+// throttledBody reads a response body under the bandwidth share of ctx
+type throttledBody struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (t throttledBody) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if throttleErr := throttle(t.ctx, n); throttleErr != nil {
+			return n, throttleErr
+		}
+	}
+	return n, err
+}
+
+// wait blocks until n bytes fit within this job's share of the global rate
+func (b *bandwidthShare) wait(ctx context.Context, n int) error {
+	for {
+		delay := b.reserve(n)
+		if delay <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// reserve takes n tokens if available and returns 0, otherwise returns how
+// long to wait before trying again
+func (b *bandwidthShare) reserve(n int) time.Duration {
+	l := b.limiter
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var rate float64
+	if limit := l.currentRate(now); limit > 0 && l.totalWeight > 0 {
+		rate = float64(limit) * float64(b.weight) / float64(l.totalWeight)
+	}
+	if b.maxRate > 0 && (rate == 0 || float64(b.maxRate) < rate) {
+		rate = float64(b.maxRate)
+	}
+	if rate == 0 {
+		b.last = now // Don't bank tokens for unlimited periods
+		return 0
+	}
+
+	// Allow bursts of a quarter second so reads aren't chopped into tiny sleeps
+	burst := rate / 4
+	if burst < float64(n) {
+		burst = float64(n)
+	}
+
+	b.tokens += now.Sub(b.last).Seconds() * rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+
+	if b.tokens >= float64(n) {
+		b.tokens -= float64(n)
+		return 0
+	}
+	return time.Duration((float64(n) - b.tokens) / rate * float64(time.Second))
+}
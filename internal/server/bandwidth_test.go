@@ -0,0 +1,179 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestBandwidthLimiterWeights(t *testing.T) {
+	l := newBandwidthLimiter()
+	l.SetRate(1000)
+
+	heavyCtx, releaseHeavy := l.attach(context.Background(), 3, 0)
+	defer releaseHeavy()
+	lightCtx, releaseLight := l.attach(context.Background(), 1, 0)
+
+	heavy := bandwidthShareFrom(heavyCtx)
+	light := bandwidthShareFrom(lightCtx)
+
+	// With empty buckets, the wait for n bytes is n / share-rate:
+	// 750 B/s for the heavy job, 250 B/s for the light one
+	heavyWait := heavy.reserve(75)
+	lightWait := light.reserve(75)
+	if heavyWait < 90*time.Millisecond || heavyWait > 110*time.Millisecond {
+		t.Errorf("heavy wait = %v; want ~100ms", heavyWait)
+	}
+	if lightWait < 290*time.Millisecond || lightWait > 310*time.Millisecond {
+		t.Errorf("light wait = %v; want ~300ms", lightWait)
+	}
+
+	// Once the light job ends, the heavy one gets the whole rate
+	releaseLight()
+	heavy.tokens = 0
+	if wait := heavy.reserve(100); wait < 90*time.Millisecond || wait > 110*time.Millisecond {
+		t.Errorf("heavy wait after release = %v; want ~100ms", wait)
+	}
+
+	// No limit configured means no waiting
+	l.SetRate(0)
+	if wait := heavy.reserve(1 << 20); wait != 0 {
+		t.Errorf("unlimited wait = %v; want 0", wait)
+	}
+}
+
+func TestBandwidthJobCap(t *testing.T) {
+	l := newBandwidthLimiter()
+	ctx, release := l.attach(context.Background(), 1, 1000)
+	defer release()
+	share := bandwidthShareFrom(ctx)
+
+	// Without a global limit the cap alone applies
+	if wait := share.reserve(100); wait < 90*time.Millisecond || wait > 110*time.Millisecond {
+		t.Errorf("capped wait = %v; want ~100ms", wait)
+	}
+
+	// A global share below the cap wins, and one above it doesn't
+	l.SetRate(500)
+	share.tokens = 0
+	if wait := share.reserve(100); wait < 190*time.Millisecond || wait > 210*time.Millisecond {
+		t.Errorf("wait under a lower global rate = %v; want ~200ms", wait)
+	}
+	l.SetRate(1 << 20)
+	share.tokens = 0
+	if wait := share.reserve(100); wait < 90*time.Millisecond || wait > 110*time.Millisecond {
+		t.Errorf("wait under a higher global rate = %v; want ~100ms", wait)
+	}
+
+	tests := []struct {
+		requested, limit, expected int64
+	}{
+		{0, 0, 0},
+		{500, 0, 500},
+		{0, 1000, 1000},
+		{500, 1000, 500},
+		{2000, 1000, 1000}, // Requests can't raise the server cap
+	}
+	for _, tt := range tests {
+		if got := jobRateCap(tt.requested, tt.limit); got != tt.expected {
+			t.Errorf("jobRateCap(%d, %d) = %d; want %d", tt.requested, tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestBandwidthSchedule(t *testing.T) {
+	l := newBandwidthLimiter()
+	ctx, release := l.attach(context.Background(), 1, 0)
+	defer release()
+	share := bandwidthShareFrom(ctx)
+
+	inWindow := config.RateWindow{Start: 0, End: 24 * 60, Rate: 1000}
+	for d := range inWindow.Days {
+		inWindow.Days[d] = true
+	}
+	outside := inWindow
+	outside.Days = [7]bool{}
+
+	// A window containing now replaces the (unlimited) global rate
+	l.SetSchedule([]config.RateWindow{outside, inWindow}, time.Local)
+	if wait := share.reserve(100); wait < 90*time.Millisecond || wait > 110*time.Millisecond {
+		t.Errorf("wait inside window = %v; want ~100ms", wait)
+	}
+
+	// Outside every window the global rate applies
+	l.SetSchedule([]config.RateWindow{outside}, time.Local)
+	if wait := share.reserve(1 << 20); wait != 0 {
+		t.Errorf("wait outside window = %v; want 0", wait)
+	}
+}
+
+func TestParallelStreamsShareRateLimit(t *testing.T) {
+	s := newTestServer(t, "")
+	t.Setenv("PATH", "") // No ffmpeg: keep the streams unmerged
+	const streamSize = 25 * 1024
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", strconv.Itoa(streamSize))
+		w.Write([]byte(strings.Repeat("x", streamSize)))
+	}))
+	t.Cleanup(media.Close)
+
+	// 100 KB/s for the job: both 25 KB streams together need ~0.5s, while
+	// each stream alone within the cap would finish in ~0.25s
+	s.bandwidth.SetRate(100 * 1024)
+	ctx, release := s.bandwidth.attach(context.Background(), 1, 0)
+	defer release()
+
+	var mu sync.Mutex
+	var downloaded, total int64
+	progressFn := func(d, t int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		downloaded, total = d, t
+	}
+
+	format := &extractor.VideoFormat{URL: media.URL + "/v.mp4", AudioURL: media.URL + "/a.m4a", Ext: "mp4"}
+	start := time.Now()
+	if err := s.downloadVideoWithAudio(ctx, format, filepath.Join(t.TempDir(), "clip.mp4"), progressFn); err != nil {
+		t.Fatalf("downloadVideoWithAudio: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("two streams took %v; want >= ~0.5s under a combined 100 KB/s cap", elapsed)
+	}
+	if downloaded != 2*streamSize || total != 2*streamSize {
+		t.Errorf("progress = %d/%d; want %d/%d combined", downloaded, total, 2*streamSize, 2*streamSize)
+	}
+}
+
+func TestStreamShareRateLimit(t *testing.T) {
+	const size = 50 * 1024
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", size)))
+	}))
+	t.Cleanup(media.Close)
+
+	// 100 KB/s: the 50 KB stream needs ~0.5s
+	limiter := newBandwidthLimiter()
+	limiter.SetRate(100 * 1024)
+	ctx, release := limiter.attach(context.Background(), 1, 0)
+	defer release()
+
+	w := httptest.NewRecorder()
+	start := time.Now()
+	streamFile(ctx, w, media.URL+"/a.mp3", "a.mp3", nil, 0)
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("stream took %v; want >= ~0.5s under 100 KB/s", elapsed)
+	}
+	if w.Body.Len() != size {
+		t.Errorf("streamed %d bytes; want %d", w.Body.Len(), size)
+	}
+}
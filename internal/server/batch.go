@@ -0,0 +1,310 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+// batchWebhookTimeout bounds how long a batch completion webhook may take
+const batchWebhookTimeout = 10 * time.Second
+
+// batchTracker watches groups of jobs submitted together and fires one
+// webhook per group once every job in it has finished
+type batchTracker struct {
+	mu      sync.Mutex
+	batches map[string]*batch
+	jobs    *JobQueue
+	client  *http.Client
+	retry   func() downloader.RetryPolicy // Optional; a nil func sends once
+}
+
+// batch is a group of jobs awaiting completion
+type batch struct {
+	group   string
+	webhook string
+	jobIDs  []string
+
+	// untrusted marks a webhook taken from the request rather than
+	// server.batch_webhook; it may only reach public addresses
+	untrusted bool
+}
+
+// errWebhookNotAllowed rejects a request webhook that points at a blocked
+// domain or a private address
+var errWebhookNotAllowed = errors.New("webhook not allowed")
+
+// errWebhookConflict rejects a webhook for a group that is still pending
+// with a different one
+var errWebhookConflict = errors.New("group already has a different webhook")
+
+// BatchSummary is the payload POSTed to a batch webhook
+type BatchSummary struct {
+	Group     string            `json:"group"`
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Partial   int               `json:"partial"`
+	Failed    int               `json:"failed"`
+	Cancelled int               `json:"cancelled"`
+	Files     []string          `json:"files"`
+	Jobs      []BatchJobSummary `json:"jobs"`
+}
+
+// BatchJobSummary is the final state of one job in a batch
+type BatchJobSummary struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	Status   JobStatus `json:"status"`
+	Filename string    `json:"filename,omitempty"`
+	Error    string    `json:"error,omitempty"`
+
+	Claims map[string]any `json:"claims,omitempty"` // Custom claims of the submitting token
+}
+
+func newBatchTracker(jobs *JobQueue) *batchTracker {
+	return &batchTracker{
+		batches: make(map[string]*batch),
+		jobs:    jobs,
+		client:  &http.Client{Timeout: batchWebhookTimeout},
+	}
+}
+
+// publicClient is the client for request webhooks: its dialer refuses
+// private addresses, including ones a host name resolves to only after
+// checkWebhook accepted it
+var publicClient = &http.Client{
+	Timeout: batchWebhookTimeout,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout: batchWebhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+					return fmt.Errorf("%w: %s is a private address", errWebhookNotAllowed, host)
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+// privateIP reports whether ip is on the server's own host or network
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast()
+}
+
+// checkWebhook validates a webhook URL given in a request: it must be
+// http(s), pass the domain policy, and not resolve to a private address,
+// so callers can't make the server POST into its own network
+func (s *Server) checkWebhook(ctx context.Context, webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: invalid URL", errWebhookNotAllowed)
+	}
+	if err := s.checkDomain(webhook); err != nil {
+		return fmt.Errorf("%w: %v", errWebhookNotAllowed, err)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: cannot resolve %s", errWebhookNotAllowed, u.Hostname())
+	}
+	for _, addr := range addrs {
+		if privateIP(addr.IP) {
+			return fmt.Errorf("%w: %s is a private address", errWebhookNotAllowed, u.Hostname())
+		}
+	}
+	return nil
+}
+
+// checkGroupWebhook fails with errWebhookConflict if group is still
+// pending with a webhook other than webhook
+func (b *batchTracker) checkGroupWebhook(group, webhook string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if pending, ok := b.batches[group]; ok && pending.webhook != webhook {
+		return fmt.Errorf("%w: %s", errWebhookConflict, group)
+	}
+	return nil
+}
+
+// track starts watching a group, adding to it if the group is still
+// pending. A pending group keeps its first webhook. Jobs may already have
+// finished (e.g., rejected URLs), so completion is checked right away.
+func (b *batchTracker) track(group, webhook string, untrusted bool, jobIDs []string) {
+	b.mu.Lock()
+	if pending, ok := b.batches[group]; ok {
+		pending.jobIDs = append(pending.jobIDs, jobIDs...)
+	} else {
+		b.batches[group] = &batch{group: group, webhook: webhook, jobIDs: jobIDs, untrusted: untrusted}
+	}
+	b.mu.Unlock()
+
+	b.check(group)
+}
+
+// add adds jobs to a group that is still pending, so its webhook also
+// waits for them. Jobs of other groups aren't tracked.
+func (b *batchTracker) add(group string, jobIDs []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if pending, ok := b.batches[group]; ok {
+		pending.jobIDs = append(pending.jobIDs, jobIDs...)
+	}
+}
+
+// onJobEvent re-checks the job's group when the job reaches a final state
+func (b *batchTracker) onJobEvent(event jobEvent) {
+	if event.Group == "" || !isFinished(JobStatus(event.Event)) {
+		return
+	}
+	b.check(event.Group)
+}
+
+// check fires the group's webhook if all of its jobs are finished.
+// Jobs removed from the queue in the meantime are left out of the summary.
+func (b *batchTracker) check(group string) {
+	b.mu.Lock()
+	pending, ok := b.batches[group]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+
+	summary := BatchSummary{Group: group, Total: len(pending.jobIDs), Files: []string{}}
+	for _, id := range pending.jobIDs {
+		job := b.jobs.GetJob(id)
+		if job == nil {
+			continue
+		}
+		if !isFinished(job.Status) {
+			b.mu.Unlock()
+			return
+		}
+
+		switch job.Status {
+		case JobStatusCompleted:
+			summary.Succeeded++
+		case JobStatusPartial:
+			summary.Partial++
+		case JobStatusCancelled:
+			summary.Cancelled++
+		default:
+			summary.Failed++
+		}
+		summary.Files = append(summary.Files, jobFiles(job)...)
+		summary.Jobs = append(summary.Jobs, BatchJobSummary{
+			ID:       job.ID,
+			URL:      job.URL,
+			Status:   job.Status,
+			Filename: job.Filename,
+			Error:    job.Error,
+			Claims:   job.Options.Claims,
+		})
+	}
+
+	// Fire at most once per group
+	delete(b.batches, group)
+	b.mu.Unlock()
+
+	client := b.client
+	if pending.untrusted {
+		client = publicClient
+	}
+	go b.send(client, pending.webhook, summary)
+}
+
+// send POSTs the summary with client, retrying network errors, 5xx, and
+// 429 responses with the retry policy and logging the final failure
+func (b *batchTracker) send(client *http.Client, webhook string, summary BatchSummary) {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return
+	}
+
+	policy := downloader.RetryPolicy{MaxAttempts: 1}
+	if b.retry != nil {
+		policy = b.retry()
+	}
+	err = downloader.Retry(context.Background(), policy, func() error {
+		return b.post(client, webhook, body)
+	})
+	if err != nil {
+		log.Printf("Warning: batch webhook for group %s failed: %v", summary.Group, err)
+	}
+}
+
+// post makes one webhook request. Errors retrying can't fix are Permanent.
+func (b *batchTracker) post(client *http.Client, webhook string, body []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), batchWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", webhook, bytes.NewReader(body))
+	if err != nil {
+		return downloader.Permanent(errors.New("invalid webhook URL"))
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error embeds the webhook URL, which may carry a secret token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactURL(urlErr.URL, defaultRedactedParams)
+		}
+		if errors.Is(err, errWebhookNotAllowed) {
+			return downloader.Permanent(err)
+		}
+		return err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("returned status %d", resp.StatusCode)
+	case resp.StatusCode >= 300:
+		return downloader.Permanent(fmt.Errorf("returned status %d", resp.StatusCode))
+	}
+	return nil
+}
+
+// jobFiles returns the output files of a finished job
+func jobFiles(job *Job) []string {
+	if len(job.Items) > 0 {
+		var files []string
+		for _, item := range job.Items {
+			if item.Error == "" && item.Filename != "" {
+				files = append(files, item.Filename)
+			}
+		}
+		return files
+	}
+	if job.Status == JobStatusCompleted && job.Filename != "" {
+		return []string{job.Filename}
+	}
+	return nil
+}
+
+// newBatchGroup generates a group id for a batch submitted without one
+func newBatchGroup() (string, error) {
+	id, err := generateJobID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate group id: %w", err)
+	}
+	return "batch-" + id, nil
+}
@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// Benchmark defaults and limits
+const (
+	BenchmarkDefaultMaxBytes = 32 << 20
+	BenchmarkMaxBytes        = 1 << 30
+	BenchmarkMaxConcurrency  = 32
+	BenchmarkMaxTrials       = 24
+	BenchmarkMinBuffer       = 1 << 10
+	BenchmarkMaxBuffer       = 16 << 20
+)
+
+var (
+	benchmarkDefaultConcurrency = []int{1, 2, 4, 8}
+	benchmarkDefaultBuffers     = []string{"32KB", "256KB", "1MB"}
+)
+
+// BenchmarkRequest is the request body for POST /benchmark. Every
+// combination of Concurrency and BufferSizes is tried once.
+type BenchmarkRequest struct {
+	URL string `json:"url" binding:"required"`
+
+	// Concurrency lists the numbers of parallel connections to try
+	// (default [1, 2, 4, 8])
+	Concurrency []int `json:"concurrency,omitempty"`
+
+	// BufferSizes lists the read buffer sizes to try (default
+	// ["32KB", "256KB", "1MB"])
+	BufferSizes []string `json:"buffer_sizes,omitempty"`
+
+	// MaxBytes caps how much of the URL each trial downloads (default "32MB")
+	MaxBytes string `json:"max_bytes,omitempty"`
+
+	// InsecureSkipVerify overrides server.insecure_skip_verify (see DownloadRequest)
+	InsecureSkipVerify *bool `json:"insecure_skip_verify,omitempty"`
+}
+
+// BenchmarkResult is the outcome of one concurrency and buffer size trial
+type BenchmarkResult struct {
+	Concurrency    int     `json:"concurrency"`
+	BufferSize     int     `json:"buffer_size"`
+	Bytes          int64   `json:"bytes"`
+	Seconds        float64 `json:"seconds"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	Mbps           float64 `json:"mbps"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// handleBenchmark downloads a URL once per concurrency and buffer size
+// combination into a temporary file, which is removed after each trial, and
+// reports the throughput of each. Trials run one at a time, as does the
+// endpoint, so they don't compete for bandwidth. The global rate limit
+// doesn't apply.
+func (s *Server) handleBenchmark(c *gin.Context) {
+	if !s.config().Server.EnableBenchmark {
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
+			Data:    nil,
+			Message: "benchmark is disabled: set server.enable_benchmark to true",
+		})
+		return
+	}
+
+	var req BenchmarkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: "invalid request body: url is required",
+		})
+		return
+	}
+
+	concurrency, buffers, maxBytes, err := benchmarkSettings(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := s.checkDomain(req.URL); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errDomainNotAllowed) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, Response{
+			Code:    status,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+	url, _ := extractor.NormalizeURL(req.URL) // Validated by checkDomain above
+
+	if !s.benchmark.TryLock() {
+		c.JSON(http.StatusConflict, Response{
+			Code:    409,
+			Data:    nil,
+			Message: "a benchmark is already running",
+		})
+		return
+	}
+	defer s.benchmark.Unlock()
+
+	ctx := c.Request.Context()
+	insecure := s.config().Server.InsecureSkipVerify
+	if req.InsecureSkipVerify != nil {
+		insecure = *req.InsecureSkipVerify
+	}
+	ctx = s.transferContext(ctx, insecure)
+
+	size, ranges, err := probeBenchmarkURL(ctx, url)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Data:    nil,
+			Message: fmt.Sprintf("benchmark request failed: %v", err),
+		})
+		return
+	}
+	trialBytes := maxBytes
+	if size >= 0 && size < trialBytes {
+		trialBytes = size
+	}
+
+	var results []BenchmarkResult
+	var best *BenchmarkResult
+	for _, n := range concurrency {
+		for _, buf := range buffers {
+			result := runBenchmarkTrial(ctx, url, trialBytes, ranges, n, buf)
+			results = append(results, result)
+			if ctx.Err() != nil {
+				return // Client went away
+			}
+		}
+	}
+	for i := range results {
+		if results[i].Error == "" && (best == nil || results[i].BytesPerSecond > best.BytesPerSecond) {
+			best = &results[i]
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Data: gin.H{
+			"url":             url,
+			"size":            size,
+			"range_supported": ranges,
+			"bytes_per_trial": trialBytes,
+			"results":         results,
+			"best":            best,
+		},
+		Message: fmt.Sprintf("%d trials completed", len(results)),
+	})
+}
+
+// benchmarkSettings validates a benchmark request and fills in defaults,
+// returning the concurrency levels, buffer sizes in bytes and byte cap
+func benchmarkSettings(req BenchmarkRequest) ([]int, []int, int64, error) {
+	concurrency := req.Concurrency
+	if len(concurrency) == 0 {
+		concurrency = benchmarkDefaultConcurrency
+	}
+	for _, n := range concurrency {
+		if n < 1 || n > BenchmarkMaxConcurrency {
+			return nil, nil, 0, fmt.Errorf("invalid concurrency %d: must be between 1 and %d", n, BenchmarkMaxConcurrency)
+		}
+	}
+
+	names := req.BufferSizes
+	if len(names) == 0 {
+		names = benchmarkDefaultBuffers
+	}
+	buffers := make([]int, len(names))
+	for i, name := range names {
+		n, err := config.ParseByteSize(name)
+		if err != nil || n < BenchmarkMinBuffer || n > BenchmarkMaxBuffer {
+			return nil, nil, 0, fmt.Errorf("invalid buffer size %q: must be between 1KB and 16MB", name)
+		}
+		buffers[i] = int(n)
+	}
+
+	if trials := len(concurrency) * len(buffers); trials > BenchmarkMaxTrials {
+		return nil, nil, 0, fmt.Errorf("too many trials (%d): at most %d concurrency and buffer size combinations", trials, BenchmarkMaxTrials)
+	}
+
+	maxBytes := int64(BenchmarkDefaultMaxBytes)
+	if req.MaxBytes != "" {
+		n, err := config.ParseByteSize(req.MaxBytes)
+		if err != nil || n <= 0 || n > BenchmarkMaxBytes {
+			return nil, nil, 0, fmt.Errorf("invalid max_bytes %q: must be between 1B and 1GB", req.MaxBytes)
+		}
+		maxBytes = n
+	}
+	return concurrency, buffers, maxBytes, nil
+}
+
+// probeBenchmarkURL requests the first byte of url, returning its size (-1
+// if unknown) and whether the server honors range requests
+func probeBenchmarkURL(ctx context.Context, url string) (int64, bool, error) {
+	resp, err := benchmarkGet(ctx, url, "bytes=0-0")
+	if err != nil {
+		return 0, false, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode == http.StatusPartialContent {
+		// Content-Range: bytes 0-0/12345
+		_, total, _ := strings.Cut(resp.Header.Get("Content-Range"), "/")
+		if size, err := strconv.ParseInt(total, 10, 64); err == nil {
+			return size, true, nil
+		}
+		return -1, true, nil
+	}
+	return resp.ContentLength, false, nil
+}
+
+// runBenchmarkTrial downloads the first size bytes of url over n parallel
+// connections, each reading with a buffer of bufSize bytes into its part of
+// a temporary file. Without range support every connection reads the start
+// of the body, which still measures n simultaneous transfers.
+func runBenchmarkTrial(ctx context.Context, url string, size int64, ranges bool, n, bufSize int) BenchmarkResult {
+	result := BenchmarkResult{Concurrency: n, BufferSize: bufSize}
+
+	file, err := os.CreateTemp("", "vget-benchmark-*")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	part := (size + int64(n) - 1) / int64(n)
+	var downloaded atomic.Int64
+	var wg sync.WaitGroup
+	var once sync.Once
+	start := time.Now()
+	for i := range n {
+		offset := int64(i) * part
+		length := min(part, size-offset)
+		if length <= 0 {
+			break
+		}
+		wg.Go(func() {
+			err := benchmarkPart(ctx, file, url, ranges, offset, length, bufSize, &downloaded)
+			if err != nil {
+				once.Do(func() { result.Error = err.Error() })
+			}
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	result.Bytes = downloaded.Load()
+	result.Seconds = elapsed.Seconds()
+	if result.Seconds > 0 {
+		result.BytesPerSecond = float64(result.Bytes) / result.Seconds
+		result.Mbps = result.BytesPerSecond * 8 / 1e6
+	}
+	return result
+}
+
+// benchmarkPart downloads length bytes starting at offset and writes them at
+// the same offset of file
+func benchmarkPart(ctx context.Context, file *os.File, url string, ranges bool, offset, length int64, bufSize int, downloaded *atomic.Int64) error {
+	rangeHeader := ""
+	if ranges {
+		rangeHeader = fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)
+	}
+	resp, err := benchmarkGet(ctx, url, rangeHeader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body := io.LimitReader(resp.Body, length)
+	buf := make([]byte, bufSize)
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			if _, err := file.WriteAt(buf[:n], offset); err != nil {
+				return err
+			}
+			offset += int64(n)
+			downloaded.Add(int64(n))
+		}
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+}
+
+// benchmarkGet sends a GET for url with an optional Range header, failing on
+// anything but 200 or 206
+func benchmarkGet(ctx context.Context, url, rangeHeader string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+
+	resp, err := newDownloadClient(ctx).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBenchmark(t *testing.T) {
+	s := newTestServer(t, "")
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "file.bin", time.Time{}, bytes.NewReader(content))
+	}))
+	t.Cleanup(media.Close)
+	body := jsonBody{"url": media.URL + "/file.bin", "concurrency": []int{1, 4}, "buffer_sizes": []string{"4KB"}}
+
+	// Off unless enabled
+	if w := doRequest(s, "POST", "/api/benchmark", body, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("disabled benchmark status = %d; want 403", w.Code)
+	}
+	s.cfg.Server.EnableBenchmark = true
+
+	w := doRequest(s, "POST", "/api/benchmark", body, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("benchmark status = %d; body %s", w.Code, w.Body.String())
+	}
+	data := decodeData(t, w)
+	if data["range_supported"] != true || data["bytes_per_trial"] != float64(len(content)) {
+		t.Errorf("benchmark = %v", data)
+	}
+	results, _ := data["results"].([]any)
+	if len(results) != 2 {
+		t.Fatalf("results = %v; want 2 trials", data["results"])
+	}
+	for _, r := range results {
+		result := r.(map[string]any)
+		if result["error"] != nil || result["bytes"] != float64(len(content)) || result["buffer_size"] != float64(4096) {
+			t.Errorf("trial = %v; want all %d bytes with a 4096 byte buffer", result, len(content))
+		}
+	}
+	if data["best"] == nil {
+		t.Error("best trial missing")
+	}
+
+	body["concurrency"] = []int{64}
+	if w := doRequest(s, "POST", "/api/benchmark", body, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("concurrency 64 status = %d; want 400", w.Code)
+	}
+}
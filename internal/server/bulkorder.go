@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+// Dispatch orders for a bulk batch (see BulkDownloadRequest.Order)
+const (
+	BulkOrderAsListed      = "as_listed"
+	BulkOrderSmallestFirst = "smallest_first"
+	BulkOrderLargestFirst  = "largest_first"
+)
+
+const (
+	bulkProbeTimeout = 10 * time.Second // Overall limit for sizing a batch
+	bulkProbeWorkers = 8                // Concurrent HEAD requests
+)
+
+// validBulkOrder reports whether order is a known dispatch order ("" means
+// as_listed)
+func validBulkOrder(order string) bool {
+	switch order {
+	case "", BulkOrderAsListed, BulkOrderSmallestFirst, BulkOrderLargestFirst:
+		return true
+	}
+	return false
+}
+
+// orderBulkURLs returns urls in the order their jobs should be queued.
+// Sizes come from HEAD requests: URLs of direct files with a known
+// Content-Length are sorted by size, and the rest (pages, unknown sizes,
+// failed probes) follow in list order. Without any known size the list is
+// returned unchanged.
+func (s *Server) orderBulkURLs(ctx context.Context, urls []string, order string) []string {
+	if order != BulkOrderSmallestFirst && order != BulkOrderLargestFirst {
+		return urls
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bulkProbeTimeout)
+	defer cancel()
+
+	sizes := make([]int64, len(urls))
+	sem := make(chan struct{}, bulkProbeWorkers)
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		sizes[i] = -1
+		url = strings.TrimSpace(url)
+		if url == "" || strings.HasPrefix(url, "#") || s.checkDomain(url) != nil {
+			continue
+		}
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			sizes[i] = probeBulkSize(ctx, url)
+		})
+	}
+	wg.Wait()
+
+	indexes := make([]int, len(urls))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		sa, sb := sizes[indexes[a]], sizes[indexes[b]]
+		if sa < 0 || sb < 0 {
+			return sa >= 0 && sb < 0 // Known sizes first
+		}
+		if order == BulkOrderLargestFirst {
+			return sa > sb
+		}
+		return sa < sb
+	})
+
+	ordered := make([]string, len(urls))
+	for i, index := range indexes {
+		ordered[i] = urls[index]
+	}
+	return ordered
+}
+
+// probeBulkSize returns the size of the file at url from a HEAD request, or
+// -1 if it's unknown or url is a web page rather than a file
+func probeBulkSize(ctx context.Context, url string) int64 {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return -1
+	}
+	req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+	resp, err := newDownloadClient(ctx).Do(req)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || strings.HasPrefix(strings.ToLower(resp.Header.Get("Content-Type")), "text/html") {
+		return -1
+	}
+	return resp.ContentLength
+}
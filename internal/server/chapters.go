@@ -0,0 +1,102 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// saveChapters preserves a video's chapter markers once it is saved at
+// finalPath: embedded with ffmpeg when embed_chapters is set and possible,
+// otherwise written to a .chapters sidecar (an FFMETADATA file, which
+// ffmpeg can embed later). The video is already saved, so failures are
+// only logged.
+func (s *Server) saveChapters(ctx context.Context, jobID string, file plannedFile, finalPath string) {
+	if len(file.chapters) == 0 || file.start > 0 || file.end > 0 {
+		// Clips would need every marker shifted and cut; leave them out
+		return
+	}
+	metadata := chaptersMetadata(file.chapters)
+
+	if s.config().EmbedChapters && s.store().IsLocal() && downloader.FFmpegAvailable() {
+		err := s.embedChapters(ctx, finalPath, metadata)
+		if err == nil {
+			return
+		}
+		logf(ctx, "Warning: failed to embed chapters into %s, writing a sidecar: %v", finalPath, err)
+	}
+
+	sidecar := strings.TrimSuffix(finalPath, path.Ext(finalPath)) + ".chapters"
+	w, err := s.store().Create(sidecar)
+	if err != nil {
+		logf(ctx, "Warning: failed to save chapters for %s: %v", finalPath, err)
+		return
+	}
+	if _, err := w.Write([]byte(metadata)); err != nil {
+		w.Abort()
+		logf(ctx, "Warning: failed to save chapters for %s: %v", finalPath, err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		logf(ctx, "Warning: failed to save chapters for %s: %v", finalPath, err)
+		return
+	}
+	s.addSidecar(jobID, sidecar)
+}
+
+// embedChapters rewrites the local video at videoPath with the chapters of
+// metadata, replacing it only once ffmpeg succeeded
+func (s *Server) embedChapters(ctx context.Context, videoPath, metadata string) error {
+	dir, err := os.MkdirTemp("", "vget-chapters-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	metadataPath := filepath.Join(dir, "chapters.txt")
+	if err := os.WriteFile(metadataPath, []byte(metadata), 0644); err != nil {
+		return err
+	}
+
+	output := filepath.Join(dir, "video"+filepath.Ext(videoPath))
+	release, err := s.ffmpeg.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = downloader.EmbedChapters(videoPath, metadataPath, output)
+	release()
+	if err != nil {
+		return err
+	}
+	return storage.Upload(localFiles, output, videoPath)
+}
+
+// chaptersMetadata renders chapters as an FFMETADATA file with millisecond
+// timestamps. Chapters without an end run until the next one starts.
+func chaptersMetadata(chapters []extractor.Chapter) string {
+	var b strings.Builder
+	b.WriteString(";FFMETADATA1\n")
+	for i, ch := range chapters {
+		end := ch.End
+		if end <= ch.Start && i+1 < len(chapters) {
+			end = chapters[i+1].Start
+		}
+		fmt.Fprintf(&b, "\n[CHAPTER]\nTIMEBASE=1/1000\nSTART=%d\nEND=%d\ntitle=%s\n",
+			int64(ch.Start*1000), int64(max(end, ch.Start)*1000), escapeMetadata(ch.Title))
+	}
+	return b.String()
+}
+
+// metadataEscaper escapes the characters FFMETADATA values treat specially
+var metadataEscaper = strings.NewReplacer(`\`, `\\`, "=", `\=`, ";", `\;`, "#", `\#`, "\n", "\\\n")
+
+func escapeMetadata(value string) string {
+	return metadataEscaper.Replace(value)
+}
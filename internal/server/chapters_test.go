@@ -0,0 +1,68 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestChaptersMetadata(t *testing.T) {
+	got := chaptersMetadata([]extractor.Chapter{
+		{Title: "Intro", Start: 0, End: 12.5},
+		{Title: "Q&A; a=b #1", Start: 12.5}, // No end: runs until the next chapter
+		{Title: "Outro", Start: 90, End: 95},
+	})
+	expected := `;FFMETADATA1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=0
+END=12500
+title=Intro
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=12500
+END=90000
+title=Q&A\; a\=b \#1
+
+[CHAPTER]
+TIMEBASE=1/1000
+START=90000
+END=95000
+title=Outro
+`
+	if got != expected {
+		t.Errorf("chaptersMetadata() =\n%s\nwant\n%s", got, expected)
+	}
+}
+
+func TestDownloadChaptersSidecar(t *testing.T) {
+	s := newTestServer(t, "")
+	t.Setenv("PATH", "") // No ffmpeg, so embedding falls back to the sidecar
+	s.cfg.EmbedChapters = true
+	media := newMediaServer(t, "bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:       "abc",
+		Title:    "clip",
+		Formats:  []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+		Chapters: []extractor.Chapter{{Title: "Intro", Start: 0, End: 5}},
+	}})
+
+	w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+	id, _ := decodeData(t, w)["id"].(string)
+	job := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+	if job.Status != JobStatusCompleted {
+		t.Fatalf("job status = %s (error: %s); want completed", job.Status, job.Error)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.outputDir, "clip.chapters"))
+	if err != nil {
+		t.Fatalf("chapters sidecar missing: %v", err)
+	}
+	if string(data) != chaptersMetadata([]extractor.Chapter{{Title: "Intro", Start: 0, End: 5}}) {
+		t.Errorf("sidecar = %q", data)
+	}
+}
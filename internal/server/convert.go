@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// JobConversion records a video converted to the convert_to container
+type JobConversion struct {
+	Original   string `json:"original"`             // Path as downloaded (removed once converted)
+	Converted  string `json:"converted,omitempty"`  // Path of the converted file
+	Transcoded bool   `json:"transcoded,omitempty"` // Codecs had to be re-encoded, not just copied
+	Error      string `json:"error,omitempty"`      // Why the original was kept
+}
+
+// convertOutput converts a video saved at finalPath to the convert_to
+// container, recording the result on the job jobID, and returns the path
+// the video ended up at. Only local videos are converted, and only when
+// ffmpeg is available. The download already succeeded, so a failed
+// conversion keeps the original and is only recorded.
+func (s *Server) convertOutput(ctx context.Context, jobID string, file plannedFile, finalPath string) string {
+	container := s.config().ConvertTo
+	if container == "" || !file.video || !s.store().IsLocal() || !downloader.FFmpegAvailable() {
+		return finalPath
+	}
+	ext := filepath.Ext(finalPath)
+	if strings.EqualFold(strings.TrimPrefix(ext, "."), container) {
+		return finalPath
+	}
+
+	conversion := JobConversion{Original: finalPath}
+	output := strings.TrimSuffix(finalPath, ext) + "." + container
+	transcoded, err := s.convertContainer(ctx, finalPath, output, container)
+	if err != nil {
+		logf(ctx, "Warning: failed to convert %s to %s, keeping the original: %v", finalPath, container, err)
+		conversion.Error = err.Error()
+	} else {
+		conversion.Converted, conversion.Transcoded = output, transcoded
+		if err := s.store().Remove(finalPath); err != nil {
+			logf(ctx, "Warning: failed to remove %s after converting it: %v", finalPath, err)
+		}
+	}
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.Conversions = append(j.Conversions, conversion) })
+	if err != nil {
+		return finalPath
+	}
+	return output
+}
+
+// convertContainer converts the local video at videoPath into output,
+// staging it so output only appears once ffmpeg succeeded
+func (s *Server) convertContainer(ctx context.Context, videoPath, output, container string) (bool, error) {
+	dir, err := os.MkdirTemp("", "vget-convert-")
+	if err != nil {
+		return false, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	staged := filepath.Join(dir, "video."+container)
+	release, err := s.ffmpeg.acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	transcoded, err := downloader.ConvertContainer(videoPath, staged, container)
+	release()
+	if err != nil {
+		return false, err
+	}
+	return transcoded, storage.Upload(localFiles, staged, output)
+}
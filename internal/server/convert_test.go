@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestConvertToConfig(t *testing.T) {
+	s := newTestServer(t, "")
+
+	w := doRequest(s, "POST", "/api/config", jsonBody{"key": "convert_to", "value": "avi"}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("POST /api/config convert_to=avi = %d; want 400", w.Code)
+	}
+	w = doRequest(s, "POST", "/api/config", jsonBody{"key": "convert_to", "value": "mkv"}, nil)
+	if w.Code != http.StatusOK || s.cfg.ConvertTo != "mkv" {
+		t.Errorf("POST /api/config convert_to=mkv = %d, convert_to %q; want 200, mkv", w.Code, s.cfg.ConvertTo)
+	}
+}
+
+func TestConvertToWithoutFFmpeg(t *testing.T) {
+	s := newTestServer(t, "")
+	t.Setenv("PATH", "") // No ffmpeg, so the original is kept
+	s.cfg.ConvertTo = "mkv"
+	media := newMediaServer(t, "bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+	id, _ := decodeData(t, w)["id"].(string)
+	job := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+	if job.Status != JobStatusCompleted {
+		t.Fatalf("job status = %s (error: %s); want completed", job.Status, job.Error)
+	}
+	if _, err := os.Stat(filepath.Join(s.outputDir, "clip.mp4")); err != nil {
+		t.Errorf("original missing: %v", err)
+	}
+	if len(job.Conversions) != 0 {
+		t.Errorf("conversions = %+v; want none without ffmpeg", job.Conversions)
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// Values for JobUpload.Status
+const (
+	UploadUploading = "uploading"
+	UploadCompleted = "completed"
+	UploadFailed    = "failed"
+)
+
+// JobUpload reports a job's upload of its finished files to the
+// configured destination
+type JobUpload struct {
+	Status   string   `json:"status"`
+	Uploaded int64    `json:"uploaded"` // bytes uploaded
+	Total    int64    `json:"total"`    // total bytes to upload
+	Files    []string `json:"files,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// uploadToDestination uploads a job's saved files to the configured
+// destination, if any, recording its progress on the job. A failed upload
+// fails the job unless destination.fail_on_error is off; either way the
+// downloaded files are kept. items are the results of a partial download,
+// updated like the job's filename when the local files are removed.
+func (s *Server) uploadToDestination(ctx context.Context, jobID string, paths []string, items []JobItem) error {
+	cfg := s.config().Destination
+	if cfg.Type == "" || len(paths) == 0 {
+		return nil
+	}
+
+	var sidecars []string
+	if job := s.jobQueue.GetJob(jobID); job != nil {
+		sidecars = job.sidecars
+	}
+	err := s.uploadFiles(ctx, jobID, paths, sidecars, items)
+	if err == nil {
+		return nil
+	}
+	s.updateUpload(jobID, func(u *JobUpload) {
+		u.Status = UploadFailed
+		u.Error = err.Error()
+	})
+	// The download itself succeeded, so failure cleanup must not remove it
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.outputs = nil })
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if cfg.FailOnErrorEnabled() {
+		return fmt.Errorf("upload to destination failed: %w", err)
+	}
+	logf(ctx, "Warning: upload to destination failed for job %s: %v", jobID, err)
+	return nil
+}
+
+// uploadFiles copies paths and their sidecars from the output storage to
+// the destination, keeping their layout under the output root, then removes
+// the originals when destination.delete_local is set. The job's filename
+// and items then point at the uploaded copies.
+func (s *Server) uploadFiles(ctx context.Context, jobID string, paths, sidecars []string, items []JobItem) error {
+	cfg := s.config().Destination
+	dest, err := storage.NewDestination(cfg)
+	if err != nil {
+		return err
+	}
+	saved := len(paths)
+	paths = append(slices.Clone(paths), sidecars...)
+
+	sizes := make([]int64, len(paths))
+	var total int64
+	for i, p := range paths {
+		info, err := s.store().Stat(p)
+		if err != nil {
+			return err
+		}
+		sizes[i] = info.Size
+		total += info.Size
+	}
+	s.updateUpload(jobID, func(u *JobUpload) {
+		*u = JobUpload{Status: UploadUploading, Total: total}
+	})
+
+	agg := newProgressAggregator(func(uploaded, _ int64) {
+		s.updateUpload(jobID, func(u *JobUpload) { u.Uploaded = uploaded })
+	}, progressInterval)
+	report := agg.source()
+
+	var done int64
+	locations := make(map[string]string, len(paths))
+	for i, p := range paths {
+		name := dest.Join(s.destinationName(p))
+		err := s.uploadFile(ctx, p, dest, name, func(uploaded, _ int64) {
+			report(done+uploaded, total)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to upload %s: %w", name, err)
+		}
+		done += sizes[i]
+		locations[p] = destinationLocation(cfg, name)
+		s.updateUpload(jobID, func(u *JobUpload) { u.Files = append(u.Files, name) })
+	}
+	agg.flush()
+
+	if cfg.DeleteLocal {
+		for _, p := range paths {
+			if err := s.store().Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				log.Printf("Warning: failed to remove uploaded file %s: %v", p, err)
+			}
+		}
+		uploaded := make([]string, saved)
+		for i, p := range paths[:saved] {
+			uploaded[i] = locations[p]
+		}
+		s.updateJobFilename(jobID, strings.Join(uploaded, ", "))
+		for i, item := range items {
+			if location, ok := locations[item.Filename]; ok {
+				items[i].Filename = location
+			}
+		}
+	}
+	s.updateUpload(jobID, func(u *JobUpload) { u.Status = UploadCompleted })
+	return nil
+}
+
+// destinationLocation returns where the uploaded file name is found: its
+// URL on a WebDAV destination, or s3://<bucket>/<key>
+func destinationLocation(cfg config.DestinationConfig, name string) string {
+	if cfg.Type == config.DestinationWebDAV {
+		return strings.TrimSuffix(cfg.URL, "/") + "/" + strings.TrimPrefix(name, "/")
+	}
+	return "s3://" + cfg.Bucket + "/" + strings.TrimPrefix(name, "/")
+}
+
+// uploadFile copies the stored file p to name in dest
+func (s *Server) uploadFile(ctx context.Context, p string, dest storage.Storage, name string, progressFn func(uploaded, total int64)) error {
+	src, err := s.store().Open(p)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := dest.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := copyWithProgress(ctx, w, src, -1, progressFn); err != nil {
+		w.Abort()
+		return err
+	}
+	return w.Close()
+}
+
+// destinationName returns a stored file's path relative to the output
+// root, so uploads mirror its layout (e.g., date_partition directories).
+// Files outside the root keep only their base name.
+func (s *Server) destinationName(p string) string {
+	st := s.store()
+	root := st.Join()
+	if st.IsLocal() {
+		if rel, err := filepath.Rel(root, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	} else if root == "" {
+		return p
+	} else if rel, ok := strings.CutPrefix(p, root+"/"); ok {
+		return rel
+	}
+	return path.Base(filepath.ToSlash(p))
+}
+
+// updateUpload applies fn to a copy of the job's upload state and stores
+// the copy, so job snapshots never share a JobUpload being modified
+func (s *Server) updateUpload(jobID string, fn func(u *JobUpload)) {
+	s.jobQueue.updateJob(jobID, func(j *Job) {
+		var u JobUpload
+		if j.Upload != nil {
+			u = *j.Upload
+			u.Files = slices.Clone(u.Files)
+		}
+		fn(&u)
+		j.Upload = &u
+	})
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// fakeWebDAV is a minimal in-memory WebDAV server that, like real ones,
+// rejects PUTs into collections that don't exist
+type fakeWebDAV struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	dirs  map[string]bool
+	fail  bool // Answer every request with 500
+}
+
+func newFakeWebDAV(t *testing.T) (*fakeWebDAV, *httptest.Server) {
+	t.Helper()
+	f := &fakeWebDAV{files: make(map[string][]byte), dirs: map[string]bool{"/dav": true}}
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+	return f, ts
+}
+
+func (f *fakeWebDAV) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.fail {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if user, pass, _ := r.BasicAuth(); user != "alice" || pass != "secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case "MKCOL":
+		dir := path.Clean(r.URL.Path)
+		if f.dirs[dir] {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		f.dirs[dir] = true
+		w.WriteHeader(http.StatusCreated)
+	case "PUT":
+		if !f.dirs[path.Dir(r.URL.Path)] {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.files[r.URL.Path], _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestUploadToDestination(t *testing.T) {
+	media := newMediaServer(t, "video-bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:        "abc",
+		Title:     "clip",
+		Thumbnail: media.URL + "/poster.jpg",
+		Formats:   []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+	off := false
+
+	tests := []struct {
+		name        string
+		fail        bool
+		deleteLocal bool
+		failOnError *bool
+		wantStatus  JobStatus
+		wantUpload  string
+		wantLocal   bool
+	}{
+		{"uploaded and kept", false, false, nil, JobStatusCompleted, UploadCompleted, true},
+		{"uploaded and removed", false, true, nil, JobStatusCompleted, UploadCompleted, false},
+		{"failure fails the job", true, true, nil, JobStatusFailed, UploadFailed, true},
+		{"failure only recorded", true, true, &off, JobStatusCompleted, UploadFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t, "")
+			s.cfg.DownloadThumbnail = true
+			dav, ts := newFakeWebDAV(t)
+			dav.fail = tt.fail
+			s.cfg.Destination = config.DestinationConfig{
+				Type:        config.DestinationWebDAV,
+				URL:         ts.URL + "/dav/",
+				Username:    "alice",
+				Password:    "secret",
+				Prefix:      "videos",
+				DeleteLocal: tt.deleteLocal,
+				FailOnError: tt.failOnError,
+			}
+
+			w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+			id, _ := decodeData(t, w)["id"].(string)
+			job := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+			if job.Status != tt.wantStatus {
+				t.Fatalf("job status = %s (error: %s); want %s", job.Status, job.Error, tt.wantStatus)
+			}
+			if job.Upload == nil || job.Upload.Status != tt.wantUpload {
+				t.Fatalf("upload = %+v; want status %s", job.Upload, tt.wantUpload)
+			}
+
+			if !tt.fail {
+				if got := string(dav.files["/dav/videos/clip.mp4"]); got != "video-bytes" {
+					t.Errorf("uploaded file = %q; want %q", got, "video-bytes")
+				}
+				if _, ok := dav.files["/dav/videos/clip.jpg"]; !ok {
+					t.Errorf("thumbnail not uploaded; files = %v", job.Upload.Files)
+				}
+				if job.Upload.Uploaded != 2*int64(len("video-bytes")) || job.Upload.Total != job.Upload.Uploaded {
+					t.Errorf("upload progress = %d/%d; want %d", job.Upload.Uploaded, job.Upload.Total, 2*len("video-bytes"))
+				}
+			}
+			wantFilename := filepath.Join(s.outputDir, "clip.mp4")
+			if tt.deleteLocal && !tt.fail {
+				wantFilename = ts.URL + "/dav/videos/clip.mp4"
+			}
+			if job.Filename != wantFilename {
+				t.Errorf("job filename = %q; want %q", job.Filename, wantFilename)
+			}
+			for _, name := range []string{"clip.mp4", "clip.jpg"} {
+				_, err := os.Stat(filepath.Join(s.outputDir, name))
+				if local := err == nil; local != tt.wantLocal {
+					t.Errorf("local %s present = %v; want %v", name, local, tt.wantLocal)
+				}
+			}
+		})
+	}
+}
+
+func TestUnsupportedDestination(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Destination.Type = "sftp"
+	if err := s.Start(); err == nil || !strings.Contains(err.Error(), "SFTP uploads are not implemented") {
+		t.Errorf("Start with an sftp destination = %v; want it refused", err)
+	}
+	if err := s.setConfigValue(&config.Config{}, "destination.type", "sftp"); err == nil {
+		t.Error("setting destination.type to sftp succeeded")
+	}
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// errDomainNotAllowed is returned when a URL's host violates the domain policy
+var errDomainNotAllowed = errors.New("domain not allowed")
+
+// checkDomain validates the URL's host against the configured
+// allowed/blocked domain lists
+func (s *Server) checkDomain(rawURL string) error {
+	normalized, err := extractor.NormalizeURL(rawURL)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(normalized)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %s", rawURL)
+	}
+
+	if !s.config().Server.IsDomainAllowed(u.Hostname()) {
+		return fmt.Errorf("%w: %s", errDomainNotAllowed, u.Hostname())
+	}
+	return nil
+}
+
+// urlOrigin returns the scheme://host origin of a URL, or "" if it can't be parsed
+func urlOrigin(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// splitList splits a comma-separated config value into trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// Values for server.skip_match
+const (
+	SkipMatchExact = "exact" // URLs match after the usual normalization (default)
+	SkipMatchLoose = "loose" // Also ignore scheme, "www.", fragments, trailing slashes, query order and tracking parameters
+)
+
+// duplicatePolicy decides when AddJob returns an earlier completed job
+// instead of queueing the same URL again
+type duplicatePolicy struct {
+	key       func(url string) string // Comparison key of a normalized URL
+	checkFile bool                    // Require the earlier job's files to still exist
+}
+
+// duplicatePolicy returns the skip_if_completed policy, or nil when
+// completed URLs are downloaded again
+func (s *Server) duplicatePolicy() *duplicatePolicy {
+	if !s.config().Server.SkipIfCompleted {
+		return nil
+	}
+	policy := &duplicatePolicy{
+		key:       func(url string) string { return url },
+		checkFile: s.config().Server.SkipCheckFileEnabled(),
+	}
+	if s.config().Server.SkipMatch == SkipMatchLoose {
+		policy.key = looseURLKey
+	}
+	return policy
+}
+
+// trackingParams are query parameters that only identify where a link was
+// shared from, ignored by loose matching along with any utm_* parameter
+var trackingParams = map[string]bool{
+	"fbclid":       true,
+	"gclid":        true,
+	"igshid":       true,
+	"si":           true,
+	"feature":      true,
+	"ref":          true,
+	"spm":          true,
+	"share_source": true,
+}
+
+// looseURLKey reduces a URL to the parts that select the content: host
+// without "www." (case-insensitive), path without a trailing slash, and the
+// non-tracking query parameters in sorted order
+func looseURLKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	query := u.Query()
+	for name := range query {
+		if trackingParams[strings.ToLower(name)] || strings.HasPrefix(strings.ToLower(name), "utm_") {
+			query.Del(name)
+		}
+	}
+
+	key := host + strings.TrimSuffix(u.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+	return key
+}
+
+// findCompleted returns a copy of the most recent completed job whose URL
+// matches url under policy and that was submitted with the same filename
+// and options (see sameDownload), or nil
+func (jq *JobQueue) findCompleted(url, filename string, opts DownloadOptions, policy *duplicatePolicy) *Job {
+	key := policy.key(url)
+	var candidates []*Job
+	jq.mu.RLock()
+	st := jq.storage
+	for _, job := range jq.jobs {
+		if job.Status == JobStatusCompleted && policy.key(job.URL) == key && sameDownload(job, filename, opts) {
+			jobCopy := *job
+			candidates = append(candidates, &jobCopy)
+		}
+	}
+	jq.mu.RUnlock()
+
+	// Newest first, so re-submissions report the latest download
+	slices.SortFunc(candidates, func(a, b *Job) int { return b.UpdatedAt.Compare(a.UpdatedAt) })
+	for _, job := range candidates {
+		if !policy.checkFile || savedFilesExist(st, job) {
+			return job
+		}
+	}
+	return nil
+}
+
+// sameDownload reports whether a job submitted with filename and opts asks
+// for the files job saved: same filename, quality, formats, gallery items,
+// clip range and extractor, by the same token "user" claim. Other users
+// get their own copy, since they may not see each other's jobs.
+func sameDownload(job *Job, filename string, opts DownloadOptions) bool {
+	prior := job.Options
+	return job.requested == filename &&
+		prior.Quality == opts.Quality &&
+		slices.Equal(prior.Qualities, opts.Qualities) &&
+		prior.FormatID == opts.FormatID &&
+		slices.Equal(prior.Indices, opts.Indices) &&
+		prior.StartTime == opts.StartTime &&
+		prior.EndTime == opts.EndTime &&
+		prior.Extractor == opts.Extractor &&
+		claimedUser(prior.Claims) == claimedUser(opts.Claims)
+}
+
+// claimedUser returns the "user" claim of a job's token, or "" without one
+func claimedUser(claims map[string]any) string {
+	if user, ok := claims["user"]; ok {
+		return fmt.Sprint(user)
+	}
+	return ""
+}
+
+// savedFilesExist reports whether every file a job saved is still in st.
+// A job that recorded no files can't be vouched for.
+func savedFilesExist(st storage.Storage, job *Job) bool {
+	if len(job.saved) == 0 {
+		return false
+	}
+	for _, p := range job.saved {
+		if _, err := st.Stat(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
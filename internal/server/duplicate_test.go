@@ -0,0 +1,92 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestLooseURLKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"https://example.com/v/1", "http://www.Example.com/v/1/", true},
+		{"https://example.com/v/1?b=2&a=1", "https://example.com/v/1?a=1&b=2#t=10", true},
+		{"https://example.com/v/1?utm_source=x&si=abc", "https://example.com/v/1", true},
+		{"https://example.com/v/1?id=1", "https://example.com/v/1?id=2", false},
+		{"https://example.com/v/1", "https://example.com/v/2", false},
+	}
+
+	for _, tt := range tests {
+		if same := looseURLKey(tt.a) == looseURLKey(tt.b); same != tt.same {
+			t.Errorf("looseURLKey(%q) == looseURLKey(%q) is %v; want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestSkipIfCompleted(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Server.SkipIfCompleted = true
+	media := newMediaServer(t, "video-bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+	first, _ := decodeData(t, w)["id"].(string)
+	waitForStatus(t, s.jobQueue, first, JobStatusCompleted)
+
+	// Same URL again: the earlier job is returned, nothing is queued
+	w = doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+	data := decodeData(t, w)
+	if data["id"] != first || data["status"] != string(JobStatusCompleted) {
+		t.Errorf("resubmission = %v; want job %s completed", data, first)
+	}
+	if got := len(s.jobQueue.GetAllJobs()); got != 1 {
+		t.Errorf("jobs = %d; want 1", got)
+	}
+
+	// Loose matching sees through tracking parameters
+	s.cfg.Server.SkipMatch = SkipMatchLoose
+	w = doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL + "?utm_source=feed"}, nil)
+	if id := decodeData(t, w)["id"]; id != first {
+		t.Errorf("loose resubmission id = %v; want %s", id, first)
+	}
+
+	// Once the file is gone, the URL is downloaded again
+	if err := os.Remove(filepath.Join(s.outputDir, "clip.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+	second, _ := decodeData(t, w)["id"].(string)
+	if second == first {
+		t.Fatalf("download with missing file returned the earlier job")
+	}
+	waitForStatus(t, s.jobQueue, second, JobStatusCompleted)
+
+	// Another filename, clip or user asks for a different download
+	for _, opts := range []struct {
+		filename string
+		opts     DownloadOptions
+	}{
+		{"copy.mp4", DownloadOptions{}},
+		{"", DownloadOptions{EndTime: time.Second}},
+		{"", DownloadOptions{Claims: map[string]any{"user": "bob"}}},
+	} {
+		job, err := s.jobQueue.AddJob(pageURL, opts.filename, opts.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.ID == second {
+			t.Errorf("AddJob(%q, %+v) returned the earlier job", opts.filename, opts.opts)
+			continue
+		}
+		waitForStatus(t, s.jobQueue, job.ID, JobStatusCompleted, JobStatusFailed)
+	}
+}
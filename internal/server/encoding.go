@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decodeContentEncoding replaces a media response's body with its decoded
+// bytes when the server compressed it with gzip or deflate on its own, so
+// the saved file isn't a compressed blob. Go's transport only decodes gzip
+// it asked for itself, which it doesn't do for ranged requests. The length of
+// the decoded body is unknown, so ContentLength becomes -1. Other codings
+// are left as sent.
+func decodeContentEncoding(resp *http.Response) error {
+	if resp.Uncompressed {
+		return nil
+	}
+	coding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+	var decoded io.Reader
+	switch coding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to decode gzip response: %w", err)
+		}
+		decoded = zr
+	case "deflate":
+		// "deflate" should be zlib-wrapped, but some servers send raw deflate
+		br := bufio.NewReader(resp.Body)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return fmt.Errorf("failed to decode deflate response: %w", err)
+			}
+			decoded = zr
+		} else {
+			decoded = flate.NewReader(br)
+		}
+	default:
+		logf(resp.Request.Context(), "Warning: saving %s as sent with unsupported Content-Encoding %q", resp.Request.URL.Host, coding)
+		return nil
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{decoded, resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// isZlibHeader reports whether b starts a zlib stream (RFC 1950): deflate
+// compression and a header checksum divisible by 31
+func isZlibHeader(b []byte) bool {
+	return b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0
+}
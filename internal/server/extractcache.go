@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+	"golang.org/x/sync/singleflight"
+)
+
+// extractCache shares extraction results between requests for the same URL
+// with the same extractor: concurrent extractions (e.g., duplicate URLs in a
+// bulk list) run once, and with server.extract_cache_ttl set the result is
+// reused until it expires. Failures are shared but never cached. Jobs also
+// skip entries older than server.extract_freshness, so signed URLs are
+// never older than that when a download starts. Prefetched extractions
+// (see prefetch) are kept even without a TTL, until one job uses them.
+type extractCache struct {
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]extractCacheEntry
+}
+
+type extractCacheEntry struct {
+	media     extractor.Media
+	extracted time.Time
+	expires   time.Time
+	once      bool // Prefetched with the cache off: serves one extraction
+}
+
+// prefetchHold is how long a prefetched extraction waits for its job when
+// the cache is off
+const prefetchHold = 10 * time.Minute
+
+func newExtractCache() *extractCache {
+	return &extractCache{entries: make(map[string]extractCacheEntry)}
+}
+
+// extract returns ext's media for url, from the cache if an unexpired entry
+// extracted within maxAge (0 = any age) exists, or by joining an
+// extraction of it already in progress. A new extraction is bound to ctx.
+func (c *extractCache) extract(ctx context.Context, ext extractor.Extractor, url string, ttl, maxAge time.Duration) (extractor.Media, error) {
+	key := ext.Name() + " " + url
+	if media, ok := c.lookup(key, maxAge, ttl > 0); ok {
+		return media, nil
+	}
+
+	v, err, _ := c.group.Do(key, func() (any, error) {
+		media, err := extractWithRetry(ctx, ext, url)
+		if err == nil && ttl > 0 {
+			c.store(key, extractCacheEntry{media: media, extracted: time.Now()}, ttl)
+		}
+		return media, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	media, _ := v.(extractor.Media)
+	return media, nil
+}
+
+// prefetch extracts url ahead of the job that will need it, unless an entry
+// the job could use already exists, and caches the result: for the TTL, or
+// with the cache off until one extraction takes it (at most prefetchHold).
+// Nothing is kept if ctx ends first.
+func (c *extractCache) prefetch(ctx context.Context, ext extractor.Extractor, url string, ttl, maxAge time.Duration) error {
+	key := ext.Name() + " " + url
+	if c.has(key, maxAge, ttl > 0) {
+		return nil
+	}
+
+	_, err, _ := c.group.Do(key, func() (any, error) {
+		media, err := extractWithRetry(ctx, ext, url)
+		if err == nil && ctx.Err() == nil {
+			entry := extractCacheEntry{media: media, extracted: time.Now()}
+			if ttl <= 0 {
+				entry.once, ttl = true, prefetchHold
+			}
+			c.store(key, entry, ttl)
+		}
+		return media, err
+	})
+	return err
+}
+
+// lookup returns the media of a usable entry for key (see has), removing
+// it if it's a prefetched entry and the cache is off
+func (c *extractCache) lookup(key string, maxAge time.Duration, cached bool) (extractor.Media, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.usableLocked(key, maxAge, cached) {
+		return nil, false
+	}
+	entry := c.entries[key]
+	if !cached {
+		delete(c.entries, key)
+	}
+	return entry.media, true
+}
+
+// has reports whether key has an unexpired entry extracted within maxAge
+// (0 = any age) that an extraction would use: any entry with the cache on
+// (cached), only a prefetched one with it off
+func (c *extractCache) has(key string, maxAge time.Duration, cached bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.usableLocked(key, maxAge, cached)
+}
+
+func (c *extractCache) usableLocked(key string, maxAge time.Duration, cached bool) bool {
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expires) || (!cached && !entry.once) {
+		return false
+	}
+	return maxAge <= 0 || time.Since(entry.extracted) <= maxAge
+}
+
+// store adds an entry that expires ttl after its extraction, dropping
+// expired ones so the cache only holds URLs extracted within the last TTL
+func (c *extractCache) store(key string, entry extractCacheEntry, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for k, old := range c.entries {
+		if now.After(old.expires) {
+			delete(c.entries, k)
+		}
+	}
+	entry.expires = entry.extracted.Add(ttl)
+	c.entries[key] = entry
+}
+
+// extractWithRetry runs ext on url, retrying transient failures with the
+// retry policy of ctx
+func extractWithRetry(ctx context.Context, ext extractor.Extractor, url string) (extractor.Media, error) {
+	var media extractor.Media
+	err := downloader.RetryContext(ctx, func() error {
+		var err error
+		media, err = extractor.ExtractContext(ctx, ext, url)
+		if err != nil && !transientError(err) {
+			return downloader.Permanent(err)
+		}
+		return err
+	})
+	return media, err
+}
+
+// extract runs ext on url through the server's extraction cache, skipping
+// entries older than the freshness attached to ctx. Transient failures are
+// retried under retry.*.
+func (s *Server) extract(ctx context.Context, ext extractor.Extractor, url string) (extractor.Media, error) {
+	ctx = downloader.WithRetryPolicy(ctx, s.retryPolicy())
+	return s.extracts.extract(ctx, ext, url, s.config().Server.ExtractCacheTTLDuration(), extractFreshnessFrom(ctx))
+}
+
+type extractFreshnessKey struct{}
+
+// withExtractFreshness makes extractions under ctx skip cached media
+// extracted more than maxAge ago (0 = any age)
+func withExtractFreshness(ctx context.Context, maxAge time.Duration) context.Context {
+	return context.WithValue(ctx, extractFreshnessKey{}, maxAge)
+}
+
+// extractFreshnessFrom returns the freshness attached by withExtractFreshness
+func extractFreshnessFrom(ctx context.Context) time.Duration {
+	maxAge, _ := ctx.Value(extractFreshnessKey{}).(time.Duration)
+	return maxAge
+}
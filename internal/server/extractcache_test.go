@@ -0,0 +1,75 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// blockingMock holds each extraction open until release is closed
+type blockingMock struct {
+	MockExtractor
+	release chan struct{}
+}
+
+func (m *blockingMock) Extract(url string) (extractor.Media, error) {
+	<-m.release
+	return m.MockExtractor.Extract(url)
+}
+
+func TestExtractCache(t *testing.T) {
+	s := newTestServer(t, "")
+	m := &blockingMock{MockExtractor: MockExtractor{Media: &extractor.VideoMedia{ID: "abc"}}, release: make(chan struct{})}
+
+	// Concurrent extractions of one URL share a single call
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			if media, err := s.extract(context.Background(), m, "https://example.com/a"); err != nil || media == nil {
+				t.Errorf("extract = %v, %v", media, err)
+			}
+		})
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(m.release)
+	wg.Wait()
+	if n := m.Calls(); n != 1 {
+		t.Errorf("concurrent extractions called Extract %d times; want 1", n)
+	}
+
+	// Without a TTL nothing is kept
+	s.extract(context.Background(), m, "https://example.com/a")
+	if n := m.Calls(); n != 2 {
+		t.Errorf("Extract called %d times; want 2 with the cache off", n)
+	}
+
+	s.cfg.Server.ExtractCacheTTL = "1m"
+	s.extract(context.Background(), m, "https://example.com/a")
+	s.extract(context.Background(), m, "https://example.com/a")
+	if n := m.Calls(); n != 3 {
+		t.Errorf("Extract called %d times; want 3 with the second served from cache", n)
+	}
+
+	// Jobs skip entries older than their freshness window
+	time.Sleep(20 * time.Millisecond)
+	s.extract(withExtractFreshness(context.Background(), time.Minute), m, "https://example.com/a")
+	if n := m.Calls(); n != 3 {
+		t.Errorf("Extract called %d times; want 3 with a fresh enough entry", n)
+	}
+	s.extract(withExtractFreshness(context.Background(), 10*time.Millisecond), m, "https://example.com/a")
+	if n := m.Calls(); n != 4 {
+		t.Errorf("Extract called %d times; want 4 with a stale entry re-extracted", n)
+	}
+
+	// Failures are not cached
+	m.Media, m.Err = nil, errors.New("rate limited")
+	s.extract(context.Background(), m, "https://example.com/b")
+	s.extract(context.Background(), m, "https://example.com/b")
+	if n := m.Calls(); n != 6 {
+		t.Errorf("Extract called %d times; want 6 with failures retried", n)
+	}
+}
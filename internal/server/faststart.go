@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+// faststart moves the moov atom of an MP4 saved at finalPath to the front
+// when faststart is set, so browsers can start playing it before it is
+// fully fetched. Files already laid out that way are left alone. Only
+// local files are rewritten, and only when ffmpeg is available. The
+// download already succeeded, so failures are only logged.
+func (s *Server) faststart(ctx context.Context, file plannedFile, finalPath string) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(finalPath), "."))
+	if !s.config().Faststart || !(file.video || file.audio) || !slices.Contains(downloader.FaststartExts, ext) ||
+		!s.store().IsLocal() || !downloader.FFmpegAvailable() {
+		return
+	}
+
+	needed, err := downloader.NeedsFaststart(finalPath)
+	if err == nil && !needed {
+		return
+	}
+	if err == nil {
+		err = s.rewriteFaststart(ctx, finalPath)
+	}
+	if err != nil {
+		logf(ctx, "Warning: failed to move the index of %s to the front: %v", finalPath, err)
+		return
+	}
+	jobLogf(ctx, "Moved the index of %s to the front for streaming", finalPath)
+}
+
+// rewriteFaststart rewrites the local MP4 at videoPath with faststart,
+// replacing it only once ffmpeg succeeded. The rewrite is staged next to
+// videoPath, so replacing it is a rename rather than another copy.
+func (s *Server) rewriteFaststart(ctx context.Context, videoPath string) error {
+	dir, err := os.MkdirTemp(filepath.Dir(videoPath), ".vget-faststart-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "video"+filepath.Ext(videoPath))
+	release, err := s.ffmpeg.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = downloader.Faststart(ctx, videoPath, output)
+	release()
+	if err != nil {
+		return err
+	}
+	return os.Rename(output, videoPath)
+}
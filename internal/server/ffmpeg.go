@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// ffmpegLimiter caps how many ffmpeg operations (merges, remuxes) run at
+// once, so CPU-heavy post-processing is serialized while downloads stay
+// parallel. The limit can change while operations are waiting.
+type ffmpegLimiter struct {
+	mu      sync.Mutex
+	limit   int
+	running int
+	changed chan struct{} // Closed and replaced when a slot frees or the limit changes
+}
+
+func newFFmpegLimiter(limit int) *ffmpegLimiter {
+	return &ffmpegLimiter{limit: limit, changed: make(chan struct{})}
+}
+
+// SetLimit changes the number of concurrent operations (minimum 1)
+func (l *ffmpegLimiter) SetLimit(limit int) {
+	if limit < 1 {
+		limit = 1
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limit = limit
+	l.notifyLocked()
+}
+
+// acquire waits for a free slot and returns the func that frees it, or
+// ctx's error if ctx ends first. The time from acquire to release counts
+// as the job's post-processing time (see JobTimings).
+func (l *ffmpegLimiter) acquire(ctx context.Context) (release func(), err error) {
+	start := time.Now()
+	for {
+		l.mu.Lock()
+		if l.running < l.limit {
+			l.running++
+			l.mu.Unlock()
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					l.release()
+					addFFmpegTime(ctx, time.Since(start))
+				})
+			}, nil
+		}
+		wait := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (l *ffmpegLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.running--
+	l.notifyLocked()
+}
+
+func (l *ffmpegLimiter) notifyLocked() {
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
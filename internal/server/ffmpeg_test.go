@@ -0,0 +1,84 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFFmpegLimiter(t *testing.T) {
+	l := newFFmpegLimiter(2)
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(context.Background())
+			if err != nil {
+				t.Errorf("acquire() error: %v", err)
+				return
+			}
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			running.Add(-1)
+			release()
+			release() // Releasing twice must not free a second slot
+		}()
+	}
+	wg.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Errorf("peak concurrent operations = %d; want 2", got)
+	}
+
+	// A waiter gives up when its context ends
+	l.SetLimit(1)
+	release, _ := l.acquire(context.Background())
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx); err == nil {
+		t.Error("acquire() with a full limiter and expired context succeeded")
+	}
+
+	// Raising the limit wakes waiters
+	acquired := make(chan struct{})
+	go func() {
+		if r, err := l.acquire(context.Background()); err == nil {
+			r()
+			close(acquired)
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	l.SetLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Error("waiter not woken by SetLimit")
+	}
+	release()
+}
+
+func TestFFmpegLimiterTiming(t *testing.T) {
+	l := newFFmpegLimiter(1)
+	ctx, elapsed := withFFmpegTimer(context.Background())
+
+	release, err := l.acquire(ctx)
+	if err != nil {
+		t.Fatalf("acquire() error: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	release()
+	release() // Counted once
+	if d := time.Duration(elapsed.Load()); d < 20*time.Millisecond || d > time.Second {
+		t.Errorf("ffmpeg time = %v; want about 20ms", d)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"log"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// splitGallery queues the images of a gallery with more images than
+// server.gallery_split as separate jobs of at most that many images each,
+// so each chunk progresses, fails and is retried on its own. The jobs join
+// the gallery job's group (a new one if it has none) and its batch, if
+// any; they extract the gallery again, which the extraction cache shares.
+// It reports whether the gallery was split, leaving the gallery job with
+// nothing more to download.
+func (s *Server) splitGallery(jobID, url, filename string, opts DownloadOptions, plan *downloadPlan) (bool, error) {
+	size := s.config().Server.GallerySplit
+	if size <= 0 || plan.MediaType != extractor.MediaTypeImage || !plan.multi || len(plan.Files) <= size {
+		return false, nil
+	}
+
+	group := opts.Group
+	if group == "" {
+		var err error
+		if group, err = newBatchGroup(); err != nil {
+			return false, err
+		}
+	}
+
+	var ids []string
+	for start := 0; start < len(plan.Files); start += size {
+		chunk := plan.Files[start:min(start+size, len(plan.Files))]
+		childOpts := opts
+		childOpts.Group = group
+		childOpts.SplitFrom = jobID
+		childOpts.Indices = make([]int, len(chunk))
+		for i, file := range chunk {
+			childOpts.Indices[i] = file.Index
+		}
+
+		job, err := s.jobQueue.AddJob(url, filename, childOpts)
+		if err != nil {
+			// Keep the chunk visible so it can be retried
+			job = s.jobQueue.AddFailedJob(url, err.Error())
+			s.jobQueue.updateJob(job.ID, func(j *Job) { j.Options = childOpts })
+		}
+		ids = append(ids, job.ID)
+	}
+
+	s.batches.add(group, ids)
+	s.jobQueue.updateJob(jobID, func(j *Job) {
+		j.Options.Group = group
+		j.Split = ids
+	})
+	log.Printf("Job %s: split %d images into %d jobs (group %s)", jobID, len(plan.Files), len(ids), group)
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestGallerySplit(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Server.GallerySplit = 2
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/4.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("\xff\xd8\xff image"))
+	}))
+	t.Cleanup(media.Close)
+
+	gallery := &extractor.ImageMedia{ID: "post", Title: "post"}
+	for i := 1; i <= 5; i++ {
+		gallery.Images = append(gallery.Images, extractor.Image{URL: fmt.Sprintf("%s/%d.jpg", media.URL, i), Ext: "jpg"})
+	}
+	pageURL := registerMock(t, &MockExtractor{Media: gallery})
+
+	id, _ := decodeData(t, doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil))["id"].(string)
+	parent := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+	if parent.Status != JobStatusCompleted || len(parent.Split) != 3 || parent.Options.Group == "" {
+		t.Fatalf("gallery job = %s, split %v, group %q; want completed, split into 3 jobs of a group", parent.Status, parent.Split, parent.Options.Group)
+	}
+
+	// Each chunk is its own job; only the one holding the missing image fails
+	wantIndices := [][]int{{1, 2}, {3, 4}, {5}}
+	wantStatus := []JobStatus{JobStatusCompleted, JobStatusPartial, JobStatusCompleted}
+	for i, childID := range parent.Split {
+		child := waitForStatus(t, s.jobQueue, childID, JobStatusCompleted, JobStatusPartial, JobStatusFailed)
+		if !reflect.DeepEqual(child.Options.Indices, wantIndices[i]) || child.Status != wantStatus[i] {
+			t.Errorf("job %d = indices %v, %s; want %v, %s", i, child.Options.Indices, child.Status, wantIndices[i], wantStatus[i])
+		}
+		if child.Options.Group != parent.Options.Group || child.Options.SplitFrom != id || len(child.Split) != 0 {
+			t.Errorf("job %d = group %q, split_from %q, split %v; want the gallery's group and id, not split again", i, child.Options.Group, child.Options.SplitFrom, child.Split)
+		}
+	}
+
+	// Galleries within the limit stay in one job
+	s.cfg.Server.GallerySplit = 5
+	id, _ = decodeData(t, doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil))["id"].(string)
+	if job := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusPartial, JobStatusFailed); len(job.Split) != 0 || len(job.Items) != 5 {
+		t.Errorf("unsplit gallery = split %v, %d items; want one job with 5 items", job.Split, len(job.Items))
+	}
+}
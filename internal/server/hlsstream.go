@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+)
+
+// streamHLS assembles an HLS stream in a temporary directory (remuxed to
+// mp4 unless hls_format is "ts") and serves the result to the client with
+// range support. The temporary files are removed once the response is done.
+func (s *Server) streamHLS(ctx context.Context, w http.ResponseWriter, r *http.Request, url, filename string, headers map[string]string) {
+	dir, err := os.MkdirTemp("", "vget-stream-")
+	if err != nil {
+		http.Error(w, "failed to create temporary directory", http.StatusInternalServerError)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	hlsConfig := downloader.DefaultHLSConfig()
+	hlsConfig.Remux = s.config().HLSFormat != "ts"
+	hlsConfig.InsecureSkipVerify = insecureTLSFrom(ctx)
+	hlsConfig.ForceHTTP1 = forceHTTP1From(ctx)
+	hlsConfig.MinTLSVersion = minTLSVersionFrom(ctx)
+	hlsConfig.Throttle = throttle
+	hlsConfig.AcquireFFmpeg = s.ffmpeg.acquire
+
+	output := filepath.Join(dir, "stream.ts")
+	finalPath, err := downloader.DownloadHLSWithConfig(ctx, url, output, headers, hlsConfig, nil)
+	if err != nil {
+		switch {
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			http.Error(w, "deadline exceeded", http.StatusGatewayTimeout)
+		case ctx.Err() != nil:
+			// Client went away while the stream was assembled
+		default:
+			http.Error(w, fmt.Sprintf("failed to assemble HLS stream: %v", err), http.StatusBadGateway)
+		}
+		return
+	}
+
+	file, err := os.Open(finalPath)
+	if err != nil {
+		http.Error(w, "failed to open assembled stream", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		http.Error(w, "failed to open assembled stream", http.StatusInternalServerError)
+		return
+	}
+
+	// The filename was planned as .ts; match whatever the assembly produced
+	ext := filepath.Ext(finalPath)
+	filename = strings.TrimSuffix(filename, filepath.Ext(filename)) + ext
+	contentType := "video/mp2t"
+	if ext == ".mp4" {
+		contentType = "video/mp4"
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(&deadlineWriter{ResponseWriter: w, timeout: s.writeTimeout()}, r, filename, info.ModTime(), file)
+}
+
+// deadlineWriter pushes the write deadline forward before every write, so a
+// server write timeout only catches stalled writes, as in streamFile
+type deadlineWriter struct {
+	http.ResponseWriter
+	timeout time.Duration
+}
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	if d.timeout > 0 {
+		http.NewResponseController(d.ResponseWriter).SetWriteDeadline(time.Now().Add(d.timeout))
+	}
+	return d.ResponseWriter.Write(p)
+}
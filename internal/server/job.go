@@ -4,11 +4,19 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/guiyumin/vget/internal/core/extractor"
+	"github.com/guiyumin/vget/internal/core/storage"
 )
 
 // JobStatus represents the current state of a download job
@@ -20,57 +28,182 @@ const (
 	JobStatusCompleted   JobStatus = "completed"
 	JobStatusFailed      JobStatus = "failed"
 	JobStatusCancelled   JobStatus = "cancelled"
+	JobStatusPartial     JobStatus = "partial"     // Some items of a multi-item job failed
+	JobStatusInterrupted JobStatus = "interrupted" // Was downloading when the server stopped (see server.jobs_file)
 )
 
+// isFinished reports whether the status is terminal (no further work will happen)
+func isFinished(status JobStatus) bool {
+	return status == JobStatusCompleted || status == JobStatusFailed ||
+		status == JobStatusCancelled || status == JobStatusPartial ||
+		status == JobStatusInterrupted
+}
+
+// JobItem records the outcome of one item in a multi-item job (e.g., an image gallery)
+type JobItem struct {
+	Index    int    `json:"index"` // 1-based position in the source
+	Filename string `json:"filename,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// PartialError is returned by a DownloadFunc when some items of a
+// multi-item download succeeded and others failed
+type PartialError struct {
+	Items []JobItem
+}
+
+func (e *PartialError) Error() string {
+	failed := 0
+	for _, item := range e.Items {
+		if item.Error != "" {
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d of %d items failed", failed, len(e.Items))
+}
+
 // Job represents a download job
 type Job struct {
-	ID         string    `json:"id"`
-	URL        string    `json:"url"`
-	Filename   string    `json:"filename,omitempty"`
-	Status     JobStatus `json:"status"`
-	Progress   float64   `json:"progress"`
-	Downloaded int64     `json:"downloaded"` // bytes downloaded
-	Total      int64     `json:"total"`      // total bytes (-1 if unknown)
-	Error      string    `json:"error,omitempty"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID             string             `json:"id"`
+	URL            string             `json:"url"`
+	Filename       string             `json:"filename,omitempty"`
+	Status         JobStatus          `json:"status"`
+	Progress       float64            `json:"progress"`
+	Downloaded     int64              `json:"downloaded"` // bytes downloaded
+	Total          int64              `json:"total"`      // total bytes (-1 if unknown)
+	Error          string             `json:"error,omitempty"`
+	Items          []JobItem          `json:"items,omitempty"`   // Per-item results for partial jobs
+	Quality        string             `json:"quality,omitempty"` // Video quality actually selected
+	Options        DownloadOptions    `json:"options"`
+	Deadline       time.Time          `json:"deadline,omitzero"`         // Wall-clock limit, set when the job starts
+	Pinned         bool               `json:"pinned,omitempty"`          // Kept out of history cleanup unless forced
+	Upload         *JobUpload         `json:"upload,omitempty"`          // Upload to the configured destination
+	Timings        *JobTimings        `json:"timings,omitempty"`         // Time spent per phase, updated as each phase ends
+	Conversions    []JobConversion    `json:"conversions,omitempty"`     // Videos converted to convert_to
+	Normalizations []JobNormalization `json:"normalizations,omitempty"`  // Audio run through normalize_audio
+	Phase          JobPhase           `json:"phase,omitempty"`           // Stage of a downloading job
+	Split          []string           `json:"split,omitempty"`           // Jobs a large gallery was split into
+	ResumeDiscards []ResumeDiscard    `json:"resume_discards,omitempty"` // Partial files started over instead of resumed
+	CreatedAt      time.Time          `json:"created_at"`
+	UpdatedAt      time.Time          `json:"updated_at"`
 
 	// Internal fields (not serialized)
-	cancel context.CancelFunc `json:"-"`
-	ctx    context.Context    `json:"-"`
+	cancel    context.CancelFunc `json:"-"`
+	ctx       context.Context    `json:"-"`
+	log       *jobLog            // What happened during the job (see handleJobLog)
+	outputs   map[int][]string   // Files written per item index (0 for single-file jobs)
+	saved     []string           // Files the download saved, for skip_if_completed
+	sidecars  []string           // Thumbnails and chapters saved next to them
+	requested string             // Filename the job was submitted with, for skip_if_completed
+	milestone int                // Last progress percentage reported to onEvent
 }
 
 // JobQueue manages download jobs with a worker pool
 type JobQueue struct {
 	jobs          map[string]*Job
 	mu            sync.RWMutex
-	queue         chan *Job
+	queue         *jobScheduler
 	maxConcurrent int
 	outputDir     string
+	storage       storage.Storage // Where job outputs live, for cleanup (guarded by mu, like outputDir)
 	downloadFn    DownloadFunc
+	validateURL   func(url string) error  // Optional policy check run before queueing
+	cleanupOnFail func() bool             // Optional; reports whether failed jobs' partial files are removed
+	duplicates    func() *duplicatePolicy // Optional; returns the skip_if_completed policy (nil = re-download)
+	onEvent       func(jobEvent)          // Optional; called (outside mu) on every job state transition
+	reserved      map[string]string       // Output paths claimed by running transfers, by stem
+	version       uint64                  // Bumped (under mu) on every job change
+	statePath     string                  // Jobs file the jobs are saved to ("" = memory only)
+	savedVersion  uint64                  // version last written to statePath (guarded by mu)
+	saveMu        sync.Mutex              // Serializes writes of statePath
+	watchers      jobWatchers             // Status streams by job ID (guarded by mu)
+	prefetch      jobPrefetch             // Lookahead extraction of the next queued job
 	wg            sync.WaitGroup
 	cleanupTicker *time.Ticker
 	stopCleanup   chan struct{}
 }
 
+// DownloadOptions holds per-request settings that tune how a job is downloaded
+type DownloadOptions struct {
+	// Indices restricts a gallery download to these 1-based items (empty = all)
+	Indices []int `json:"indices,omitempty"`
+
+	// Quality requested for video formats (empty = configured default)
+	Quality string `json:"quality,omitempty"`
+
+	// Qualities downloads one file per listed quality instead of Quality
+	Qualities []string `json:"qualities,omitempty"`
+
+	// FormatID picks one exact format instead of Quality (see selectFormatByID)
+	FormatID string `json:"format_id,omitempty"`
+
+	// StartTime and EndTime cut videos down to this range (0 = open end)
+	StartTime time.Duration `json:"-"`
+	EndTime   time.Duration `json:"-"`
+
+	// Timeout is the wall-clock limit for the job once it starts (0 = none)
+	Timeout time.Duration `json:"-"`
+
+	// Deadline is an absolute cutoff set by the caller through the
+	// X-Download-Deadline header (zero = none); it applies while queued too
+	Deadline time.Time `json:"-"`
+
+	// Weight is the job's share of the global rate limit (0 = DefaultJobWeight)
+	Weight int `json:"weight,omitempty"`
+
+	// MaxRate caps the job's download rate in bytes per second (0 = none)
+	MaxRate int64 `json:"max_rate_bps,omitempty"`
+
+	// Extractor forces a named extractor instead of matching by URL
+	Extractor string `json:"extractor,omitempty"`
+
+	// Group ties jobs submitted together in one bulk request
+	Group string `json:"group,omitempty"`
+
+	// SplitFrom is the gallery job this job was split from (see
+	// server.gallery_split); such jobs skip skip_if_completed
+	SplitFrom string `json:"split_from,omitempty"`
+
+	// InsecureSkipVerify disables TLS certificate verification for the
+	// media requests of this job (self-signed sources only)
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// Claims is the custom payload of the token that submitted the job
+	// (e.g., {"user": "alice"}); set by the server, never by the request
+	Claims map[string]any `json:"claims,omitempty"`
+}
+
 // DownloadFunc is the function signature for downloading a URL
-// It receives the job context, URL, output path, and a progress callback
-type DownloadFunc func(ctx context.Context, url, outputPath string, progressFn func(downloaded, total int64)) error
+// It receives the job context, job ID, URL, output path, per-request options, and a progress callback
+type DownloadFunc func(ctx context.Context, jobID, url, outputPath string, opts DownloadOptions, progressFn func(downloaded, total int64)) error
 
-// NewJobQueue creates a new job queue with the specified concurrency
-func NewJobQueue(maxConcurrent int, outputDir string, downloadFn DownloadFunc) *JobQueue {
+// NewJobQueue creates a new job queue with the specified concurrency. With
+// a statePath, jobs saved there by an earlier run are restored and changes
+// are written back to it (see loadJobs and saveJobs).
+func NewJobQueue(maxConcurrent int, outputDir string, downloadFn DownloadFunc, statePath string) *JobQueue {
 	if maxConcurrent <= 0 {
 		maxConcurrent = 10
 	}
 
 	jq := &JobQueue{
 		jobs:          make(map[string]*Job),
-		queue:         make(chan *Job, 100),
+		queue:         newJobScheduler(100),
 		maxConcurrent: maxConcurrent,
 		outputDir:     outputDir,
+		storage:       storage.NewLocal(outputDir),
 		downloadFn:    downloadFn,
+		reserved:      make(map[string]string),
+		statePath:     statePath,
+		watchers:      make(jobWatchers),
 		stopCleanup:   make(chan struct{}),
 	}
+	if statePath != "" {
+		if err := jq.loadJobs(); err != nil {
+			// Leave the file alone rather than overwrite what it holds
+			log.Printf("Warning: failed to load jobs from %s, they won't be saved this run: %v", statePath, err)
+			jq.statePath = ""
+		}
+	}
 
 	return jq
 }
@@ -86,22 +219,32 @@ func (jq *JobQueue) Start() {
 	// Start cleanup routine (every 10 minutes, remove jobs older than 1 hour)
 	jq.cleanupTicker = time.NewTicker(10 * time.Minute)
 	go jq.cleanupLoop()
+
+	if jq.statePath != "" {
+		go jq.saveLoop()
+	}
 }
 
 // Stop gracefully shuts down the job queue
 func (jq *JobQueue) Stop() {
-	close(jq.queue)
+	jq.queue.close()
 	close(jq.stopCleanup)
 	if jq.cleanupTicker != nil {
 		jq.cleanupTicker.Stop()
 	}
 	jq.wg.Wait()
+	jq.saveJobs()
 }
 
 func (jq *JobQueue) worker() {
 	defer jq.wg.Done()
 
-	for job := range jq.queue {
+	for {
+		job, ok := jq.queue.next()
+		if !ok {
+			return
+		}
+		jq.prefetchNext()
 		jq.processJob(job)
 	}
 }
@@ -114,13 +257,48 @@ func (jq *JobQueue) processJob(job *Job) {
 		jq.updateJobProgressBytes(job.ID, downloaded, total)
 	}
 
-	// Execute download
-	err := jq.downloadFn(job.ctx, job.URL, job.Filename, progressFn)
+	// Enforce the wall-clock limit and caller deadline, independent of progress
+	ctx := job.ctx
+	if job.Options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Options.Timeout)
+		defer cancel()
+	}
+	callerDeadline := false
+	if !job.Options.Deadline.IsZero() {
+		timeoutAt, hasTimeout := ctx.Deadline()
+		callerDeadline = !hasTimeout || job.Options.Deadline.Before(timeoutAt)
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, job.Options.Deadline)
+		defer cancel()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		jq.setJobDeadline(job.ID, deadline)
+	}
+
+	// Execute download, unless the caller's deadline passed while queued
+	ctx = withJobLog(ctx, job.log)
+	err := ctx.Err()
+	if err == nil {
+		err = jq.download(ctx, job, progressFn)
+	}
 
 	if err != nil {
+		var partial *PartialError
 		if job.ctx.Err() == context.Canceled {
 			jq.updateJobStatus(job.ID, JobStatusCancelled, 0, "cancelled by user")
+		} else if ctx.Err() == context.DeadlineExceeded && callerDeadline {
+			jq.updateJobStatus(job.ID, JobStatusCancelled, 0, "deadline exceeded")
+		} else if ctx.Err() == context.DeadlineExceeded {
+			jq.removePartialOutputs(job.ID, nil)
+			jq.updateJobStatus(job.ID, JobStatusFailed, 0, fmt.Sprintf("job exceeded time limit of %s", job.Options.Timeout))
+		} else if errors.As(err, &partial) {
+			jq.removePartialOutputs(job.ID, partial.Items)
+			jq.setJobItems(job.ID, partial.Items)
+			jq.updateJobStatus(job.ID, JobStatusPartial, 0, err.Error())
 		} else {
+			jq.removePartialOutputs(job.ID, nil)
 			jq.updateJobStatus(job.ID, JobStatusFailed, 0, err.Error())
 		}
 		return
@@ -129,6 +307,121 @@ func (jq *JobQueue) processJob(job *Job) {
 	jq.updateJobStatus(job.ID, JobStatusCompleted, 100, "")
 }
 
+// download runs downloadFn for job, turning a panic (e.g., a nil deref in a
+// fragile extractor) into an error that fails the job, so the worker stays
+// alive for the next one
+func (jq *JobQueue) download(ctx context.Context, job *Job, progressFn func(downloaded, total int64)) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			log.Printf("Job %s panicked: %v\n%s", job.ID, r, stack)
+			err = fmt.Errorf("panic: %v\n%s", r, stack)
+		}
+	}()
+	return jq.downloadFn(ctx, job.ID, job.URL, job.Filename, job.Options, progressFn)
+}
+
+// reservePaths claims output paths for the duration of a transfer so
+// concurrent jobs never write the same file. Claims are by stem (the path
+// without its extension) so companion files like a merge's separate audio
+// stream or an HLS remux are covered too. A path whose stem is taken gets a
+// " (2)", " (3)", ... suffix. Call release once the transfer is done.
+func (jq *JobQueue) reservePaths(paths []string) (reserved []string, release func()) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	var stems []string
+	for _, path := range paths {
+		ext := filepath.Ext(path)
+		stem := strings.TrimSuffix(path, ext)
+		candidate := stem
+		for n := 2; ; n++ {
+			if _, taken := jq.reserved[candidate]; !taken {
+				break
+			}
+			candidate = fmt.Sprintf("%s (%d)", stem, n)
+		}
+		jq.reserved[candidate] = candidate + ext
+		stems = append(stems, candidate)
+		reserved = append(reserved, candidate+ext)
+	}
+
+	release = func() {
+		jq.mu.Lock()
+		defer jq.mu.Unlock()
+		for _, stem := range stems {
+			delete(jq.reserved, stem)
+		}
+	}
+	return reserved, release
+}
+
+// retargetReservation records that the transfer holding path's stem now
+// writes path, e.g., after falling back to a format with another extension
+func (jq *JobQueue) retargetReservation(path string) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	if _, ok := jq.reserved[stem]; ok {
+		jq.reserved[stem] = path
+	}
+}
+
+// setOutput switches where new job outputs live, for live config changes
+func (jq *JobQueue) setOutput(outputDir string, st storage.Storage) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	jq.outputDir = outputDir
+	jq.storage = st
+}
+
+// removePartialOutputs deletes files left behind by a failed job. With
+// items (a partial job), only the files of failed items are removed;
+// with nil items, every recorded output is.
+func (jq *JobQueue) removePartialOutputs(id string, items []JobItem) {
+	if jq.cleanupOnFail == nil || !jq.cleanupOnFail() {
+		return
+	}
+
+	var paths []string
+	jq.mu.Lock()
+	st := jq.storage
+	if job, ok := jq.jobs[id]; ok {
+		if items == nil {
+			for _, p := range job.outputs {
+				paths = append(paths, p...)
+			}
+		} else {
+			for _, item := range items {
+				if item.Error != "" {
+					paths = append(paths, job.outputs[item.Index]...)
+				}
+			}
+		}
+		job.outputs = nil
+	}
+	jq.mu.Unlock()
+
+	for _, path := range paths {
+		names := []string{path}
+		if st.IsLocal() {
+			// An interrupted local transfer is still under its .part name,
+			// with the validator saved for resuming it
+			names = append(names, path+storage.PartSuffix, path+storage.ValidatorSuffix)
+		}
+		for _, name := range names {
+			err := st.Remove(name)
+			switch {
+			case err == nil, errors.Is(err, fs.ErrNotExist):
+			case errors.Is(err, storage.ErrFileInUse):
+				log.Printf("Warning: partial file %s is in use by another program and was kept", name)
+			default:
+				log.Printf("Warning: failed to remove partial file %s: %v", name, err)
+			}
+		}
+	}
+}
+
 func (jq *JobQueue) cleanupLoop() {
 	for {
 		select {
@@ -146,30 +439,50 @@ func (jq *JobQueue) cleanupOldJobs() {
 
 	cutoff := time.Now().Add(-1 * time.Hour)
 	for id, job := range jq.jobs {
-		// Only cleanup completed, failed, or cancelled jobs older than 1 hour
-		if (job.Status == JobStatusCompleted || job.Status == JobStatusFailed || job.Status == JobStatusCancelled) &&
-			job.UpdatedAt.Before(cutoff) {
+		// Only cleanup unpinned finished jobs older than 1 hour
+		if isFinished(job.Status) && !job.Pinned && job.UpdatedAt.Before(cutoff) {
 			delete(jq.jobs, id)
+			jq.version++
 		}
 	}
 }
 
-// ClearHistory removes all completed, failed, and cancelled jobs
-func (jq *JobQueue) ClearHistory() int {
+// ClearHistory removes all completed, failed, partial, and cancelled jobs.
+// Pinned jobs are kept unless force is set.
+func (jq *JobQueue) ClearHistory(force bool) int {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
 
 	count := 0
 	for id, job := range jq.jobs {
-		if job.Status == JobStatusCompleted || job.Status == JobStatusFailed || job.Status == JobStatusCancelled {
+		if isFinished(job.Status) && (force || !job.Pinned) {
 			delete(jq.jobs, id)
 			count++
 		}
 	}
+	if count > 0 {
+		jq.version++
+	}
 	return count
 }
 
-// RemoveJob removes a single completed, failed, or cancelled job by ID
+// SetPinned pins or unpins a job by ID, reporting false if it doesn't exist
+func (jq *JobQueue) SetPinned(id string, pinned bool) bool {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	job, ok := jq.jobs[id]
+	if !ok {
+		return false
+	}
+	if job.Pinned != pinned {
+		job.Pinned = pinned
+		jq.version++
+	}
+	return true
+}
+
+// RemoveJob removes a single finished job by ID
 func (jq *JobQueue) RemoveJob(id string) bool {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
@@ -179,12 +492,13 @@ func (jq *JobQueue) RemoveJob(id string) bool {
 		return false
 	}
 
-	// Can only remove completed, failed, or cancelled jobs
-	if job.Status != JobStatusCompleted && job.Status != JobStatusFailed && job.Status != JobStatusCancelled {
+	// Can only remove finished jobs
+	if !isFinished(job.Status) {
 		return false
 	}
 
 	delete(jq.jobs, id)
+	jq.version++
 	return true
 }
 
@@ -200,23 +514,44 @@ func (jq *JobQueue) AddFailedJob(rawURL, errorMsg string) *Job {
 		Progress:  0,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		log:       newJobLog(),
 	}
+	job.log.add(statusLogLine(JobStatusFailed, errorMsg))
 
 	jq.mu.Lock()
 	jq.jobs[id] = job
+	jq.version++
+	event := newJobEvent(string(JobStatusFailed), job)
 	jq.mu.Unlock()
 
+	jq.notify(event)
 	return job
 }
 
 // AddJob creates and queues a new download job
-func (jq *JobQueue) AddJob(rawURL, filename string) (*Job, error) {
+func (jq *JobQueue) AddJob(rawURL, filename string, opts DownloadOptions) (*Job, error) {
 	// Normalize URL: add https:// if missing
 	url, err := extractor.NormalizeURL(rawURL)
 	if err != nil {
 		return nil, err
 	}
 
+	if jq.validateURL != nil {
+		if err := jq.validateURL(url); err != nil {
+			return nil, err
+		}
+	}
+
+	// An earlier completed download of the same URL is returned as is; its
+	// status tells callers nothing was queued
+	if jq.duplicates != nil && opts.SplitFrom == "" {
+		if policy := jq.duplicates(); policy != nil {
+			if prior := jq.findCompleted(url, filename, opts, policy); prior != nil {
+				return prior, nil
+			}
+		}
+	}
+
 	id, err := generateJobID()
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate job ID: %w", err)
@@ -228,30 +563,47 @@ func (jq *JobQueue) AddJob(rawURL, filename string) (*Job, error) {
 		ID:        id,
 		URL:       url,
 		Filename:  filename,
+		Options:   opts,
 		Status:    JobStatusQueued,
+		requested: filename,
 		Progress:  0,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 		ctx:       ctx,
 		cancel:    cancel,
+		log:       newJobLog(),
 	}
+	job.log.add(statusLogLine(JobStatusQueued, ""))
 
 	jq.mu.Lock()
 	jq.jobs[id] = job
+	jq.version++
+	event := newJobEvent(string(JobStatusQueued), job)
+	// Callers get a copy, like GetJob: once queued, a worker updates the job
+	jobCopy := *job
 	jq.mu.Unlock()
 
-	// Queue the job (non-blocking with buffered channel)
-	select {
-	case jq.queue <- job:
-		return job, nil
-	default:
-		// Queue is full
-		jq.mu.Lock()
-		delete(jq.jobs, id)
-		jq.mu.Unlock()
-		cancel()
-		return nil, fmt.Errorf("job queue is full")
+	// Log before handing the job to a worker so "queued" precedes "started"
+	jq.notify(event)
+
+	// Queue the job (non-blocking, like a buffered channel)
+	if jq.queue.push(job) {
+		jq.prefetchNext()
+		return &jobCopy, nil
 	}
+
+	// Queue is full
+	jq.mu.Lock()
+	delete(jq.jobs, id)
+	jq.version++
+	jq.mu.Unlock()
+	cancel()
+
+	event.Time = time.Now()
+	event.Event = string(JobStatusFailed)
+	event.Error = "job queue is full"
+	jq.notify(event)
+	return nil, fmt.Errorf("job queue is full")
 }
 
 // GetJob returns a job by ID
@@ -280,54 +632,284 @@ func (jq *JobQueue) GetAllJobs() []*Job {
 	return jobs
 }
 
+// Weight returns the job's effective bandwidth weight
+func (j *Job) Weight() int {
+	if j.Options.Weight <= 0 {
+		return DefaultJobWeight
+	}
+	return j.Options.Weight
+}
+
+// clipped reports whether the options cut videos to a time range
+func (o DownloadOptions) clipped() bool {
+	return o.StartTime > 0 || o.EndTime > 0
+}
+
+// Clip describes the requested time range as "start-end" (e.g.,
+// "00:00:30-00:01:15"), or "" for whole videos. An open end is left blank.
+func (o DownloadOptions) Clip() string {
+	if !o.clipped() {
+		return ""
+	}
+	clip := formatClipTime(o.StartTime) + "-"
+	if o.EndTime > 0 {
+		clip += formatClipTime(o.EndTime)
+	}
+	return clip
+}
+
+// RemainingTime returns how long an active job has left before its deadline,
+// or -1 if it has no deadline or is no longer running
+func (j *Job) RemainingTime() time.Duration {
+	if j.Deadline.IsZero() || isFinished(j.Status) {
+		return -1
+	}
+	if remaining := time.Until(j.Deadline); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// QueueStats is a point-in-time snapshot of job queue load
+type QueueStats struct {
+	ActiveDownloads int  `json:"active_downloads"`
+	QueuedJobs      int  `json:"queued_jobs"`
+	WorkerCount     int  `json:"worker_count"`
+	TotalJobs       int  `json:"total_jobs"`
+	Paused          bool `json:"paused"`
+
+	// ActivePaths are the output paths being written right now, sorted.
+	// Each is claimed by one transfer (see reservePaths).
+	ActivePaths []string `json:"active_paths"`
+
+	// AvgTimings averages the phase timings of completed jobs in history
+	AvgTimings *JobTimings `json:"avg_timings,omitempty"`
+
+	// RateLimits are the rate limits hosts reported in their responses, for
+	// windows that haven't reset yet (filled in by the stats endpoint)
+	RateLimits map[string]extractor.RateLimitState `json:"rate_limits,omitempty"`
+}
+
+// Stats returns current load counters, read under the queue lock so they
+// are consistent with concurrent worker updates
+func (jq *JobQueue) Stats() QueueStats {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+
+	stats := QueueStats{
+		WorkerCount: jq.maxConcurrent,
+		TotalJobs:   len(jq.jobs),
+		Paused:      jq.queue.isPaused(),
+		ActivePaths: make([]string, 0, len(jq.reserved)),
+	}
+	for _, path := range jq.reserved {
+		stats.ActivePaths = append(stats.ActivePaths, path)
+	}
+	sort.Strings(stats.ActivePaths)
+	var sum JobTimings
+	var timed int
+	for _, job := range jq.jobs {
+		switch job.Status {
+		case JobStatusDownloading:
+			stats.ActiveDownloads++
+		case JobStatusQueued:
+			stats.QueuedJobs++
+		case JobStatusCompleted:
+			if t := job.Timings; t != nil {
+				sum.Extraction += t.Extraction
+				sum.Download += t.Download
+				sum.PostProcessing += t.PostProcessing
+				sum.Upload += t.Upload
+				timed++
+			}
+		}
+	}
+	if timed > 0 {
+		n := float64(timed)
+		stats.AvgTimings = &JobTimings{
+			Extraction:     roundSeconds(sum.Extraction / n),
+			Download:       roundSeconds(sum.Download / n),
+			PostProcessing: roundSeconds(sum.PostProcessing / n),
+			Upload:         roundSeconds(sum.Upload / n),
+		}
+	}
+	return stats
+}
+
+// Pause stops workers from starting queued jobs; running jobs continue.
+// Jobs can still be added and wait in the queue until Resume.
+func (jq *JobQueue) Pause() {
+	jq.queue.setPaused(true)
+}
+
+// Resume lets workers start queued jobs again after Pause
+func (jq *JobQueue) Resume() {
+	jq.queue.setPaused(false)
+}
+
+// Paused reports whether the queue is paused
+func (jq *JobQueue) Paused() bool {
+	return jq.queue.isPaused()
+}
+
+// Version returns a counter that changes whenever any job is added, removed, or updated
+func (jq *JobQueue) Version() uint64 {
+	jq.mu.RLock()
+	defer jq.mu.RUnlock()
+	return jq.version
+}
+
 // CancelJob cancels a job by ID
 func (jq *JobQueue) CancelJob(id string) bool {
 	jq.mu.Lock()
-	defer jq.mu.Unlock()
 
 	job, ok := jq.jobs[id]
 	if !ok {
+		jq.mu.Unlock()
 		return false
 	}
 
 	// Can only cancel queued or downloading jobs
 	if job.Status != JobStatusQueued && job.Status != JobStatusDownloading {
+		jq.mu.Unlock()
 		return false
 	}
 
 	job.cancel()
 	job.Status = JobStatusCancelled
 	job.UpdatedAt = time.Now()
+	job.log.add(statusLogLine(JobStatusCancelled, "cancelled by user"))
+	jq.version++
+	jq.jobChanged(id)
+	event := newJobEvent(string(JobStatusCancelled), job)
+	jq.mu.Unlock()
+
+	jq.notify(event)
 	return true
 }
 
 func (jq *JobQueue) updateJobStatus(id string, status JobStatus, progress float64, errMsg string) {
+	jq.mu.Lock()
+	job, ok := jq.jobs[id]
+	if !ok {
+		jq.mu.Unlock()
+		return
+	}
+
+	changed := job.Status != status
+	job.Status = status
+	if status != JobStatusDownloading {
+		job.Phase = ""
+	}
+	if progress > 0 {
+		job.Progress = progress
+	}
+	if errMsg != "" {
+		job.Error = errMsg
+	}
+	job.UpdatedAt = time.Now()
+	jq.version++
+	jq.jobChanged(id)
+
+	var event jobEvent
+	if changed {
+		event = newJobEvent(statusEvent(status), job)
+		job.log.add(statusLogLine(status, job.Error))
+	}
+	jq.mu.Unlock()
+
+	if changed {
+		jq.notify(event)
+	}
+}
+
+// statusLogLine is the job log line for entering status
+func statusLogLine(status JobStatus, errMsg string) string {
+	if errMsg != "" && status != JobStatusDownloading && status != JobStatusCompleted {
+		return fmt.Sprintf("Status: %s (%s)", status, errMsg)
+	}
+	return "Status: " + string(status)
+}
+
+// statusEvent names the progress log event for entering status
+func statusEvent(status JobStatus) string {
+	if status == JobStatusDownloading {
+		return "started"
+	}
+	return string(status)
+}
+
+// notify passes event to the onEvent hook, if any
+func (jq *JobQueue) notify(event jobEvent) {
+	if jq.onEvent != nil {
+		jq.onEvent(event)
+	}
+}
+
+func (jq *JobQueue) setJobItems(id string, items []JobItem) {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
 
 	if job, ok := jq.jobs[id]; ok {
-		job.Status = status
-		if progress > 0 {
-			job.Progress = progress
-		}
-		if errMsg != "" {
-			job.Error = errMsg
-		}
+		job.Items = items
 		job.UpdatedAt = time.Now()
+		jq.version++
 	}
 }
 
-func (jq *JobQueue) updateJobProgressBytes(id string, downloaded, total int64) {
+// updateJob applies fn to the job with the given ID under the queue lock
+func (jq *JobQueue) updateJob(id string, fn func(j *Job)) {
 	jq.mu.Lock()
 	defer jq.mu.Unlock()
 
 	if job, ok := jq.jobs[id]; ok {
-		job.Downloaded = downloaded
-		job.Total = total
-		if total > 0 {
-			job.Progress = float64(downloaded) / float64(total) * 100
-		}
+		fn(job)
 		job.UpdatedAt = time.Now()
+		jq.version++
+		jq.jobChanged(id)
+	}
+}
+
+func (jq *JobQueue) setJobDeadline(id string, deadline time.Time) {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+
+	if job, ok := jq.jobs[id]; ok {
+		job.Deadline = deadline
+		job.UpdatedAt = time.Now()
+		jq.version++
+	}
+}
+
+func (jq *JobQueue) updateJobProgressBytes(id string, downloaded, total int64) {
+	jq.mu.Lock()
+	job, ok := jq.jobs[id]
+	if !ok {
+		jq.mu.Unlock()
+		return
+	}
+
+	job.Downloaded = downloaded
+	job.Total = total
+	if total > 0 {
+		job.Progress = float64(downloaded) / float64(total) * 100
+	}
+	job.UpdatedAt = time.Now()
+	jq.version++
+	jq.jobChanged(id)
+
+	// Report each 25% step once; 100% is covered by the completed event
+	milestone := int(job.Progress) / progressMilestone * progressMilestone
+	reached := milestone > job.milestone && milestone < 100
+	var event jobEvent
+	if reached {
+		job.milestone = milestone
+		event = newJobEvent("progress", job)
+	}
+	jq.mu.Unlock()
+
+	if reached {
+		jq.notify(event)
 	}
 }
 
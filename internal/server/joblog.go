@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Caps on a job's log, so a long playlist can't grow it without bound
+const (
+	maxJobLogLines    = 200  // The oldest lines are dropped past this
+	maxJobLogLineSize = 1024 // Longer messages are truncated
+)
+
+// JobLogLine is one timestamped line of a job's log
+type JobLogLine struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// jobLog collects what happened during one job: status changes, the
+// extractor and formats picked, fallbacks, merges and warnings. It keeps
+// the last maxJobLogLines lines and counts the ones dropped before them.
+type jobLog struct {
+	mu      sync.Mutex
+	lines   []JobLogLine
+	dropped int
+}
+
+func newJobLog() *jobLog {
+	return &jobLog{}
+}
+
+// add appends a line, dropping the oldest one when full
+func (l *jobLog) add(message string) {
+	if len(message) > maxJobLogLineSize {
+		message = strings.ToValidUTF8(message[:maxJobLogLineSize], "") + "…"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.lines) >= maxJobLogLines {
+		l.lines = append(l.lines[:0], l.lines[1:]...)
+		l.dropped++
+	}
+	l.lines = append(l.lines, JobLogLine{Time: time.Now(), Message: message})
+}
+
+// snapshot returns a copy of the lines and the number dropped before them
+func (l *jobLog) snapshot() ([]JobLogLine, int) {
+	if l == nil {
+		return []JobLogLine{}, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]JobLogLine{}, l.lines...), l.dropped
+}
+
+type jobLogKey struct{}
+
+// withJobLog returns ctx recording into l (see logf and jobLogf)
+func withJobLog(ctx context.Context, l *jobLog) context.Context {
+	return context.WithValue(ctx, jobLogKey{}, l)
+}
+
+// jobLogf adds a line to the log of the job running under ctx. It does
+// nothing outside a job (e.g., for streamed downloads).
+func jobLogf(ctx context.Context, format string, args ...any) {
+	if l, ok := ctx.Value(jobLogKey{}).(*jobLog); ok {
+		l.add(fmt.Sprintf(format, args...))
+	}
+}
+
+// logf writes a line to the server log and to the log of the job running
+// under ctx, if any
+func logf(ctx context.Context, format string, args ...any) {
+	log.Printf(format, args...)
+	jobLogf(ctx, format, args...)
+}
+
+// handleJobLog returns the log of a job
+func (s *Server) handleJobLog(c *gin.Context) {
+	job := s.jobQueue.GetJob(c.Param("id"))
+	if job == nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Data:    nil,
+			Message: "job not found",
+		})
+		return
+	}
+
+	lines, dropped := job.log.snapshot()
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Data: gin.H{
+			"id":      job.ID,
+			"status":  job.Status,
+			"lines":   lines,
+			"dropped": dropped,
+		},
+		Message: fmt.Sprintf("%d log lines", len(lines)),
+	})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestJobLog(t *testing.T) {
+	s := newTestServer(t, "")
+	media := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(media.Close)
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4?token=s3cr3t", Ext: "mp4"}},
+	}})
+
+	job, err := s.jobQueue.AddJob(pageURL, "", DownloadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitForStatus(t, s.jobQueue, job.ID, JobStatusFailed)
+
+	w := doRequest(s, "GET", "/api/jobs/"+job.ID+"/log", nil, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET log = %d; want 200 (%s)", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data struct {
+			Lines []JobLogLine `json:"lines"`
+		} `json:"data"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	var messages []string
+	for _, line := range resp.Data.Lines {
+		messages = append(messages, line.Message)
+	}
+	got := strings.Join(messages, "\n")
+	for _, want := range []string{
+		"Status: queued",
+		"Status: downloading",
+		`Extractor mock found video "clip"`,
+		"/clip.mp4?token=REDACTED (best, mp4)",
+		"Status: failed (",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("log missing %q:\n%s", want, got)
+		}
+	}
+
+	if w := doRequest(s, "GET", "/api/jobs/missing/log", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job = %d; want 404", w.Code)
+	}
+}
+
+func TestJobLogCaps(t *testing.T) {
+	l := newJobLog()
+	for i := range maxJobLogLines + 10 {
+		l.add(strings.Repeat("x", i))
+	}
+	l.add(strings.Repeat("é", maxJobLogLineSize))
+
+	lines, dropped := l.snapshot()
+	if len(lines) != maxJobLogLines || dropped != 11 {
+		t.Errorf("lines = %d, dropped = %d; want %d, 11", len(lines), dropped, maxJobLogLines)
+	}
+	if first := lines[0].Message; first != strings.Repeat("x", 11) {
+		t.Errorf("first line = %d characters; want the 12th line kept", len(first))
+	}
+	if last := lines[len(lines)-1].Message; len(last) > maxJobLogLineSize+len("…") || !strings.HasSuffix(last, "é…") {
+		t.Errorf("long line = %d bytes; want truncated at a character boundary", len(last))
+	}
+}
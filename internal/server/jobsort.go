@@ -0,0 +1,104 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// jobSortFields are the fields GET /api/jobs can sort by (?sort=)
+var jobSortFields = map[string]func(a, b *Job) int{
+	"created_at": func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"status":     func(a, b *Job) int { return cmp.Compare(jobStatusRank[a.Status], jobStatusRank[b.Status]) },
+	"progress":   func(a, b *Job) int { return cmp.Compare(a.Progress, b.Progress) },
+	"size":       func(a, b *Job) int { return cmp.Compare(a.Total, b.Total) },
+}
+
+// jobStatusRank orders statuses by lifecycle for ?sort=status
+var jobStatusRank = map[JobStatus]int{
+	JobStatusQueued:      0,
+	JobStatusDownloading: 1,
+	JobStatusCompleted:   2,
+	JobStatusPartial:     3,
+	JobStatusFailed:      4,
+	JobStatusCancelled:   5,
+	JobStatusInterrupted: 6,
+}
+
+// jobListing is how GET /api/jobs orders and pages its jobs
+type jobListing struct {
+	sort   string // Key of jobSortFields
+	desc   bool
+	limit  int // 0 = no limit
+	offset int
+}
+
+// parseJobListing reads ?sort=, ?order=, ?limit= and ?offset=. Jobs are
+// listed oldest first by default.
+func parseJobListing(query func(string) string) (jobListing, error) {
+	listing := jobListing{sort: "created_at"}
+	if field := query("sort"); field != "" {
+		if _, ok := jobSortFields[field]; !ok {
+			return listing, fmt.Errorf("invalid sort: use created_at, status, progress or size")
+		}
+		listing.sort = field
+	}
+	switch query("order") {
+	case "", "asc":
+	case "desc":
+		listing.desc = true
+	default:
+		return listing, fmt.Errorf("invalid order: use asc or desc")
+	}
+	for _, param := range []struct {
+		name string
+		dest *int
+	}{{"limit", &listing.limit}, {"offset", &listing.offset}} {
+		value := query(param.name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return listing, fmt.Errorf("invalid %s: %s", param.name, value)
+		}
+		*param.dest = n
+	}
+	return listing, nil
+}
+
+// etagSuffix distinguishes the ETag of a non-default listing, since it sees
+// a different body for the same jobs
+func (l jobListing) etagSuffix() string {
+	if l == (jobListing{sort: "created_at"}) {
+		return ""
+	}
+	order := "asc"
+	if l.desc {
+		order = "desc"
+	}
+	return fmt.Sprintf("-%s-%s-%d-%d", l.sort, order, l.limit, l.offset)
+}
+
+// apply sorts jobs in place and returns the requested page. Jobs that tie
+// on the sort field keep their creation order, so listings are stable.
+func (l jobListing) apply(jobs []*Job) []*Job {
+	slices.SortFunc(jobs, func(a, b *Job) int {
+		return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID, b.ID))
+	})
+	compare := jobSortFields[l.sort]
+	slices.SortStableFunc(jobs, func(a, b *Job) int {
+		if l.desc {
+			return compare(b, a)
+		}
+		return compare(a, b)
+	})
+
+	jobs = jobs[min(l.offset, len(jobs)):]
+	if l.limit > 0 {
+		jobs = jobs[:min(l.limit, len(jobs))]
+	}
+	return jobs
+}
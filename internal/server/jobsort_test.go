@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestJobListing(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	newJobs := func() []*Job {
+		return []*Job{
+			{ID: "c", Status: JobStatusQueued, Total: -1, CreatedAt: start.Add(2 * time.Minute)},
+			{ID: "a", Status: JobStatusCompleted, Progress: 100, Total: 500, CreatedAt: start},
+			{ID: "d", Status: JobStatusFailed, Progress: 10, Total: 500, CreatedAt: start.Add(3 * time.Minute)},
+			{ID: "b", Status: JobStatusDownloading, Progress: 40, Total: 9000, CreatedAt: start.Add(time.Minute)},
+		}
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"", []string{"a", "b", "c", "d"}},
+		{"order=desc", []string{"d", "c", "b", "a"}},
+		{"sort=status", []string{"c", "b", "a", "d"}},
+		{"sort=progress&order=desc", []string{"a", "b", "d", "c"}},
+		{"sort=size&order=desc", []string{"b", "a", "d", "c"}}, // a and d tie, oldest first
+		{"sort=size&limit=2", []string{"c", "a"}},
+		{"limit=2&offset=3", []string{"d"}},
+		{"offset=9", []string{}},
+	}
+	for _, tt := range tests {
+		values, _ := url.ParseQuery(tt.query)
+		listing, err := parseJobListing(values.Get)
+		if err != nil {
+			t.Fatalf("parseJobListing(%q): %v", tt.query, err)
+		}
+		var got []string
+		for _, job := range listing.apply(newJobs()) {
+			got = append(got, job.ID)
+		}
+		if !slices.Equal(got, tt.expected) {
+			t.Errorf("?%s lists %v; want %v", tt.query, got, tt.expected)
+		}
+	}
+
+	for _, query := range []string{"sort=name", "order=up", "limit=-1", "offset=x"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseJobListing(values.Get); err == nil {
+			t.Errorf("parseJobListing(%q) succeeded; want error", query)
+		}
+	}
+}
+
+func TestGetJobsListing(t *testing.T) {
+	s := newTestServer(t, "")
+
+	if w := doRequest(s, "GET", "/api/jobs?sort=name", nil, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/jobs?sort=name = %d; want 400", w.Code)
+	}
+
+	w := doRequest(s, "GET", "/api/jobs", nil, nil)
+	sorted := doRequest(s, "GET", "/api/jobs?sort=size&order=desc", nil, nil)
+	if etag := w.Header().Get("ETag"); etag == "" || etag == sorted.Header().Get("ETag") {
+		t.Errorf("ETags = %q, %q; want distinct for another ordering", etag, sorted.Header().Get("ETag"))
+	}
+	if total, _ := decodeData(t, sorted)["total"].(float64); total != 0 {
+		t.Errorf("total = %v; want 0", total)
+	}
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// jobSaveInterval is how often changed jobs are written to the jobs file,
+// so progress updates don't rewrite it after every chunk
+const jobSaveInterval = 2 * time.Second
+
+// savedJob is a job as stored in the jobs file: its API form plus the
+// options and saved files the API leaves out
+type savedJob struct {
+	*Job
+	StartTime      time.Duration `json:"start_time,omitempty"`
+	EndTime        time.Duration `json:"end_time,omitempty"`
+	Timeout        time.Duration `json:"timeout,omitempty"`
+	CallerDeadline time.Time     `json:"caller_deadline,omitzero"`
+	Saved          []string      `json:"saved,omitempty"`
+	Requested      string        `json:"requested_filename,omitempty"`
+}
+
+// jobsFilePath resolves the jobs_file setting against the output directory
+// ("" = don't save jobs)
+func jobsFilePath(outputDir, jobsFile string) string {
+	if jobsFile == "" || filepath.IsAbs(jobsFile) {
+		return jobsFile
+	}
+	return filepath.Join(outputDir, jobsFile)
+}
+
+// isJobsFile reports whether abs is the jobs file or its temporary copy,
+// which hold every job's URL, options and claims and must not be served
+// from the output directory
+func (jq *JobQueue) isJobsFile(abs string) bool {
+	if jq.statePath == "" {
+		return false
+	}
+	statePath, err := filepath.Abs(jq.statePath)
+	return err == nil && (abs == statePath || abs == statePath+".tmp")
+}
+
+// loadJobs restores the jobs saved in the jobs file, oldest first. Queued
+// jobs are queued again; jobs that were downloading when the server
+// stopped are marked interrupted, since their transfer is gone. A missing
+// file means there is nothing to restore.
+func (jq *JobQueue) loadJobs() error {
+	data, err := os.ReadFile(jq.statePath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var saved []savedJob
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("invalid jobs file: %w", err)
+	}
+	sort.SliceStable(saved, func(i, j int) bool {
+		return saved[i].Job != nil && saved[j].Job != nil && saved[i].CreatedAt.Before(saved[j].CreatedAt)
+	})
+
+	var queued, interrupted int
+	for _, entry := range saved {
+		job := entry.Job
+		if job == nil || job.ID == "" {
+			continue
+		}
+		job.Options.StartTime = entry.StartTime
+		job.Options.EndTime = entry.EndTime
+		job.Options.Timeout = entry.Timeout
+		job.Options.Deadline = entry.CallerDeadline
+		job.saved = entry.Saved
+		job.requested = entry.Requested
+		job.ctx, job.cancel = context.WithCancel(context.Background())
+		job.log = newJobLog()
+		job.log.add("Restored after a server restart")
+
+		switch job.Status {
+		case JobStatusDownloading:
+			job.Status = JobStatusInterrupted
+			job.Phase = ""
+			job.Error = "interrupted by a server restart"
+			job.UpdatedAt = time.Now()
+			job.log.add(statusLogLine(job.Status, job.Error))
+			interrupted++
+		case JobStatusQueued:
+			if !jq.queue.push(job) {
+				job.Status = JobStatusFailed
+				job.Error = "job queue is full"
+				job.UpdatedAt = time.Now()
+				break
+			}
+			queued++
+		}
+		jq.jobs[job.ID] = job
+	}
+	jq.savedVersion = jq.version
+	if interrupted > 0 {
+		jq.version++ // Save the new statuses
+	}
+	if len(jq.jobs) > 0 {
+		log.Printf("Restored %d jobs from %s (%d queued again, %d interrupted)", len(jq.jobs), jq.statePath, queued, interrupted)
+	}
+	return nil
+}
+
+// saveLoop writes the jobs file every jobSaveInterval while jobs change,
+// until Stop
+func (jq *JobQueue) saveLoop() {
+	ticker := time.NewTicker(jobSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			jq.saveJobs()
+		case <-jq.stopCleanup:
+			return
+		}
+	}
+}
+
+// saveJobs writes all jobs to the jobs file if any changed since the last
+// save. The file is replaced atomically, so a crash mid-write keeps the
+// previous version.
+func (jq *JobQueue) saveJobs() {
+	if jq.statePath == "" {
+		return
+	}
+	jq.saveMu.Lock()
+	defer jq.saveMu.Unlock()
+
+	jq.mu.RLock()
+	version := jq.version
+	if version == jq.savedVersion {
+		jq.mu.RUnlock()
+		return
+	}
+	saved := make([]savedJob, 0, len(jq.jobs))
+	for _, job := range jq.jobs {
+		saved = append(saved, savedJob{
+			Job:            job,
+			StartTime:      job.Options.StartTime,
+			EndTime:        job.Options.EndTime,
+			Timeout:        job.Options.Timeout,
+			CallerDeadline: job.Options.Deadline,
+			Saved:          job.saved,
+			Requested:      job.requested,
+		})
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].CreatedAt.Before(saved[j].CreatedAt) })
+	data, err := json.MarshalIndent(saved, "", "  ")
+	jq.mu.RUnlock()
+	if err == nil {
+		err = writeFileAtomic(jq.statePath, data)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to save jobs to %s: %v", jq.statePath, err)
+		return
+	}
+
+	jq.mu.Lock()
+	jq.savedVersion = version
+	jq.mu.Unlock()
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames
+// it over name. Only the owner can read it, since jobs carry their claims.
+func writeFileAtomic(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	os.Remove(tmp) // A leftover copy would keep its permissions
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, name); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobQueuePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "jobs.json")
+	release := make(chan struct{})
+	jq := NewJobQueue(1, t.TempDir(), func(ctx context.Context, jobID, url, filename string, opts DownloadOptions, progressFn func(downloaded, total int64)) error {
+		if strings.HasSuffix(url, "/slow") {
+			<-release
+		}
+		return nil
+	}, path)
+	jq.Start()
+	defer jq.Stop()
+	defer close(release)
+
+	done, _ := jq.AddJob("https://example.com/done", "", DownloadOptions{})
+	waitForStatus(t, jq, done.ID, JobStatusCompleted)
+	slow, _ := jq.AddJob("https://example.com/slow", "", DownloadOptions{})
+	waitForStatus(t, jq, slow.ID, JobStatusDownloading)
+	queued, _ := jq.AddJob("https://example.com/next", "", DownloadOptions{StartTime: 90 * time.Second})
+	jq.saveJobs()
+
+	// A second queue on the same file picks up where the first stopped
+	restored := NewJobQueue(1, t.TempDir(), func(ctx context.Context, jobID, url, filename string, opts DownloadOptions, progressFn func(downloaded, total int64)) error {
+		if opts.StartTime != 90*time.Second {
+			t.Errorf("restored start time = %s; want 1m30s", opts.StartTime)
+		}
+		return nil
+	}, path)
+	restored.Start()
+	defer restored.Stop()
+
+	if job := restored.GetJob(done.ID); job == nil || job.Status != JobStatusCompleted {
+		t.Errorf("completed job = %+v; want it kept as completed", job)
+	}
+	if job := restored.GetJob(slow.ID); job == nil || job.Status != JobStatusInterrupted {
+		t.Errorf("downloading job = %+v; want interrupted", job)
+	}
+	waitForStatus(t, restored, queued.ID, JobStatusCompleted)
+
+	// Changes are written back, and clearing history reaches the file too
+	restored.ClearHistory(true)
+	restored.saveJobs()
+	if again := NewJobQueue(1, t.TempDir(), nil, path); len(again.GetAllJobs()) != 0 {
+		t.Errorf("jobs after clearing history = %d; want 0", len(again.GetAllJobs()))
+	}
+}
+
+func TestJobsFileNotServed(t *testing.T) {
+	s := newTestServer(t, "")
+	s.jobQueue.statePath = filepath.Join(s.outputDir, "jobs.json")
+	s.jobQueue.AddFailedJob("https://example.com/a.mp4", "failed")
+	s.jobQueue.saveJobs()
+
+	info, err := os.Stat(s.jobQueue.statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0o600 {
+		t.Errorf("jobs file mode = %o; want 600", perm)
+	}
+	if w := doRequest(s, "GET", "/api/download?path="+url.QueryEscape(s.jobQueue.statePath), nil, nil); w.Code != http.StatusForbidden {
+		t.Errorf("GET jobs file = %d; want 403", w.Code)
+	}
+}
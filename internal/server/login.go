@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// errNotMedia fails a media request that was redirected to a web page
+var errNotMedia = errors.New("got HTML, not media")
+
+// mediaCheck guards a transfer against login and paywall pages, and other
+// pages or mismatched files, served in place of the media. Login failures
+// wrap extractor.ErrLoginRequired.
+type mediaCheck struct {
+	url       string              // The media URL as requested
+	kind      extractor.MediaType // What extraction said the file is ("" = unknown)
+	video     bool                // The file is expected to be a video
+	minSize   int64               // Smallest plausible video (0 = no size check)
+	markers   []string            // Extra login URL markers from server.login_markers
+	skipTypes bool                // server.disable_media_type_check: ignore Content-Type
+}
+
+// mediaCheckError is a response a mediaCheck rejected
+type mediaCheckError struct{ err error }
+
+func (e *mediaCheckError) Error() string { return e.err.Error() }
+func (e *mediaCheckError) Unwrap() error { return e.err }
+
+type mediaCheckKey struct{}
+
+// withMediaCheck attaches check to ctx for downloadFile
+func withMediaCheck(ctx context.Context, check mediaCheck) context.Context {
+	return context.WithValue(ctx, mediaCheckKey{}, check)
+}
+
+// mediaCheckFrom returns the check attached by withMediaCheck
+func mediaCheckFrom(ctx context.Context) (mediaCheck, bool) {
+	check, ok := ctx.Value(mediaCheckKey{}).(mediaCheck)
+	return check, ok
+}
+
+// mediaCheckFor builds the check for a planned file. Merged streams skip
+// the video checks, since the separate audio stream is checked too.
+func (s *Server) mediaCheckFor(file plannedFile) mediaCheck {
+	check := mediaCheck{
+		url:       file.URL,
+		markers:   s.config().Server.LoginMarkers,
+		skipTypes: s.config().Server.DisableMediaTypeCheck,
+	}
+	switch {
+	case file.video && !file.Merge:
+		check.kind = extractor.MediaTypeVideo
+		check.video = true
+		check.minSize = s.config().Server.MinVideoSizeBytes()
+	case file.audio:
+		check.kind = extractor.MediaTypeAudio
+	case file.image:
+		check.kind = extractor.MediaTypeImage
+	}
+	return check
+}
+
+// response rejects a media response that is really a page or the wrong
+// kind of file: a redirect to a login URL, an HTML document, an image where
+// a video was expected, JSON where media was expected, or a video too
+// small to be real
+func (c mediaCheck) response(resp *http.Response) error {
+	final := resp.Request.URL
+	if final.String() != c.url && extractor.IsLoginURL(final, c.markers) {
+		return fmt.Errorf("%w: media request was redirected to %s", extractor.ErrLoginRequired, final.Host+final.Path)
+	}
+
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if c.skipTypes {
+		contentType = ""
+	}
+	switch {
+	case contentType == "text/html" || contentType == "application/xhtml+xml":
+		// A redirect to a landing page isn't a login wall; a page served
+		// at the media URL itself usually is
+		if resp.Request.Response != nil {
+			return fmt.Errorf("%w: redirected to %s", errNotMedia, final.Host+final.Path)
+		}
+		return fmt.Errorf("%w: %w", extractor.ErrLoginRequired, errNotMedia)
+	case c.video && strings.HasPrefix(contentType, "image/"):
+		return fmt.Errorf("%w: expected a video but got an image (%s)", extractor.ErrLoginRequired, contentType)
+	case c.kind != "" && (contentType == "application/json" || contentType == "application/problem+json"):
+		// An API error body rather than the file
+		return fmt.Errorf("expected %s but got %s", c.kind, contentType)
+	}
+
+	if resp.ContentLength >= 0 {
+		return c.size(resp.ContentLength)
+	}
+	return nil
+}
+
+// size rejects a video smaller than the configured minimum
+func (c mediaCheck) size(n int64) error {
+	if c.video && c.minSize > 0 && n < c.minSize {
+		return fmt.Errorf("%w: video is only %d bytes (server.min_video_size is %d)", extractor.ErrLoginRequired, n, c.minSize)
+	}
+	return nil
+}
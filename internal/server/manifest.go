@@ -0,0 +1,237 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// bulkManifest records the state of every URL of a bulk download in the
+// output directory, so that re-submitting the list skips URLs that already
+// completed
+type bulkManifest struct {
+	Group     string                    `json:"group"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+	Entries   map[string]*manifestEntry `json:"entries"` // Keyed by normalized URL
+
+	st         storage.Storage
+	name       string
+	submitting bool // Jobs are still being queued; keep following the group
+}
+
+// manifestEntry is the last known state of one URL
+type manifestEntry struct {
+	Status   JobStatus `json:"status"`
+	Filename string    `json:"filename,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// manifestTracker keeps the manifests of running bulk downloads open and
+// writes every job outcome back to them
+type manifestTracker struct {
+	mu      sync.Mutex
+	byGroup map[string]*bulkManifest
+}
+
+func newManifestTracker() *manifestTracker {
+	return &manifestTracker{byGroup: make(map[string]*bulkManifest)}
+}
+
+var manifestKeyUnsafe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// manifestName returns the manifest file for a bulk download: named after
+// the group when one is given, otherwise after a hash of the URL list
+func manifestName(st storage.Storage, group string, urls []string) string {
+	key := manifestKeyUnsafe.ReplaceAllString(group, "_")
+	if key == "" {
+		sum := sha256.Sum256([]byte(strings.Join(manifestURLs(urls), "\n")))
+		key = hex.EncodeToString(sum[:8])
+	}
+	return st.Join(".vget-bulk-" + key + ".json")
+}
+
+// manifestURLs returns the normalized URLs of a bulk list, without blank
+// lines and comments
+func manifestURLs(urls []string) []string {
+	var keys []string
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		if url == "" || strings.HasPrefix(url, "#") {
+			continue
+		}
+		keys = append(keys, manifestKey(url))
+	}
+	return keys
+}
+
+// manifestKey normalizes url the way AddJob does, so entries match job URLs
+func manifestKey(url string) string {
+	if normalized, err := extractor.NormalizeURL(url); err == nil {
+		return normalized
+	}
+	return url
+}
+
+// open returns the manifest for a bulk download, reusing the one of a
+// still-running submission of the same list or loading it from st
+func (t *manifestTracker) open(st storage.Storage, group string, urls []string) (*bulkManifest, error) {
+	name := manifestName(st, group, urls)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, m := range t.byGroup {
+		if m.name == name {
+			return m, nil
+		}
+	}
+
+	m := &bulkManifest{Group: group, Entries: make(map[string]*manifestEntry), st: st, name: name}
+	r, err := st.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bulk manifest: %w", err)
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bulk manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid bulk manifest %s: %w", name, err)
+	}
+	if m.Entries == nil {
+		m.Entries = make(map[string]*manifestEntry)
+	}
+	// Nothing from the file is running anymore, so unfinished URLs were cut off
+	for _, entry := range m.Entries {
+		if !isFinished(entry.Status) {
+			entry.Status = JobStatusFailed
+			entry.Error = "interrupted before finishing"
+		}
+	}
+	return m, nil
+}
+
+// done reports whether url needs no new job: it finished successfully in
+// an earlier run, or its job from a submission still running is queued or
+// downloading
+func (t *manifestTracker) done(m *bulkManifest, url string) (*manifestEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := m.Entries[manifestKey(url)]
+	if !ok || (entry.Status != JobStatusCompleted && isFinished(entry.Status)) {
+		return nil, false
+	}
+	copied := *entry
+	return &copied, true
+}
+
+// group returns the batch group m was saved or tracked with
+func (t *manifestTracker) group(m *bulkManifest) string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return m.Group
+}
+
+// record sets the state of url. Call it before queueing the job so its
+// outcome, which may arrive right away, isn't overwritten.
+func (t *manifestTracker) record(m *bulkManifest, url string, status JobStatus, errMsg string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m.Entries[manifestKey(url)] = &manifestEntry{Status: status, Error: errMsg}
+}
+
+// track starts following m as the manifest of group. Call it before
+// queueing any job, and submitted once they are all queued.
+func (t *manifestTracker) track(m *bulkManifest, group string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m.Group = group
+	m.submitting = true
+	t.byGroup[group] = m
+}
+
+// submitted writes the manifest out after a bulk request queued its jobs
+func (t *manifestTracker) submitted(m *bulkManifest) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m.submitting = false
+	t.saveLocked(m)
+	t.releaseLocked(m)
+}
+
+// onJobEvent writes the outcome of a finished job to its group's manifest
+func (t *manifestTracker) onJobEvent(event jobEvent) {
+	if event.Group == "" || !isFinished(JobStatus(event.Event)) {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	m, ok := t.byGroup[event.Group]
+	if !ok {
+		return
+	}
+	if _, ok := m.Entries[event.URL]; !ok {
+		return
+	}
+	m.Entries[event.URL] = &manifestEntry{
+		Status:   JobStatus(event.Event),
+		Filename: event.Filename,
+		Error:    event.Error,
+	}
+	t.saveLocked(m)
+	t.releaseLocked(m)
+}
+
+// releaseLocked stops following m once no URL is pending. Call with t.mu held.
+func (t *manifestTracker) releaseLocked(m *bulkManifest) {
+	if m.submitting {
+		return
+	}
+	for _, entry := range m.Entries {
+		if !isFinished(entry.Status) {
+			return
+		}
+	}
+	if t.byGroup[m.Group] == m {
+		delete(t.byGroup, m.Group)
+	}
+}
+
+// saveLocked writes m to storage, logging failures. Call with t.mu held.
+func (t *manifestTracker) saveLocked(m *bulkManifest) {
+	m.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return
+	}
+	w, err := m.st.Create(m.name)
+	if err != nil {
+		log.Printf("Warning: failed to write bulk manifest %s: %v", m.name, err)
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		w.Abort()
+		log.Printf("Warning: failed to write bulk manifest %s: %v", m.name, err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		log.Printf("Warning: failed to write bulk manifest %s: %v", m.name, err)
+	}
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// JobNormalization records an audio file run through normalize_audio
+type JobNormalization struct {
+	Path       string  `json:"path"`
+	TargetLUFS float64 `json:"target_lufs"`
+	Error      string  `json:"error,omitempty"` // Why the file was left as downloaded
+}
+
+// normalizeAudio evens out the loudness of an audio file saved at
+// finalPath when normalize_audio is set, recording the result on the job
+// jobID. Only local files are normalized, and only when ffmpeg is
+// available. The download already succeeded, so a failed pass leaves the
+// file as downloaded and is only recorded.
+func (s *Server) normalizeAudio(ctx context.Context, jobID string, file plannedFile, finalPath string) {
+	cfg := s.config()
+	if !cfg.NormalizeAudio || !file.audio || !s.store().IsLocal() || !downloader.FFmpegAvailable() {
+		return
+	}
+
+	normalization := JobNormalization{Path: finalPath, TargetLUFS: cfg.LoudnessTargetLUFS()}
+	if err := s.normalizeLoudness(ctx, finalPath, normalization.TargetLUFS); err != nil {
+		logf(ctx, "Warning: failed to normalize loudness of %s: %v", finalPath, err)
+		normalization.Error = err.Error()
+	}
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.Normalizations = append(j.Normalizations, normalization) })
+}
+
+// normalizeLoudness rewrites the local audio file at audioPath at
+// targetLUFS, replacing it only once ffmpeg succeeded
+func (s *Server) normalizeLoudness(ctx context.Context, audioPath string, targetLUFS float64) error {
+	dir, err := os.MkdirTemp("", "vget-loudnorm-")
+	if err != nil {
+		return fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	output := filepath.Join(dir, "audio"+filepath.Ext(audioPath))
+	release, err := s.ffmpeg.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	err = downloader.NormalizeLoudness(audioPath, output, targetLUFS)
+	release()
+	if err != nil {
+		return err
+	}
+	return storage.Upload(localFiles, output, audioPath)
+}
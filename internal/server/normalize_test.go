@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLoudnessTargetConfig(t *testing.T) {
+	s := newTestServer(t, "")
+
+	if got, _ := decodeData(t, doRequest(s, "GET", "/api/config", nil, nil))["loudness_target"].(float64); got != -16 {
+		t.Errorf("default loudness_target = %v; want -16", got)
+	}
+	for _, value := range []string{"-3", "-80", "loud"} {
+		if w := doRequest(s, "POST", "/api/config", jsonBody{"key": "loudness_target", "value": value}, nil); w.Code != http.StatusBadRequest {
+			t.Errorf("POST /api/config loudness_target=%s = %d; want 400", value, w.Code)
+		}
+	}
+	w := doRequest(s, "POST", "/api/config", jsonBody{"key": "loudness_target", "value": "-23"}, nil)
+	if w.Code != http.StatusOK || s.cfg.LoudnessTargetLUFS() != -23 {
+		t.Errorf("POST /api/config loudness_target=-23 = %d, target %v; want 200, -23", w.Code, s.cfg.LoudnessTargetLUFS())
+	}
+}
@@ -0,0 +1,233 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+)
+
+// notifyTimeout bounds how long each notifier may take for one job
+const notifyTimeout = 30 * time.Second
+
+// Notifier sends a notification that a job finished
+type Notifier interface {
+	Name() string
+	Notify(ctx context.Context, summary JobSummary) error
+}
+
+// JobSummary is the final state of a job, as passed to notifiers
+type JobSummary struct {
+	ID       string    `json:"id"`
+	URL      string    `json:"url"`
+	Status   JobStatus `json:"status"`
+	Filename string    `json:"filename,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Title is a one-line description of the outcome
+func (s JobSummary) Title() string {
+	switch s.Status {
+	case JobStatusCompleted:
+		return "Download completed"
+	case JobStatusPartial:
+		return "Download partially completed"
+	case JobStatusCancelled:
+		return "Download cancelled"
+	default:
+		return "Download failed"
+	}
+}
+
+// Body names the file, or the URL and error, for the notification text
+func (s JobSummary) Body() string {
+	name := s.Filename
+	if name == "" {
+		name = s.URL
+	}
+	if s.Error != "" {
+		return name + "\n" + s.Error
+	}
+	return name
+}
+
+// notifierFunc returns the notifiers a config enables
+type notifierFunc func(cfg config.NotifiersConfig) []Notifier
+
+// enabledNotifiers returns the notifiers enabled by cfg
+func enabledNotifiers(cfg config.NotifiersConfig) []Notifier {
+	var enabled []Notifier
+	if cfg.Desktop {
+		enabled = append(enabled, desktopNotifier{})
+	}
+	if cfg.Telegram.BotToken != "" && cfg.Telegram.ChatID != "" {
+		enabled = append(enabled, &telegramNotifier{
+			apiURL: telegramAPIURL,
+			token:  cfg.Telegram.BotToken,
+			chatID: cfg.Telegram.ChatID,
+			client: &http.Client{Timeout: notifyTimeout},
+		})
+	}
+	if cfg.Email.Host != "" && len(cfg.Email.To) > 0 {
+		enabled = append(enabled, emailNotifier{cfg: cfg.Email})
+	}
+	return enabled
+}
+
+// notifyJob sends a finished job's summary to every enabled notifier whose
+// events include its status. Failures are only logged.
+func (s *Server) notifyJob(event jobEvent) {
+	cfg := s.config().Notifiers
+	if !slices.Contains(cfg.NotifyEvents(), event.Event) {
+		return
+	}
+	summary := JobSummary{
+		ID:       event.JobID,
+		URL:      redactURL(event.URL, s.redactedParams()),
+		Status:   JobStatus(event.Event),
+		Filename: event.Filename,
+		Error:    event.Error,
+	}
+	for _, n := range s.notifiers(cfg) {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+			defer cancel()
+			if err := n.Notify(ctx, summary); err != nil {
+				log.Printf("Warning: %s notification for job %s failed: %v", n.Name(), summary.ID, err)
+			}
+		}()
+	}
+}
+
+// desktopNotifier shows a notification on the server's desktop with the
+// platform's own tool
+type desktopNotifier struct{}
+
+func (desktopNotifier) Name() string { return "desktop" }
+
+func (desktopNotifier) Notify(ctx context.Context, summary JobSummary) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s",
+			strconv.Quote(summary.Body()), strconv.Quote(summary.Title()))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	case "windows":
+		quote := func(s string) string { return "'" + strings.ReplaceAll(s, "'", "''") + "'" }
+		script := "Add-Type -AssemblyName System.Windows.Forms; " +
+			"$n = New-Object System.Windows.Forms.NotifyIcon; " +
+			"$n.Icon = [System.Drawing.SystemIcons]::Information; $n.Visible = $true; " +
+			fmt.Sprintf("$n.ShowBalloonTip(10000, %s, %s, 'Info'); ", quote(summary.Title()), quote(summary.Body())) +
+			"Start-Sleep -Seconds 10; $n.Dispose()"
+		cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-Command", script)
+	default:
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=vget", summary.Title(), summary.Body())
+	}
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// telegramAPIURL is the Bot API base the bot token is appended to
+const telegramAPIURL = "https://api.telegram.org/bot"
+
+// telegramNotifier sends a message from a bot to a chat
+type telegramNotifier struct {
+	apiURL string
+	token  string
+	chatID string
+	client *http.Client
+}
+
+func (*telegramNotifier) Name() string { return "telegram" }
+
+func (t *telegramNotifier) Notify(ctx context.Context, summary JobSummary) error {
+	payload, err := json.Marshal(map[string]string{
+		"chat_id": t.chatID,
+		"text":    summary.Title() + "\n" + summary.Body(),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.apiURL+t.token+"/sendMessage", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		// The request URL holds the bot token; keep it out of the log
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("failed to reach the Telegram Bot API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Telegram Bot API returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// emailNotifier sends a plain-text message through an SMTP server
+type emailNotifier struct {
+	cfg config.EmailNotifierConfig
+}
+
+func (emailNotifier) Name() string { return "email" }
+
+func (e emailNotifier) Notify(ctx context.Context, summary JobSummary) error {
+	port := e.cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	from := e.cfg.From
+	if from == "" {
+		from = e.cfg.Username
+	}
+	var auth smtp.Auth
+	if e.cfg.Username != "" {
+		auth = smtp.PlainAuth("", e.cfg.Username, e.cfg.Password, e.cfg.Host)
+	}
+
+	// smtp.SendMail takes no context; bound it by running it aside
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(net.JoinHostPort(e.cfg.Host, strconv.Itoa(port)), auth, from, e.cfg.To, emailMessage(from, e.cfg.To, summary))
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// emailMessage renders summary as an RFC 5322 message
+func emailMessage(from string, to []string, summary JobSummary) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: [vget] %s\r\n", summary.Title())
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(summary.Body(), "\n", "\r\n"))
+	fmt.Fprintf(&b, "\r\n\r\nJob: %s\r\nURL: %s\r\n", summary.ID, summary.URL)
+	return []byte(b.String())
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// recordingNotifier passes every summary it is sent to a channel
+type recordingNotifier chan JobSummary
+
+func (recordingNotifier) Name() string { return "recording" }
+
+func (r recordingNotifier) Notify(ctx context.Context, summary JobSummary) error {
+	r <- summary
+	return nil
+}
+
+func TestNotifyJob(t *testing.T) {
+	s := newTestServer(t, "")
+	sent := make(recordingNotifier, 10)
+	s.notifiers = func(config.NotifiersConfig) []Notifier { return []Notifier{sent} }
+	media := newMediaServer(t, "bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	id, _ := decodeData(t, doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil))["id"].(string)
+	select {
+	case summary := <-sent:
+		if summary.ID != id || summary.Status != JobStatusCompleted || !strings.HasSuffix(summary.Filename, "clip.mp4") {
+			t.Errorf("summary = %+v; want job %s completed with clip.mp4", summary, id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no notification for the completed job")
+	}
+
+	// Only the configured events notify
+	s.cfg.Notifiers.Events = []string{"failed"}
+	id, _ = decodeData(t, doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil))["id"].(string)
+	waitForStatus(t, s.jobQueue, id, JobStatusCompleted)
+	select {
+	case summary := <-sent:
+		t.Errorf("notified %+v; want no notification for completed jobs", summary)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestTelegramNotifier(t *testing.T) {
+	var got map[string]string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:secret/sendMessage" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	t.Cleanup(api.Close)
+
+	n := &telegramNotifier{apiURL: api.URL + "/bot", token: "123:secret", chatID: "42", client: api.Client()}
+	summary := JobSummary{ID: "j1", Status: JobStatusFailed, URL: "https://example.com/v", Error: "HTTP 403"}
+	if err := n.Notify(context.Background(), summary); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if got["chat_id"] != "42" || got["text"] != "Download failed\nhttps://example.com/v\nHTTP 403" {
+		t.Errorf("message = %v", got)
+	}
+
+	n.token = "wrong"
+	if err := n.Notify(context.Background(), summary); err == nil || strings.Contains(err.Error(), "wrong") {
+		t.Errorf("Notify with a bad token = %v; want an error without the token", err)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// planPages plans a download from a paginated listing. Its files are only
+// known as pages are fetched, so they're planned then, numbered across
+// pages and filtered by opts.Indices.
+func (s *Server) planPages(paged extractor.PagedExtractor, url, filename string, opts DownloadOptions) *downloadPlan {
+	name := paged.Name()
+	lastIndex := 0
+	if len(opts.Indices) > 0 {
+		lastIndex = slices.Max(opts.Indices)
+	}
+
+	return &downloadPlan{
+		Extractor: name,
+		MediaType: (&extractor.PlaylistMedia{}).Type(),
+		Paged:     true,
+		multi:     true,
+		noun:      "playlist entries",
+		pages: func(ctx context.Context, fn func(files []plannedFile) error) error {
+			next := 1
+			ctx = downloader.WithRetryPolicy(ctx, s.retryPolicy())
+			return paged.ExtractPages(ctx, url, func(page *extractor.PlaylistMedia) error {
+				var files []plannedFile
+				for _, entry := range page.Entries {
+					if len(opts.Indices) == 0 || slices.Contains(opts.Indices, next) {
+						files = append(files, s.planPlaylistEntry(name, url, filename, page, next, entry))
+					}
+					next++
+				}
+				s.partitionByDate(files)
+				if err := fn(files); err != nil {
+					return err
+				}
+				if lastIndex > 0 && next > lastIndex {
+					return extractor.ErrStopPaging
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// downloadPages downloads a paginated listing as its pages arrive, so large
+// channels are never held in memory whole, stopping after server.max_items
+// entries. Like downloadItems it keeps going past failed entries. When
+// fetching a later page fails, what was saved is kept and the job is
+// reported as partial.
+func (s *Server) downloadPages(ctx context.Context, jobID string, plan *downloadPlan) ([]string, error) {
+	maxItems := s.config().Server.MaxItems
+	var results itemResults
+	var releases []func()
+	defer func() {
+		for _, release := range releases {
+			release()
+		}
+	}()
+
+	err := plan.pages(ctx, func(files []plannedFile) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if maxItems > 0 {
+			files = files[:min(len(files), maxItems-len(results.items))]
+		}
+
+		release, err := s.prepareFiles(jobID, files)
+		if err != nil {
+			return err
+		}
+		releases = append(releases, release)
+		for _, file := range files {
+			if err := s.downloadItem(ctx, jobID, file, &results); err != nil {
+				return err
+			}
+		}
+		s.updateJobFilename(jobID, strings.Join(results.filenames, ", "))
+
+		if maxItems > 0 && len(results.items) >= maxItems {
+			return extractor.ErrStopPaging
+		}
+		return nil
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil && !errors.Is(err, extractor.ErrStopPaging) {
+		if len(results.filenames) == 0 {
+			return nil, fmt.Errorf("extraction failed: %w", err)
+		}
+		results.items = append(results.items, JobItem{Error: fmt.Sprintf("failed to list more %s: %v", plan.noun, err)})
+		results.failed++
+	}
+	if len(results.items) == 0 {
+		return nil, fmt.Errorf("no %s found", plan.noun)
+	}
+	return results.result(plan.noun)
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// pagedMock lists its pages through ExtractPages, failing at page failAt (1-based)
+type pagedMock struct {
+	MockExtractor
+	pages   [][]extractor.PlaylistEntry
+	failAt  int
+	fetched int
+}
+
+func (m *pagedMock) Paginated(url string) bool { return true }
+
+func (m *pagedMock) ExtractPages(ctx context.Context, url string, page extractor.PageFunc) error {
+	for i, entries := range m.pages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if i+1 == m.failAt {
+			return errors.New("page unavailable")
+		}
+		m.fetched++
+		if err := page(&extractor.PlaylistMedia{ID: "channel", Entries: entries}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestPagedDownload(t *testing.T) {
+	media := newMediaServer(t, "audio-bytes")
+	newPaged := func(failAt int) (*pagedMock, string) {
+		m := &pagedMock{failAt: failAt}
+		for p := range 3 {
+			m.pages = append(m.pages, []extractor.PlaylistEntry{
+				{URL: fmt.Sprintf("%s/%d-a.mp3", media.URL, p)},
+				{URL: fmt.Sprintf("%s/%d-b.mp3", media.URL, p)},
+			})
+		}
+		host := fmt.Sprintf("paged-%d.example.com", time.Now().UnixNano())
+		extractor.Register(m, host)
+		return m, "https://" + host + "/channel"
+	}
+
+	t.Run("max_items stops paging", func(t *testing.T) {
+		s := newTestServer(t, "")
+		s.cfg.Server.MaxItems = 3
+		m, pageURL := newPaged(0)
+
+		w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+		id, _ := decodeData(t, w)["id"].(string)
+		waitForStatus(t, s.jobQueue, id, JobStatusCompleted)
+		if m.fetched != 2 {
+			t.Errorf("fetched %d pages; want 2", m.fetched)
+		}
+		for i := 1; i <= 4; i++ {
+			_, err := os.Stat(filepath.Join(s.outputDir, fmt.Sprintf("channel_%d.mp3", i)))
+			if exists := err == nil; exists != (i <= 3) {
+				t.Errorf("channel_%d.mp3 exists = %v", i, exists)
+			}
+		}
+	})
+
+	t.Run("listing failure keeps earlier pages", func(t *testing.T) {
+		s := newTestServer(t, "")
+		_, pageURL := newPaged(2)
+
+		w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+		id, _ := decodeData(t, w)["id"].(string)
+		job := waitForStatus(t, s.jobQueue, id, JobStatusPartial)
+		if len(job.Items) != 3 || job.Items[2].Error == "" {
+			t.Errorf("items = %+v; want 2 saved and the listing error", job.Items)
+		}
+	})
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// pathHeadroom is room kept under the limits for what a transfer adds to a
+// planned path: " (2)" deduplication suffixes, .part files, and the
+// companion files of merges and remuxes
+const pathHeadroom = 32
+
+// maxPathLength returns the longest local output path allowed:
+// server.max_path_length if set, otherwise the platform limit
+func (s *Server) maxPathLength() int {
+	if n := s.config().Server.MaxPathLength; n > 0 {
+		return n
+	}
+	return defaultMaxPathLength
+}
+
+// fitPathLengths shortens the names of local files whose full path (output
+// directory, date_partition subdirectories and name) or name alone would
+// exceed the limits, keeping their extensions, so a long title doesn't
+// fail the transfer when the file is created. Object storage has no such
+// limits and is left alone.
+func (s *Server) fitPathLengths(files []plannedFile) {
+	if !s.store().IsLocal() {
+		return
+	}
+	maxPath := s.maxPathLength()
+	for i := range files {
+		fitted, ok := fitPathLength(files[i].Path, maxPath-pathHeadroom, maxNameLength-pathHeadroom)
+		if !ok {
+			log.Printf("Warning: output directory of %s is too long to fit any filename within %d characters", files[i].Path, maxPath)
+			continue
+		}
+		files[i].Path = fitted
+	}
+}
+
+// fitPathLength trims the base name of p, before its extension, until the
+// absolute path fits maxPath and the name fits maxName. It reports false
+// if not even a one-character name would fit.
+func fitPathLength(p string, maxPath, maxName int) (string, bool) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		abs = p
+	}
+	dir, name := filepath.Dir(p), filepath.Base(p)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	over := max(pathLength(abs)-maxPath, pathLength(name)-maxName)
+	if over <= 0 {
+		return p, true
+	}
+	for over > 0 && stem != "" {
+		_, size := utf8.DecodeLastRuneInString(stem)
+		over -= pathLength(stem[len(stem)-size:])
+		stem = stem[:len(stem)-size]
+	}
+	// Windows drops trailing dots and spaces, which would change the name
+	stem = strings.TrimRight(stem, ". ")
+	if stem == "" {
+		return p, false
+	}
+	return filepath.Join(dir, stem+ext), true
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+
+package server
+
+const (
+	// defaultMaxPathLength is PATH_MAX (4096) less the terminating NUL
+	defaultMaxPathLength = 4095
+	maxNameLength        = 255 // NAME_MAX
+)
+
+// pathLength measures p in bytes, as Unix filesystems count it
+func pathLength(p string) int {
+	return len(p)
+}
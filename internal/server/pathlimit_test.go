@@ -0,0 +1,63 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestFitPathLength(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "a", "b")
+	tests := []struct {
+		name     string
+		maxPath  int
+		maxName  int
+		expected string // Base name of the result
+	}{
+		{name: "short title.mp4", maxPath: 4095, maxName: 255, expected: "short title.mp4"},
+		{name: "long title.mp4", maxPath: len(dir) + len("/long.mp4"), maxName: 255, expected: "long.mp4"},
+		{name: "long title.mp4", maxPath: 4095, maxName: len("long .mp4"), expected: "long.mp4"}, // No trailing space
+		{name: "视频标题.mp4", maxPath: 4095, maxName: len("视频.mp4") + 2, expected: "视频.mp4"},        // Whole runes only
+	}
+	for _, tt := range tests {
+		got, ok := fitPathLength(filepath.Join(dir, tt.name), tt.maxPath, tt.maxName)
+		if !ok || got != filepath.Join(dir, tt.expected) {
+			t.Errorf("fitPathLength(%q, %d, %d) = %q, %v; want %q", tt.name, tt.maxPath, tt.maxName, got, ok, tt.expected)
+		}
+	}
+
+	if _, ok := fitPathLength(filepath.Join(dir, "title.mp4"), len(dir), 255); ok {
+		t.Error("fitPathLength with no room for a name succeeded; want false")
+	}
+}
+
+func TestDownloadDeepPathShortened(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.DatePartition = true
+	media := newMediaServer(t, "bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   strings.Repeat("very long title ", 4),
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	// Room for "2026/01/02/" and a 12-character name, plus the headroom
+	dir, _ := filepath.Abs(s.outputDir)
+	s.cfg.Server.MaxPathLength = len(dir) + len("/2026/01/02/") + 12 + pathHeadroom
+
+	w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil)
+	id, _ := decodeData(t, w)["id"].(string)
+	job := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+	if job.Status != JobStatusCompleted {
+		t.Fatalf("job status = %s (error: %s); want completed", job.Status, job.Error)
+	}
+
+	expected := filepath.Join(s.outputDir, time.Now().Format("2006/01/02"), "very lon.mp4")
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("shortened output missing: %v (filename %s)", err, job.Filename)
+	}
+}
@@ -0,0 +1,15 @@
+package server
+
+import "unicode/utf16"
+
+const (
+	// defaultMaxPathLength is MAX_PATH (260) less the terminating NUL, the
+	// limit without long path support
+	defaultMaxPathLength = 259
+	maxNameLength        = 255
+)
+
+// pathLength measures p in UTF-16 code units, as Windows counts it
+func pathLength(p string) int {
+	return len(utf16.Encode([]rune(p)))
+}
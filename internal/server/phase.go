@@ -0,0 +1,36 @@
+package server
+
+import "context"
+
+// JobPhase is the stage a downloading job is in, so clients can tell a
+// merge or other post-processing from a stalled transfer at 100%
+type JobPhase string
+
+const (
+	JobPhaseExtracting     JobPhase = "extracting"      // Extracting media info and planning the files
+	JobPhaseDownloading    JobPhase = "downloading"     // Transferring media
+	JobPhaseMerging        JobPhase = "merging"         // ffmpeg merging streams or remuxing HLS
+	JobPhasePostProcessing JobPhase = "post_processing" // Clips, conversion, loudness, chapters and thumbnails
+	JobPhaseUploading      JobPhase = "uploading"       // Copying to destination.*
+)
+
+type phaseKey struct{}
+
+// withPhase returns ctx reporting the phases of the job jobID to the queue
+func (s *Server) withPhase(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, phaseKey{}, func(phase JobPhase) {
+		s.jobQueue.updateJob(jobID, func(j *Job) {
+			if j.Status == JobStatusDownloading {
+				j.Phase = phase
+			}
+		})
+	})
+}
+
+// setPhase reports that the job running under ctx entered phase. It does
+// nothing outside a job (e.g., for streamed downloads).
+func setPhase(ctx context.Context, phase JobPhase) {
+	if report, ok := ctx.Value(phaseKey{}).(func(JobPhase)); ok {
+		report(phase)
+	}
+}
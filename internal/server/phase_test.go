@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestJobPhase(t *testing.T) {
+	s := newTestServer(t, "")
+	release := make(chan struct{})
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Write([]byte("bytes"))
+	}))
+	t.Cleanup(media.Close)
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	id, _ := decodeData(t, doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, nil))["id"].(string)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		phase, _ := decodeData(t, doRequest(s, "GET", "/api/status/"+id, nil, nil))["phase"].(string)
+		if phase == string(JobPhaseDownloading) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("phase = %q; want downloading while the transfer runs", phase)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(release)
+	job := waitForStatus(t, s.jobQueue, id, JobStatusCompleted, JobStatusFailed)
+	if job.Status != JobStatusCompleted || job.Phase != "" {
+		t.Errorf("finished job = %s, phase %q; want completed with no phase", job.Status, job.Phase)
+	}
+}
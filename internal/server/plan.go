@@ -0,0 +1,967 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+// downloadPlan describes what a download will do without transferring any
+// bytes: which URLs are fetched, with which headers, and where they land
+type downloadPlan struct {
+	Extractor string              `json:"extractor"`
+	MediaType extractor.MediaType `json:"media_type"`
+	Title     string              `json:"title"`
+	Quality   string              `json:"quality,omitempty"` // Selected video quality
+	Clip      string              `json:"clip,omitempty"`    // Time range cut from the video
+	Files     []plannedFile       `json:"files"`
+	Merge     bool                `json:"merge"` // Separate video/audio streams merged with ffmpeg
+	HLS       bool                `json:"hls"`   // Segmented HLS download (final path may change after remux)
+
+	// Paged plans a paginated listing's files page by page as the download
+	// runs, so a dry run lists none
+	Paged bool `json:"paged,omitempty"`
+
+	// multi marks galleries/playlists, which keep going past per-item failures
+	multi bool
+	noun  string // Item noun used in errors, e.g. "images"
+
+	// fallbacks are the files of other formats tried in order when the
+	// single video file fails to download (see server.format_fallbacks)
+	fallbacks []plannedFile
+
+	// pages plans a paginated source page by page, passing each page's
+	// files to fn (see downloadPages)
+	pages func(ctx context.Context, fn func(files []plannedFile) error) error
+}
+
+// plannedFile is one output file of a download plan
+type plannedFile struct {
+	Index    int               `json:"index,omitempty"` // 1-based position for multi-item sources
+	URL      string            `json:"url"`
+	AudioURL string            `json:"audio_url,omitempty"`
+	Ext      string            `json:"ext"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Path     string            `json:"path"`
+	Quality  string            `json:"quality,omitempty"`
+	Merge    bool              `json:"merge,omitempty"`
+	HLS      bool              `json:"hls,omitempty"`
+
+	// AudioHeaders replace Headers for AudioURL when the extractor set
+	// separate ones (e.g., the audio is served from another host)
+	AudioHeaders map[string]string `json:"audio_headers,omitempty"`
+
+	// Thumbnail is an image saved next to the output (see saveThumbnail)
+	Thumbnail string `json:"thumbnail,omitempty"`
+
+	video    bool                // A video format, checked against login pages (see mediaCheck)
+	audio    bool                // An audio file; audio and video are checked against min_output_size
+	image    bool                // An image, checked against non-image responses (see mediaCheck)
+	inferExt bool                // Audio without a trustworthy Ext: named after its Content-Type (see audioExtFor)
+	chapters []extractor.Chapter // Saved with the video (see saveChapters)
+
+	// start and end cut the downloaded video to a time range (see downloadClip)
+	start, end time.Duration
+
+	// checkHost marks a URL listed by the source rather than produced by an
+	// extractor (a playlist entry), checked against the domain policy
+	checkHost bool
+
+	// live marks a playlist entry of unknown duration, recorded for at most
+	// server.max_live_duration
+	live bool
+}
+
+// findExtractor returns the extractor for a URL, falling back to sites.yml
+// and then the generic browser extractor, with credentials applied.
+// opts.Extractor forces that extractor and skips matching.
+func (s *Server) findExtractor(ctx context.Context, url string, opts DownloadOptions) extractor.Extractor {
+	var ext extractor.Extractor
+	if opts.Extractor != "" {
+		ext = extractor.ByName(opts.Extractor)
+	} else {
+		ext = extractor.Match(url)
+	}
+	if ext == nil {
+		if site := matchSite(url); site != nil {
+			ext = extractor.NewBrowserExtractor(site, false)
+		}
+		if ext == nil {
+			// A direct file without a telling extension needs no browser
+			ext = s.probeDirect(ctx, url, opts)
+		}
+		if ext == nil {
+			ext = extractor.NewGenericBrowserExtractor(false)
+		}
+	}
+
+	// Configure Twitter extractor with auth if available
+	if twitterExt, ok := ext.(*extractor.TwitterExtractor); ok {
+		if s.config().Twitter.AuthToken != "" {
+			twitterExt.SetAuth(s.config().Twitter.AuthToken)
+		}
+	}
+
+	if opts.InsecureSkipVerify {
+		ext = extractor.WithInsecureTLS(ext)
+	}
+	return extractor.WithLoginMarkers(ext, s.config().Server.LoginMarkers)
+}
+
+// probeDirect checks whether url is a direct media file (see
+// extractor.ProbeDirect) with the server's transport settings, following
+// only redirects the domain policy allows
+func (s *Server) probeDirect(ctx context.Context, url string, opts DownloadOptions) extractor.Extractor {
+	client := newDownloadClient(s.transferContext(ctx, opts.InsecureSkipVerify))
+	checkRedirect := client.CheckRedirect
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if err := checkRedirect(req, via); err != nil {
+			return err
+		}
+		return s.checkDomain(req.URL.String())
+	}
+	return extractor.ProbeDirect(ctx, client, url)
+}
+
+// matchSite returns the sites.yml entry matching url, or nil. An invalid
+// sites.yml is logged and ignored.
+func matchSite(url string) *config.Site {
+	sitesConfig, err := config.LoadSites()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return nil
+	}
+	return sitesConfig.MatchSite(url)
+}
+
+// preferFormat narrows formats to those in the preferred container ext,
+// keeping them all when ext is empty or "best", or none of them match
+func preferFormat(formats []extractor.VideoFormat, ext string) []extractor.VideoFormat {
+	ext = strings.ToLower(ext)
+	if ext == "" || ext == "best" {
+		return formats
+	}
+	var preferred []extractor.VideoFormat
+	for _, f := range formats {
+		if strings.EqualFold(f.Ext, ext) {
+			preferred = append(preferred, f)
+		}
+	}
+	if len(preferred) == 0 {
+		return formats
+	}
+	return preferred
+}
+
+// planDownload extracts media info for url, after resolving it if it's a
+// shortened link, and computes the download plan
+func (s *Server) planDownload(ctx context.Context, url, filename string, opts DownloadOptions) (*downloadPlan, error) {
+	url, err := s.resolveURL(ctx, url, opts)
+	if err != nil {
+		return nil, err
+	}
+	ext := s.findExtractor(ctx, url, opts)
+	if paged, ok := ext.(extractor.PagedExtractor); ok && paged.Paginated(url) {
+		return s.planPages(paged, url, filename, opts), nil
+	}
+
+	media, err := s.extract(ctx, ext, url)
+	if err != nil {
+		return nil, fmt.Errorf("extraction failed: %w", err)
+	}
+
+	return s.planMedia(ext.Name(), url, filename, opts, media)
+}
+
+// planMedia selects formats and computes output paths for media extracted
+// by the named extractor
+func (s *Server) planMedia(extractorName, url, filename string, opts DownloadOptions, media extractor.Media) (*downloadPlan, error) {
+	plan := &downloadPlan{
+		Extractor: extractorName,
+		MediaType: media.Type(),
+		Title:     media.GetTitle(),
+	}
+
+	switch m := media.(type) {
+	case *extractor.VideoMedia:
+		if len(m.Formats) == 0 {
+			return nil, fmt.Errorf("no video formats available")
+		}
+
+		// A matching sites.yml entry's preferences come before the global
+		// quality; a quality in the request comes before both
+		formats := m.Formats
+		quality := opts.Quality
+		if site := matchSite(url); site != nil {
+			formats = preferFormat(formats, site.Format)
+			if quality == "" {
+				quality = site.Quality
+			}
+		}
+
+		if len(opts.Qualities) > 0 {
+			// One file per distinct format, named after the quality it resolved to
+			seen := make(map[string]bool)
+			var labels []string
+			for _, requested := range opts.Qualities {
+				format, quality, err := s.selectFormat(formats, requested)
+				if err != nil {
+					return nil, err
+				}
+				key := format.URL + "|" + format.AudioURL
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				labels = append(labels, quality)
+
+				file := s.planVideoFile(plan.Extractor, url, filename, m, format, quality)
+				file.Index = len(plan.Files) + 1
+				file.start, file.end = opts.StartTime, opts.EndTime
+				plan.Files = append(plan.Files, file)
+				plan.Merge = plan.Merge || file.Merge
+				plan.HLS = plan.HLS || file.HLS
+			}
+			plan.Quality = strings.Join(labels, ", ")
+			plan.Clip = opts.Clip()
+			plan.multi = true
+			plan.noun = "qualities"
+			break
+		}
+
+		var format *extractor.VideoFormat
+		var err error
+		if opts.FormatID != "" {
+			// IDs follow the extractor's order, not the site-preferred one
+			format, err = selectFormatByID(m.Formats, opts.FormatID)
+			if err == nil {
+				quality = formatQuality(format)
+			}
+		} else {
+			format, quality, err = s.selectFormat(formats, quality)
+		}
+		if err != nil {
+			return nil, err
+		}
+		plan.Quality = quality
+
+		file := s.planVideoFile(plan.Extractor, url, filename, m, format, "")
+		file.Quality = quality
+		file.start, file.end = opts.StartTime, opts.EndTime
+		plan.Files = []plannedFile{file}
+
+		// Next-best formats to try if this one fails, unless the request
+		// asked for a quality or format
+		if limit := s.config().Server.FormatFallbacks; limit > 0 && opts.Quality == "" && opts.FormatID == "" {
+			for _, f := range s.fallbackFormats(formats, format, limit) {
+				fallback := s.planVideoFile(plan.Extractor, url, filename, m, f, "")
+				fallback.Quality = formatQuality(f)
+				fallback.start, fallback.end = opts.StartTime, opts.EndTime
+				plan.fallbacks = append(plan.fallbacks, fallback)
+			}
+		}
+		plan.Merge = file.Merge
+		plan.HLS = file.HLS
+		plan.Clip = opts.Clip()
+
+	case *extractor.AudioMedia:
+		// A missing or generic extension is taken from the URL if it names
+		// an audio type, otherwise from the response (see downloadAudioFile)
+		ext := m.Ext
+		inferExt := false
+		if ambiguousAudioExt(strings.ToLower(ext)) {
+			if fromURL := audioExtFromURL(m.URL); fromURL != "" {
+				ext = fromURL
+			} else {
+				inferExt = true
+			}
+		}
+		suffix := ""
+		if ext != "" {
+			suffix = "." + ext
+		}
+
+		var outputPath string
+		if filename != "" {
+			// Sanitize the provided filename to remove invalid path characters
+			sanitized := s.sanitizeFilename(filename)
+			// Ensure the filename has the correct extension
+			if !strings.HasSuffix(strings.ToLower(sanitized), strings.ToLower(suffix)) {
+				sanitized += suffix
+			}
+			outputPath = s.store().Join(sanitized)
+		} else {
+			title := s.sanitizeFilename(m.Title)
+			if title != "" {
+				outputPath = s.store().Join(title + suffix)
+			} else {
+				outputPath = s.store().Join(m.ID + suffix)
+			}
+		}
+
+		plan.Files = []plannedFile{{
+			URL:       m.URL,
+			Ext:       ext,
+			Headers:   s.mediaHeaders(nil, plan.Extractor, url),
+			Path:      outputPath,
+			HLS:       isHLSURL(m.URL),
+			Thumbnail: s.thumbnailURL(m.Thumbnail, m.Thumbnails),
+			audio:     true,
+			inferExt:  inferExt,
+		}}
+		plan.HLS = plan.Files[0].HLS
+
+	case *extractor.ImageMedia:
+		if len(m.Images) == 0 {
+			return nil, fmt.Errorf("no images available")
+		}
+
+		selected, err := selectIndices(len(m.Images), opts.Indices)
+		if err != nil {
+			return nil, err
+		}
+
+		title := s.sanitizeFilename(m.Title)
+		if title == "" {
+			title = m.ID
+		}
+		for _, i := range selected {
+			img := m.Images[i]
+			imgPath := s.store().Join(fmt.Sprintf("%s.%s", title, img.Ext))
+			if len(m.Images) > 1 {
+				imgPath = s.store().Join(fmt.Sprintf("%s_%d.%s", title, i+1, img.Ext))
+			}
+			plan.Files = append(plan.Files, plannedFile{
+				Index:   i + 1,
+				URL:     img.URL,
+				Ext:     img.Ext,
+				Headers: s.mediaHeaders(nil, plan.Extractor, url),
+				Path:    imgPath,
+				image:   true,
+			})
+		}
+		plan.multi = true
+		plan.noun = "images"
+
+	case *extractor.PlaylistMedia:
+		if len(m.Entries) == 0 {
+			return nil, fmt.Errorf("playlist is empty")
+		}
+
+		selected, err := selectIndices(len(m.Entries), opts.Indices)
+		if err != nil {
+			return nil, err
+		}
+		if maxItems := s.config().Server.MaxItems; maxItems > 0 && len(selected) > maxItems {
+			selected = selected[:maxItems]
+		}
+
+		for _, i := range selected {
+			plan.Files = append(plan.Files, s.planPlaylistEntry(plan.Extractor, url, filename, m, i+1, m.Entries[i]))
+		}
+		plan.multi = true
+		plan.noun = "playlist entries"
+
+	default:
+		return nil, fmt.Errorf("unsupported media type")
+	}
+
+	// Plans are made as the job starts, so now is the job's start date
+	s.partitionByDate(plan.Files)
+	return plan, nil
+}
+
+// partitionByDate moves files into a dated directory (e.g., 2024/05/01)
+// when date_partition is enabled
+func (s *Server) partitionByDate(files []plannedFile) {
+	if !s.config().DatePartition {
+		return
+	}
+	dir := time.Now().Format("2006/01/02")
+	for i := range files {
+		files[i].Path = s.store().Join(dir, filepath.Base(files[i].Path))
+	}
+}
+
+// planPlaylistEntry plans the file for the entry at 1-based index of
+// playlist m, named after filename if given or else the playlist's ID
+func (s *Server) planPlaylistEntry(extractorName, url, filename string, m *extractor.PlaylistMedia, index int, entry extractor.PlaylistEntry) plannedFile {
+	title := m.ID
+	if filename != "" {
+		title = s.sanitizeFilename(filename)
+	}
+	return plannedFile{
+		Index:     index,
+		URL:       entry.URL,
+		Ext:       entry.Ext(),
+		Headers:   s.mediaHeaders(nil, extractorName, url),
+		Path:      s.store().Join(fmt.Sprintf("%s_%d.%s", title, index, entry.Ext())),
+		checkHost: true,
+		live:      entry.Duration <= 0,
+	}
+}
+
+// planVideoFile computes the output file for one video format. A non-empty
+// suffix (e.g., a quality label) is appended to the base name.
+func (s *Server) planVideoFile(extractorName, url, filename string, m *extractor.VideoMedia, format *extractor.VideoFormat, suffix string) plannedFile {
+	ext := format.Ext
+	if ext == "m3u8" {
+		// HLS is saved as .ts first; the final name comes from DownloadHLSWithConfig
+		ext = "ts"
+	}
+
+	var base string
+	if filename != "" {
+		// Sanitize the provided filename to remove invalid path characters
+		base = s.sanitizeFilename(filename)
+		if format.Ext == "m3u8" {
+			// Avoid "name.mp4.ts" -> "name.mp4.mp4" when the caller already named the result
+			base = trimExt(base, ".mp4", ".ts")
+		}
+		base = trimExt(base, "."+ext)
+	} else {
+		base = s.sanitizeFilename(m.Title)
+		if base == "" {
+			base = m.ID
+		}
+	}
+	if suffix != "" {
+		base = fmt.Sprintf("%s_%s", base, suffix)
+	}
+
+	merge := format.AudioURL != ""
+	var audioHeaders map[string]string
+	if merge && format.AudioHeaders != nil {
+		audioHeaders = s.mediaHeaders(format.AudioHeaders, extractorName, url)
+	}
+	return plannedFile{
+		URL:          format.URL,
+		AudioURL:     format.AudioURL,
+		Ext:          format.Ext,
+		Headers:      s.mediaHeaders(format.Headers, extractorName, url),
+		AudioHeaders: audioHeaders,
+		Path:         s.store().Join(fmt.Sprintf("%s.%s", base, ext)),
+		Quality:      suffix,
+		Merge:        merge,
+		HLS:          !merge && isHLSURL(format.URL),
+		Thumbnail:    s.thumbnailURL(m.Thumbnail, m.Thumbnails),
+		video:        true,
+		chapters:     m.Chapters,
+	}
+}
+
+// thumbnailURL returns the thumbnail to save when download_thumbnail is
+// enabled: the size of thumbnails that best fits thumbnail_max_height, or
+// url when the source offers no sizes
+func (s *Server) thumbnailURL(url string, thumbnails []extractor.Thumbnail) string {
+	if !s.config().DownloadThumbnail {
+		return ""
+	}
+	return extractor.BestThumbnail(url, thumbnails, s.config().ThumbnailMaxHeight)
+}
+
+// executePlan performs the byte transfer for a plan computed by planDownload,
+// recording the output files on the job jobID. It returns the paths of the
+// saved files.
+func (s *Server) executePlan(ctx context.Context, jobID string, plan *downloadPlan, progressFn func(downloaded, total int64)) ([]string, error) {
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.outputs, j.sidecars, j.Conversions, j.Normalizations = nil, nil, nil, nil })
+	if plan.pages != nil {
+		return s.downloadPages(ctx, jobID, plan)
+	}
+
+	release, err := s.prepareFiles(jobID, plan.Files)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	if plan.multi {
+		return s.downloadItems(ctx, jobID, plan.noun, plan.Files)
+	}
+
+	file := plan.Files[0]
+	s.updateJobFilename(jobID, file.Path)
+
+	finalPath, err := s.downloadPlannedFile(ctx, file, progressFn)
+	for _, fallback := range plan.fallbacks {
+		if err == nil || ctx.Err() != nil || !formatFailed(err) {
+			break
+		}
+		logf(ctx, "Job %s: %s format failed (%v), falling back to %s", jobID, file.Quality, err, fallback.Quality)
+
+		// Drop the failed attempt's files, and save under the claimed name
+		s.jobQueue.removePartialOutputs(jobID, nil)
+		fallback.Path = strings.TrimSuffix(file.Path, filepath.Ext(file.Path)) + filepath.Ext(fallback.Path)
+		file = fallback
+		st := s.store()
+		s.jobQueue.updateJob(jobID, func(j *Job) {
+			j.outputs = map[int][]string{0: file.outputPaths(st)}
+			j.Quality = file.Quality
+		})
+		s.jobQueue.retargetReservation(file.Path)
+		s.updateJobFilename(jobID, file.Path)
+		finalPath, err = s.downloadPlannedFile(ctx, file, progressFn)
+	}
+	if err != nil {
+		return nil, err
+	}
+	setPhase(ctx, JobPhasePostProcessing)
+	finalPath = s.convertOutput(ctx, jobID, file, finalPath)
+	s.normalizeAudio(ctx, jobID, file, finalPath)
+	if finalPath != file.Path {
+		s.updateJobFilename(jobID, finalPath)
+	}
+	s.saveThumbnail(ctx, jobID, file, finalPath)
+	s.saveChapters(ctx, jobID, file, finalPath)
+	s.faststart(ctx, file, finalPath)
+	return []string{finalPath}, nil
+}
+
+// prepareFiles readies planned files for transfer: it shortens names that
+// would exceed the path limits (see fitPathLengths), claims their output
+// paths, so a concurrent job resolving to the same name gets a deduplicated
+// one instead of interleaving writes, creates their directories, and adds
+// every file the transfer may write to the job's outputs so a failure can
+// clean up. The returned func releases the claimed paths.
+func (s *Server) prepareFiles(jobID string, files []plannedFile) (func(), error) {
+	s.fitPathLengths(files)
+	paths := make([]string, len(files))
+	for i, file := range files {
+		paths[i] = file.Path
+	}
+	paths, release := s.jobQueue.reservePaths(paths)
+	for i := range files {
+		files[i].Path = paths[i]
+	}
+
+	// Create date_partition directories; object storage needs none
+	if s.store().IsLocal() {
+		for _, p := range paths {
+			if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+				release()
+				return nil, fmt.Errorf("failed to create output directory: %w", err)
+			}
+		}
+	}
+
+	st := s.store()
+	s.jobQueue.updateJob(jobID, func(j *Job) {
+		if j.outputs == nil {
+			j.outputs = make(map[int][]string, len(files))
+		}
+		for _, file := range files {
+			j.outputs[file.Index] = file.outputPaths(st)
+		}
+	})
+	return release, nil
+}
+
+// saveThumbnail downloads a file's thumbnail next to its output at
+// finalPath, with the same base name and the image's extension. The media
+// is already saved, so failures are only logged.
+func (s *Server) saveThumbnail(ctx context.Context, jobID string, file plannedFile, finalPath string) {
+	if file.Thumbnail == "" {
+		return
+	}
+	// Thumbnails are often on another host than the media, named by the page
+	if err := s.checkDomain(file.Thumbnail); err != nil {
+		logf(ctx, "Warning: not saving thumbnail for %s: %v", finalPath, err)
+		return
+	}
+	thumbPath := strings.TrimSuffix(finalPath, path.Ext(finalPath)) + "." + thumbnailExt(file.Thumbnail)
+	if err := downloadFile(ctx, s.store(), file.Thumbnail, thumbPath, thumbnailHeaders(file), nil); err != nil {
+		logf(ctx, "Warning: failed to save thumbnail for %s: %v", finalPath, err)
+		return
+	}
+	s.addSidecar(jobID, thumbPath)
+}
+
+// addSidecar records a file saved next to one of the job's outputs (a
+// thumbnail or chapters), so it is uploaded to the destination with them
+func (s *Server) addSidecar(jobID, p string) {
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.sidecars = append(j.sidecars, p) })
+}
+
+// thumbnailHeaders returns the headers file's thumbnail is fetched with: the
+// media's own, without those that may carry credentials (cookies, auth)
+// when the thumbnail is on another host
+func thumbnailHeaders(file plannedFile) map[string]string {
+	media, err1 := url.Parse(file.URL)
+	thumb, err2 := url.Parse(file.Thumbnail)
+	if err1 == nil && err2 == nil && strings.EqualFold(media.Hostname(), thumb.Hostname()) {
+		return file.Headers
+	}
+	headers := make(map[string]string, len(file.Headers))
+	for name, value := range file.Headers {
+		if !isSensitiveHeader(name) {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// thumbnailExt returns the image extension of a thumbnail URL, "jpg" when
+// the URL doesn't name a known image type
+func thumbnailExt(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		switch ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), ".")); ext {
+		case "jpg", "png", "webp", "gif":
+			return ext
+		case "jpeg":
+			return "jpg"
+		}
+		// Twitter-style ?format=png
+		if format := u.Query().Get("format"); format == "png" || format == "webp" {
+			return format
+		}
+	}
+	return "jpg"
+}
+
+// audioContentTypes maps audio Content-Types to file extensions
+var audioContentTypes = map[string]string{
+	"audio/mpeg":   "mp3",
+	"audio/mp3":    "mp3",
+	"audio/mp4":    "m4a",
+	"audio/x-m4a":  "m4a",
+	"audio/m4a":    "m4a",
+	"audio/aac":    "aac",
+	"audio/ogg":    "ogg",
+	"audio/opus":   "opus",
+	"audio/webm":   "webm",
+	"audio/wav":    "wav",
+	"audio/x-wav":  "wav",
+	"audio/flac":   "flac",
+	"audio/x-flac": "flac",
+}
+
+// audioExts are the extensions audioContentTypes can produce
+var audioExts = []string{"mp3", "m4a", "aac", "ogg", "opus", "webm", "wav", "flac"}
+
+// ambiguousAudioExt reports whether an extractor's audio extension says
+// nothing about the format
+func ambiguousAudioExt(ext string) bool {
+	switch ext {
+	case "", "bin", "dat", "raw", "audio", "octet-stream":
+		return true
+	}
+	return false
+}
+
+// audioExtFromURL returns the audio extension named by a URL's path, or ""
+func audioExtFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(u.Path), "."))
+	if slices.Contains(audioExts, ext) {
+		return ext
+	}
+	return ""
+}
+
+// audioExtFor returns the extension for an audio Content-Type, or "" when
+// it isn't a known audio type
+func audioExtFor(contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return audioContentTypes[strings.ToLower(mediaType)]
+}
+
+// withAudioExt replaces the ambiguous extension ext of p (possibly none)
+// with newExt
+func withAudioExt(p, ext, newExt string) string {
+	if ext != "" {
+		p = strings.TrimSuffix(p, "."+ext)
+	}
+	return p + "." + newExt
+}
+
+// downloadAudioFile downloads an audio file planned without a trustworthy
+// extension, naming it after the response's Content-Type. Unknown types
+// keep the planned path. It returns the path the file was saved at.
+func downloadAudioFile(ctx context.Context, st storage.Storage, file plannedFile, progressFn func(downloaded, total int64)) (string, error) {
+	finalPath := file.Path
+	err := fetchFile(ctx, st, file.URL, file.Headers, progressFn, func(resp *http.Response) string {
+		if ext := audioExtFor(resp.Header.Get("Content-Type")); ext != "" {
+			finalPath = withAudioExt(file.Path, file.Ext, ext)
+		}
+		return finalPath
+	}, "")
+	return finalPath, err
+}
+
+// downloadPlannedFile transfers a single planned file and checks its size,
+// returning the path the output ended up at
+func (s *Server) downloadPlannedFile(ctx context.Context, file plannedFile, progressFn func(downloaded, total int64)) (string, error) {
+	finalPath, err := s.transferPlannedFile(ctx, file, progressFn)
+	if err != nil {
+		return finalPath, err
+	}
+	return finalPath, s.checkOutputSize(file, finalPath)
+}
+
+// checkOutputSize fails a video or audio file that saved less than
+// server.min_output_size and deletes it, since a tiny "successful" download
+// is almost always an error page saved as media. Images and other items,
+// which can legitimately be tiny, aren't checked.
+func (s *Server) checkOutputSize(file plannedFile, finalPath string) error {
+	minSize := s.config().Server.MinOutputSizeBytes()
+	if minSize <= 0 || !(file.video || file.audio) {
+		return nil
+	}
+	st := s.store()
+	info, err := st.Stat(finalPath)
+	if err != nil || info.Size >= minSize {
+		return nil
+	}
+	if err := st.Remove(finalPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("Warning: failed to remove undersized output %s: %v", finalPath, err)
+	}
+	return &mediaCheckError{fmt.Errorf("output is only %d bytes (server.min_output_size is %d), likely an error page; removed %s", info.Size, minSize, filepath.Base(finalPath))}
+}
+
+// formatFailed reports whether err is a failure of the format itself,
+// which another format of the same media might not hit: a network error,
+// an error status, or a response or output the media checks rejected.
+// Login walls and the domain policy apply to every format.
+func formatFailed(err error) bool {
+	if errors.Is(err, extractor.ErrLoginRequired) || errors.Is(err, errDomainNotAllowed) {
+		return false
+	}
+	var netErr net.Error
+	var statusErr *downloader.StatusError
+	var checkErr *mediaCheckError
+	return errors.As(err, &netErr) || errors.As(err, &statusErr) || errors.As(err, &checkErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// errLiveLimit is the cause of a live recording stopped at
+// server.max_live_duration; copyWithProgress keeps what was recorded
+var errLiveLimit = errors.New("live recording limit reached")
+
+// transferPlannedFile transfers a single planned file, merging or fetching
+// HLS segments as planned, and returns the path the output ended up at
+func (s *Server) transferPlannedFile(ctx context.Context, file plannedFile, progressFn func(downloaded, total int64)) (string, error) {
+	if file.checkHost {
+		if err := s.checkDomain(file.URL); err != nil {
+			return file.Path, err
+		}
+	}
+	if file.live {
+		limit := s.config().Server.MaxLiveDurationLimit()
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limit, errLiveLimit)
+		defer cancel()
+		defer func() {
+			if context.Cause(ctx) == errLiveLimit {
+				jobLogf(ctx, "Stopped recording %s after %s (server.max_live_duration)", filepath.Base(file.Path), limit)
+			}
+		}()
+	}
+	setPhase(ctx, JobPhaseDownloading)
+	jobLogf(ctx, "Downloading %s to %s", describePlannedFile(file, s.redactedParams()), file.Path)
+	ctx = withMediaCheck(ctx, s.mediaCheckFor(file))
+	if file.start > 0 || file.end > 0 {
+		return s.downloadClip(ctx, file, progressFn)
+	}
+	if !file.Merge && !file.HLS {
+		if file.inferExt {
+			return downloadAudioFile(ctx, s.store(), file, progressFn)
+		}
+		return file.Path, downloadFile(ctx, s.store(), file.URL, file.Path, file.Headers, progressFn)
+	}
+	if !s.store().IsLocal() {
+		return s.downloadStaged(ctx, file, progressFn)
+	}
+	return s.assembleLocal(ctx, file, progressFn)
+}
+
+// describePlannedFile summarizes a file's source for the job log: its URL
+// (redacted) and what the transfer involves
+func describePlannedFile(file plannedFile, redacted []string) string {
+	var notes []string
+	if file.Quality != "" {
+		notes = append(notes, file.Quality)
+	}
+	if file.Ext != "" {
+		notes = append(notes, file.Ext)
+	}
+	switch {
+	case file.Merge:
+		notes = append(notes, "separate audio "+redactURL(file.AudioURL, redacted))
+	case file.HLS:
+		notes = append(notes, "HLS")
+	}
+	if file.start > 0 || file.end > 0 {
+		notes = append(notes, "clipped")
+	}
+	desc := redactURL(file.URL, redacted)
+	if len(notes) > 0 {
+		desc += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return desc
+}
+
+// assembleLocal merges a file's streams or fetches its HLS segments. Path
+// must be on the local disk, since ffmpeg and the HLS downloader need it.
+func (s *Server) assembleLocal(ctx context.Context, file plannedFile, progressFn func(downloaded, total int64)) (string, error) {
+	if file.Merge {
+		format := &extractor.VideoFormat{
+			URL:          file.URL,
+			AudioURL:     file.AudioURL,
+			Ext:          file.Ext,
+			Headers:      file.Headers,
+			AudioHeaders: file.AudioHeaders,
+		}
+		return file.Path, s.downloadVideoWithAudio(ctx, format, file.Path, progressFn)
+	}
+
+	hlsConfig := downloader.DefaultHLSConfig()
+	hlsConfig.Remux = s.config().HLSFormat != "ts"
+	hlsConfig.InsecureSkipVerify = insecureTLSFrom(ctx)
+	hlsConfig.ForceHTTP1 = forceHTTP1From(ctx)
+	hlsConfig.MinTLSVersion = minTLSVersionFrom(ctx)
+	hlsConfig.Throttle = throttle
+	hlsConfig.AcquireFFmpeg = func(ctx context.Context) (func(), error) {
+		setPhase(ctx, JobPhaseMerging)
+		return s.ffmpeg.acquire(ctx)
+	}
+	return downloader.DownloadHLSWithConfig(ctx, file.URL, file.Path, file.Headers, hlsConfig, progressFn)
+}
+
+// downloadStaged assembles a merge or HLS file in a temporary directory,
+// then uploads every resulting file (e.g., the remuxed mp4, or the video,
+// audio, and merged outputs) next to file.Path in remote storage
+func (s *Server) downloadStaged(ctx context.Context, file plannedFile, progressFn func(downloaded, total int64)) (string, error) {
+	dir, err := os.MkdirTemp("", "vget-staging-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	base := path.Base(file.Path)
+	staged := file
+	staged.Path = filepath.Join(dir, base)
+	stagedFinal, err := s.assembleLocal(ctx, staged, progressFn)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	storeDir := strings.TrimSuffix(file.Path, base)
+	finalPath := file.Path
+	for _, entry := range entries {
+		name := storeDir + entry.Name()
+		if err := storage.Upload(s.store(), filepath.Join(dir, entry.Name()), name); err != nil {
+			return "", fmt.Errorf("failed to store %s: %w", name, err)
+		}
+		if entry.Name() == filepath.Base(stagedFinal) {
+			finalPath = name
+		}
+	}
+	return finalPath, nil
+}
+
+// downloadClip fetches the whole video into a temporary directory, cuts
+// the planned time range out of it with ffmpeg, and stores only the clip.
+// The clip keeps the container of the assembled source (e.g., the remuxed
+// mp4 of an HLS stream), so the returned path may differ from file.Path.
+func (s *Server) downloadClip(ctx context.Context, file plannedFile, progressFn func(downloaded, total int64)) (string, error) {
+	dir, err := os.MkdirTemp("", "vget-clip-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	staged := file
+	staged.Path = filepath.Join(dir, "source"+path.Ext(file.Path))
+	source := staged.Path
+	if !file.Merge && !file.HLS {
+		err = downloadFile(ctx, localFiles, file.URL, source, file.Headers, progressFn)
+	} else {
+		source, err = s.assembleLocal(ctx, staged, progressFn)
+	}
+	if err != nil {
+		return "", err
+	}
+	if file.Merge {
+		// Cut the merged file; without one, only the video stream exists
+		merged := filepath.Join(dir, "(merged)"+filepath.Base(staged.Path))
+		if _, err := os.Stat(merged); err == nil {
+			source = merged
+		}
+	}
+
+	ext := filepath.Ext(source)
+	clip := filepath.Join(dir, "clip"+ext)
+	setPhase(ctx, JobPhasePostProcessing)
+	release, err := s.ffmpeg.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	err = downloader.TrimMedia(source, clip, file.start, file.end)
+	release()
+	if err != nil {
+		return "", err
+	}
+
+	finalPath := strings.TrimSuffix(file.Path, path.Ext(file.Path)) + ext
+	if err := storage.Upload(s.store(), clip, finalPath); err != nil {
+		return "", fmt.Errorf("failed to store %s: %w", finalPath, err)
+	}
+	return finalPath, nil
+}
+
+// outputPaths lists the new files written while downloading f, including
+// the separate audio stream for merges and the remuxed mp4 for HLS. Files
+// that already exist are left out, since the transfer may fail before
+// touching them.
+func (f plannedFile) outputPaths(st storage.Storage) []string {
+	candidates := []string{f.Path}
+	switch {
+	case f.Merge:
+		candidates = append(candidates, audioStreamPath(f.Ext, f.Path))
+	case f.HLS:
+		candidates = append(candidates, strings.TrimSuffix(f.Path, filepath.Ext(f.Path))+".mp4")
+	case f.inferExt:
+		for _, ext := range audioExts {
+			candidates = append(candidates, withAudioExt(f.Path, f.Ext, ext))
+		}
+	}
+
+	var paths []string
+	for _, path := range candidates {
+		if _, err := st.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// isHLSURL reports whether a media URL points at an m3u8 playlist
+func isHLSURL(rawURL string) bool {
+	lower := strings.ToLower(rawURL)
+	return strings.HasSuffix(lower, ".m3u8") || strings.Contains(lower, ".m3u8?")
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaylistLinkDuration is how long the signed file links of a playlist stay valid
+const PlaylistLinkDuration = 24 * time.Hour
+
+// handleJobsPlaylist serves the files of finished jobs as an extended M3U
+// playlist, oldest job first. ?tag= limits it to one batch group, ?user=
+// to jobs of one token "user" claim as in handleGetJobs, and ?format=m3u
+// selects the .m3u flavor instead of .m3u8. Files no longer in the output
+// storage (e.g., removed by destination.delete_local) are left out. When an
+// api_key is set, the file links are signed so a media player can fetch
+// them as is.
+func (s *Server) handleJobsPlaylist(c *gin.Context) {
+	tag := c.Query("tag")
+	user := c.Query("user")
+	format := c.DefaultQuery("format", "m3u8")
+	if format != "m3u8" && format != "m3u" {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: "invalid format: use m3u8 or m3u",
+		})
+		return
+	}
+
+	jobs := s.jobQueue.GetAllJobs()
+	jobs = slices.DeleteFunc(jobs, func(job *Job) bool {
+		finished := job.Status == JobStatusCompleted || job.Status == JobStatusPartial
+		return !finished || (tag != "" && job.Options.Group != tag) ||
+			(user != "" && claimedUser(job.Options.Claims) != user)
+	})
+	slices.SortFunc(jobs, func(a, b *Job) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	base := requestScheme(c) + "://" + c.Request.Host + s.apiPrefix() + "/download?"
+	expires := time.Now().Add(PlaylistLinkDuration)
+
+	st := s.store()
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for _, job := range jobs {
+		for _, p := range job.saved {
+			if _, err := st.Stat(p); err != nil {
+				continue
+			}
+			title := strings.TrimSuffix(path.Base(filepath.ToSlash(p)), path.Ext(p))
+			fmt.Fprintf(&b, "#EXTINF:-1,%s\n%s%s\n", title, base, s.signedFileQuery(p, expires))
+		}
+	}
+
+	name := "vget"
+	if tag != "" {
+		name += "-" + manifestKeyUnsafe.ReplaceAllString(tag, "_")
+	}
+	contentType := "application/vnd.apple.mpegurl"
+	if format == "m3u" {
+		contentType = "audio/x-mpegurl"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=\"%s.%s\"", name, format))
+	c.Data(http.StatusOK, contentType, []byte(b.String()))
+}
+
+// requestScheme returns the scheme clients used to reach the server,
+// trusting X-Forwarded-Proto from a reverse proxy
+func requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestJobsPlaylist(t *testing.T) {
+	const apiKey = "test-secret"
+	s := newTestServer(t, apiKey)
+	token, err := s.generateJWT("api", time.Hour, nil)
+	if err != nil {
+		t.Fatalf("generateJWT: %v", err)
+	}
+	auth := map[string]string{"Authorization": "Bearer " + token}
+
+	media := newMediaServer(t, "video-bytes")
+	var ids []string
+	for _, title := range []string{"first", "second"} {
+		pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+			ID:      title,
+			Title:   title,
+			Formats: []extractor.VideoFormat{{URL: media.URL + "/" + title + ".mp4", Ext: "mp4"}},
+		}})
+		w := doRequest(s, "POST", "/api/download", jsonBody{"url": pageURL}, auth)
+		id, _ := decodeData(t, w)["id"].(string)
+		waitForStatus(t, s.jobQueue, id, JobStatusCompleted)
+		ids = append(ids, id)
+	}
+	s.jobQueue.updateJob(ids[1], func(j *Job) { j.Options.Group = "binge" })
+
+	w := doRequest(s, "GET", "/api/jobs/playlist?tag=binge", nil, auth)
+	if w.Code != http.StatusOK {
+		t.Fatalf("playlist status = %d; want 200", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/vnd.apple.mpegurl" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "#EXTM3U" || lines[1] != "#EXTINF:-1,second" {
+		t.Fatalf("playlist =\n%s", w.Body.String())
+	}
+
+	// The signed link works without credentials, but not once tampered with
+	link := strings.TrimPrefix(lines[2], "http://example.com")
+	if w := doRequest(s, "GET", link, nil, nil); w.Code != http.StatusOK || w.Body.String() != "video-bytes" {
+		t.Errorf("signed link = %d %q; want 200 video-bytes", w.Code, w.Body.String())
+	}
+	tampered := strings.Replace(link, "second.mp4", "first.mp4", 1)
+	if w := doRequest(s, "GET", tampered, nil, nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("tampered link status = %d; want 401", w.Code)
+	}
+
+	// Without a tag, every finished job is listed, oldest first
+	w = doRequest(s, "GET", "/api/jobs/playlist?format=m3u", nil, auth)
+	body := w.Body.String()
+	if !strings.Contains(body, "#EXTINF:-1,first\n") || strings.Index(body, "first") > strings.Index(body, "second") {
+		t.Errorf("untagged playlist =\n%s", body)
+	}
+
+	// ?user= keeps one user's jobs, and removed files are left out
+	s.jobQueue.updateJob(ids[0], func(j *Job) { j.Options.Claims = map[string]any{"user": "alice"} })
+	w = doRequest(s, "GET", "/api/jobs/playlist?user=alice", nil, auth)
+	if body := w.Body.String(); !strings.Contains(body, "first") || strings.Contains(body, "second") {
+		t.Errorf("alice's playlist =\n%s", body)
+	}
+	if err := os.Remove(filepath.Join(s.outputDir, "second.mp4")); err != nil {
+		t.Fatal(err)
+	}
+	w = doRequest(s, "GET", "/api/jobs/playlist", nil, auth)
+	if body := w.Body.String(); !strings.Contains(body, "first") || strings.Contains(body, "second") {
+		t.Errorf("playlist after removing second.mp4 =\n%s", body)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// jobPrefetch runs fn for the job that will start next while every worker
+// is busy, one job at a time, so its extraction (often a slow browser
+// session) is done by the time a worker frees up
+type jobPrefetch struct {
+	fn func(ctx context.Context, job *Job) // Optional; nil disables prefetching
+
+	mu      sync.Mutex
+	running bool   // A prefetch is in progress
+	last    string // ID of the job prefetched last
+}
+
+// prefetchNext starts prefetching the job next in line, unless it was
+// already prefetched or another prefetch is still running. It's called
+// whenever a job is queued or starts and when a prefetch ends, as any of
+// these can change which job is next. The prefetch runs under the job's
+// context, so cancelling the job cancels it.
+func (jq *JobQueue) prefetchNext() {
+	p := &jq.prefetch
+	if p.fn == nil {
+		return
+	}
+	job := jq.queue.peek()
+	if job == nil {
+		return
+	}
+
+	p.mu.Lock()
+	if p.running || p.last == job.ID {
+		p.mu.Unlock()
+		return
+	}
+	p.running, p.last = true, job.ID
+	p.mu.Unlock()
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("Prefetch for job %s panicked: %v", job.ID, r)
+			}
+			p.mu.Lock()
+			p.running = false
+			p.mu.Unlock()
+			jq.prefetchNext()
+		}()
+		p.fn(withJobLog(job.ctx, job.log), job)
+	}()
+}
+
+// prefetchExtraction extracts a queued job's media into the extraction
+// cache with server.prefetch_extraction on, resolving the URL and picking
+// the extractor as the job will. Failures are only logged: the job
+// extracts again when it starts.
+func (s *Server) prefetchExtraction(ctx context.Context, job *Job) {
+	cfg := s.config()
+	if !cfg.Server.PrefetchExtraction || ctx.Err() != nil || s.checkDomain(job.URL) != nil {
+		return
+	}
+	url, err := s.resolveURL(ctx, job.URL, job.Options)
+	if err != nil || ctx.Err() != nil {
+		return
+	}
+	ext := s.findExtractor(ctx, url, job.Options)
+	if paged, ok := ext.(extractor.PagedExtractor); ok && paged.Paginated(url) {
+		return // Pages are extracted one at a time as they download
+	}
+
+	err = s.extracts.prefetch(downloader.WithRetryPolicy(ctx, s.retryPolicy()), ext, url, cfg.Server.ExtractCacheTTLDuration(), cfg.Server.ExtractFreshnessDuration())
+	switch {
+	case ctx.Err() != nil:
+		// The job was cancelled meanwhile
+	case err != nil:
+		logf(ctx, "Prefetching extraction for job %s failed: %v", job.ID, err)
+	default:
+		jobLogf(ctx, "Extracted with %s while queued", ext.Name())
+	}
+}
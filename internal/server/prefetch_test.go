@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+func TestPrefetchNext(t *testing.T) {
+	release := make(chan struct{})
+	jq := NewJobQueue(1, t.TempDir(), func(ctx context.Context, jobID, url, filename string, opts DownloadOptions, progressFn func(downloaded, total int64)) error {
+		<-release
+		return nil
+	}, "")
+	prefetched := make(chan *Job, 10)
+	jq.prefetch.fn = func(ctx context.Context, job *Job) { prefetched <- job }
+	jq.Start()
+	t.Cleanup(jq.Stop)
+	t.Cleanup(func() { close(release) })
+	waitForIdleWorker(t, jq.queue)
+
+	// The only worker takes the first job, so nothing is next in line
+	first, _ := jq.AddJob("https://example.com/1", "", DownloadOptions{})
+	waitForStatus(t, jq, first.ID, JobStatusDownloading)
+	second, _ := jq.AddJob("https://example.com/2", "", DownloadOptions{})
+	jq.AddJob("https://example.com/3", "", DownloadOptions{})
+
+	select {
+	case job := <-prefetched:
+		if job.ID != second.ID {
+			t.Errorf("prefetched %s; want the next job %s", job.URL, second.URL)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("next job was not prefetched")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(prefetched) != 0 {
+		t.Errorf("prefetched %d more jobs; want only one job ahead", len(prefetched))
+	}
+}
+
+// waitForIdleWorker waits until a worker is waiting for jobs in sc
+func waitForIdleWorker(t *testing.T, sc *jobScheduler) {
+	t.Helper()
+	for range 100 {
+		sc.mu.Lock()
+		idle := sc.idle
+		sc.mu.Unlock()
+		if idle > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("no worker became idle")
+}
+
+func TestExtractCachePrefetch(t *testing.T) {
+	c := newExtractCache()
+	m := &MockExtractor{Media: &extractor.VideoMedia{ID: "abc"}}
+
+	// With the cache off a prefetch serves exactly one extraction
+	if err := c.prefetch(context.Background(), m, "https://example.com/a", 0, 0); err != nil {
+		t.Fatalf("prefetch: %v", err)
+	}
+	c.extract(context.Background(), m, "https://example.com/a", 0, 0)
+	if n := m.Calls(); n != 1 {
+		t.Errorf("Extract called %d times; want 1 with the prefetch used", n)
+	}
+	c.extract(context.Background(), m, "https://example.com/a", 0, 0)
+	if n := m.Calls(); n != 2 {
+		t.Errorf("Extract called %d times; want 2 after the prefetch was used up", n)
+	}
+
+	// A prefetch for a cancelled job keeps nothing
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.prefetch(ctx, m, "https://example.com/b", 0, 0)
+	c.extract(context.Background(), m, "https://example.com/b", 0, 0)
+	if n := m.Calls(); n != 4 {
+		t.Errorf("Extract called %d times; want 4 with the cancelled prefetch dropped", n)
+	}
+
+	// Nothing to prefetch when a fresh cached entry exists
+	c.extract(context.Background(), m, "https://example.com/c", time.Minute, 0)
+	c.prefetch(context.Background(), m, "https://example.com/c", time.Minute, 0)
+	if n := m.Calls(); n != 5 {
+		t.Errorf("Extract called %d times; want 5 with the cached entry reused", n)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// progressInterval is how often a progressAggregator forwards updates
+const progressInterval = 200 * time.Millisecond
+
+// progressAggregator combines the progress of concurrent transfers (e.g.,
+// the video and audio streams of a merged download) into one downloaded/total
+// pair. The combined total is only known once every source has reported its
+// own, and downloaded never goes backwards, even when a source restarts.
+// Updates are forwarded to progressFn at most once per interval.
+type progressAggregator struct {
+	mu         sync.Mutex
+	progressFn func(downloaded, total int64)
+	interval   time.Duration
+	sources    []*sourceProgress
+	reported   int64 // Highest downloaded value forwarded so far
+	lastReport time.Time
+}
+
+// sourceProgress is the last update of one source, guarded by the aggregator
+type sourceProgress struct {
+	downloaded int64
+	total      int64 // <= 0 while unknown
+}
+
+// newProgressAggregator returns an aggregator forwarding to progressFn,
+// which may be nil
+func newProgressAggregator(progressFn func(downloaded, total int64), interval time.Duration) *progressAggregator {
+	return &progressAggregator{progressFn: progressFn, interval: interval}
+}
+
+// source registers a transfer and returns the progress callback to pass to
+// it. Register every source before any of them starts, so the combined total
+// isn't computed from a partial set.
+func (a *progressAggregator) source() func(downloaded, total int64) {
+	src := &sourceProgress{}
+	a.mu.Lock()
+	a.sources = append(a.sources, src)
+	a.mu.Unlock()
+
+	return func(downloaded, total int64) {
+		a.mu.Lock()
+		src.downloaded, src.total = downloaded, total
+		a.reportLocked(false)
+		a.mu.Unlock()
+	}
+}
+
+// flush forwards the current combined progress regardless of the interval.
+// Call it once the transfers are done so the last update isn't dropped.
+func (a *progressAggregator) flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.reportLocked(true)
+}
+
+// reportLocked forwards the combined progress if force is set, the interval
+// has passed, or the transfers just completed. Call with a.mu held; progressFn
+// runs under it so updates arrive in order.
+func (a *progressAggregator) reportLocked(force bool) {
+	if a.progressFn == nil {
+		return
+	}
+
+	var downloaded, total int64
+	known := true
+	for _, src := range a.sources {
+		downloaded += src.downloaded
+		if src.total <= 0 {
+			known = false
+		}
+		total += src.total
+	}
+	if downloaded < a.reported {
+		downloaded = a.reported
+	}
+	if !known {
+		total = -1
+	} else if total < downloaded {
+		total = downloaded
+	}
+
+	done := known && downloaded == total
+	if !force && !done && time.Since(a.lastReport) < a.interval {
+		return
+	}
+	a.reported = downloaded
+	a.lastReport = time.Now()
+	a.progressFn(downloaded, total)
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProgressAggregator(t *testing.T) {
+	var got [][2]int64
+	agg := newProgressAggregator(func(downloaded, total int64) {
+		got = append(got, [2]int64{downloaded, total})
+	}, 0)
+	video := agg.source()
+	audio := agg.source()
+
+	video(10, 100)  // Audio total still unknown
+	audio(5, 20)    // Both known
+	video(0, 100)   // Video restarted: downloaded must not go backwards
+	video(100, 100) // Video done
+	audio(20, 20)   // All done
+
+	expected := [][2]int64{{10, -1}, {15, 120}, {15, 120}, {105, 120}, {120, 120}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("updates = %v; want %v", got, expected)
+	}
+}
+
+func TestProgressAggregatorThrottle(t *testing.T) {
+	var got [][2]int64
+	agg := newProgressAggregator(func(downloaded, total int64) {
+		got = append(got, [2]int64{downloaded, total})
+	}, time.Hour)
+	src := agg.source()
+
+	src(1, 10) // First update goes through
+	src(2, 10) // Throttled
+	src(3, 10) // Throttled
+	agg.flush()
+	src(10, 10) // Completion is never throttled
+
+	expected := [][2]int64{{1, 10}, {3, 10}, {10, 10}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("updates = %v; want %v", got, expected)
+	}
+}
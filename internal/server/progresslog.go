@@ -0,0 +1,192 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/config"
+)
+
+// DefaultProgressLogMaxSize is the size at which the progress log is rotated
+// when server.progress_log_max_size is unset
+const DefaultProgressLogMaxSize = 10 << 20
+
+// progressMilestone is the percentage step at which "progress" events are logged
+const progressMilestone = 25
+
+// jobEvent is one line of the progress log, describing a job state transition
+type jobEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"` // queued, started, progress, completed, partial, failed, cancelled
+	JobID      string    `json:"job_id"`
+	Group      string    `json:"group,omitempty"`
+	URL        string    `json:"url"`
+	Filename   string    `json:"filename,omitempty"`
+	Progress   float64   `json:"progress"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total"`
+	Error      string    `json:"error,omitempty"`
+
+	Claims map[string]any `json:"claims,omitempty"` // Custom claims of the submitting token
+}
+
+// newJobEvent snapshots job for the progress log. Call with the queue lock held.
+func newJobEvent(event string, job *Job) jobEvent {
+	return jobEvent{
+		Time:       time.Now(),
+		Event:      event,
+		JobID:      job.ID,
+		Group:      job.Options.Group,
+		URL:        job.URL,
+		Filename:   job.Filename,
+		Progress:   job.Progress,
+		Downloaded: job.Downloaded,
+		Total:      job.Total,
+		Error:      job.Error,
+		Claims:     job.Options.Claims,
+	}
+}
+
+// checkProgressLogPath restricts a progress log set through the API to the
+// output or config directory, so a caller can't have the server append to
+// arbitrary files
+func (s *Server) checkProgressLogPath(p string) error {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return fmt.Errorf("invalid value for progress_log: %s", p)
+	}
+	dirs := []string{s.output()}
+	if dir, err := config.ConfigDir(); err == nil {
+		dirs = append(dirs, dir)
+	}
+	for _, dir := range dirs {
+		if absDir, err := filepath.Abs(dir); err == nil && withinDir(absDir, abs) {
+			return nil
+		}
+	}
+	return fmt.Errorf("progress_log must be inside the output or config directory: %s", p)
+}
+
+// isProgressLog reports whether abs is the configured progress log or its
+// rotation, which lists every job's URL and claims and must not be served
+// from the output directory
+func (s *Server) isProgressLog(abs string) bool {
+	logPath := s.config().Server.ProgressLog
+	if logPath == "" {
+		return false
+	}
+	logPath, err := filepath.Abs(logPath)
+	return err == nil && (abs == logPath || abs == logPath+".1")
+}
+
+// progressLog appends job events as JSON lines to a file. Once the file
+// would grow past maxSize it is renamed to <path>.1 (replacing any older
+// rotation) and a fresh file is started.
+type progressLog struct {
+	mu      sync.Mutex
+	path    string // Empty disables logging
+	maxSize int64
+	file    *os.File
+	size    int64
+}
+
+func newProgressLog() *progressLog {
+	return &progressLog{}
+}
+
+// Configure sets the log file and size cap, closing the previous file if
+// the path changed. An empty path turns logging off.
+func (p *progressLog) Configure(path string, maxSize int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if maxSize <= 0 {
+		maxSize = DefaultProgressLogMaxSize
+	}
+	p.maxSize = maxSize
+	if path != p.path {
+		p.closeLocked()
+		p.path = path
+	}
+}
+
+// Close closes the underlying file; the next event reopens it
+func (p *progressLog) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeLocked()
+}
+
+// record appends an event, logging (not returning) write failures so a
+// broken log file never affects downloads
+func (p *progressLog) record(event jobEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.path == "" {
+		return
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	if err := p.openLocked(); err != nil {
+		log.Printf("Warning: failed to open progress log: %v", err)
+		return
+	}
+	if p.size > 0 && p.size+int64(len(line)) > p.maxSize {
+		if err := p.rotateLocked(); err != nil {
+			log.Printf("Warning: failed to rotate progress log: %v", err)
+			return
+		}
+	}
+
+	n, err := p.file.Write(line)
+	p.size += int64(n)
+	if err != nil {
+		log.Printf("Warning: failed to write progress log: %v", err)
+	}
+}
+
+func (p *progressLog) openLocked() error {
+	if p.file != nil {
+		return nil
+	}
+
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	p.file = file
+	p.size = info.Size()
+	return nil
+}
+
+func (p *progressLog) rotateLocked() error {
+	p.closeLocked()
+	if err := os.Rename(p.path, p.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return p.openLocked()
+}
+
+func (p *progressLog) closeLocked() {
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+		p.size = 0
+	}
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+// readEvents parses a JSON-lines progress log
+func readEvents(t *testing.T, path string) []jobEvent {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer file.Close()
+
+	var events []jobEvent
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event jobEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestProgressLogJobEvents(t *testing.T) {
+	s := newTestServer(t, "")
+	if err := s.setConfigValue(s.cfg, "progress_log", filepath.Join(t.TempDir(), "progress.jsonl")); err == nil {
+		t.Errorf("progress_log outside the output and config directories accepted")
+	}
+	logPath := filepath.Join(s.outputDir, "progress.jsonl")
+	if err := s.setConfigValue(s.cfg, "progress_log", logPath); err != nil {
+		t.Fatalf("setConfigValue: %v", err)
+	}
+	s.progress.Configure(s.cfg.Server.ProgressLog, s.cfg.Server.ProgressLogMaxBytes())
+
+	media := newMediaServer(t, "video-bytes")
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "abc",
+		Title:   "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	job, err := s.jobQueue.AddJob(pageURL, "", DownloadOptions{})
+	if err != nil {
+		t.Fatalf("AddJob: %v", err)
+	}
+	waitForStatus(t, s.jobQueue, job.ID, JobStatusCompleted)
+
+	// The event is written just after the status changes
+	var events []jobEvent
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		events = readEvents(t, logPath)
+		if events[len(events)-1].Event == "completed" {
+			break
+		}
+	}
+	var names []string
+	for _, event := range events {
+		if event.JobID != job.ID {
+			t.Errorf("event job_id = %s; want %s", event.JobID, job.ID)
+		}
+		names = append(names, event.Event)
+	}
+	// Progress milestones depend on how the body is chunked, so ignore them
+	got := strings.ReplaceAll(strings.Join(names, ","), "progress,", "")
+	if got != "queued,started,completed" {
+		t.Errorf("events = %s; want queued,started,completed", got)
+	}
+
+	if end := events[len(events)-1]; end.Downloaded != int64(len("video-bytes")) || !strings.HasSuffix(end.Filename, "clip.mp4") {
+		t.Errorf("completed event = %+v; want downloaded=11 filename=clip.mp4", end)
+	}
+
+	if w := doRequest(s, "GET", "/api/download?path="+url.QueryEscape(logPath), nil, nil); w.Code != http.StatusForbidden {
+		t.Errorf("GET progress log = %d; want 403", w.Code)
+	}
+}
+
+func TestProgressLogRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress.jsonl")
+	p := newProgressLog()
+	p.Configure(path, 300)
+	defer p.Close()
+
+	for i := 0; i < 5; i++ {
+		p.record(jobEvent{Time: time.Now(), Event: "queued", JobID: "job", URL: "https://example.com/video"})
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat log: %v", err)
+	}
+	if info.Size() > 300 {
+		t.Errorf("log size = %d; want <= 300", info.Size())
+	}
+	rotated := readEvents(t, path+".1")
+	current := readEvents(t, path)
+	if len(rotated) == 0 || len(rotated)+len(current) > 5 {
+		t.Errorf("rotated/current = %d/%d; want a rotation with no more than 5 events total", len(rotated), len(current))
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces masked query parameter values in logs
+const redactedValue = "REDACTED"
+
+// defaultRedactedParams are query parameters always masked in logged URLs,
+// in addition to server.log_redact_params
+var defaultRedactedParams = []string{
+	"token", "access_token", "refresh_token", "id_token",
+	"key", "api_key", "apikey",
+	"signature", "sig", "password", "secret", "auth",
+	"path",
+}
+
+// redactedParams returns the built-in and configured parameter names to mask
+func (s *Server) redactedParams() []string {
+	return append(append([]string{}, defaultRedactedParams...), s.config().Server.LogRedactParams...)
+}
+
+// redactRequestURI formats a request path and query for logging, masking
+// sensitive query parameter values
+func redactRequestURI(u *url.URL, params []string) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + redactQuery(u.RawQuery, params)
+}
+
+// redactURL formats an absolute URL for logging, masking sensitive query
+// parameter values and any userinfo password. Unparseable input is dropped
+// entirely rather than risk logging it.
+func redactURL(raw string, params []string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+	if _, hasPassword := u.User.Password(); hasPassword {
+		u.User = url.UserPassword(u.User.Username(), redactedValue)
+	}
+	u.RawQuery = redactQuery(u.RawQuery, params)
+	return u.String()
+}
+
+// redactQuery masks the values of matching parameters in a raw query,
+// keeping parameter order and the encoding of everything else
+func redactQuery(rawQuery string, params []string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	parts := strings.Split(rawQuery, "&")
+	for i, part := range parts {
+		rawKey, _, hasValue := strings.Cut(part, "=")
+		if !hasValue {
+			continue
+		}
+		key, err := url.QueryUnescape(rawKey)
+		if err != nil {
+			key = rawKey
+		}
+		if isRedactedParam(key, params) {
+			parts[i] = rawKey + "=" + redactedValue
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// isRedactedParam reports whether key matches one of params (case-insensitive)
+func isRedactedParam(key string, params []string) bool {
+	for _, param := range params {
+		if strings.EqualFold(key, param) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ResumeDiscard records a partial file that was downloaded again from the
+// start because it could not be resumed safely
+type ResumeDiscard struct {
+	Path   string `json:"path"`
+	Offset int64  `json:"offset"` // Bytes of the partial file thrown away
+	Reason string `json:"reason"`
+}
+
+type resumeDiscardKey struct{}
+
+// withResumeDiscards returns ctx recording discarded partial files on the
+// job jobID
+func (s *Server) withResumeDiscards(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, resumeDiscardKey{}, func(discard ResumeDiscard) {
+		s.jobQueue.updateJob(jobID, func(j *Job) { j.ResumeDiscards = append(j.ResumeDiscards, discard) })
+	})
+}
+
+// discardResume logs that the partial file of name, offset bytes long, is
+// started over for reason, and records it on the job running under ctx
+func discardResume(ctx context.Context, name string, offset int64, reason string) {
+	logf(ctx, "Not resuming %s at %d bytes: %s; starting over", filepath.Base(name), offset, reason)
+	if record, ok := ctx.Value(resumeDiscardKey{}).(func(ResumeDiscard)); ok {
+		record(ResumeDiscard{Path: name, Offset: offset, Reason: reason})
+	}
+}
+
+// responseValidator returns what identifies the version of the file resp
+// serves, for If-Range: its strong ETag, or else its Last-Modified. Weak
+// ETags can't be used with If-Range, so "" means a partial file of resp
+// can't be resumed safely.
+func responseValidator(resp *http.Response) string {
+	if etag := strings.TrimSpace(resp.Header.Get("ETag")); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return strings.TrimSpace(resp.Header.Get("Last-Modified"))
+}
+
+// What fetchFile does with the answer to a ranged request that resumes a
+// partial file
+const (
+	resumeAppend   = iota // Append the 206 body to the partial file
+	resumeComplete        // The partial file already holds the whole file
+	resumeRestart         // Start over with a full download
+)
+
+// resumeAction checks the 206 or 416 answer to a request for the bytes
+// from offset on. A 206 is appended only if it starts at offset and isn't
+// compressed. For appending, resp.ContentLength becomes the size of the
+// whole file (-1 if unknown), so size checks and progress see the file
+// rather than the rest of it. A 416 means the partial file is complete if
+// it is exactly as large as the file; if it's larger, or the range is
+// rejected for another reason, the download starts over.
+func resumeAction(resp *http.Response, offset int64) int {
+	start, total, ok := parseContentRange(resp.Header.Get("Content-Range"))
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		if ok && total == offset {
+			return resumeComplete
+		}
+		return resumeRestart
+	}
+
+	coding := strings.TrimSpace(resp.Header.Get("Content-Encoding"))
+	if !ok || start != offset || (total >= 0 && offset >= total) || (coding != "" && !strings.EqualFold(coding, "identity")) {
+		return resumeRestart
+	}
+	switch {
+	case total >= 0:
+		resp.ContentLength = total
+	case resp.ContentLength >= 0:
+		resp.ContentLength += offset
+	}
+	return resumeAppend
+}
+
+// parseContentRange parses the Content-Range of a 206 ("bytes
+// start-end/total") or 416 ("bytes */total") response. start is -1 for
+// "*" and total is -1 when the server doesn't know it.
+func parseContentRange(header string) (start, total int64, ok bool) {
+	spec, found := strings.CutPrefix(strings.TrimSpace(header), "bytes ")
+	if !found {
+		return 0, 0, false
+	}
+	rng, size, found := strings.Cut(spec, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	total = -1
+	if size != "*" {
+		n, err := strconv.ParseInt(size, 10, 64)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		total = n
+	}
+	if rng == "*" {
+		return -1, total, true
+	}
+	first, _, found := strings.Cut(rng, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return 0, 0, false
+	}
+	return start, total, true
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guiyumin/vget/internal/core/extractor"
+	"github.com/guiyumin/vget/internal/core/storage"
+)
+
+func TestDownloadFileResume(t *testing.T) {
+	payload := []byte(strings.Repeat("0123456789", 100))
+	var mu sync.Mutex
+	var ranges []string
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		ranges = append(ranges, r.Header.Get("Range"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "video/mp4")
+		if r.URL.Path == "/norange" {
+			w.Write(payload) // Ignores the Range header
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		http.ServeContent(w, r, "clip.mp4", time.Time{}, bytes.NewReader(payload))
+	}))
+	t.Cleanup(media.Close)
+
+	tests := []struct {
+		name      string
+		path      string
+		partial   []byte
+		validator string // Saved with the partial file
+		wantRange string
+	}{
+		{"resumed", "/clip.mp4", payload[:300], `"v1"`, "bytes=300-"},
+		{"range ignored", "/norange", []byte("stale bytes"), `"v1"`, "bytes=11-"},
+		{"partial larger than file", "/clip.mp4", append(bytes.Clone(payload), "extra"...), `"v1"`, "bytes=1005-"},
+		{"partial already complete", "/clip.mp4", payload, `"v1"`, "bytes=1000-"},
+		{"remote file changed", "/clip.mp4", []byte("older version"), `"v0"`, "bytes=13-"},
+		{"no validator", "/clip.mp4", payload[:300], "", ""},
+		{"no partial", "/clip.mp4", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges = nil
+			out := filepath.Join(t.TempDir(), "clip.mp4")
+			if tt.partial != nil {
+				os.WriteFile(out+storage.PartSuffix, tt.partial, 0o644)
+			}
+			if tt.validator != "" {
+				os.WriteFile(out+storage.ValidatorSuffix, []byte(tt.validator), 0o644)
+			}
+
+			var last, lastTotal int64
+			err := downloadFile(context.Background(), localFiles, media.URL+tt.path, out, nil, func(downloaded, total int64) {
+				last, lastTotal = downloaded, total
+			})
+			if err != nil {
+				t.Fatalf("downloadFile: %v", err)
+			}
+			if got, _ := os.ReadFile(out); !bytes.Equal(got, payload) {
+				t.Errorf("saved %d bytes %q...; want the %d byte file", len(got), got[:min(len(got), 20)], len(payload))
+			}
+			for _, suffix := range []string{storage.PartSuffix, storage.ValidatorSuffix} {
+				if _, err := os.Stat(out + suffix); err == nil {
+					t.Errorf("%s file left behind", suffix)
+				}
+			}
+			if len(ranges) == 0 || ranges[0] != tt.wantRange {
+				t.Errorf("first request Range = %q; want %q", ranges, tt.wantRange)
+			}
+			if last > 0 && (last != int64(len(payload)) || lastTotal != int64(len(payload))) {
+				t.Errorf("last progress = %d of %d; want %d of %d", last, lastTotal, len(payload), len(payload))
+			}
+		})
+	}
+}
+
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		header       string
+		start, total int64
+		ok           bool
+	}{
+		{"bytes 300-999/1000", 300, 1000, true},
+		{"bytes 300-999/*", 300, -1, true},
+		{"bytes */1000", -1, 1000, true},
+		{"bytes 300-999", 0, 0, false},
+		{"items 0-1/2", 0, 0, false},
+		{"", 0, 0, false},
+	}
+	for _, tt := range tests {
+		start, total, ok := parseContentRange(tt.header)
+		if start != tt.start || total != tt.total || ok != tt.ok {
+			t.Errorf("parseContentRange(%q) = %d, %d, %v; want %d, %d, %v", tt.header, start, total, ok, tt.start, tt.total, tt.ok)
+		}
+	}
+}
+
+func TestResumeAfterRemoteChange(t *testing.T) {
+	s := newTestServer(t, "")
+	if err := s.setConfigValue(s.cfg, "server.cleanup_partial_on_failure", "false"); err != nil {
+		t.Fatalf("setConfigValue: %v", err)
+	}
+	s.cfg.Retry.MaxAttempts = 1 // Let the cut transfer fail the job
+
+	var mu sync.Mutex
+	version, payload, cut := `"v1"`, []byte(strings.Repeat("a", 1000)), true
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		version, payload, cut := version, payload, cut
+		mu.Unlock()
+		w.Header().Set("ETag", version)
+		if cut {
+			// Drop the connection halfway, leaving a partial file
+			w.Header().Set("Content-Length", "1000")
+			w.Write(payload[:400])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "clip.mp4", time.Time{}, bytes.NewReader(payload))
+	}))
+	t.Cleanup(media.Close)
+	pageURL := registerMock(t, &MockExtractor{Media: &extractor.VideoMedia{
+		ID:      "clip",
+		Formats: []extractor.VideoFormat{{URL: media.URL + "/clip.mp4", Ext: "mp4"}},
+	}})
+
+	job, _ := s.jobQueue.AddJob(pageURL, "clip.mp4", DownloadOptions{})
+	waitForStatus(t, s.jobQueue, job.ID, JobStatusFailed)
+	out := filepath.Join(s.outputDir, "clip.mp4")
+	if s.store().(storage.Resumer).PartialValidator(out) != `"v1"` {
+		t.Fatalf("validator of the partial file not saved")
+	}
+
+	// The remote file is replaced before the retry
+	mu.Lock()
+	version, payload, cut = `"v2"`, []byte(strings.Repeat("b", 1000)), false
+	mu.Unlock()
+
+	job, _ = s.jobQueue.AddJob(pageURL, "clip.mp4", DownloadOptions{})
+	got := waitForStatus(t, s.jobQueue, job.ID, JobStatusCompleted, JobStatusFailed)
+	if got.Status != JobStatusCompleted {
+		t.Fatalf("retry = %s (%s); want completed", got.Status, got.Error)
+	}
+	if data, _ := os.ReadFile(out); !bytes.Equal(data, []byte(strings.Repeat("b", 1000))) {
+		t.Errorf("saved file mixes versions: %q...", data[:min(len(data), 420)])
+	}
+	if len(got.ResumeDiscards) != 1 || got.ResumeDiscards[0].Offset != 400 || got.ResumeDiscards[0].Reason != "the remote file changed" {
+		t.Errorf("resume_discards = %+v; want the 400 byte partial file discarded as changed", got.ResumeDiscards)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"bytes"
+	_ "embed"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/guiyumin/vget/internal/core/version"
+)
+
+//go:embed static/index.html
+var rootPageHTML string
+
+var rootPageTemplate = template.Must(template.New("root").Parse(rootPageHTML))
+
+// Values for server.root_page
+const (
+	RootPageJSON     = "json"     // Small JSON pointer to the API (default)
+	RootPageStatus   = "page"     // Embedded minimal HTML status page
+	RootPageRedirect = "redirect" // Redirect to the health endpoint
+	RootPageOff      = "off"      // No root handler (404)
+)
+
+// handleRoot greets clients hitting the server root so they have a pointer
+// to the API instead of a bare 404. It never requires authentication.
+func (s *Server) handleRoot(c *gin.Context) {
+	info := struct {
+		Version string `json:"version"`
+		APIBase string `json:"api_base"`
+		Health  string `json:"health"`
+	}{
+		Version: version.Version,
+		APIBase: s.apiPrefix(),
+		Health:  s.apiPrefix() + "/health",
+	}
+
+	switch s.config().Server.RootPage {
+	case RootPageOff:
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Data:    nil,
+			Message: "not found",
+		})
+	case RootPageRedirect:
+		c.Redirect(http.StatusFound, info.Health)
+	case RootPageStatus:
+		var buf bytes.Buffer
+		if err := rootPageTemplate.Execute(&buf, info); err != nil {
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Data:    nil,
+				Message: "failed to render status page",
+			})
+			return
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+	default:
+		c.JSON(http.StatusOK, Response{
+			Code:    200,
+			Data:    info,
+			Message: "vget server is running; see api_base for the API",
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package server
+
+import "sync"
+
+// Values for server.scheduler
+const (
+	SchedulerFIFO = "fifo" // Jobs start in submission order (default)
+	SchedulerFair = "fair" // Jobs from different groups take turns
+)
+
+// jobScheduler holds queued jobs until a worker is free and picks which one
+// starts next. With the fair policy, groups (bulk batches, with all
+// ungrouped jobs sharing one) are served round-robin, so a large batch
+// can't hold every worker while later jobs wait behind it.
+type jobScheduler struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []*Job            // Queued jobs in submission order
+	capacity int               // Maximum number of queued jobs
+	served   map[string]uint64 // Fair policy: when each group last started a job
+	turn     uint64
+	closed   bool
+	paused   bool          // Jobs stay queued until resumed
+	idle     int           // Workers waiting in next
+	policy   func() string // Optional; returns the policy, FIFO when nil or unknown
+}
+
+func newJobScheduler(capacity int) *jobScheduler {
+	sc := &jobScheduler{
+		capacity: capacity,
+		served:   make(map[string]uint64),
+	}
+	sc.cond = sync.NewCond(&sc.mu)
+	return sc
+}
+
+// push queues a job, reporting false if the queue is full or closed
+func (sc *jobScheduler) push(job *Job) bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.closed || len(sc.pending) >= sc.capacity {
+		return false
+	}
+	sc.pending = append(sc.pending, job)
+	sc.cond.Signal()
+	return true
+}
+
+// next blocks until a job is queued and the scheduler isn't paused, and
+// returns it. After close it keeps returning the remaining jobs, then
+// reports false; a paused scheduler reports false right away.
+func (sc *jobScheduler) next() (*Job, bool) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for len(sc.pending) == 0 || sc.paused {
+		if sc.closed {
+			return nil, false
+		}
+		sc.idle++
+		sc.cond.Wait()
+		sc.idle--
+	}
+
+	i := 0
+	fair := sc.fair()
+	if fair {
+		i = sc.fairPick()
+	}
+	job := sc.pending[i]
+	sc.pending = append(sc.pending[:i], sc.pending[i+1:]...)
+
+	if fair {
+		sc.turn++
+		sc.served[job.Options.Group] = sc.turn
+	} else {
+		// Turns only matter to the fair policy; switching to it starts afresh
+		clear(sc.served)
+	}
+	return job, true
+}
+
+// peek returns the job next would hand out without removing it, or nil if
+// none is queued, the scheduler is paused, or an idle worker is about to
+// take it anyway
+func (sc *jobScheduler) peek() *Job {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if len(sc.pending) == 0 || sc.paused || sc.idle > 0 {
+		return nil
+	}
+	if sc.fair() {
+		return sc.pending[sc.fairIndex()]
+	}
+	return sc.pending[0]
+}
+
+// fair reports whether the fair policy is in effect
+func (sc *jobScheduler) fair() bool {
+	return sc.policy != nil && sc.policy() == SchedulerFair
+}
+
+// fairPick returns the index of the oldest job in the group that has gone
+// longest without starting one. Groups that never started a job go first.
+func (sc *jobScheduler) fairPick() int {
+	best := sc.fairIndex()
+
+	// Forget groups with nothing queued so the map doesn't grow forever;
+	// one that comes back simply counts as new
+	queued := make(map[string]bool, len(sc.served))
+	for _, job := range sc.pending {
+		queued[job.Options.Group] = true
+	}
+	for group := range sc.served {
+		if !queued[group] {
+			delete(sc.served, group)
+		}
+	}
+	return best
+}
+
+// fairIndex is fairPick without forgetting idle groups
+func (sc *jobScheduler) fairIndex() int {
+	best := 0
+	bestTurn := sc.served[sc.pending[0].Options.Group]
+	for i, job := range sc.pending[1:] {
+		if turn := sc.served[job.Options.Group]; turn < bestTurn {
+			best, bestTurn = i+1, turn
+		}
+	}
+	return best
+}
+
+// setPaused holds queued jobs back (true) or lets workers take them again
+func (sc *jobScheduler) setPaused(paused bool) {
+	sc.mu.Lock()
+	sc.paused = paused
+	sc.mu.Unlock()
+	sc.cond.Broadcast()
+}
+
+// isPaused reports whether queued jobs are held back
+func (sc *jobScheduler) isPaused() bool {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.paused
+}
+
+// close stops accepting jobs and wakes idle workers so they can exit
+func (sc *jobScheduler) close() {
+	sc.mu.Lock()
+	sc.closed = true
+	sc.mu.Unlock()
+	sc.cond.Broadcast()
+}
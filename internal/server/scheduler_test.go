@@ -0,0 +1,96 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestJobScheduler(t *testing.T) {
+	// A 3-job batch, then an interactive download and a second batch
+	submitted := []*Job{
+		{ID: "b1", Options: DownloadOptions{Group: "big"}},
+		{ID: "b2", Options: DownloadOptions{Group: "big"}},
+		{ID: "b3", Options: DownloadOptions{Group: "big"}},
+		{ID: "single"},
+		{ID: "o1", Options: DownloadOptions{Group: "other"}},
+		{ID: "o2", Options: DownloadOptions{Group: "other"}},
+	}
+
+	tests := []struct {
+		policy   string
+		expected []string
+	}{
+		{policy: "", expected: []string{"b1", "b2", "b3", "single", "o1", "o2"}},
+		{policy: SchedulerFIFO, expected: []string{"b1", "b2", "b3", "single", "o1", "o2"}},
+		{policy: SchedulerFair, expected: []string{"b1", "single", "o1", "b2", "o2", "b3"}},
+	}
+
+	for _, tt := range tests {
+		sc := newJobScheduler(10)
+		sc.policy = func() string { return tt.policy }
+		for _, job := range submitted {
+			sc.push(job)
+		}
+		sc.close()
+
+		var order []string
+		for {
+			peeked := sc.peek()
+			job, ok := sc.next()
+			if !ok {
+				break
+			}
+			if peeked != job {
+				t.Errorf("policy %q: peek() = %v before next() = %s", tt.policy, peeked, job.ID)
+			}
+			order = append(order, job.ID)
+		}
+		if !reflect.DeepEqual(order, tt.expected) {
+			t.Errorf("policy %q started %v; want %v", tt.policy, order, tt.expected)
+		}
+		// Groups are forgotten once they have nothing queued
+		if len(sc.served) > 1 {
+			t.Errorf("policy %q remembers %d groups after the queue emptied", tt.policy, len(sc.served))
+		}
+		if tt.policy != SchedulerFair && len(sc.served) != 0 {
+			t.Errorf("policy %q recorded turns for %v", tt.policy, sc.served)
+		}
+	}
+}
+
+func TestJobSchedulerCapacity(t *testing.T) {
+	sc := newJobScheduler(1)
+	if !sc.push(&Job{ID: "a"}) {
+		t.Fatal("push() into an empty scheduler failed")
+	}
+	if sc.push(&Job{ID: "b"}) {
+		t.Error("push() into a full scheduler succeeded")
+	}
+
+	// An idle worker wakes up for new jobs and exits on close
+	sc.next()
+	got := make(chan string)
+	go func() {
+		for {
+			job, ok := sc.next()
+			if !ok {
+				close(got)
+				return
+			}
+			got <- job.ID
+		}
+	}()
+	time.Sleep(10 * time.Millisecond)
+	sc.push(&Job{ID: "c"})
+	if id := <-got; id != "c" {
+		t.Errorf("next() = %s; want c", id)
+	}
+	sc.close()
+	if _, ok := <-got; ok {
+		t.Error("next() returned a job after close")
+	}
+	if sc.push(&Job{ID: "d"}) {
+		t.Error("push() after close succeeded")
+	}
+}
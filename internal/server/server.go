@@ -1,23 +1,38 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+	"unicode"
 
 	"github.com/gin-gonic/gin"
 	"github.com/guiyumin/vget/internal/core/config"
 	"github.com/guiyumin/vget/internal/core/downloader"
 	"github.com/guiyumin/vget/internal/core/extractor"
 	"github.com/guiyumin/vget/internal/core/i18n"
+	"github.com/guiyumin/vget/internal/core/storage"
 	"github.com/guiyumin/vget/internal/core/version"
+	"golang.org/x/net/netutil"
 )
 
 // Response is the standard API response structure
@@ -27,27 +42,120 @@ type Response struct {
 	Message string `json:"message"`
 }
 
+// DefaultMaxInlineSize is the largest file an inline download returns in
+// its JSON response when server.max_inline_size is unset
+const DefaultMaxInlineSize = 1 << 20
+
 // DownloadRequest is the request body for POST /download
 type DownloadRequest struct {
 	URL        string `json:"url" binding:"required"`
 	Filename   string `json:"filename,omitempty"`
 	ReturnFile bool   `json:"return_file,omitempty"`
+
+	// Inline returns the file base64-encoded in the JSON response when it
+	// is no larger than server.max_inline_size, and streams it as
+	// return_file does otherwise (implies return_file)
+	Inline bool `json:"inline,omitempty"`
+
+	// Indices or Range restrict gallery downloads to specific 1-based items
+	// (e.g., [1, 3] or "3-7,10")
+	Indices []int  `json:"indices,omitempty"`
+	Range   string `json:"range,omitempty"`
+
+	// Quality overrides the configured default quality (e.g., "720p", "best")
+	Quality string `json:"quality,omitempty"`
+
+	// Qualities downloads the video once per listed quality, suffixing each
+	// filename with the quality it resolved to (e.g., ["1080p", "480p"])
+	Qualities []string `json:"qualities,omitempty"`
+
+	// FormatID downloads exactly this video format, as listed in the
+	// "formats" of POST /api/extract (e.g., "720p-mp4"), instead of
+	// selecting one by quality; the job fails if it isn't offered
+	FormatID string `json:"format_id,omitempty"`
+
+	// StartTime and EndTime cut a video down to this time range with ffmpeg
+	// (seconds or [hh:]mm:ss, e.g., "00:30" and "01:15"); either may be omitted
+	StartTime string `json:"start_time,omitempty"`
+	EndTime   string `json:"end_time,omitempty"`
+
+	// Timeout overrides server.job_timeout for this job (Go duration, e.g., "10m")
+	Timeout string `json:"timeout,omitempty"`
+
+	// Weight is the job's share of the global rate limit relative to other
+	// running jobs (1-100, default 1)
+	Weight int `json:"weight,omitempty"`
+
+	// MaxRateBps caps this job's download bandwidth in bytes per second
+	// (0 = only server.max_rate applies); it can't raise server.max_rate
+	MaxRateBps int64 `json:"max_rate_bps,omitempty"`
+
+	// Extractor forces a named extractor (e.g., "browser", "direct"),
+	// bypassing URL matching
+	Extractor string `json:"extractor,omitempty"`
+
+	// InsecureSkipVerify overrides server.insecure_skip_verify for this
+	// request. DANGEROUS: disables TLS certificate checks on media requests,
+	// so only use it for trusted sources with self-signed certificates.
+	InsecureSkipVerify *bool `json:"insecure_skip_verify,omitempty"`
+
+	// DryRun returns the download plan (URLs, headers, output paths) without downloading
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// ExtractRequest asks for the raw media info an extractor returns for a URL,
+// as the body of POST /extract or the query of GET /extract
+type ExtractRequest struct {
+	URL       string `json:"url" form:"url" binding:"required"`
+	Extractor string `json:"extractor,omitempty" form:"extractor"`
+
+	// InsecureSkipVerify overrides server.insecure_skip_verify (see DownloadRequest)
+	InsecureSkipVerify *bool `json:"insecure_skip_verify,omitempty" form:"insecure_skip_verify"`
 }
 
 // BulkDownloadRequest is the request body for POST /bulk-download
 type BulkDownloadRequest struct {
 	URLs []string `json:"urls" binding:"required"`
+
+	// Group names the batch (generated if empty); jobs carry it in "group"
+	Group string `json:"group,omitempty"`
+
+	// Webhook overrides server.batch_webhook for this batch
+	Webhook string `json:"webhook,omitempty"`
+
+	// Manifest records each URL's outcome in a manifest file in the output
+	// directory; re-submitting the list then skips URLs already completed
+	Manifest bool `json:"manifest,omitempty"`
+
+	// Order is the order the batch's jobs are queued in: as_listed (default),
+	// smallest_first or largest_first (see orderBulkURLs)
+	Order string `json:"order,omitempty"`
 }
 
 // Server is the HTTP server for vget
 type Server struct {
-	port       int
-	outputDir  string
-	apiKey     string
-	jobQueue   *JobQueue
-	cfg        *config.Config
-	server     *http.Server
-	engine     *gin.Engine
+	port      int
+	apiKey    string
+	basePath  string // Route prefix, e.g. "/vget" (empty when served at root)
+	jobQueue  *JobQueue
+	bandwidth *bandwidthLimiter // Global rate limit shared between jobs by weight
+	ffmpeg    *ffmpegLimiter    // Caps concurrent ffmpeg merges and remuxes
+	progress  *progressLog      // JSON-lines audit trail of job state transitions
+	batches   *batchTracker     // Bulk batches awaiting a completion webhook
+	manifests *manifestTracker  // Bulk manifests of resumable batches
+	extracts  *extractCache     // Shares concurrent and recent extractions of a URL
+	notifiers notifierFunc      // Builds the notifiers the config enables
+	server    *http.Server
+	engine    *gin.Engine
+	benchmark sync.Mutex // Held while a benchmark runs, so runs don't skew each other
+
+	// Live config changes replace these while downloads read them. A
+	// published cfg is never modified; changes swap in a new one.
+	mu        sync.RWMutex
+	updateMu  sync.Mutex // Serializes config changes (load, modify, save, apply)
+	cfg       *config.Config
+	outputDir string
+	storage   storage.Storage // Where downloads are written: output_dir or a remote bucket
 }
 
 // NewServer creates a new HTTP server
@@ -58,20 +166,52 @@ func NewServer(port int, outputDir, apiKey string, maxConcurrent int) *Server {
 		port:      port,
 		outputDir: outputDir,
 		apiKey:    apiKey,
+		basePath:  normalizeBasePath(cfg.Server.BasePath),
+		bandwidth: newBandwidthLimiter(),
+		ffmpeg:    newFFmpegLimiter(cfg.Server.FFmpegConcurrency()),
+		progress:  newProgressLog(),
+		extracts:  newExtractCache(),
 		cfg:       cfg,
 	}
+	s.bandwidth.SetRate(cfg.Server.RateLimitBytes())
+	s.bandwidth.SetSchedule(cfg.Server.RateWindows(), cfg.Server.ScheduleLocation())
+	s.progress.Configure(cfg.Server.ProgressLog, cfg.Server.ProgressLogMaxBytes())
 
 	// Create job queue with download function
-	s.jobQueue = NewJobQueue(maxConcurrent, outputDir, s.downloadWithExtractor)
+	s.jobQueue = NewJobQueue(maxConcurrent, outputDir, s.downloadWithExtractor, jobsFilePath(outputDir, cfg.Server.JobsFile))
+	if err := s.applyStorage(); err != nil {
+		// Start reports this; until then fall back to the output directory
+		s.storage = storage.NewLocal(outputDir)
+		s.jobQueue.setOutput(outputDir, s.storage)
+	}
+	s.jobQueue.validateURL = s.checkDomain
+	s.jobQueue.cleanupOnFail = func() bool { return s.config().Server.CleanupPartialEnabled() }
+	s.jobQueue.duplicates = s.duplicatePolicy
+	s.jobQueue.queue.policy = func() string { return s.config().Server.Scheduler }
+	s.jobQueue.prefetch.fn = s.prefetchExtraction
+	s.batches = newBatchTracker(s.jobQueue)
+	s.batches.retry = s.retryPolicy
+	s.manifests = newManifestTracker()
+	s.notifiers = enabledNotifiers
+	s.jobQueue.onEvent = s.onJobEvent
 
 	return s
 }
 
+// onJobEvent fans job state transitions out to the progress log, batch
+// tracker and notifiers
+func (s *Server) onJobEvent(event jobEvent) {
+	s.progress.record(event)
+	s.batches.onJobEvent(event)
+	s.manifests.onJobEvent(event)
+	s.notifyJob(event)
+}
+
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	// Warn if no config file exists
 	if !config.Exists() {
-		lang := s.cfg.Language
+		lang := s.config().Language
 		if lang == "" {
 			lang = "zh"
 		}
@@ -80,29 +220,80 @@ func (s *Server) Start() error {
 		log.Printf("   %s", t.Server.RunInitHint)
 	}
 
+	if err := s.applyStorage(); err != nil {
+		return fmt.Errorf("invalid storage config: %w", err)
+	}
+	if err := s.config().Destination.Validate(); err != nil {
+		return fmt.Errorf("invalid destination config: %w", err)
+	}
+
 	// Ensure output directory exists
-	if err := os.MkdirAll(s.outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	if s.store().IsLocal() {
+		if err := os.MkdirAll(s.output(), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
 	}
 
 	// Start job queue workers
 	s.jobQueue.Start()
 
+	s.engine = s.setupRouter()
+
+	s.server = &http.Server{
+		Addr:         fmt.Sprintf(":%d", s.port),
+		Handler:      s.engine,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: s.writeTimeout(), // None by default; streams extend it per chunk
+		IdleTimeout:  120 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	if limit := s.config().Server.MaxConnections; limit > 0 {
+		listener = netutil.LimitListener(listener, limit)
+	}
+
+	log.Printf("Starting vget server on port %d", s.port)
+	if s.store().IsLocal() {
+		log.Printf("Output directory: %s", s.output())
+	} else {
+		log.Printf("Output storage: s3://%s/%s", s.config().Storage.Bucket, s.store().Join())
+	}
+	if s.basePath != "" {
+		log.Printf("Base path: %s", s.basePath)
+	}
+	if s.apiKey != "" {
+		log.Printf("API key authentication enabled")
+	}
+	if limit := s.config().Server.MaxConnections; limit > 0 {
+		log.Printf("Max connections: %d", limit)
+	}
+
+	return s.server.Serve(listener)
+}
+
+// setupRouter creates the Gin engine with middleware and all API routes
+func (s *Server) setupRouter() *gin.Engine {
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
 
 	// Create Gin engine
-	s.engine = gin.New()
+	engine := gin.New()
 
 	// Add middleware
-	s.engine.Use(gin.Recovery())
-	s.engine.Use(s.loggingMiddleware())
+	engine.Use(gin.Recovery())
+	engine.Use(s.loggingMiddleware())
 	if s.apiKey != "" {
-		s.engine.Use(s.jwtAuthMiddleware())
+		engine.Use(s.jwtAuthMiddleware())
 	}
 
+	// Landing route pointing visitors at the API
+	engine.GET(s.basePath+"/", s.handleRoot)
+
 	// API routes
-	api := s.engine.Group("/api")
+	api := engine.Group(s.apiPrefix())
 	api.GET("/health", s.handleHealth)
 
 	// Auth routes (don't require authentication)
@@ -112,61 +303,160 @@ func (s *Server) Start() error {
 	api.GET("/download", s.handleFileDownload) // Download local file by path
 	api.POST("/download", s.handleDownload)
 	api.POST("/bulk-download", s.handleBulkDownload)
+	api.GET("/extract", s.handleExtract)
+	api.POST("/extract", s.handleExtract)
 	api.GET("/status/:id", s.handleStatus)
+	api.GET("/status/:id/stream", s.handleStatusStream)
 	api.GET("/jobs", s.handleGetJobs)
+	api.GET("/jobs/playlist", s.handleJobsPlaylist)
+	api.GET("/stats", s.handleStats)
+	api.POST("/pause", s.handlePause)
+	api.POST("/resume", s.handleResume)
+	api.POST("/benchmark", s.handleBenchmark)
 	api.DELETE("/jobs", s.handleClearJobs)
 	api.DELETE("/jobs/:id", s.handleDeleteJob)
+	api.POST("/jobs/:id/pin", s.handlePinJob)
+	api.POST("/jobs/:id/unpin", s.handleUnpinJob)
+	api.GET("/jobs/:id/log", s.handleJobLog)
 	api.GET("/config", s.handleGetConfig)
 	api.POST("/config", s.handleSetConfig)
 	api.PUT("/config", s.handleUpdateConfig)
 	api.GET("/i18n", s.handleI18n)
 
-	s.server = &http.Server{
-		Addr:         fmt.Sprintf(":%d", s.port),
-		Handler:      s.engine,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 0, // No timeout for downloads
-		IdleTimeout:  120 * time.Second,
+	return engine
+}
+
+// maxInlineSize returns the largest file an inline download returns in JSON
+func (s *Server) maxInlineSize() int64 {
+	if n := s.config().Server.MaxInlineSizeBytes(); n > 0 {
+		return n
 	}
+	return DefaultMaxInlineSize
+}
 
-	log.Printf("Starting vget server on port %d", s.port)
-	log.Printf("Output directory: %s", s.outputDir)
-	if s.apiKey != "" {
-		log.Printf("API key authentication enabled")
+// writeTimeout returns the configured server.write_timeout (0 = none)
+func (s *Server) writeTimeout() time.Duration {
+	d, err := time.ParseDuration(s.config().Server.WriteTimeout)
+	if err != nil || d < 0 {
+		return 0
 	}
+	return d
+}
 
-	return s.server.ListenAndServe()
+// retryPolicy returns the backoff configured under retry.*, with invalid
+// or unset values left at the defaults
+func (s *Server) retryPolicy() downloader.RetryPolicy {
+	rc := s.config().Retry
+	policy := downloader.DefaultRetryPolicy()
+	if d, err := time.ParseDuration(rc.BaseDelay); err == nil && d > 0 {
+		policy.BaseDelay = d
+	}
+	if d, err := time.ParseDuration(rc.MaxDelay); err == nil && d > 0 {
+		policy.MaxDelay = d
+	}
+	if rc.Multiplier >= 1 {
+		policy.Multiplier = rc.Multiplier
+	}
+	if rc.Jitter != nil {
+		policy.Jitter = *rc.Jitter
+	}
+	if rc.MaxAttempts > 0 {
+		policy.MaxAttempts = rc.MaxAttempts
+	}
+	if d, err := time.ParseDuration(rc.MaxElapsed); err == nil && d > 0 {
+		policy.MaxElapsed = d
+	}
+	return policy
 }
 
 // Stop gracefully shuts down the server
 func (s *Server) Stop(ctx context.Context) error {
 	s.jobQueue.Stop()
+	s.progress.Close()
 	return s.server.Shutdown(ctx)
 }
 
+// apiPrefix returns the route prefix for the API group (e.g., "/vget/api")
+func (s *Server) apiPrefix() string {
+	return s.basePath + "/api"
+}
+
+// normalizeBasePath ensures a leading slash and strips trailing slashes,
+// returning "" for the root path
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
 // Middleware
 
 func (s *Server) loggingMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		c.Next()
-		log.Printf("%s %s %s", c.Request.Method, c.Request.URL.Path, time.Since(start))
+		// Only the method and redacted URI are logged, never headers such as Authorization
+		log.Printf("%s %s %s", c.Request.Method, redactRequestURI(c.Request.URL, s.redactedParams()), time.Since(start))
 	}
 }
 
 // Handlers
 
 func (s *Server) handleHealth(c *gin.Context) {
+	data := gin.H{
+		"status":  "ok",
+		"version": version.Version,
+		"paused":  s.jobQueue.Paused(),
+	}
+	if !s.config().Server.HideHealthLoad {
+		stats := s.jobQueue.Stats()
+		data["active_downloads"] = stats.ActiveDownloads
+		data["queued_jobs"] = stats.QueuedJobs
+		data["worker_count"] = stats.WorkerCount
+	}
+
 	c.JSON(http.StatusOK, Response{
-		Code: 200,
-		Data: gin.H{
-			"status":  "ok",
-			"version": version.Version,
-		},
+		Code:    200,
+		Data:    data,
 		Message: "everything is good",
 	})
 }
 
+func (s *Server) handleStats(c *gin.Context) {
+	stats := s.jobQueue.Stats()
+	stats.RateLimits = extractor.RateLimits()
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Data:    stats,
+		Message: "stats retrieved",
+	})
+}
+
+// handlePause stops queued jobs from starting until /resume; running
+// downloads finish normally
+func (s *Server) handlePause(c *gin.Context) {
+	s.jobQueue.Pause()
+	log.Printf("Queue paused")
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Data:    gin.H{"paused": true},
+		Message: "queue paused",
+	})
+}
+
+// handleResume lets queued jobs start again after /pause
+func (s *Server) handleResume(c *gin.Context) {
+	s.jobQueue.Resume()
+	log.Printf("Queue resumed")
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Data:    gin.H{"paused": false},
+		Message: "queue resumed",
+	})
+}
+
 // handleFileDownload serves a local file for download
 func (s *Server) handleFileDownload(c *gin.Context) {
 	filePath := c.Query("path")
@@ -179,6 +469,20 @@ func (s *Server) handleFileDownload(c *gin.Context) {
 		return
 	}
 
+	if !s.signedLinkReferrerAllowed(c) {
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
+			Data:    nil,
+			Message: "access denied: signed link not allowed from this site",
+		})
+		return
+	}
+
+	if !s.store().IsLocal() {
+		s.serveStoredFile(c, filePath)
+		return
+	}
+
 	// Security: ensure the file is within the output directory
 	absPath, err := filepath.Abs(filePath)
 	if err != nil {
@@ -190,8 +494,8 @@ func (s *Server) handleFileDownload(c *gin.Context) {
 		return
 	}
 
-	absOutputDir, _ := filepath.Abs(s.outputDir)
-	if !strings.HasPrefix(absPath, absOutputDir) {
+	absOutputDir, _ := filepath.Abs(s.output())
+	if !withinDir(absOutputDir, absPath) || s.isProgressLog(absPath) || s.jobQueue.isJobsFile(absPath) {
 		c.JSON(http.StatusForbidden, Response{
 			Code:    403,
 			Data:    nil,
@@ -201,7 +505,7 @@ func (s *Server) handleFileDownload(c *gin.Context) {
 	}
 
 	// Check file exists
-	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+	if _, err := s.store().Stat(absPath); errors.Is(err, fs.ErrNotExist) {
 		c.JSON(http.StatusNotFound, Response{
 			Code:    404,
 			Data:    nil,
@@ -216,116 +520,161 @@ func (s *Server) handleFileDownload(c *gin.Context) {
 	c.File(absPath)
 }
 
-func (s *Server) handleDownload(c *gin.Context) {
-	var req DownloadRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, Response{
-			Code:    400,
+// withinDir reports whether the absolute, clean path p is beneath dir. A
+// sibling that merely shares dir's prefix (/output-evil for /output) and
+// dir itself are not.
+func withinDir(dir, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return strings.HasPrefix(p, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator))
+}
+
+// serveStoredFile streams a file from remote storage. name must be a clean
+// path under the storage root, as reported in job filenames.
+func (s *Server) serveStoredFile(c *gin.Context, name string) {
+	root := s.store().Join()
+	if path.Clean(name) != name || strings.HasPrefix(name, "../") || (root != "" && !strings.HasPrefix(name, root+"/")) {
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
 			Data:    nil,
-			Message: "invalid request body: url is required",
+			Message: "access denied: file outside output directory",
 		})
 		return
 	}
 
-	// If return_file is true, download and stream directly
-	if req.ReturnFile {
-		s.downloadAndStream(c, req.URL, req.Filename)
+	info, err := s.store().Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Data:    nil,
+			Message: "file not found",
+		})
 		return
 	}
 
-	// Otherwise, queue the download
-	job, err := s.jobQueue.AddJob(req.URL, req.Filename)
+	var reader io.ReadCloser
+	if err == nil {
+		reader, err = s.store().Open(name)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{
-			Code:    500,
+		c.JSON(http.StatusBadGateway, Response{
+			Code:    502,
 			Data:    nil,
-			Message: err.Error(),
+			Message: fmt.Sprintf("failed to read from storage: %v", err),
 		})
 		return
 	}
+	defer reader.Close()
 
-	c.JSON(http.StatusOK, Response{
-		Code: 200,
-		Data: gin.H{
-			"id":     job.ID,
-			"status": job.Status,
-		},
-		Message: "download started",
+	c.DataFromReader(http.StatusOK, info.Size, "application/octet-stream", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=\"%s\"", path.Base(name)),
 	})
 }
 
-func (s *Server) handleBulkDownload(c *gin.Context) {
-	var req BulkDownloadRequest
+func (s *Server) handleDownload(c *gin.Context) {
+	var req DownloadRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
 			Data:    nil,
-			Message: "invalid request body: urls array is required",
+			Message: "invalid request body: url is required",
 		})
 		return
 	}
 
-	if len(req.URLs) == 0 {
+	opts, err := req.options()
+	if err != nil {
 		c.JSON(http.StatusBadRequest, Response{
 			Code:    400,
 			Data:    nil,
-			Message: "urls array cannot be empty",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	// Queue all downloads
-	var jobs []gin.H
-	var queued, failed int
+	opts.Claims = customClaims(c)
+	opts.InsecureSkipVerify = s.config().Server.InsecureSkipVerify
+	if req.InsecureSkipVerify != nil {
+		opts.InsecureSkipVerify = *req.InsecureSkipVerify
+	}
 
-	for _, url := range req.URLs {
-		url = strings.TrimSpace(url)
-		// Skip empty lines and comments
-		if url == "" || strings.HasPrefix(url, "#") {
-			continue
-		}
+	if req.DryRun {
+		s.handleDryRun(c, req.URL, req.Filename, opts)
+		return
+	}
 
-		job, err := s.jobQueue.AddJob(url, "")
+	if header := c.GetHeader("X-Download-Deadline"); header != "" {
+		deadline, err := parseDownloadDeadline(header, time.Now())
 		if err != nil {
-			// Create a failed job so clients can see it in job listings
-			failedJob := s.jobQueue.AddFailedJob(url, err.Error())
-			jobs = append(jobs, gin.H{
-				"id":     failedJob.ID,
-				"url":    failedJob.URL,
-				"status": failedJob.Status,
-				"error":  failedJob.Error,
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: err.Error(),
 			})
-			failed++
-			continue
+			return
 		}
-		jobs = append(jobs, gin.H{
-			"id":     job.ID,
-			"url":    job.URL,
-			"status": job.Status,
-		})
-		queued++
+		opts.Deadline = deadline
 	}
 
-	c.JSON(http.StatusOK, Response{
-		Code: 200,
-		Data: gin.H{
-			"jobs":   jobs,
-			"queued": queued,
-			"failed": failed,
-		},
-		Message: fmt.Sprintf("%d downloads queued", queued),
-	})
-}
+	// If return_file is true, download and stream directly
+	if req.ReturnFile || req.Inline {
+		if len(opts.Qualities) > 0 {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: "qualities cannot be combined with return_file",
+			})
+			return
+		}
 
-func (s *Server) handleStatus(c *gin.Context) {
-	id := c.Param("id")
+		if opts.clipped() {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: "start_time/end_time cannot be combined with return_file",
+			})
+			return
+		}
 
-	job := s.jobQueue.GetJob(id)
-	if job == nil {
-		c.JSON(http.StatusNotFound, Response{
-			Code:    404,
+		s.downloadAndStream(c, req.URL, req.Filename, opts, req.Inline)
+		return
+	}
+
+	// Otherwise, queue the download. A request may shorten
+	// server.job_timeout but not extend it.
+	if limit := s.config().Server.JobTimeoutDuration(); opts.Timeout == 0 || (limit > 0 && opts.Timeout > limit) {
+		opts.Timeout = limit
+	}
+	job, err := s.jobQueue.AddJob(req.URL, req.Filename, opts)
+	if errors.Is(err, errDomainNotAllowed) {
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
 			Data:    nil,
-			Message: "job not found",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if job.Status == JobStatusCompleted {
+		// skip_if_completed found an earlier download of this URL
+		c.JSON(http.StatusOK, Response{
+			Code: 200,
+			Data: gin.H{
+				"id":       job.ID,
+				"status":   job.Status,
+				"filename": job.Filename,
+			},
+			Message: "already downloaded",
 		})
 		return
 	}
@@ -333,47 +682,618 @@ func (s *Server) handleStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code: 200,
 		Data: gin.H{
-			"id":       job.ID,
-			"status":   job.Status,
-			"progress": job.Progress,
-			"filename": job.Filename,
-			"error":    job.Error,
+			"id":     job.ID,
+			"status": job.Status,
 		},
-		Message: string(job.Status),
+		Message: "download started",
 	})
 }
 
-func (s *Server) handleGetJobs(c *gin.Context) {
-	jobs := s.jobQueue.GetAllJobs()
-
-	jobList := make([]gin.H, len(jobs))
-	for i, job := range jobs {
-		jobList[i] = gin.H{
-			"id":         job.ID,
-			"url":        job.URL,
-			"status":     job.Status,
-			"progress":   job.Progress,
-			"downloaded": job.Downloaded,
-			"total":      job.Total,
-			"filename":   job.Filename,
-			"error":      job.Error,
+// handleDryRun responds with the download plan for a URL without downloading it
+func (s *Server) handleDryRun(c *gin.Context, url, filename string, opts DownloadOptions) {
+	if err := s.checkDomain(url); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errDomainNotAllowed) {
+			status = http.StatusForbidden
 		}
+		c.JSON(status, Response{
+			Code:    status,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+	url, _ = extractor.NormalizeURL(url) // Validated by checkDomain above
+
+	var data any
+	plan, err := s.planDownload(c.Request.Context(), url, filename, opts)
+	if err == nil {
+		// Headers carry extractor_headers credentials, masked as in GET /api/config
+		data, err = maskedJSON(plan)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, Response{
-		Code: 200,
-		Data: gin.H{
-			"jobs": jobList,
-		},
-		Message: fmt.Sprintf("%d jobs found", len(jobs)),
+		Code:    200,
+		Data:    data,
+		Message: "download plan",
 	})
 }
 
-func (s *Server) handleClearJobs(c *gin.Context) {
-	count := s.jobQueue.ClearHistory()
-	c.JSON(http.StatusOK, Response{
-		Code: 200,
-		Data: gin.H{
+// handleExtract dumps everything the extractor returned for a URL, for
+// previewing a download (e.g., to pick a format_id) or diagnosing extractor
+// problems. Nothing is downloaded. Sensitive header values are masked.
+func (s *Server) handleExtract(c *gin.Context) {
+	var req ExtractRequest
+	bind, what := c.ShouldBindJSON, "request body"
+	if c.Request.Method == http.MethodGet {
+		bind, what = c.ShouldBindQuery, "query"
+	}
+	if err := bind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: "invalid " + what + ": url is required",
+		})
+		return
+	}
+
+	if req.Extractor != "" && extractor.ByName(req.Extractor) == nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: fmt.Sprintf("unknown extractor %q (available: %s)", req.Extractor, strings.Join(extractor.Names(), ", ")),
+		})
+		return
+	}
+
+	if err := s.checkDomain(req.URL); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errDomainNotAllowed) {
+			status = http.StatusForbidden
+		}
+		c.JSON(status, Response{
+			Code:    status,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+	url, _ := extractor.NormalizeURL(req.URL) // Validated by checkDomain above
+
+	opts := DownloadOptions{Extractor: req.Extractor, InsecureSkipVerify: s.config().Server.InsecureSkipVerify}
+	if req.InsecureSkipVerify != nil {
+		opts.InsecureSkipVerify = *req.InsecureSkipVerify
+	}
+
+	url, err := s.resolveURL(c.Request.Context(), url, opts)
+	if err != nil {
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+	ext := s.findExtractor(c.Request.Context(), url, opts)
+	media, err := s.extract(c.Request.Context(), ext, url)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Data:    gin.H{"extractor": ext.Name()},
+			Message: fmt.Sprintf("extraction failed: %v", err),
+		})
+		return
+	}
+
+	raw, err := maskedJSON(media)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{
+			Code:    500,
+			Data:    nil,
+			Message: fmt.Sprintf("failed to encode media: %v", err),
+		})
+		return
+	}
+
+	data := gin.H{
+		"extractor":  ext.Name(),
+		"media_type": media.Type(),
+		"go_type":    fmt.Sprintf("%T", media),
+		"media":      raw,
+	}
+	if video, ok := media.(*extractor.VideoMedia); ok {
+		data["formats"] = formatChoices(video.Formats)
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Data:    data,
+		Message: "media extracted",
+	})
+}
+
+// FormatChoice describes a video format a download can ask for by ID
+type FormatChoice struct {
+	ID            string `json:"id"`      // Pass as format_id to download this format
+	Quality       string `json:"quality"` // Normalized label, e.g. "720p" ("best" if unknown)
+	Ext           string `json:"ext,omitempty"`
+	Width         int    `json:"width,omitempty"`
+	Height        int    `json:"height,omitempty"`
+	Bitrate       int    `json:"bitrate,omitempty"`
+	SeparateAudio bool   `json:"separate_audio,omitempty"` // Audio is a second stream, merged with ffmpeg
+}
+
+// formatChoices lists formats with their IDs, in the extractor's order
+func formatChoices(formats []extractor.VideoFormat) []FormatChoice {
+	ids := extractor.FormatIDs(formats)
+	choices := make([]FormatChoice, len(formats))
+	for i := range formats {
+		f := &formats[i]
+		choices[i] = FormatChoice{
+			ID:            ids[i],
+			Quality:       formatQuality(f),
+			Ext:           f.Ext,
+			Width:         f.Width,
+			Height:        formatHeight(*f),
+			Bitrate:       f.Bitrate,
+			SeparateAudio: f.AudioURL != "",
+		}
+	}
+	return choices
+}
+
+// maskedJSON converts v (extracted media or a download plan) to generic
+// JSON, masking the values of sensitive entries in any headers map so
+// dumps can be shared safely
+func maskedJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	maskHeaders(raw)
+	return raw, nil
+}
+
+// maskHeaders walks decoded JSON and masks sensitive header values in place
+func maskHeaders(v any) {
+	switch node := v.(type) {
+	case map[string]any:
+		for key, child := range node {
+			if headers, ok := child.(map[string]any); ok && isHeadersKey(key) {
+				for name, value := range headers {
+					if str, ok := value.(string); ok && isSensitiveHeader(name) {
+						headers[name] = maskSecret(str)
+					}
+				}
+				continue
+			}
+			maskHeaders(child)
+		}
+	case []any:
+		for _, child := range node {
+			maskHeaders(child)
+		}
+	}
+}
+
+// isHeadersKey reports whether a JSON key holds a header map: the Headers
+// and AudioHeaders of extracted formats, or headers and audio_headers of
+// planned files
+func isHeadersKey(key string) bool {
+	switch key {
+	case "Headers", "AudioHeaders", "headers", "audio_headers":
+		return true
+	}
+	return false
+}
+
+// isSensitiveHeader reports whether a header may carry credentials
+func isSensitiveHeader(name string) bool {
+	lower := strings.ToLower(name)
+	for _, marker := range []string{"auth", "cookie", "token", "key", "secret", "session", "csrf"} {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskedExtractorHeaders copies the extractor_headers config with
+// sensitive header values masked
+func maskedExtractorHeaders(byExtractor map[string]map[string]string) map[string]map[string]string {
+	masked := make(map[string]map[string]string, len(byExtractor))
+	for name, headers := range byExtractor {
+		masked[name] = make(map[string]string, len(headers))
+		for key, value := range headers {
+			if isSensitiveHeader(key) {
+				value = maskSecret(value)
+			}
+			masked[name][key] = value
+		}
+	}
+	return masked
+}
+
+// maskSecret keeps a short prefix of a secret so values can still be told apart
+func maskSecret(value string) string {
+	if len(value) <= 8 {
+		return "****"
+	}
+	return value[:4] + "****"
+}
+
+func (s *Server) handleBulkDownload(c *gin.Context) {
+	var req BulkDownloadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: "invalid request body: urls array is required",
+		})
+		return
+	}
+
+	if len(req.URLs) == 0 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: "urls array cannot be empty",
+		})
+		return
+	}
+
+	if !validBulkOrder(req.Order) {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: fmt.Sprintf("invalid order %q: must be as_listed, smallest_first or largest_first", req.Order),
+		})
+		return
+	}
+
+	group := strings.TrimSpace(req.Group)
+	if len(group) > 64 {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: "group must be at most 64 characters",
+		})
+		return
+	}
+
+	// A webhook from the request must not reach the server's own network,
+	// nor replace the webhook of a group another caller is still waiting on
+	webhook, untrusted := s.config().Server.BatchWebhook, false
+	if req.Webhook != "" {
+		webhook, untrusted = req.Webhook, true
+		if err := s.checkWebhook(c.Request.Context(), webhook); err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if webhook != "" && group != "" {
+		if err := s.batches.checkGroupWebhook(group, webhook); err != nil {
+			c.JSON(http.StatusConflict, Response{
+				Code:    409,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	// Resume from the manifest of an earlier run, keeping its group
+	var manifest *bulkManifest
+	if req.Manifest {
+		var err error
+		if manifest, err = s.manifests.open(s.store(), group, req.URLs); err != nil {
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+		if group == "" {
+			group = s.manifests.group(manifest)
+		}
+	}
+	if group == "" {
+		var err error
+		if group, err = newBatchGroup(); err != nil {
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	opts := DownloadOptions{
+		Timeout:            s.config().Server.JobTimeoutDuration(),
+		Group:              group,
+		InsecureSkipVerify: s.config().Server.InsecureSkipVerify,
+		Claims:             customClaims(c),
+	}
+
+	if manifest != nil {
+		s.manifests.track(manifest, group)
+	}
+
+	probeCtx := c.Request.Context()
+	if opts.InsecureSkipVerify {
+		probeCtx = withInsecureTLS(probeCtx)
+	}
+	urls := s.orderBulkURLs(probeCtx, req.URLs, req.Order)
+
+	// Queue all downloads
+	var jobs []gin.H
+	var jobIDs []string
+	var queued, failed, skipped int
+
+	for _, url := range urls {
+		url = strings.TrimSpace(url)
+		// Skip empty lines and comments
+		if url == "" || strings.HasPrefix(url, "#") {
+			continue
+		}
+
+		if manifest != nil {
+			if entry, done := s.manifests.done(manifest, url); done {
+				// A job still running from another submission has no filename yet
+				jobs = append(jobs, gin.H{
+					"url":      url,
+					"status":   "skipped",
+					"filename": entry.Filename,
+				})
+				skipped++
+				continue
+			}
+			s.manifests.record(manifest, url, JobStatusQueued, "")
+		}
+
+		job, err := s.jobQueue.AddJob(url, "", opts)
+		if err != nil {
+			if manifest != nil {
+				s.manifests.record(manifest, url, JobStatusFailed, err.Error())
+			}
+			// Create a failed job so clients can see it in job listings
+			failedJob := s.jobQueue.AddFailedJob(url, err.Error())
+			s.jobQueue.updateJob(failedJob.ID, func(j *Job) { j.Options.Claims = opts.Claims })
+			jobIDs = append(jobIDs, failedJob.ID)
+			jobs = append(jobs, gin.H{
+				"id":     failedJob.ID,
+				"url":    failedJob.URL,
+				"status": failedJob.Status,
+				"error":  failedJob.Error,
+			})
+			failed++
+			continue
+		}
+		if job.Status == JobStatusCompleted {
+			// skip_if_completed found an earlier download of this URL
+			if manifest != nil {
+				s.manifests.record(manifest, url, JobStatusCompleted, "")
+			}
+			jobs = append(jobs, gin.H{
+				"id":       job.ID,
+				"url":      job.URL,
+				"status":   "skipped",
+				"filename": job.Filename,
+			})
+			skipped++
+			continue
+		}
+		jobIDs = append(jobIDs, job.ID)
+		jobs = append(jobs, gin.H{
+			"id":     job.ID,
+			"url":    job.URL,
+			"status": job.Status,
+		})
+		queued++
+	}
+	if manifest != nil {
+		s.manifests.submitted(manifest)
+	}
+
+	if webhook != "" && len(jobIDs) > 0 {
+		s.batches.track(group, webhook, untrusted, jobIDs)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Data: gin.H{
+			"group":   group,
+			"jobs":    jobs,
+			"queued":  queued,
+			"failed":  failed,
+			"skipped": skipped,
+		},
+		Message: fmt.Sprintf("%d downloads queued", queued),
+	})
+}
+
+func (s *Server) handleStatus(c *gin.Context) {
+	id := c.Param("id")
+
+	job := s.jobQueue.GetJob(id)
+	if job == nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Data:    nil,
+			Message: "job not found",
+		})
+		return
+	}
+
+	data := gin.H{
+		"id":              job.ID,
+		"status":          job.Status,
+		"progress":        job.Progress,
+		"downloaded":      job.Downloaded,
+		"total":           job.Total,
+		"filename":        job.Filename,
+		"error":           job.Error,
+		"items":           job.Items,
+		"quality":         job.Quality,
+		"clip":            job.Options.Clip(),
+		"weight":          job.Weight(),
+		"group":           job.Options.Group,
+		"pinned":          job.Pinned,
+		"claims":          job.Options.Claims,
+		"upload":          job.Upload,
+		"timings":         job.Timings,
+		"phase":           job.Phase,
+		"split":           job.Split,
+		"resume_discards": job.ResumeDiscards,
+		"split_from":      job.Options.SplitFrom,
+		"conversions":     job.Conversions,
+		"normalizations":  job.Normalizations,
+	}
+	if remaining := job.RemainingTime(); remaining >= 0 {
+		data["deadline"] = job.Deadline
+		data["remaining_seconds"] = int(remaining.Seconds())
+	}
+	if c.Query("human") == "true" {
+		addHumanSizes(data, job)
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Data:    data,
+		Message: string(job.Status),
+	})
+}
+
+func (s *Server) handleGetJobs(c *gin.Context) {
+	// ?user= lists only jobs submitted with a token whose "user" claim matches
+	user := c.Query("user")
+	human := c.Query("human") == "true"
+	listing, err := parseJobListing(c.Query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{
+			Code:    400,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// Read the version before the snapshot so the ETag can never be newer than the body
+	var etag string
+	if !s.config().Server.DisableJobsETag {
+		etag = fmt.Sprintf(`W/"jobs-%d"`, s.jobQueue.Version())
+		if user != "" {
+			// Each filter sees a different body for the same version
+			etag = fmt.Sprintf(`%s-%x"`, strings.TrimSuffix(etag, `"`), user)
+		}
+		if human {
+			etag = strings.TrimSuffix(etag, `"`) + `-h"`
+		}
+		if suffix := listing.etagSuffix(); suffix != "" {
+			etag = strings.TrimSuffix(etag, `"`) + suffix + `"`
+		}
+		if etagMatches(c.GetHeader("If-None-Match"), etag) {
+			c.Header("ETag", etag)
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+
+	jobs := s.jobQueue.GetAllJobs()
+	if user != "" {
+		jobs = slices.DeleteFunc(jobs, func(job *Job) bool { return claimedUser(job.Options.Claims) != user })
+	}
+	total := len(jobs)
+	jobs = listing.apply(jobs)
+
+	jobList := make([]gin.H, len(jobs))
+	for i, job := range jobs {
+		jobList[i] = gin.H{
+			"id":         job.ID,
+			"url":        job.URL,
+			"status":     job.Status,
+			"progress":   job.Progress,
+			"downloaded": job.Downloaded,
+			"total":      job.Total,
+			"filename":   job.Filename,
+			"error":      job.Error,
+			"items":      job.Items,
+			"quality":    job.Quality,
+			"clip":       job.Options.Clip(),
+			"weight":     job.Weight(),
+			"group":      job.Options.Group,
+			"pinned":     job.Pinned,
+			"claims":     job.Options.Claims,
+			"upload":     job.Upload,
+			"timings":    job.Timings,
+			"phase":      job.Phase,
+			"split":      job.Split,
+			"split_from": job.Options.SplitFrom,
+		}
+		if human {
+			addHumanSizes(jobList[i], job)
+		}
+	}
+
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Data: gin.H{
+			"jobs":  jobList,
+			"total": total,
+		},
+		Message: fmt.Sprintf("%d jobs found", total),
+	})
+}
+
+// addHumanSizes adds readable downloaded_human and total_human fields (e.g.,
+// "1.5 MB") next to a job's raw byte counts, for ?human=true. total_human
+// is left out while the total is unknown.
+func addHumanSizes(data gin.H, job *Job) {
+	data["downloaded_human"] = config.FormatByteSize(job.Downloaded)
+	if job.Total >= 0 {
+		data["total_human"] = config.FormatByteSize(job.Total)
+	}
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleClearJobs(c *gin.Context) {
+	// Pinned jobs are only cleared with ?force=true
+	count := s.jobQueue.ClearHistory(c.Query("force") == "true")
+	c.JSON(http.StatusOK, Response{
+		Code: 200,
+		Data: gin.H{
 			"cleared": count,
 		},
 		Message: fmt.Sprintf("%d jobs cleared", count),
@@ -405,6 +1325,37 @@ func (s *Server) handleDeleteJob(c *gin.Context) {
 	}
 }
 
+func (s *Server) handlePinJob(c *gin.Context) {
+	s.setJobPinned(c, true)
+}
+
+func (s *Server) handleUnpinJob(c *gin.Context) {
+	s.setJobPinned(c, false)
+}
+
+// setJobPinned pins or unpins the job named in the path
+func (s *Server) setJobPinned(c *gin.Context, pinned bool) {
+	id := c.Param("id")
+	if !s.jobQueue.SetPinned(id, pinned) {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Data:    nil,
+			Message: "job not found",
+		})
+		return
+	}
+
+	message := "job pinned"
+	if !pinned {
+		message = "job unpinned"
+	}
+	c.JSON(http.StatusOK, Response{
+		Code:    200,
+		Data:    gin.H{"id": id, "pinned": pinned},
+		Message: message,
+	})
+}
+
 // ConfigSetRequest is the request body for POST /config
 type ConfigSetRequest struct {
 	Key   string `json:"key" binding:"required"`
@@ -422,14 +1373,120 @@ func (s *Server) handleGetConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code: 200,
 		Data: gin.H{
-			"output_dir":            s.outputDir,
-			"language":              cfg.Language,
-			"format":                cfg.Format,
-			"quality":               cfg.Quality,
-			"twitter_auth_token":    cfg.Twitter.AuthToken,
-			"server_port":           cfg.Server.Port,
-			"server_max_concurrent": cfg.Server.MaxConcurrent,
-			"server_api_key":        cfg.Server.APIKey,
+			"output_dir":                        s.output(),
+			"language":                          cfg.Language,
+			"format":                            cfg.Format,
+			"quality":                           cfg.Quality,
+			"quality_ladder":                    cfg.QualityLadder,
+			"min_height":                        cfg.MinHeight,
+			"extractor_headers":                 maskedExtractorHeaders(cfg.ExtractorHeaders),
+			"download_thumbnail":                cfg.DownloadThumbnail,
+			"thumbnail_max_height":              cfg.ThumbnailMaxHeight,
+			"embed_chapters":                    cfg.EmbedChapters,
+			"date_partition":                    cfg.DatePartition,
+			"hls_format":                        cfg.HLSFormat,
+			"convert_to":                        cfg.ConvertTo,
+			"normalize_audio":                   cfg.NormalizeAudio,
+			"faststart":                         cfg.Faststart,
+			"loudness_target":                   cfg.LoudnessTargetLUFS(),
+			"twitter_auth_token":                cfg.Twitter.AuthToken,
+			"server_port":                       cfg.Server.Port,
+			"server_max_concurrent":             cfg.Server.MaxConcurrent,
+			"server_max_concurrent_ffmpeg":      cfg.Server.FFmpegConcurrency(),
+			"server_max_connections":            cfg.Server.MaxConnections,
+			"server_api_key":                    cfg.Server.APIKey,
+			"server_base_path":                  cfg.Server.BasePath,
+			"server_jwt_issuer":                 cfg.Server.JWTIssuer,
+			"server_jwt_audience":               cfg.Server.JWTAudience,
+			"allowed_domains":                   cfg.Server.AllowedDomains,
+			"blocked_domains":                   cfg.Server.BlockedDomains,
+			"signed_link_referrers":             cfg.Server.SignedLinkReferrers,
+			"server_job_timeout":                cfg.Server.JobTimeout,
+			"server_stream_timeout":             cfg.Server.StreamTimeout,
+			"server_max_live_duration":          cfg.Server.MaxLiveDuration,
+			"server_extract_cache_ttl":          cfg.Server.ExtractCacheTTL,
+			"server_extract_freshness":          cfg.Server.ExtractFreshness,
+			"server_prefetch_extraction":        cfg.Server.PrefetchExtraction,
+			"server_rate_limit":                 cfg.Server.RateLimit,
+			"server_max_rate":                   cfg.Server.MaxRate,
+			"server_rate_schedule":              cfg.Server.RateSchedule,
+			"server_rate_schedule_timezone":     cfg.Server.RateScheduleTimezone,
+			"server_cleanup_partial_on_failure": cfg.Server.CleanupPartialEnabled(),
+			"server_skip_if_completed":          cfg.Server.SkipIfCompleted,
+			"server_enable_benchmark":           cfg.Server.EnableBenchmark,
+			"server_max_items":                  cfg.Server.MaxItems,
+			"server_resolve_shorteners":         cfg.Server.ResolveShorteners,
+			"server_skip_match":                 cfg.Server.SkipMatch,
+			"server_skip_check_file":            cfg.Server.SkipCheckFileEnabled(),
+			"server_progress_log":               cfg.Server.ProgressLog,
+			"server_progress_log_max_size":      cfg.Server.ProgressLogMaxSize,
+			"server_root_page":                  cfg.Server.RootPage,
+			"server_scheduler":                  cfg.Server.Scheduler,
+			"server_batch_webhook":              cfg.Server.BatchWebhook,
+			"server_insecure_skip_verify":       cfg.Server.InsecureSkipVerify,
+			"server_log_redact_params":          cfg.Server.LogRedactParams,
+			"server_min_video_size":             cfg.Server.MinVideoSize,
+			"server_min_output_size":            cfg.Server.MinOutputSize,
+			"server_max_inline_size":            cfg.Server.MaxInlineSize,
+			"server_login_markers":              cfg.Server.LoginMarkers,
+			"server_disable_media_type_check":   cfg.Server.DisableMediaTypeCheck,
+			"server_force_http1":                cfg.Server.ForceHTTP1,
+			"server_raw_content_encoding":       cfg.Server.RawContentEncoding,
+			"server_max_redirects":              cfg.Server.MaxRedirects,
+			"server_log_redirects":              cfg.Server.LogRedirects,
+			"server_format_fallbacks":           cfg.Server.FormatFallbacks,
+			"server_min_tls_version":            cfg.Server.MinTLSVersion,
+			"server_max_path_length":            cfg.Server.MaxPathLength,
+			"server_gallery_split":              cfg.Server.GallerySplit,
+			"server_jobs_file":                  cfg.Server.JobsFile,
+			"storage_type":                      cfg.Storage.Type,
+			"storage_endpoint":                  cfg.Storage.Endpoint,
+			"storage_region":                    cfg.Storage.Region,
+			"storage_bucket":                    cfg.Storage.Bucket,
+			"storage_prefix":                    cfg.Storage.Prefix,
+			"storage_access_key":                cfg.Storage.AccessKey,
+			"storage_path_style":                cfg.Storage.PathStyle,
+			"filename_rules": gin.H{
+				"replacement":     cfg.FilenameRules.Replacement,
+				"keep_whitespace": cfg.FilenameRules.KeepWhitespace,
+				"max_length":      cfg.FilenameRules.MaxLength,
+				"lowercase":       cfg.FilenameRules.Lowercase,
+			},
+			"retry": gin.H{
+				"base_delay":   cfg.Retry.BaseDelay,
+				"max_delay":    cfg.Retry.MaxDelay,
+				"multiplier":   cfg.Retry.Multiplier,
+				"jitter":       cfg.Retry.Jitter,
+				"max_attempts": cfg.Retry.MaxAttempts,
+				"max_elapsed":  cfg.Retry.MaxElapsed,
+			},
+			// Credentials are write-only
+			"destination": gin.H{
+				"type":          cfg.Destination.Type,
+				"url":           cfg.Destination.URL,
+				"username":      cfg.Destination.Username,
+				"endpoint":      cfg.Destination.Endpoint,
+				"region":        cfg.Destination.Region,
+				"bucket":        cfg.Destination.Bucket,
+				"prefix":        cfg.Destination.Prefix,
+				"path_style":    cfg.Destination.PathStyle,
+				"delete_local":  cfg.Destination.DeleteLocal,
+				"fail_on_error": cfg.Destination.FailOnErrorEnabled(),
+			},
+			"notifiers": gin.H{
+				"events":  cfg.Notifiers.NotifyEvents(),
+				"desktop": cfg.Notifiers.Desktop,
+				"telegram": gin.H{
+					"chat_id": cfg.Notifiers.Telegram.ChatID,
+				},
+				"email": gin.H{
+					"host":     cfg.Notifiers.Email.Host,
+					"port":     cfg.Notifiers.Email.Port,
+					"username": cfg.Notifiers.Email.Username,
+					"from":     cfg.Notifiers.Email.From,
+					"to":       cfg.Notifiers.Email.To,
+				},
+			},
 		},
 		Message: "config retrieved",
 	})
@@ -447,6 +1504,8 @@ func (s *Server) handleSetConfig(c *gin.Context) {
 	}
 
 	// Load current config, update, save
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
 	cfg := config.LoadOrDefault()
 	if err := s.setConfigValue(cfg, req.Key, req.Value); err != nil {
 		c.JSON(http.StatusBadRequest, Response{
@@ -467,11 +1526,18 @@ func (s *Server) handleSetConfig(c *gin.Context) {
 	}
 
 	// Update server's cached config
-	s.cfg = cfg
+	s.bandwidth.SetRate(cfg.Server.RateLimitBytes())
+	s.bandwidth.SetSchedule(cfg.Server.RateWindows(), cfg.Server.ScheduleLocation())
+	s.ffmpeg.SetLimit(cfg.Server.FFmpegConcurrency())
+	s.progress.Configure(cfg.Server.ProgressLog, cfg.Server.ProgressLogMaxBytes())
 
 	// Special handling for output_dir
+	outputDir := s.output()
 	if req.Key == "output_dir" {
 		if err := os.MkdirAll(req.Value, 0755); err != nil {
+			s.mu.Lock()
+			s.cfg = cfg
+			s.mu.Unlock()
 			c.JSON(http.StatusBadRequest, Response{
 				Code:    400,
 				Data:    nil,
@@ -479,10 +1545,18 @@ func (s *Server) handleSetConfig(c *gin.Context) {
 			})
 			return
 		}
-		s.outputDir = req.Value
-		s.jobQueue.outputDir = req.Value
+		outputDir = req.Value
 	}
 
+	s.mu.Lock()
+	s.cfg = cfg
+	s.outputDir = outputDir
+	// A half-configured backend (e.g., type set before bucket) keeps the current one
+	if err := s.applyStorageLocked(); err != nil {
+		log.Printf("Warning: storage config not applied: %v", err)
+	}
+	s.mu.Unlock()
+
 	c.JSON(http.StatusOK, Response{
 		Code: 200,
 		Data: gin.H{
@@ -504,6 +1578,9 @@ func (s *Server) handleUpdateConfig(c *gin.Context) {
 		return
 	}
 
+	s.updateMu.Lock()
+	defer s.updateMu.Unlock()
+
 	if req.OutputDir != "" {
 		if err := os.MkdirAll(req.OutputDir, 0755); err != nil {
 			c.JSON(http.StatusBadRequest, Response{
@@ -514,8 +1591,12 @@ func (s *Server) handleUpdateConfig(c *gin.Context) {
 			return
 		}
 
+		s.mu.Lock()
 		s.outputDir = req.OutputDir
-		s.jobQueue.outputDir = req.OutputDir
+		if err := s.applyStorageLocked(); err != nil {
+			log.Printf("Warning: storage config not applied: %v", err)
+		}
+		s.mu.Unlock()
 
 		cfg := config.LoadOrDefault()
 		cfg.OutputDir = req.OutputDir
@@ -527,14 +1608,56 @@ func (s *Server) handleUpdateConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{
 		Code: 200,
 		Data: gin.H{
-			"output_dir": s.outputDir,
+			"output_dir": s.output(),
 		},
 		Message: "config updated",
 	})
 }
 
+// applyStorage switches the server and job queue to the backend selected
+// by the storage config, rooted at the current output directory. On error
+// the current backend stays in place.
+func (s *Server) applyStorage() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyStorageLocked()
+}
+
+// applyStorageLocked is applyStorage for callers holding s.mu
+func (s *Server) applyStorageLocked() error {
+	st, err := storage.New(s.cfg.Storage, s.outputDir)
+	if err != nil {
+		return err
+	}
+	s.storage = st
+	s.jobQueue.setOutput(s.outputDir, st)
+	return nil
+}
+
+// config returns the current config. Treat it as read-only: changes go
+// through setConfigValue and replace it.
+func (s *Server) config() *config.Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cfg
+}
+
+// output returns the current output directory
+func (s *Server) output() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.outputDir
+}
+
+// store returns the current output storage backend
+func (s *Server) store() storage.Storage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.storage
+}
+
 func (s *Server) handleI18n(c *gin.Context) {
-	lang := s.cfg.Language
+	lang := s.config().Language
 	if lang == "" {
 		lang = "zh"
 	}
@@ -566,6 +1689,102 @@ func (s *Server) setConfigValue(cfg *config.Config, key, value string) error {
 		cfg.Format = value
 	case "quality":
 		cfg.Quality = value
+	case "quality_ladder":
+		cfg.QualityLadder = splitList(value)
+	case "min_height":
+		var val int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(config.NormalizeQuality(value), "p"), "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for min_height: %s", value)
+		}
+		cfg.MinHeight = val
+	case "filename_rules.replacement":
+		if strings.ContainsAny(value, `/\:*?"<>|`) || strings.ContainsFunc(value, unicode.IsControl) {
+			return fmt.Errorf("invalid value for filename_rules.replacement: %q", value)
+		}
+		cfg.FilenameRules.Replacement = value
+	case "filename_rules.keep_whitespace":
+		cfg.FilenameRules.KeepWhitespace = value == "true"
+	case "filename_rules.max_length":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for filename_rules.max_length: %s", value)
+		}
+		cfg.FilenameRules.MaxLength = val
+	case "filename_rules.lowercase":
+		cfg.FilenameRules.Lowercase = value == "true"
+	case "retry.base_delay", "retry.max_delay", "retry.max_elapsed":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return fmt.Errorf("invalid value for %s: %s", key, value)
+			}
+		}
+		switch key {
+		case "retry.base_delay":
+			cfg.Retry.BaseDelay = value
+		case "retry.max_delay":
+			cfg.Retry.MaxDelay = value
+		default:
+			cfg.Retry.MaxElapsed = value
+		}
+	case "retry.multiplier":
+		var val float64
+		if value != "" {
+			if _, err := fmt.Sscanf(value, "%g", &val); err != nil || val < 1 {
+				return fmt.Errorf("invalid value for retry.multiplier: %s (must be at least 1)", value)
+			}
+		}
+		cfg.Retry.Multiplier = val
+	case "retry.jitter":
+		if value == "" {
+			cfg.Retry.Jitter = nil
+			break
+		}
+		var val float64
+		if _, err := fmt.Sscanf(value, "%g", &val); err != nil || val < 0 || val > 1 {
+			return fmt.Errorf("invalid value for retry.jitter: %s (use 0 to 1)", value)
+		}
+		cfg.Retry.Jitter = &val
+	case "retry.max_attempts":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for retry.max_attempts: %s", value)
+		}
+		cfg.Retry.MaxAttempts = val
+	case "download_thumbnail":
+		cfg.DownloadThumbnail = value == "true"
+	case "thumbnail_max_height":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for thumbnail_max_height: %s", value)
+		}
+		cfg.ThumbnailMaxHeight = val
+	case "date_partition":
+		cfg.DatePartition = value == "true"
+	case "embed_chapters":
+		cfg.EmbedChapters = value == "true"
+	case "hls_format":
+		if value != "" && value != "mp4" && value != "ts" {
+			return fmt.Errorf("invalid value for hls_format: %s (use mp4 or ts)", value)
+		}
+		cfg.HLSFormat = value
+	case "convert_to":
+		if value != "" && !slices.Contains(downloader.ConvertContainers, value) {
+			return fmt.Errorf("invalid value for convert_to: %s (use %s)", value, strings.Join(downloader.ConvertContainers, ", "))
+		}
+		cfg.ConvertTo = value
+	case "normalize_audio":
+		cfg.NormalizeAudio = value == "true"
+	case "faststart":
+		cfg.Faststart = value == "true"
+	case "loudness_target":
+		var val float64
+		if value != "" {
+			// loudnorm accepts integrated loudness targets from -70 to -5 LUFS
+			if _, err := fmt.Sscanf(value, "%g", &val); err != nil || val < -70 || val > -5 {
+				return fmt.Errorf("invalid value for loudness_target: %s (use -70 to -5)", value)
+			}
+		}
+		cfg.LoudnessTarget = val
 	case "twitter_auth_token", "twitter.auth_token":
 		cfg.Twitter.AuthToken = value
 	case "server.max_concurrent", "server_max_concurrent":
@@ -574,206 +1793,562 @@ func (s *Server) setConfigValue(cfg *config.Config, key, value string) error {
 			return fmt.Errorf("invalid value for max_concurrent: %s", value)
 		}
 		cfg.Server.MaxConcurrent = val
+	case "server.max_concurrent_ffmpeg", "server_max_concurrent_ffmpeg":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for max_concurrent_ffmpeg: %s", value)
+		}
+		cfg.Server.MaxConcurrentFFmpeg = val
+	case "server.max_connections", "server_max_connections":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for max_connections: %s", value)
+		}
+		cfg.Server.MaxConnections = val
 	case "server.api_key", "server_api_key":
 		cfg.Server.APIKey = value
+	case "server.jwt_issuer", "server_jwt_issuer":
+		cfg.Server.JWTIssuer = value
+	case "server.jwt_audience", "server_jwt_audience":
+		cfg.Server.JWTAudience = value
+	case "server.default_referer", "server_default_referer":
+		cfg.Server.DefaultReferer = value == "true"
+	case "server.disable_jobs_etag", "server_disable_jobs_etag":
+		cfg.Server.DisableJobsETag = value == "true"
+	case "server.hide_health_load", "server_hide_health_load":
+		cfg.Server.HideHealthLoad = value == "true"
+	case "server.enable_benchmark", "server_enable_benchmark":
+		cfg.Server.EnableBenchmark = value == "true"
+	case "server.max_items", "server_max_items":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for max_items: %s", value)
+		}
+		cfg.Server.MaxItems = val
+	case "server.resolve_shorteners", "server_resolve_shorteners":
+		cfg.Server.ResolveShorteners = value == "true"
+	case "server.cleanup_partial_on_failure", "server_cleanup_partial_on_failure":
+		cleanup := value == "true"
+		cfg.Server.CleanupPartialOnFailure = &cleanup
+	case "server.skip_if_completed", "server_skip_if_completed":
+		cfg.Server.SkipIfCompleted = value == "true"
+	case "server.skip_match", "server_skip_match":
+		switch value {
+		case "", SkipMatchExact, SkipMatchLoose:
+			cfg.Server.SkipMatch = value
+		default:
+			return fmt.Errorf("invalid value for skip_match: %s (use exact or loose)", value)
+		}
+	case "server.skip_check_file", "server_skip_check_file":
+		check := value == "true"
+		cfg.Server.SkipCheckFile = &check
+	case "server.base_path", "server_base_path":
+		cfg.Server.BasePath = normalizeBasePath(value)
+	case "server.write_timeout", "server_write_timeout":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return fmt.Errorf("invalid value for write_timeout: %s", value)
+			}
+		}
+		cfg.Server.WriteTimeout = value
+	case "server.job_timeout", "server_job_timeout":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return fmt.Errorf("invalid value for job_timeout: %s", value)
+			}
+		}
+		cfg.Server.JobTimeout = value
+	case "server.stream_timeout", "server_stream_timeout":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return fmt.Errorf("invalid value for stream_timeout: %s", value)
+			}
+		}
+		cfg.Server.StreamTimeout = value
+	case "server.max_live_duration", "server_max_live_duration":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				return fmt.Errorf("invalid value for max_live_duration: %s", value)
+			}
+		}
+		cfg.Server.MaxLiveDuration = value
+	case "server.extract_cache_ttl", "server_extract_cache_ttl":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return fmt.Errorf("invalid value for extract_cache_ttl: %s", value)
+			}
+		}
+		cfg.Server.ExtractCacheTTL = value
+	case "server.extract_freshness", "server_extract_freshness":
+		if value != "" {
+			if d, err := time.ParseDuration(value); err != nil || d < 0 {
+				return fmt.Errorf("invalid value for extract_freshness: %s", value)
+			}
+		}
+		cfg.Server.ExtractFreshness = value
+	case "server.prefetch_extraction", "server_prefetch_extraction":
+		cfg.Server.PrefetchExtraction = value == "true"
+	case "server.rate_limit", "server_rate_limit":
+		if _, err := config.ParseByteSize(value); err != nil {
+			return fmt.Errorf("invalid value for rate_limit: %s", value)
+		}
+		cfg.Server.RateLimit = value
+	case "server.max_rate", "server_max_rate":
+		if _, err := config.ParseByteSize(value); err != nil {
+			return fmt.Errorf("invalid value for max_rate: %s", value)
+		}
+		cfg.Server.MaxRate = value
+	case "server.rate_schedule", "server_rate_schedule":
+		entries := splitList(value)
+		for _, entry := range entries {
+			if _, err := config.ParseRateWindow(entry); err != nil {
+				return err
+			}
+		}
+		cfg.Server.RateSchedule = entries
+	case "server.rate_schedule_timezone", "server_rate_schedule_timezone":
+		if value != "" {
+			if _, err := time.LoadLocation(value); err != nil {
+				return fmt.Errorf("invalid value for rate_schedule_timezone: %s", value)
+			}
+		}
+		cfg.Server.RateScheduleTimezone = value
+	case "server.min_video_size", "server_min_video_size":
+		if _, err := config.ParseByteSize(value); err != nil {
+			return fmt.Errorf("invalid value for min_video_size: %s", value)
+		}
+		cfg.Server.MinVideoSize = value
+	case "server.min_output_size", "server_min_output_size":
+		if _, err := config.ParseByteSize(value); err != nil {
+			return fmt.Errorf("invalid value for min_output_size: %s", value)
+		}
+		cfg.Server.MinOutputSize = value
+	case "server.max_inline_size", "server_max_inline_size":
+		if _, err := config.ParseByteSize(value); err != nil {
+			return fmt.Errorf("invalid value for max_inline_size: %s", value)
+		}
+		cfg.Server.MaxInlineSize = value
+	case "server.login_markers", "server_login_markers":
+		cfg.Server.LoginMarkers = splitList(value)
+	case "server.disable_media_type_check", "server_disable_media_type_check":
+		cfg.Server.DisableMediaTypeCheck = value == "true"
+	case "server.force_http1", "server_force_http1":
+		cfg.Server.ForceHTTP1 = value == "true"
+	case "server.raw_content_encoding", "server_raw_content_encoding":
+		cfg.Server.RawContentEncoding = value == "true"
+	case "server.max_redirects", "server_max_redirects":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for max_redirects: %s", value)
+		}
+		cfg.Server.MaxRedirects = val
+	case "server.log_redirects", "server_log_redirects":
+		cfg.Server.LogRedirects = value == "true"
+	case "server.min_tls_version", "server_min_tls_version":
+		if _, ok := config.TLSVersions[value]; value != "" && !ok {
+			return fmt.Errorf("invalid value for min_tls_version: %s (use 1.2 or 1.3)", value)
+		}
+		cfg.Server.MinTLSVersion = value
+	case "server.format_fallbacks", "server_format_fallbacks":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for format_fallbacks: %s", value)
+		}
+		cfg.Server.FormatFallbacks = val
+	case "server.max_path_length", "server_max_path_length":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for max_path_length: %s", value)
+		}
+		cfg.Server.MaxPathLength = val
+	case "server.gallery_split", "server_gallery_split":
+		var val int
+		if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 {
+			return fmt.Errorf("invalid value for gallery_split: %s", value)
+		}
+		cfg.Server.GallerySplit = val
+	case "server.jobs_file", "server_jobs_file":
+		cfg.Server.JobsFile = value
+	case "progress_log", "server.progress_log", "server_progress_log":
+		if value != "" {
+			if err := s.checkProgressLogPath(value); err != nil {
+				return err
+			}
+		}
+		cfg.Server.ProgressLog = value
+	case "insecure_skip_verify", "server.insecure_skip_verify", "server_insecure_skip_verify":
+		cfg.Server.InsecureSkipVerify = value == "true"
+	case "storage.type", "storage_type":
+		switch value {
+		case "", config.StorageLocal, config.StorageS3:
+			cfg.Storage.Type = value
+		default:
+			return fmt.Errorf("invalid value for storage.type: %s (want local or s3)", value)
+		}
+	case "storage.endpoint", "storage_endpoint":
+		cfg.Storage.Endpoint = value
+	case "storage.region", "storage_region":
+		cfg.Storage.Region = value
+	case "storage.bucket", "storage_bucket":
+		cfg.Storage.Bucket = value
+	case "storage.prefix", "storage_prefix":
+		cfg.Storage.Prefix = value
+	case "storage.access_key", "storage_access_key":
+		cfg.Storage.AccessKey = value
+	case "storage.secret_key", "storage_secret_key":
+		cfg.Storage.SecretKey = value
+	case "storage.path_style", "storage_path_style":
+		cfg.Storage.PathStyle = value == "true"
+	case "destination.type":
+		if err := (&config.DestinationConfig{Type: value}).Validate(); err != nil {
+			return err
+		}
+		cfg.Destination.Type = value
+	case "destination.url":
+		cfg.Destination.URL = value
+	case "destination.username":
+		cfg.Destination.Username = value
+	case "destination.password":
+		cfg.Destination.Password = value
+	case "destination.endpoint":
+		cfg.Destination.Endpoint = value
+	case "destination.region":
+		cfg.Destination.Region = value
+	case "destination.bucket":
+		cfg.Destination.Bucket = value
+	case "destination.prefix":
+		cfg.Destination.Prefix = value
+	case "destination.access_key":
+		cfg.Destination.AccessKey = value
+	case "destination.secret_key":
+		cfg.Destination.SecretKey = value
+	case "destination.path_style":
+		cfg.Destination.PathStyle = value == "true"
+	case "destination.delete_local":
+		cfg.Destination.DeleteLocal = value == "true"
+	case "destination.fail_on_error":
+		enabled := value == "true"
+		cfg.Destination.FailOnError = &enabled
+	case "notifiers.events":
+		events := splitList(value)
+		for _, event := range events {
+			if !isFinished(JobStatus(event)) {
+				return fmt.Errorf("invalid value for notifiers.events: %s (use completed, partial, failed or cancelled)", event)
+			}
+		}
+		cfg.Notifiers.Events = events
+	case "notifiers.desktop":
+		cfg.Notifiers.Desktop = value == "true"
+	case "notifiers.telegram.bot_token":
+		cfg.Notifiers.Telegram.BotToken = value
+	case "notifiers.telegram.chat_id":
+		cfg.Notifiers.Telegram.ChatID = value
+	case "notifiers.email.host":
+		cfg.Notifiers.Email.Host = value
+	case "notifiers.email.port":
+		var val int
+		if value != "" {
+			if _, err := fmt.Sscanf(value, "%d", &val); err != nil || val < 0 || val > 65535 {
+				return fmt.Errorf("invalid value for notifiers.email.port: %s", value)
+			}
+		}
+		cfg.Notifiers.Email.Port = val
+	case "notifiers.email.username":
+		cfg.Notifiers.Email.Username = value
+	case "notifiers.email.password":
+		cfg.Notifiers.Email.Password = value
+	case "notifiers.email.from":
+		cfg.Notifiers.Email.From = value
+	case "notifiers.email.to":
+		cfg.Notifiers.Email.To = splitList(value)
+	case "server.log_redact_params", "server_log_redact_params":
+		cfg.Server.LogRedactParams = splitList(value)
+	case "server.batch_webhook", "server_batch_webhook":
+		cfg.Server.BatchWebhook = value
+	case "server.scheduler", "server_scheduler":
+		switch value {
+		case "", SchedulerFIFO, SchedulerFair:
+			cfg.Server.Scheduler = value
+		default:
+			return fmt.Errorf("invalid value for scheduler: %s (use fifo or fair)", value)
+		}
+	case "server.root_page", "server_root_page":
+		switch value {
+		case "", RootPageJSON, RootPageStatus, RootPageRedirect, RootPageOff:
+			cfg.Server.RootPage = value
+		default:
+			return fmt.Errorf("invalid value for root_page: %s (use json, page, redirect, or off)", value)
+		}
+	case "server.progress_log_max_size", "server_progress_log_max_size":
+		if _, err := config.ParseByteSize(value); err != nil {
+			return fmt.Errorf("invalid value for progress_log_max_size: %s", value)
+		}
+		cfg.Server.ProgressLogMaxSize = value
+	case "allowed_domains", "server.allowed_domains":
+		cfg.Server.AllowedDomains = splitList(value)
+	case "blocked_domains", "server.blocked_domains":
+		cfg.Server.BlockedDomains = splitList(value)
+	case "signed_link_referrers", "server.signed_link_referrers":
+		cfg.Server.SignedLinkReferrers = splitList(value)
 	default:
+		// extractor_headers.<extractor>.<header>; an empty value removes it
+		if rest, ok := strings.CutPrefix(key, "extractor_headers."); ok {
+			name, header, ok := strings.Cut(rest, ".")
+			if !ok || name == "" || !validHeaderName(header) {
+				return fmt.Errorf("invalid config key: %s (use extractor_headers.<extractor>.<header>)", key)
+			}
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("invalid value for %s: header values cannot contain newlines", key)
+			}
+			cfg.SetExtractorHeader(name, http.CanonicalHeaderKey(header), value)
+			return nil
+		}
 		return fmt.Errorf("unknown config key: %s", key)
 	}
 	return nil
 }
 
-// downloadWithExtractor is the download function used by the job queue
-func (s *Server) downloadWithExtractor(ctx context.Context, url, filename string, progressFn func(downloaded, total int64)) error {
-	// Find matching extractor
-	ext := extractor.Match(url)
-	if ext == nil {
-		sitesConfig, _ := config.LoadSites()
-		if sitesConfig != nil {
-			if site := sitesConfig.MatchSite(url); site != nil {
-				ext = extractor.NewBrowserExtractor(site, false)
-			}
-		}
-		if ext == nil {
-			ext = extractor.NewGenericBrowserExtractor(false)
-		}
+// validHeaderName reports whether name is a non-empty HTTP header token
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
 	}
-
-	// Configure Twitter extractor with auth if available
-	if twitterExt, ok := ext.(*extractor.TwitterExtractor); ok {
-		if s.cfg.Twitter.AuthToken != "" {
-			twitterExt.SetAuth(s.cfg.Twitter.AuthToken)
+	for _, r := range name {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("!#$%&'*+-.^_`|~", r)) {
+			return false
 		}
 	}
+	return true
+}
 
-	// Extract media info
-	media, err := ext.Extract(url)
-	if err != nil {
-		return fmt.Errorf("extraction failed: %w", err)
+// downloadWithExtractor is the download function used by the job queue
+func (s *Server) downloadWithExtractor(ctx context.Context, jobID, url, filename string, opts DownloadOptions, progressFn func(downloaded, total int64)) error {
+	// Re-check domain policy in case it changed while the job was queued
+	if err := s.checkDomain(url); err != nil {
+		return err
 	}
 
-	// Determine output path based on media type
-	var outputPath string
-	var downloadURL string
-	var headers map[string]string
-
-	switch m := media.(type) {
-	case *extractor.VideoMedia:
-		if len(m.Formats) == 0 {
-			return fmt.Errorf("no video formats available")
-		}
-		format := selectBestFormat(m.Formats)
-		downloadURL = format.URL
-		headers = format.Headers
+	// Share the global rate limit with other running jobs by weight, within
+	// the job's own cap
+	ctx, release := s.bandwidth.attach(ctx, opts.Weight, jobRateCap(opts.MaxRate, s.config().Server.MaxRateBytes()))
+	defer release()
 
-		ext := format.Ext
-		if ext == "m3u8" {
-			ext = "ts"
-		}
+	if opts.InsecureSkipVerify {
+		logf(ctx, "Warning: TLS certificate verification disabled for %s", redactURL(url, s.redactedParams()))
+	}
+	ctx = s.transferContext(ctx, opts.InsecureSkipVerify)
+	ctx = withExtractFreshness(ctx, s.config().Server.ExtractFreshnessDuration())
+	ctx, ffmpegTime := withFFmpegTimer(ctx)
+	ctx = s.withPhase(ctx, jobID)
+	ctx = s.withResumeDiscards(ctx, jobID)
+
+	// Record each phase's duration on the job as it ends
+	var timings JobTimings
+	phase := time.Now()
+	endPhase := func(set func(elapsed time.Duration)) {
+		set(time.Since(phase))
+		snapshot := timings
+		s.jobQueue.updateJob(jobID, func(j *Job) { j.Timings = &snapshot })
+		phase = time.Now()
+	}
 
-		if filename != "" {
-			// Sanitize the provided filename to remove invalid path characters
-			sanitized := extractor.SanitizeFilename(filename)
-			// Ensure the filename has the correct extension
-			if !strings.HasSuffix(strings.ToLower(sanitized), "."+ext) {
-				sanitized = fmt.Sprintf("%s.%s", sanitized, ext)
-			}
-			outputPath = filepath.Join(s.outputDir, sanitized)
-		} else {
-			title := extractor.SanitizeFilename(m.Title)
-			if title != "" {
-				outputPath = filepath.Join(s.outputDir, fmt.Sprintf("%s.%s", title, ext))
-			} else {
-				outputPath = filepath.Join(s.outputDir, fmt.Sprintf("%s.%s", m.ID, ext))
-			}
-		}
+	setPhase(ctx, JobPhaseExtracting)
+	plan, err := s.planDownload(ctx, url, filename, opts)
+	endPhase(func(elapsed time.Duration) { timings.Extraction = phaseSeconds(elapsed) })
+	if err != nil {
+		return err
+	}
+	jobLogf(ctx, "Extractor %s found %s %q, files: %d", plan.Extractor, plan.MediaType, plan.Title, len(plan.Files))
+	if plan.Quality != "" {
+		jobLogf(ctx, "Selected quality %s", plan.Quality)
+		s.jobQueue.updateJob(jobID, func(j *Job) { j.Quality = plan.Quality })
+	}
+	if split, err := s.splitGallery(jobID, url, filename, opts, plan); err != nil || split {
+		return err
+	}
 
-		s.updateJobFilename(url, outputPath)
+	saved, err := s.executePlan(ctx, jobID, plan, progressFn)
+	endPhase(func(elapsed time.Duration) {
+		post := time.Duration(ffmpegTime.Load())
+		timings.Download = phaseSeconds(elapsed - post)
+		timings.PostProcessing = phaseSeconds(post)
+	})
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.saved = saved })
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return err
+	}
+	// Upload whatever was saved, even when some items of a set failed
+	setPhase(ctx, JobPhaseUploading)
+	var items []JobItem
+	if partial != nil {
+		items = partial.Items
+	}
+	uerr := s.uploadToDestination(ctx, jobID, saved, items)
+	endPhase(func(elapsed time.Duration) { timings.Upload = phaseSeconds(elapsed) })
+	if uerr != nil {
+		return uerr
+	}
+	return err
+}
 
-		// Handle separate audio stream
-		if format.AudioURL != "" {
-			return s.downloadVideoWithAudio(ctx, format, outputPath, progressFn)
+// downloadItems downloads each target in turn (gallery images, playlist
+// entries, or several qualities of one video). It keeps going when a single
+// item fails so the rest of the set is still saved, and reports a
+// *PartialError when only some items failed. It returns the paths of the
+// saved items.
+func (s *Server) downloadItems(ctx context.Context, jobID, noun string, targets []plannedFile) ([]string, error) {
+	var results itemResults
+	for _, target := range targets {
+		if err := s.downloadItem(ctx, jobID, target, &results); err != nil {
+			return nil, err
 		}
+	}
 
-	case *extractor.AudioMedia:
-		downloadURL = m.URL
-
-		if filename != "" {
-			// Sanitize the provided filename to remove invalid path characters
-			sanitized := extractor.SanitizeFilename(filename)
-			// Ensure the filename has the correct extension
-			if !strings.HasSuffix(strings.ToLower(sanitized), "."+m.Ext) {
-				sanitized = fmt.Sprintf("%s.%s", sanitized, m.Ext)
-			}
-			outputPath = filepath.Join(s.outputDir, sanitized)
-		} else {
-			title := extractor.SanitizeFilename(m.Title)
-			if title != "" {
-				outputPath = filepath.Join(s.outputDir, fmt.Sprintf("%s.%s", title, m.Ext))
-			} else {
-				outputPath = filepath.Join(s.outputDir, fmt.Sprintf("%s.%s", m.ID, m.Ext))
-			}
-		}
+	s.updateJobFilename(jobID, strings.Join(results.filenames, ", "))
+	return results.result(noun)
+}
 
-		s.updateJobFilename(url, outputPath)
+// itemResults collects the outcome of each item of a multi-item download
+type itemResults struct {
+	filenames []string // Saved items
+	items     []JobItem
+	failed    int
+}
 
-	case *extractor.ImageMedia:
-		if len(m.Images) == 0 {
-			return fmt.Errorf("no images available")
+// downloadItem downloads one item of the job jobID's set into results. A
+// failed item is recorded there; the error is only returned when ctx is done.
+func (s *Server) downloadItem(ctx context.Context, jobID string, target plannedFile, results *itemResults) error {
+	finalPath, err := s.downloadPlannedFile(ctx, target, nil)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		jobLogf(ctx, "Item %d failed: %v", target.Index, err)
+		results.items = append(results.items, JobItem{Index: target.Index, Filename: target.Path, Error: err.Error()})
+		results.failed++
+		return nil
+	}
 
-		title := extractor.SanitizeFilename(m.Title)
-		var filenames []string
+	setPhase(ctx, JobPhasePostProcessing)
+	finalPath = s.convertOutput(ctx, jobID, target, finalPath)
+	s.normalizeAudio(ctx, jobID, target, finalPath)
+	s.saveThumbnail(ctx, jobID, target, finalPath)
+	s.saveChapters(ctx, jobID, target, finalPath)
+	s.faststart(ctx, target, finalPath)
+	results.filenames = append(results.filenames, finalPath)
+	results.items = append(results.items, JobItem{Index: target.Index, Filename: finalPath})
+	return nil
+}
 
-		for i, img := range m.Images {
-			var imgPath string
-			if len(m.Images) == 1 {
-				if title != "" {
-					imgPath = filepath.Join(s.outputDir, fmt.Sprintf("%s.%s", title, img.Ext))
-				} else {
-					imgPath = filepath.Join(s.outputDir, fmt.Sprintf("%s.%s", m.ID, img.Ext))
-				}
-			} else {
-				if title != "" {
-					imgPath = filepath.Join(s.outputDir, fmt.Sprintf("%s_%d.%s", title, i+1, img.Ext))
-				} else {
-					imgPath = filepath.Join(s.outputDir, fmt.Sprintf("%s_%d.%s", m.ID, i+1, img.Ext))
-				}
-			}
+// result returns the saved paths, failing when every item failed and
+// reporting a *PartialError when only some did
+func (r *itemResults) result(noun string) ([]string, error) {
+	if r.failed == len(r.items) {
+		return nil, fmt.Errorf("failed to download all %d %s: %s", r.failed, noun, r.items[0].Error)
+	}
+	if r.failed > 0 {
+		return r.filenames, &PartialError{Items: r.items}
+	}
+	return r.filenames, nil
+}
 
-			filenames = append(filenames, imgPath)
+// sanitizeFilename applies the configured filename_rules
+func (s *Server) sanitizeFilename(name string) string {
+	rules := s.config().FilenameRules
+	return extractor.SanitizeFilenameWith(name, extractor.SanitizeOptions{
+		Replacement:    rules.Replacement,
+		KeepWhitespace: rules.KeepWhitespace,
+		MaxLength:      rules.MaxLength,
+		Lowercase:      rules.Lowercase,
+	})
+}
 
-			if err := downloadFile(ctx, img.URL, imgPath, nil, nil); err != nil {
-				return fmt.Errorf("failed to download image %d: %w", i+1, err)
-			}
+// trimExt removes the first matching extension (case-insensitive) from name
+func trimExt(name string, exts ...string) string {
+	for _, ext := range exts {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return name[:len(name)-len(ext)]
 		}
-
-		s.updateJobFilename(url, strings.Join(filenames, ", "))
-		return nil
-
-	default:
-		return fmt.Errorf("unsupported media type")
 	}
+	return name
+}
 
-	// Check if this is an HLS stream
-	if strings.HasSuffix(strings.ToLower(downloadURL), ".m3u8") ||
-		strings.Contains(strings.ToLower(downloadURL), ".m3u8?") {
-		finalPath, err := downloader.DownloadHLSWithProgress(ctx, downloadURL, outputPath, headers, progressFn)
-		if err != nil {
-			return err
+// mediaHeaders returns the headers for media requests of a file produced
+// by the named extractor. The extractor's own headers come first, then the
+// configured extractor_headers defaults, then the default Referer; a header
+// set by an earlier source is never overridden. The input map is never
+// modified.
+func (s *Server) mediaHeaders(headers map[string]string, extractorName, pageURL string) map[string]string {
+	defaults := s.config().ExtractorHeaders[extractorName]
+	if len(defaults) > 0 {
+		result := make(map[string]string, len(headers)+len(defaults))
+		for key, value := range headers {
+			result[key] = value
 		}
-		if finalPath != outputPath {
-			s.updateJobFilename(url, finalPath)
+		for key, value := range defaults {
+			if !hasHeader(result, key) {
+				result[key] = value
+			}
 		}
-		return nil
+		headers = result
 	}
-
-	return downloadFile(ctx, downloadURL, outputPath, headers, progressFn)
+	return s.refererHeaders(headers, pageURL)
 }
 
-func (s *Server) updateJobFilename(url, filename string) {
-	jobs := s.jobQueue.GetAllJobs()
-	for _, job := range jobs {
-		if job.URL == url {
-			s.jobQueue.mu.Lock()
-			if j, ok := s.jobQueue.jobs[job.ID]; ok {
-				j.Filename = filename
-			}
-			s.jobQueue.mu.Unlock()
-			break
+// hasHeader reports whether headers sets key (case-insensitive)
+func hasHeader(headers map[string]string, key string) bool {
+	for k := range headers {
+		if strings.EqualFold(k, key) {
+			return true
 		}
 	}
+	return false
 }
 
-// downloadVideoWithAudio downloads video and audio in parallel then merges them with ffmpeg
-func (s *Server) downloadVideoWithAudio(ctx context.Context, format *extractor.VideoFormat, outputPath string, progressFn func(downloaded, total int64)) error {
-	// Determine audio extension based on video format
-	audioExt := "m4a"
-	if format.Ext == "webm" {
-		audioExt = "opus"
+// refererHeaders returns headers with a Referer derived from the page URL's
+// origin when server.default_referer is enabled and the extractor set none.
+// The input map is never modified.
+func (s *Server) refererHeaders(headers map[string]string, pageURL string) map[string]string {
+	if !s.config().Server.DefaultReferer {
+		return headers
+	}
+	if hasHeader(headers, "Referer") {
+		return headers
 	}
 
-	// Build filenames
-	ext := filepath.Ext(outputPath)
-	baseName := strings.TrimSuffix(outputPath, ext)
-	videoFile := outputPath
-	audioFile := baseName + "." + audioExt
-
-	// Track progress from both downloads
-	var videoDownloaded, videoTotal int64
-	var audioDownloaded, audioTotal int64
-	var mu sync.Mutex
-
-	reportProgress := func() {
-		if progressFn != nil {
-			mu.Lock()
-			total := videoTotal + audioTotal
-			downloaded := videoDownloaded + audioDownloaded
-			mu.Unlock()
-			if total > 0 {
-				progressFn(downloaded, total)
-			}
-		}
+	origin := urlOrigin(pageURL)
+	if origin == "" {
+		return headers
+	}
+
+	result := make(map[string]string, len(headers)+1)
+	for key, value := range headers {
+		result[key] = value
 	}
+	result["Referer"] = origin + "/"
+	return result
+}
+
+func (s *Server) updateJobFilename(jobID, filename string) {
+	s.jobQueue.updateJob(jobID, func(j *Job) { j.Filename = filename })
+}
+
+// localFiles writes to plain local paths, for transfers ffmpeg works on
+var localFiles = storage.NewLocal("")
+
+// downloadVideoWithAudio downloads video and audio in parallel then merges them with ffmpeg.
+// Both streams draw on the job's one bandwidth share (see copyWithProgress),
+// so together they stay within the job's share of server.rate_limit.
+// outputPath must be local; see assembleLocal.
+func (s *Server) downloadVideoWithAudio(ctx context.Context, format *extractor.VideoFormat, outputPath string, progressFn func(downloaded, total int64)) error {
+	videoFile := outputPath
+	audioFile := audioStreamPath(format.Ext, outputPath)
+
+	// Report combined progress of both downloads
+	progress := newProgressAggregator(progressFn, progressInterval)
+	videoProgress := progress.source()
+	audioProgress := progress.source()
 
 	// Download video and audio in parallel
 	var wg sync.WaitGroup
@@ -784,28 +2359,17 @@ func (s *Server) downloadVideoWithAudio(ctx context.Context, format *extractor.V
 	// Download video stream
 	go func() {
 		defer wg.Done()
-		videoErr = downloadFile(ctx, format.URL, videoFile, format.Headers, func(downloaded, total int64) {
-			mu.Lock()
-			videoDownloaded = downloaded
-			videoTotal = total
-			mu.Unlock()
-			reportProgress()
-		})
+		videoErr = downloadFile(ctx, localFiles, format.URL, videoFile, format.Headers, videoProgress)
 	}()
 
 	// Download audio stream
 	go func() {
 		defer wg.Done()
-		audioErr = downloadFile(ctx, format.AudioURL, audioFile, format.Headers, func(downloaded, total int64) {
-			mu.Lock()
-			audioDownloaded = downloaded
-			audioTotal = total
-			mu.Unlock()
-			reportProgress()
-		})
+		audioErr = downloadFile(ctx, localFiles, format.AudioURL, audioFile, format.AudioRequestHeaders(), audioProgress)
 	}()
 
 	wg.Wait()
+	progress.flush()
 
 	// Check for errors
 	if videoErr != nil {
@@ -817,41 +2381,69 @@ func (s *Server) downloadVideoWithAudio(ctx context.Context, format *extractor.V
 
 	// Try to merge with ffmpeg if available
 	if downloader.FFmpegAvailable() {
-		_, err := downloader.MergeVideoAudioKeepOriginals(videoFile, audioFile)
+		setPhase(ctx, JobPhaseMerging)
+		release, err := s.ffmpeg.acquire(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = downloader.MergeVideoAudioKeepOriginals(videoFile, audioFile)
+		release()
 		if err != nil {
 			// Merge failed but downloads succeeded - log warning but don't fail
-			log.Printf("Warning: ffmpeg merge failed: %v (files kept: %s, %s)", err, videoFile, audioFile)
+			logf(ctx, "Warning: ffmpeg merge failed: %v (files kept: %s, %s)", err, videoFile, audioFile)
+		} else {
+			jobLogf(ctx, "Merged video and audio into %s", outputPath)
 		}
 	} else {
 		// ffmpeg not available - just leave the separate files
-		log.Printf("ffmpeg not found, video and audio saved separately: %s, %s", videoFile, audioFile)
+		logf(ctx, "ffmpeg not found, video and audio saved separately: %s, %s", videoFile, audioFile)
 	}
 
 	return nil
 }
 
-// downloadAndStream extracts and streams the file directly to the response
-func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
-	ext := extractor.Match(url)
-	if ext == nil {
-		sitesConfig, _ := config.LoadSites()
-		if sitesConfig != nil {
-			if site := sitesConfig.MatchSite(url); site != nil {
-				ext = extractor.NewBrowserExtractor(site, false)
-			}
-		}
-		if ext == nil {
-			ext = extractor.NewGenericBrowserExtractor(false)
-		}
+// audioStreamPath returns where downloadVideoWithAudio saves the separate
+// audio stream for a video written to outputPath
+func audioStreamPath(videoExt, outputPath string) string {
+	// Determine audio extension based on video format
+	audioExt := "m4a"
+	if videoExt == "webm" {
+		audioExt = "opus"
 	}
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + "." + audioExt
+}
 
-	if twitterExt, ok := ext.(*extractor.TwitterExtractor); ok {
-		if s.cfg.Twitter.AuthToken != "" {
-			twitterExt.SetAuth(s.cfg.Twitter.AuthToken)
+// downloadAndStream extracts and streams the file directly to the response,
+// or with inline set returns it in the JSON response if it's small enough
+func (s *Server) downloadAndStream(c *gin.Context, url, filename string, opts DownloadOptions, inline bool) {
+	if err := s.checkDomain(url); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errDomainNotAllowed) {
+			status = http.StatusForbidden
 		}
+		c.JSON(status, Response{
+			Code:    status,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
 	}
+	url, _ = extractor.NormalizeURL(url) // Validated by checkDomain above
 
-	media, err := ext.Extract(url)
+	if opts.InsecureSkipVerify {
+		log.Printf("Warning: TLS certificate verification disabled for %s", redactURL(url, s.redactedParams()))
+	}
+	url, err := s.resolveURL(c.Request.Context(), url, opts)
+	if err != nil {
+		c.JSON(http.StatusForbidden, Response{
+			Code:    403,
+			Data:    nil,
+			Message: err.Error(),
+		})
+		return
+	}
+	ext := s.findExtractor(c.Request.Context(), url, opts)
+	media, err := s.extract(c.Request.Context(), ext, url)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -864,6 +2456,8 @@ func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
 	var downloadURL string
 	var headers map[string]string
 	var outputFilename string
+	var hls bool
+	var live bool // A playlist entry without a known duration, capped at server.max_live_duration
 
 	switch m := media.(type) {
 	case *extractor.VideoMedia:
@@ -871,18 +2465,40 @@ func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
 			c.JSON(http.StatusInternalServerError, Response{
 				Code:    500,
 				Data:    nil,
-				Message: "no video formats available",
+				Message: "no video formats available",
+			})
+			return
+		}
+		var format *extractor.VideoFormat
+		if opts.FormatID != "" {
+			format, err = selectFormatByID(m.Formats, opts.FormatID)
+		} else {
+			format, _, err = s.selectFormat(m.Formats, opts.Quality)
+		}
+		if errors.Is(err, errFormatNotAvailable) {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Data:    nil,
+				Message: err.Error(),
 			})
 			return
 		}
-		format := selectBestFormat(m.Formats)
 		downloadURL = format.URL
 		headers = format.Headers
+		hls = format.Ext == "m3u8"
 
 		if filename != "" {
 			outputFilename = filename
 		} else {
-			title := extractor.SanitizeFilename(m.Title)
+			title := s.sanitizeFilename(m.Title)
 			ext := format.Ext
 			if ext == "m3u8" {
 				ext = "ts"
@@ -899,7 +2515,7 @@ func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
 		if filename != "" {
 			outputFilename = filename
 		} else {
-			title := extractor.SanitizeFilename(m.Title)
+			title := s.sanitizeFilename(m.Title)
 			if title != "" {
 				outputFilename = fmt.Sprintf("%s.%s", title, m.Ext)
 			} else {
@@ -916,12 +2532,21 @@ func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
 			})
 			return
 		}
-		img := m.Images[0]
+		selected, err := selectIndices(len(m.Images), opts.Indices)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+		img := m.Images[selected[0]]
 		downloadURL = img.URL
 		if filename != "" {
 			outputFilename = filename
 		} else {
-			title := extractor.SanitizeFilename(m.Title)
+			title := s.sanitizeFilename(m.Title)
 			if title != "" {
 				outputFilename = fmt.Sprintf("%s.%s", title, img.Ext)
 			} else {
@@ -929,6 +2554,46 @@ func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
 			}
 		}
 
+	case *extractor.PlaylistMedia:
+		if len(m.Entries) == 0 {
+			c.JSON(http.StatusInternalServerError, Response{
+				Code:    500,
+				Data:    nil,
+				Message: "playlist is empty",
+			})
+			return
+		}
+		selected, err := selectIndices(len(m.Entries), opts.Indices)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{
+				Code:    400,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+		entry := m.Entries[selected[0]]
+		// Entries are listed by the playlist, not the URL checked above
+		if err := s.checkDomain(entry.URL); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errDomainNotAllowed) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, Response{
+				Code:    status,
+				Data:    nil,
+				Message: err.Error(),
+			})
+			return
+		}
+		downloadURL = entry.URL
+		live = entry.Duration <= 0
+		if filename != "" {
+			outputFilename = filename
+		} else {
+			outputFilename = fmt.Sprintf("%s_%d.%s", m.ID, selected[0]+1, entry.Ext())
+		}
+
 	default:
 		c.JSON(http.StatusInternalServerError, Response{
 			Code:    500,
@@ -938,7 +2603,348 @@ func (s *Server) downloadAndStream(c *gin.Context, url, filename string) {
 		return
 	}
 
-	streamFile(c.Writer, downloadURL, outputFilename, headers)
+	ctx := s.transferContext(c.Request.Context(), opts.InsecureSkipVerify)
+	if !opts.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, opts.Deadline)
+		defer cancel()
+	}
+	if timeout := s.config().Server.StreamTimeoutDuration(); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", errStreamTimeout, timeout))
+		defer cancel()
+	}
+	if live {
+		// Stream the live entry up to the limit and end the response there
+		limit := s.config().Server.MaxLiveDurationLimit()
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, limit, errLiveLimit)
+		defer cancel()
+		defer func() {
+			if liveLimitReached(ctx) {
+				log.Printf("stream %s: stopped after %s (server.max_live_duration)", outputFilename, limit)
+			}
+		}()
+	}
+
+	// Streams share server.rate_limit with running jobs like a job does
+	ctx, release := s.bandwidth.attach(ctx, opts.Weight, jobRateCap(opts.MaxRate, s.config().Server.MaxRateBytes()))
+	defer release()
+
+	headers = s.mediaHeaders(headers, ext.Name(), url)
+	if hls || isHLSURL(downloadURL) {
+		s.streamHLS(ctx, c.Writer, c.Request, downloadURL, outputFilename, headers)
+		return
+	}
+	if inline {
+		inlineFile(ctx, c.Writer, downloadURL, outputFilename, headers, s.writeTimeout(), s.maxInlineSize())
+		return
+	}
+	streamFile(ctx, c.Writer, downloadURL, outputFilename, headers, s.writeTimeout())
+}
+
+// options converts the request's per-download settings into DownloadOptions
+func (r *DownloadRequest) options() (DownloadOptions, error) {
+	var opts DownloadOptions
+
+	indices := append([]int{}, r.Indices...)
+	if r.Range != "" {
+		parsed, err := parseIndexRange(r.Range)
+		if err != nil {
+			return opts, err
+		}
+		indices = append(indices, parsed...)
+	}
+	for _, idx := range indices {
+		if idx < 1 {
+			return opts, fmt.Errorf("invalid index %d: indices are 1-based", idx)
+		}
+	}
+	opts.Indices = indices
+	opts.Quality = config.NormalizeQuality(r.Quality)
+	for _, q := range r.Qualities {
+		if q = config.NormalizeQuality(q); q != "" {
+			opts.Qualities = append(opts.Qualities, q)
+		}
+	}
+	opts.FormatID = strings.TrimSpace(r.FormatID)
+	if opts.FormatID != "" && len(opts.Qualities) > 0 {
+		return opts, fmt.Errorf("format_id cannot be combined with qualities")
+	}
+
+	if r.Weight < 0 || r.Weight > MaxJobWeight {
+		return opts, fmt.Errorf("invalid weight %d: must be between 1 and %d", r.Weight, MaxJobWeight)
+	}
+	opts.Weight = r.Weight
+
+	if r.MaxRateBps < 0 {
+		return opts, fmt.Errorf("invalid max_rate_bps %d: must not be negative", r.MaxRateBps)
+	}
+	opts.MaxRate = r.MaxRateBps
+
+	if r.Extractor != "" && extractor.ByName(r.Extractor) == nil {
+		return opts, fmt.Errorf("unknown extractor %q (available: %s)", r.Extractor, strings.Join(extractor.Names(), ", "))
+	}
+	opts.Extractor = r.Extractor
+
+	if r.StartTime != "" || r.EndTime != "" {
+		var err error
+		if opts.StartTime, err = parseClipTime(r.StartTime); err != nil {
+			return opts, fmt.Errorf("invalid start_time: %s", r.StartTime)
+		}
+		if opts.EndTime, err = parseClipTime(r.EndTime); err != nil {
+			return opts, fmt.Errorf("invalid end_time: %s", r.EndTime)
+		}
+		if opts.EndTime > 0 && opts.EndTime <= opts.StartTime {
+			return opts, fmt.Errorf("end_time must be after start_time")
+		}
+		if !downloader.FFmpegAvailable() {
+			return opts, fmt.Errorf("start_time/end_time require ffmpeg, which was not found in PATH")
+		}
+	}
+
+	if r.Timeout != "" {
+		timeout, err := time.ParseDuration(r.Timeout)
+		if err != nil || timeout <= 0 {
+			return opts, fmt.Errorf("invalid timeout: %s", r.Timeout)
+		}
+		opts.Timeout = timeout
+	}
+
+	return opts, nil
+}
+
+// parseDownloadDeadline parses an X-Download-Deadline header: either an
+// RFC3339 timestamp or a number of seconds from now (fractions allowed)
+func parseDownloadDeadline(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if seconds <= 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) {
+			return time.Time{}, fmt.Errorf("invalid X-Download-Deadline: %s", value)
+		}
+		return now.Add(time.Duration(seconds * float64(time.Second))), nil
+	}
+
+	deadline, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid X-Download-Deadline: %s (want RFC3339 or seconds)", value)
+	}
+	if !deadline.After(now) {
+		return time.Time{}, fmt.Errorf("X-Download-Deadline has already passed: %s", value)
+	}
+	return deadline, nil
+}
+
+// parseClipTime parses a clip boundary given in seconds ("90", "90.5") or
+// as [hh:]mm:ss[.fff] ("01:30", "1:02:03.5"). Empty means unset (0).
+func parseClipTime(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time: %s", value)
+	}
+	var seconds float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		// Only the last field may be fractional; minutes and seconds after
+		// the leading field must be below 60
+		if err != nil || strings.Trim(part, "0123456789.") != "" ||
+			(i < len(parts)-1 && n != math.Trunc(n)) || (i > 0 && n >= 60) {
+			return 0, fmt.Errorf("invalid time: %s", value)
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// formatClipTime renders d as hh:mm:ss, with milliseconds when present
+func formatClipTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	s := fmt.Sprintf("%02d:%02d:%02d", ms/3600000, ms/60000%60, ms/1000%60)
+	if ms%1000 != 0 {
+		s += fmt.Sprintf(".%03d", ms%1000)
+	}
+	return s
+}
+
+// maxRangeIndex bounds the indices a range spec may name, so a spec like
+// "1-2000000000" is rejected before it is expanded
+const maxRangeIndex = 10000
+
+// parseIndexRange parses a 1-based range spec like "3-7,10" into indices
+func parseIndexRange(spec string) ([]int, error) {
+	var indices []int
+	for _, part := range splitList(spec) {
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		if err != nil {
+			return nil, fmt.Errorf("invalid range: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(last)); err != nil {
+				return nil, fmt.Errorf("invalid range: %s", part)
+			}
+		}
+		if start < 1 || end < start {
+			return nil, fmt.Errorf("invalid range: %s", part)
+		}
+		if end > maxRangeIndex {
+			return nil, fmt.Errorf("invalid range: %s (indices above %d are not supported)", part, maxRangeIndex)
+		}
+		for i := start; i <= end; i++ {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
+
+// selectIndices validates 1-based indices against the number of items in a
+// gallery or playlist and returns the matching 0-based positions in order,
+// without duplicates. An empty selection means every item.
+func selectIndices(count int, indices []int) ([]int, error) {
+	if len(indices) == 0 {
+		selected := make([]int, count)
+		for i := range selected {
+			selected[i] = i
+		}
+		return selected, nil
+	}
+
+	seen := make(map[int]bool)
+	var selected []int
+	for _, idx := range indices {
+		if idx < 1 || idx > count {
+			return nil, fmt.Errorf("index %d out of range (source has %d items)", idx, count)
+		}
+		if !seen[idx] {
+			seen[idx] = true
+			selected = append(selected, idx-1)
+		}
+	}
+	sort.Ints(selected)
+	return selected, nil
+}
+
+// errNoAcceptableQuality is returned when every format is below min_height
+var errNoAcceptableQuality = errors.New("no acceptable quality available")
+
+// selectFormat picks the format for the requested quality (or the configured
+// default), walking down the quality ladder when that rung isn't offered and
+// falling back to the best format. It also returns the quality it settled on.
+// Formats below min_height are ignored; if none remain, it fails with
+// errNoAcceptableQuality.
+func (s *Server) selectFormat(formats []extractor.VideoFormat, quality string) (*extractor.VideoFormat, string, error) {
+	cfg := s.config()
+	if quality == "" {
+		quality = cfg.Quality
+	}
+
+	if cfg.MinHeight > 0 {
+		var acceptable []extractor.VideoFormat
+		for _, f := range formats {
+			if height := formatHeight(f); height == 0 || height >= cfg.MinHeight {
+				acceptable = append(acceptable, f)
+			}
+		}
+		if len(acceptable) == 0 {
+			return nil, "", fmt.Errorf("%w (min_height %dp)", errNoAcceptableQuality, cfg.MinHeight)
+		}
+		formats = acceptable
+	}
+
+	for _, candidate := range cfg.QualityCandidates(quality) {
+		var matches []extractor.VideoFormat
+		for _, f := range formats {
+			if formatHasQuality(f, candidate) {
+				matches = append(matches, f)
+			}
+		}
+		if len(matches) > 0 {
+			return selectBestFormat(matches), candidate, nil
+		}
+	}
+
+	best := selectBestFormat(formats)
+	return best, formatQuality(best), nil
+}
+
+// formatQuality returns the normalized quality label of a format, or
+// "best" when it has none
+func formatQuality(f *extractor.VideoFormat) string {
+	if label := f.QualityLabel(); label != "unknown" {
+		return config.NormalizeQuality(label)
+	}
+	return "best"
+}
+
+// errFormatNotAvailable is returned when a requested format_id isn't offered
+var errFormatNotAvailable = errors.New("format not available")
+
+// selectFormatByID returns the format with the given ID (see
+// extractor.FormatIDs), or an error listing the IDs on offer
+func selectFormatByID(formats []extractor.VideoFormat, id string) (*extractor.VideoFormat, error) {
+	ids := extractor.FormatIDs(formats)
+	for i, candidate := range ids {
+		if strings.EqualFold(candidate, id) {
+			return &formats[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q (available: %s)", errFormatNotAvailable, id, strings.Join(ids, ", "))
+}
+
+// fallbackFormats returns up to limit formats to try, best first, when
+// selected fails to download. Formats taller than selected or below
+// min_height are left out, so a fallback never goes above the chosen
+// quality; formats of unknown height come last.
+func (s *Server) fallbackFormats(formats []extractor.VideoFormat, selected *extractor.VideoFormat, limit int) []*extractor.VideoFormat {
+	minHeight := s.config().MinHeight
+	maxHeight := formatHeight(*selected)
+
+	var candidates []*extractor.VideoFormat
+	for i := range formats {
+		f := &formats[i]
+		if f.URL == selected.URL && f.AudioURL == selected.AudioURL {
+			continue
+		}
+		if height := formatHeight(*f); height > 0 && ((maxHeight > 0 && height > maxHeight) || height < minHeight) {
+			continue
+		}
+		candidates = append(candidates, f)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		hi, hj := formatHeight(*candidates[i]), formatHeight(*candidates[j])
+		if hi != hj {
+			return hi > hj
+		}
+		return candidates[i].Bitrate > candidates[j].Bitrate
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// formatHeight returns a format's height, taken from its quality label when
+// the extractor didn't report one, or 0 if unknown
+func formatHeight(f extractor.VideoFormat) int {
+	if f.Height > 0 {
+		return f.Height
+	}
+	return config.QualityHeight(f.Quality)
+}
+
+// formatHasQuality reports whether a format matches a normalized quality label
+func formatHasQuality(f extractor.VideoFormat, quality string) bool {
+	if f.Quality != "" && config.NormalizeQuality(f.Quality) == quality {
+		return true
+	}
+	return f.Height > 0 && fmt.Sprintf("%dp", f.Height) == quality
 }
 
 func selectBestFormat(formats []extractor.VideoFormat) *extractor.VideoFormat {
@@ -968,60 +2974,179 @@ func selectBestFormat(formats []extractor.VideoFormat) *extractor.VideoFormat {
 	return best
 }
 
-func downloadFile(ctx context.Context, url, outputPath string, headers map[string]string, progressFn func(downloaded, total int64)) error {
-	client := &http.Client{
-		Timeout: 0,
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-		},
-	}
+// downloadFile fetches url into outputPath on st, retrying transient
+// failures with the retry policy of ctx (see transferContext). On storage
+// that keeps partial files, a transfer interrupted earlier, or by a failed
+// attempt, continues where it stopped if the server supports ranges.
+func downloadFile(ctx context.Context, st storage.Storage, url, outputPath string, headers map[string]string, progressFn func(downloaded, total int64)) error {
+	return downloader.RetryContext(ctx, func() error {
+		err := fetchFile(ctx, st, url, headers, progressFn, func(*http.Response) string { return outputPath }, outputPath)
+		if err != nil && !transientError(err) {
+			return downloader.Permanent(err)
+		}
+		return err
+	})
+}
+
+// fetchFile is downloadFile with the output path chosen by outputPath once
+// the response headers are in. resumePath is the output path when it's
+// known up front, so its partial file can be resumed ("" starts over).
+func fetchFile(ctx context.Context, st storage.Storage, url string, headers map[string]string, progressFn func(downloaded, total int64), outputPath func(resp *http.Response) string, resumePath string) error {
+	client := newDownloadClient(ctx)
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	if len(headers) > 0 {
-		for key, value := range headers {
-			req.Header.Set(key, value)
+	// Custom headers override the default User-Agent
+	req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	// Resume only a partial file whose validator is known, and only if the
+	// remote file still matches it (If-Range), so bytes of two different
+	// versions never end up in one file
+	resumer, _ := st.(storage.Resumer)
+	var offset int64
+	var validator string
+	if resumer != nil && resumePath != "" {
+		if offset = resumer.PartialSize(resumePath); offset > 0 {
+			if validator = resumer.PartialValidator(resumePath); validator == "" {
+				discardResume(ctx, resumePath, offset, "no ETag or Last-Modified was saved with it")
+				offset = 0
+			}
 		}
-	} else {
-		req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		req.Header.Set("If-Range", validator)
 	}
 
+	// Hold off while the host's reported rate limit is running out
+	if err := extractor.WaitRateLimit(ctx, req.URL.Host); err != nil {
+		return err
+	}
 	resp, err := client.Do(req)
+	extractor.ObserveRateLimit(resp)
+	if errors.Is(err, errTooManyRedirects) {
+		return errors.Unwrap(err) // Without the url.Error's "Get <url>:" prefix
+	}
 	if err != nil {
 		return fmt.Errorf("download request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status %d", resp.StatusCode)
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent && responseValidator(resp) != "" && responseValidator(resp) != validator:
+		// A server that ignores If-Range sent the rest of another version
+		resp.Body.Close()
+		discardResume(ctx, resumePath, offset, "the remote file changed")
+		return fetchFile(ctx, st, url, headers, progressFn, outputPath, "")
+	case offset > 0 && (resp.StatusCode == http.StatusPartialContent || resp.StatusCode == http.StatusRequestedRangeNotSatisfiable):
+		switch resumeAction(resp, offset) {
+		case resumeComplete:
+			file, err := resumer.Append(resumePath)
+			if err != nil {
+				return fmt.Errorf("failed to open partial file: %w", err)
+			}
+			if err := file.Close(); err != nil {
+				return fmt.Errorf("failed to save file: %w", err)
+			}
+			return nil
+		case resumeRestart:
+			resp.Body.Close()
+			discardResume(ctx, resumePath, offset, fmt.Sprintf("the server's %d answer doesn't continue it", resp.StatusCode))
+			return fetchFile(ctx, st, url, headers, progressFn, outputPath, "")
+		}
+		logf(ctx, "Resuming %s at %d bytes", filepath.Base(resumePath), offset)
+	case resp.StatusCode != http.StatusOK:
+		return &downloader.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("download failed with status %d", resp.StatusCode)}
+	default:
+		if offset > 0 {
+			// If-Range failed or the server ignores ranges; start over
+			reason := "the server doesn't support ranges"
+			if v := responseValidator(resp); v != "" && v != validator {
+				reason = "the remote file changed"
+			}
+			discardResume(ctx, resumePath, offset, reason)
+			offset = 0
+		}
+		if !rawEncodingFrom(ctx) {
+			if err := decodeContentEncoding(resp); err != nil {
+				return err
+			}
+		}
 	}
 
-	total := resp.ContentLength
+	check, hasCheck := mediaCheckFrom(ctx)
+	if hasCheck {
+		if err := check.response(resp); err != nil {
+			return &mediaCheckError{err}
+		}
+	}
 
-	file, err := os.Create(outputPath)
+	var file storage.Writer
+	if offset > 0 {
+		file, err = resumer.Append(resumePath)
+	} else {
+		name := outputPath(resp)
+		if file, err = st.Create(name); err == nil && resumer != nil {
+			if v := responseValidator(resp); v != "" {
+				if err := resumer.SetPartialValidator(name, v); err != nil {
+					logf(ctx, "Warning: failed to save resume validator for %s: %v", filepath.Base(name), err)
+				}
+			}
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
-	defer file.Close()
+	progress := progressFn
+	if offset > 0 && progressFn != nil {
+		progress = func(downloaded, total int64) { progressFn(offset+downloaded, total) }
+	}
+	downloaded, err := copyWithProgress(ctx, file, resp.Body, resp.ContentLength, progress)
+	if err == nil && hasCheck {
+		err = check.size(offset + downloaded)
+	}
+	if err != nil {
+		file.Abort()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to save file: %w", err)
+	}
+	return nil
+}
 
+// copyWithProgress copies a download body to w under the job's bandwidth
+// share, reporting progress after every chunk. It returns the bytes copied.
+// A live recording cut off at its limit ends without error.
+func copyWithProgress(ctx context.Context, w io.Writer, body io.Reader, total int64, progressFn func(downloaded, total int64)) (downloaded int64, err error) {
+	defer func() {
+		if err != nil && context.Cause(ctx) == errLiveLimit {
+			err = nil
+		}
+	}()
 	buf := make([]byte, 32*1024)
-	var downloaded int64
 
 	for {
 		select {
 		case <-ctx.Done():
-			return ctx.Err()
+			return downloaded, ctx.Err()
 		default:
 		}
 
-		n, readErr := resp.Body.Read(buf)
+		n, readErr := body.Read(buf)
 		if n > 0 {
-			_, writeErr := file.Write(buf[:n])
+			if err := throttle(ctx, n); err != nil {
+				return downloaded, err
+			}
+			_, writeErr := w.Write(buf[:n])
 			if writeErr != nil {
-				return fmt.Errorf("failed to write file: %w", writeErr)
+				return downloaded, fmt.Errorf("failed to write file: %w", writeErr)
 			}
 			downloaded += int64(n)
 			if progressFn != nil {
@@ -1032,46 +3157,110 @@ func downloadFile(ctx context.Context, url, outputPath string, headers map[strin
 			break
 		}
 		if readErr != nil {
-			return fmt.Errorf("download failed: %w", readErr)
+			return downloaded, fmt.Errorf("download failed: %w", readErr)
 		}
 	}
 
-	return nil
+	return downloaded, nil
 }
 
-func streamFile(w http.ResponseWriter, url, filename string, headers map[string]string) {
-	client := &http.Client{
-		Timeout: 0,
-		Transport: &http.Transport{
-			Proxy: http.ProxyFromEnvironment,
-		},
+func streamFile(ctx context.Context, w http.ResponseWriter, url, filename string, headers map[string]string, writeTimeout time.Duration) {
+	resp := openUpstream(ctx, w, url, filename, headers)
+	if resp == nil {
+		return
 	}
+	defer resp.Body.Close()
+	copyUpstream(ctx, w, resp, throttledBody{ctx, resp.Body}, filename, writeTimeout)
+}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		http.Error(w, "failed to create request", http.StatusInternalServerError)
+// inlineFile downloads url and, if it's no larger than limit, writes it
+// base64-encoded into a JSON response. Larger files are streamed like
+// streamFile; the bytes already read are sent first.
+func inlineFile(ctx context.Context, w http.ResponseWriter, url, filename string, headers map[string]string, writeTimeout time.Duration, limit int64) {
+	resp := openUpstream(ctx, w, url, filename, headers)
+	if resp == nil {
 		return
 	}
+	defer resp.Body.Close()
 
-	if len(headers) > 0 {
-		for key, value := range headers {
-			req.Header.Set(key, value)
+	var body io.Reader = throttledBody{ctx, resp.Body}
+	if resp.ContentLength <= limit {
+		data, err := io.ReadAll(io.LimitReader(body, limit+1))
+		if err != nil && !liveLimitReached(ctx) {
+			logStreamAbort(ctx, filename, "upstream read", err)
+			switch {
+			case errors.Is(ctx.Err(), context.DeadlineExceeded):
+				http.Error(w, "deadline exceeded", http.StatusGatewayTimeout)
+			case !isClientGone(ctx, err):
+				http.Error(w, "upstream read failed", http.StatusBadGateway)
+			}
+			return
 		}
-	} else {
-		req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+		if int64(len(data)) <= limit {
+			contentType := resp.Header.Get("Content-Type")
+			if contentType == "" {
+				contentType = http.DetectContentType(data)
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(Response{
+				Code: 200,
+				Data: gin.H{
+					"filename":     filename,
+					"content_type": contentType,
+					"size":         len(data),
+					"content":      base64.StdEncoding.EncodeToString(data),
+				},
+				Message: "inline file",
+			})
+			return
+		}
+		body = io.MultiReader(bytes.NewReader(data), body)
+	}
+	copyUpstream(ctx, w, resp, body, filename, writeTimeout)
+}
+
+// openUpstream requests url for streaming to w as filename. On failure it writes the
+// error response to w and returns nil.
+func openUpstream(ctx context.Context, w http.ResponseWriter, url, filename string, headers map[string]string) *http.Response {
+	client := newDownloadClient(ctx)
+
+	// Tie the upstream request to the client so a disconnect stops the download
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		http.Error(w, "failed to create request", http.StatusInternalServerError)
+		return nil
+	}
+
+	// Custom headers override the default User-Agent
+	req.Header.Set("User-Agent", downloader.DefaultUserAgent)
+	for key, value := range headers {
+		req.Header.Set(key, value)
 	}
 
 	resp, err := client.Do(req)
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			logStreamAbort(ctx, filename, "upstream request", err)
+			http.Error(w, "deadline exceeded", http.StatusGatewayTimeout)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil // Client went away before upstream answered
+		}
 		http.Error(w, "download request failed", http.StatusBadGateway)
-		return
+		return nil
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		http.Error(w, fmt.Sprintf("upstream returned status %d", resp.StatusCode), http.StatusBadGateway)
-		return
+		return nil
 	}
+	return resp
+}
+
+// copyUpstream streams body, the content of resp, to w as an attachment
+func copyUpstream(ctx context.Context, w http.ResponseWriter, resp *http.Response, body io.Reader, filename string, writeTimeout time.Duration) {
 
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
 	if resp.ContentLength > 0 {
@@ -1081,5 +3270,79 @@ func streamFile(w http.ResponseWriter, url, filename string, headers map[string]
 		w.Header().Set("Content-Type", contentType)
 	}
 
-	io.Copy(w, resp.Body)
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	lastFlush := time.Now()
+
+	for {
+		n, readErr := body.Read(buf)
+		if n > 0 {
+			// Push the deadline forward so a server write timeout only
+			// catches stalled writes, not long transfers
+			if writeTimeout > 0 {
+				rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				logStreamAbort(ctx, filename, "write", err)
+				return
+			}
+			// Flush regularly so proxies see progress and keep the connection alive
+			if time.Since(lastFlush) >= time.Second {
+				if err := rc.Flush(); err != nil && isClientGone(ctx, err) {
+					return
+				}
+				lastFlush = time.Now()
+			}
+		}
+		if readErr == io.EOF || (readErr != nil && liveLimitReached(ctx)) {
+			rc.Flush()
+			return
+		}
+		if readErr != nil {
+			logStreamAbort(ctx, filename, "upstream read", readErr)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				// Drop the connection so the client sees a cut-off body
+				// instead of a complete (chunked) response
+				rc.SetWriteDeadline(time.Now())
+			}
+			return
+		}
+	}
+}
+
+// errStreamTimeout is the cause of a synchronous stream cut off by
+// server.stream_timeout
+var errStreamTimeout = errors.New("stream timeout exceeded")
+
+// liveLimitReached reports whether a live stream was stopped at
+// server.max_live_duration, which ends it like the upstream closing would
+func liveLimitReached(ctx context.Context) bool {
+	return context.Cause(ctx) == errLiveLimit
+}
+
+// logStreamAbort logs why a synchronous stream of filename stopped early:
+// server.stream_timeout, the request's deadline, the client disconnecting,
+// or op failing
+func logStreamAbort(ctx context.Context, filename, op string, err error) {
+	switch {
+	case errors.Is(context.Cause(ctx), errStreamTimeout):
+		log.Printf("stream %s: %v", filename, context.Cause(ctx))
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		log.Printf("stream %s: deadline exceeded", filename)
+	case isClientGone(ctx, err):
+		log.Printf("stream %s: client disconnected", filename)
+	default:
+		log.Printf("stream %s: %s failed: %v", filename, op, err)
+	}
+}
+
+// isClientGone reports whether err is caused by the client disconnecting
+// (cancelled request, broken pipe, connection reset) rather than a server fault
+func isClientGone(ctx context.Context, err error) bool {
+	return ctx.Err() != nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, http.ErrHandlerTimeout) ||
+		errors.Is(err, os.ErrDeadlineExceeded)
 }
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveShorteners(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Server.ResolveShorteners = true
+	s.cfg.Server.BlockedDomains = []string{"*.blocked.com"}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/s/hop":
+			http.Redirect(w, r, "/s/clip", http.StatusMovedPermanently)
+		case "/s/clip":
+			http.Redirect(w, r, "/media/clip.mp4", http.StatusFound)
+		case "/s/blocked":
+			http.Redirect(w, r, "https://cdn.blocked.com/a.mp4", http.StatusFound)
+		case "/media/clip.mp4":
+			w.Header().Set("Content-Type", "video/mp4")
+			fmt.Fprint(w, "video-bytes")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(ts.Close)
+	host, _ := url.Parse(ts.URL)
+	shortenerHosts[host.Hostname()] = true
+	t.Cleanup(func() { delete(shortenerHosts, host.Hostname()) })
+
+	resolved, err := s.resolveURL(context.Background(), ts.URL+"/s/hop", DownloadOptions{})
+	if err != nil || resolved != ts.URL+"/media/clip.mp4" {
+		t.Errorf("resolveURL = %q, %v; want %s/media/clip.mp4", resolved, err, ts.URL)
+	}
+
+	// The destination picks the extractor, so the direct file is downloaded
+	w := doRequest(s, "POST", "/api/download", jsonBody{"url": ts.URL + "/s/clip"}, nil)
+	id, _ := decodeData(t, w)["id"].(string)
+	waitForStatus(t, s.jobQueue, id, JobStatusCompleted)
+	if data, err := os.ReadFile(filepath.Join(s.outputDir, "clip.mp4")); err != nil || string(data) != "video-bytes" {
+		t.Errorf("clip.mp4 = %q, %v", data, err)
+	}
+
+	if _, err := s.resolveURL(context.Background(), ts.URL+"/s/blocked", DownloadOptions{}); !errors.Is(err, errDomainNotAllowed) {
+		t.Errorf("redirect to blocked domain: err = %v; want errDomainNotAllowed", err)
+	}
+
+	// Off by default
+	s.cfg.Server.ResolveShorteners = false
+	if resolved, _ := s.resolveURL(context.Background(), ts.URL+"/s/hop", DownloadOptions{}); resolved != ts.URL+"/s/hop" {
+		t.Errorf("disabled resolveURL = %q; want the URL unchanged", resolved)
+	}
+}
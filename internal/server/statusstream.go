@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statusStreamKeepalive is how often an idle status stream sends a comment
+// line, so proxies don't close it between progress updates
+const statusStreamKeepalive = 15 * time.Second
+
+// jobWatchers holds the change channels of open status streams, by job ID
+type jobWatchers map[string]map[chan struct{}]struct{}
+
+// watchJob returns a channel that receives a value after the job with the
+// given ID changes, plus a func to stop watching. Changes made while the
+// previous one is still unread are coalesced.
+func (jq *JobQueue) watchJob(id string) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	jq.mu.Lock()
+	if jq.watchers[id] == nil {
+		jq.watchers[id] = make(map[chan struct{}]struct{})
+	}
+	jq.watchers[id][ch] = struct{}{}
+	jq.mu.Unlock()
+
+	return ch, func() {
+		jq.mu.Lock()
+		defer jq.mu.Unlock()
+		delete(jq.watchers[id], ch)
+		if len(jq.watchers[id]) == 0 {
+			delete(jq.watchers, id)
+		}
+	}
+}
+
+// jobChanged wakes the status streams of a job; callers hold jq.mu
+func (jq *JobQueue) jobChanged(id string) {
+	for ch := range jq.watchers[id] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// statusStreamEvent is the data of one event of GET /api/status/:id/stream
+type statusStreamEvent struct {
+	Status     JobStatus `json:"status"`
+	Progress   float64   `json:"progress"`
+	Downloaded int64     `json:"downloaded"`
+	Total      int64     `json:"total"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// handleStatusStream sends a job's progress as Server-Sent Events: one
+// event now and one on each change, until the job finishes or the client
+// goes away
+func (s *Server) handleStatusStream(c *gin.Context) {
+	id := c.Param("id")
+
+	// Watch before the first read so no change falls in between
+	changes, stop := s.jobQueue.watchJob(id)
+	defer stop()
+
+	job := s.jobQueue.GetJob(id)
+	if job == nil {
+		c.JSON(http.StatusNotFound, Response{
+			Code:    404,
+			Data:    nil,
+			Message: "job not found",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+
+	w := &deadlineWriter{ResponseWriter: c.Writer, timeout: s.writeTimeout()} // Only stalled writes time out
+	keepalive := time.NewTicker(statusStreamKeepalive)
+	defer keepalive.Stop()
+	var last *statusStreamEvent
+	for {
+		event := statusStreamEvent{
+			Status:     job.Status,
+			Progress:   job.Progress,
+			Downloaded: job.Downloaded,
+			Total:      job.Total,
+			Error:      job.Error,
+		}
+		if last == nil || event != *last {
+			data, _ := json.Marshal(event)
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			c.Writer.Flush()
+			last = &event
+		}
+		if isFinished(job.Status) {
+			return
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-changes:
+			if job = s.jobQueue.GetJob(id); job == nil {
+				return // Removed
+			}
+		}
+	}
+}
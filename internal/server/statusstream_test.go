@@ -0,0 +1,77 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusStream(t *testing.T) {
+	s := newTestServer(t, "")
+	release := make(chan struct{})
+	s.jobQueue.downloadFn = func(ctx context.Context, jobID, url, filename string, opts DownloadOptions, progressFn func(downloaded, total int64)) error {
+		progressFn(5, 10)
+		<-release
+		progressFn(10, 10)
+		return nil
+	}
+	ts := httptest.NewServer(s.engine)
+	t.Cleanup(ts.Close)
+
+	if w := doRequest(s, "GET", "/api/status/missing/stream", nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("unknown job = %d; want 404", w.Code)
+	}
+
+	job, _ := s.jobQueue.AddJob("https://example.com/a.mp4", "", DownloadOptions{})
+	waitForStatus(t, s.jobQueue, job.ID, JobStatusDownloading)
+	for s.jobQueue.GetJob(job.ID).Downloaded != 5 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	resp, err := http.Get(ts.URL + "/api/status/" + job.ID + "/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q; want text/event-stream", ct)
+	}
+
+	// Read events until the server closes the stream at completion
+	var events []statusStreamEvent
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var event statusStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			t.Fatalf("event %q: %v", data, err)
+		}
+		events = append(events, event)
+		if len(events) == 1 {
+			close(release)
+		}
+	}
+
+	if len(events) < 2 {
+		t.Fatalf("events = %+v; want at least 2", events)
+	}
+	if first := events[0]; first.Status != JobStatusDownloading || first.Downloaded != 5 || first.Total != 10 {
+		t.Errorf("first event = %+v; want downloading 5/10", first)
+	}
+	if last := events[len(events)-1]; last.Status != JobStatusCompleted || last.Downloaded != 10 {
+		t.Errorf("last event = %+v; want completed 10/10", last)
+	}
+
+	s.jobQueue.mu.RLock()
+	defer s.jobQueue.mu.RUnlock()
+	if n := len(s.jobQueue.watchers); n != 0 {
+		t.Errorf("watchers after the stream closed = %d; want 0", n)
+	}
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"context"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// JobTimings breaks a job's run time down by phase, in seconds, to show
+// whether a slow job spent its time extracting, downloading or in ffmpeg
+type JobTimings struct {
+	Extraction     float64 `json:"extraction"`      // Extracting media info and planning the files
+	Download       float64 `json:"download"`        // Transferring media, excluding post-processing
+	PostProcessing float64 `json:"post_processing"` // ffmpeg merges, remuxes, clips and chapters, including waits for a free slot
+	Upload         float64 `json:"upload"`          // Copying to destination.*
+}
+
+type ffmpegTimeKey struct{}
+
+// withFFmpegTimer returns ctx recording the time spent holding or waiting
+// for ffmpeg slots (see ffmpegLimiter.acquire) in the returned counter
+func withFFmpegTimer(ctx context.Context) (context.Context, *atomic.Int64) {
+	var elapsed atomic.Int64
+	return context.WithValue(ctx, ffmpegTimeKey{}, &elapsed), &elapsed
+}
+
+// addFFmpegTime adds d to ctx's ffmpeg timer, if it has one
+func addFFmpegTime(ctx context.Context, d time.Duration) {
+	if elapsed, ok := ctx.Value(ffmpegTimeKey{}).(*atomic.Int64); ok {
+		elapsed.Add(int64(d))
+	}
+}
+
+// phaseSeconds converts a phase duration to seconds (see roundSeconds)
+func phaseSeconds(d time.Duration) float64 {
+	return roundSeconds(d.Seconds())
+}
+
+// roundSeconds rounds a timing to the millisecond
+func roundSeconds(seconds float64) float64 {
+	return math.Round(seconds*1000) / 1000
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/guiyumin/vget/internal/core/config"
+	"github.com/guiyumin/vget/internal/core/downloader"
+	"github.com/guiyumin/vget/internal/core/extractor"
+)
+
+type insecureTLSKey struct{}
+
+type forceHTTP1Key struct{}
+
+type minTLSVersionKey struct{}
+
+type rawEncodingKey struct{}
+
+type redirectPolicyKey struct{}
+
+// errTooManyRedirects fails a media transfer whose redirects run past
+// server.max_redirects, including redirect loops
+var errTooManyRedirects = errors.New("too many redirects")
+
+// redirectPolicy is the CheckRedirect of media transfers. Go itself drops
+// credentials (Authorization, Cookie) on redirects to another domain.
+type redirectPolicy struct {
+	max    int                     // Redirect that fails the transfer, as in Go's default client
+	log    bool                    // Log every hop (server.log_redirects)
+	redact func(url string) string // Hides secrets in logged URLs
+}
+
+func (p redirectPolicy) check(req *http.Request, via []*http.Request) error {
+	if len(via) >= p.max {
+		return fmt.Errorf("%w (max %d)", errTooManyRedirects, p.max)
+	}
+	if p.log {
+		from := via[len(via)-1]
+		logf(req.Context(), "Redirect %d: %s -> %s (%d)", len(via), p.redact(from.URL.String()), p.redact(req.URL.String()), req.Response.StatusCode)
+	}
+	return nil
+}
+
+// redirectPolicyFrom returns the redirect policy ctx was marked with by
+// transferContext, or Go's default limit without logging
+func redirectPolicyFrom(ctx context.Context) redirectPolicy {
+	if policy, ok := ctx.Value(redirectPolicyKey{}).(redirectPolicy); ok {
+		return policy
+	}
+	return redirectPolicy{max: config.DefaultMaxRedirects}
+}
+
+// withInsecureTLS marks ctx so media transfers made with it skip TLS
+// certificate verification
+func withInsecureTLS(ctx context.Context) context.Context {
+	return context.WithValue(ctx, insecureTLSKey{}, true)
+}
+
+// insecureTLSFrom reports whether ctx was marked by withInsecureTLS
+func insecureTLSFrom(ctx context.Context) bool {
+	insecure, _ := ctx.Value(insecureTLSKey{}).(bool)
+	return insecure
+}
+
+// withForceHTTP1 marks ctx so media transfers made with it don't negotiate
+// HTTP/2 (server.force_http1)
+func withForceHTTP1(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceHTTP1Key{}, true)
+}
+
+// forceHTTP1From reports whether ctx was marked by withForceHTTP1
+func forceHTTP1From(ctx context.Context) bool {
+	force, _ := ctx.Value(forceHTTP1Key{}).(bool)
+	return force
+}
+
+// minTLSVersionFrom returns the minimum TLS version ctx was marked with by
+// transferContext (0 = Go's default)
+func minTLSVersionFrom(ctx context.Context) uint16 {
+	version, _ := ctx.Value(minTLSVersionKey{}).(uint16)
+	return version
+}
+
+// rawEncodingFrom reports whether ctx was marked by transferContext to save
+// media bodies as sent, without decoding their Content-Encoding
+// (server.raw_content_encoding)
+func rawEncodingFrom(ctx context.Context) bool {
+	raw, _ := ctx.Value(rawEncodingKey{}).(bool)
+	return raw
+}
+
+// transferContext marks ctx with the server's transport settings for the
+// media transfers of one job or request
+func (s *Server) transferContext(ctx context.Context, insecure bool) context.Context {
+	if insecure {
+		ctx = withInsecureTLS(ctx)
+	}
+	if s.config().Server.ForceHTTP1 {
+		ctx = withForceHTTP1(ctx)
+	}
+	if s.config().Server.RawContentEncoding {
+		ctx = context.WithValue(ctx, rawEncodingKey{}, true)
+	}
+	params := s.redactedParams()
+	ctx = context.WithValue(ctx, redirectPolicyKey{}, redirectPolicy{
+		max:    s.config().Server.RedirectLimit(),
+		log:    s.config().Server.LogRedirects,
+		redact: func(url string) string { return redactURL(url, params) },
+	})
+	ctx = downloader.WithRetryPolicy(ctx, s.retryPolicy())
+	return context.WithValue(ctx, minTLSVersionKey{}, s.config().Server.TLSMinVersion())
+}
+
+// transientError reports whether a transfer or extraction that failed with
+// err may succeed if retried: a network error, a truncated body, or a 5xx,
+// 408 or 429 status. The domain policy, redirect limit, login walls and
+// media checks fail the same way every time.
+func transientError(err error) bool {
+	var checkErr *mediaCheckError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, errDomainNotAllowed), errors.Is(err, errTooManyRedirects),
+		errors.Is(err, extractor.ErrLoginRequired), errors.As(err, &checkErr):
+		return false
+	}
+	var statusErr *downloader.StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		return code >= 500 || code == http.StatusTooManyRequests || code == http.StatusRequestTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// newDownloadClient returns the HTTP client for a media transfer. It has no
+// overall timeout since transfers can be long; ctx bounds them instead.
+func newDownloadClient(ctx context.Context) *http.Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: insecureTLSFrom(ctx),
+			MinVersion:         minTLSVersionFrom(ctx),
+		},
+		// A custom TLS config would otherwise turn HTTP/2 off
+		ForceAttemptHTTP2: true,
+		// Don't let the transport decode gzip it asked for either
+		DisableCompression: rawEncodingFrom(ctx),
+	}
+	if forceHTTP1From(ctx) {
+		downloader.ForceHTTP1(transport)
+	}
+	return &http.Client{Transport: transport, CheckRedirect: redirectPolicyFrom(ctx).check}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/guiyumin/vget/internal/core/config"
+)
+
+func TestMinTLSVersion(t *testing.T) {
+	s := newTestServer(t, "")
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	get := func() error {
+		ctx := s.transferContext(context.Background(), true)
+		resp, err := newDownloadClient(ctx).Get(ts.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	if err := get(); err != nil {
+		t.Errorf("TLS 1.2 server with the default minimum: %v", err)
+	}
+	s.cfg.Server.MinTLSVersion = "1.3"
+	if err := get(); err == nil {
+		t.Error("TLS 1.2 server with min_tls_version 1.3 succeeded")
+	}
+
+	var cfg config.Config
+	if err := s.setConfigValue(&cfg, "server.min_tls_version", "1.1"); err == nil {
+		t.Error("min_tls_version 1.1 accepted")
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Server.MaxRedirects = 3
+	s.cfg.Server.LogRedirects = true
+	var requests atomic.Int32
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch {
+		case r.URL.Path == "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case strings.HasPrefix(r.URL.Path, "/hop/"):
+			// /hop/N takes N more redirects to reach the file
+			n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+			if n > 0 {
+				http.Redirect(w, r, fmt.Sprintf("/hop/%d?token=secret", n-1), http.StatusFound)
+				return
+			}
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write([]byte("bytes"))
+		}
+	}))
+	t.Cleanup(media.Close)
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	ctx := s.transferContext(context.Background(), false)
+	dir := t.TempDir()
+	if err := downloadFile(ctx, localFiles, media.URL+"/hop/2", filepath.Join(dir, "a.mp4"), nil, nil); err != nil {
+		t.Fatalf("2 redirects with max_redirects 3: %v", err)
+	}
+	if hops := strings.Count(buf.String(), "Redirect "); hops != 2 || strings.Contains(buf.String(), "secret") {
+		t.Errorf("log = %q; want 2 redirect hops with redacted URLs", buf.String())
+	}
+	if n := requests.Swap(0); n != 3 {
+		t.Errorf("2 redirects took %d requests; want 3", n)
+	}
+
+	// The third redirect fails, as in Go's default client
+	err := downloadFile(ctx, localFiles, media.URL+"/hop/3", filepath.Join(dir, "b.mp4"), nil, nil)
+	if err == nil || err.Error() != "too many redirects (max 3)" {
+		t.Errorf("3 redirects with max_redirects 3 = %v; want too many redirects", err)
+	}
+	if n := requests.Swap(0); n != 3 {
+		t.Errorf("failed transfer made %d requests; want 3", n)
+	}
+	if err := downloadFile(ctx, localFiles, media.URL+"/loop", filepath.Join(dir, "c.mp4"), nil, nil); !errors.Is(err, errTooManyRedirects) {
+		t.Errorf("redirect loop = %v; want too many redirects", err)
+	}
+	if n := requests.Swap(0); n != 3 {
+		t.Errorf("redirect loop made %d requests; want 3", n)
+	}
+}
+
+func TestProbeDirectPolicy(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Server.BlockedDomains = []string{"*.blocked.com"}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, "https://cdn.blocked.com/a.mp4", http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Type", "video/mp4")
+	}))
+	ts.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+
+	opts := DownloadOptions{InsecureSkipVerify: true}
+	if ext := s.probeDirect(context.Background(), ts.URL+"/clip", opts); ext == nil || ext.Name() != "direct" {
+		t.Errorf("probe = %v; want direct", ext)
+	}
+	if ext := s.probeDirect(context.Background(), ts.URL+"/away", opts); ext != nil {
+		t.Errorf("probe redirected to a blocked domain = %s; want nil", ext.Name())
+	}
+	s.cfg.Server.MinTLSVersion = "1.3"
+	if ext := s.probeDirect(context.Background(), ts.URL+"/clip", opts); ext != nil {
+		t.Errorf("probe of a TLS 1.2 server with min_tls_version 1.3 = %s; want nil", ext.Name())
+	}
+}
+
+func TestDownloadRetries(t *testing.T) {
+	s := newTestServer(t, "")
+	s.cfg.Retry = config.RetryConfig{BaseDelay: "1ms", MaxDelay: "1ms", MaxAttempts: 3}
+	var requests atomic.Int32
+	media := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		switch {
+		case r.URL.Path == "/missing":
+			http.NotFound(w, r)
+		case r.URL.Path == "/down" || n == 1:
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+		default:
+			w.Header().Set("Content-Type", "video/mp4")
+			w.Write([]byte("bytes"))
+		}
+	}))
+	t.Cleanup(media.Close)
+
+	ctx := s.transferContext(context.Background(), false)
+	dir := t.TempDir()
+	if err := downloadFile(ctx, localFiles, media.URL+"/clip.mp4", filepath.Join(dir, "a.mp4"), nil, nil); err != nil || requests.Load() != 2 {
+		t.Errorf("503 then 200 = %v after %d requests; want success after 2", err, requests.Load())
+	}
+
+	requests.Store(0)
+	if err := downloadFile(ctx, localFiles, media.URL+"/down", filepath.Join(dir, "b.mp4"), nil, nil); err == nil || requests.Load() != 3 {
+		t.Errorf("persistent 503 = %v after %d requests; want failure after retry.max_attempts", err, requests.Load())
+	}
+
+	// A 404 won't change, so it isn't retried
+	requests.Store(0)
+	if err := downloadFile(ctx, localFiles, media.URL+"/missing", filepath.Join(dir, "c.mp4"), nil, nil); err == nil || requests.Load() != 1 {
+		t.Errorf("404 = %v after %d requests; want failure after 1", err, requests.Load())
+	}
+}